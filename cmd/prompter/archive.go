@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/google/uuid"
+
+	"github.com/esnunes/prompter/internal/db"
+	"github.com/esnunes/prompter/internal/repo"
+)
+
+// runExport implements "prompter export -all": it writes every prompt request
+// not in the trash as a JSON archive that "prompter import" loads elsewhere.
+// "prompter export ID" instead writes one prompt request as a Markdown
+// transcript.
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	all := fs.Bool("all", false, "export every repository and prompt request")
+	out := fs.String("o", "-", "file to write the archive to, or - for stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	var id int64
+	switch {
+	case *all && fs.NArg() == 0:
+	case !*all && fs.NArg() == 1:
+		n, err := strconv.ParseInt(fs.Arg(0), 10, 64)
+		if err != nil {
+			return fmt.Errorf("export: invalid prompt request ID %q", fs.Arg(0))
+		}
+		id = n
+	default:
+		return errors.New("usage: prompter export [-o FILE] -all | ID")
+	}
+
+	queries, closeDB, err := openQueries()
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	w := io.Writer(os.Stdout)
+	if *out != "-" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return fmt.Errorf("creating export: %w", err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if !*all {
+		md, err := queries.ExportMarkdown(context.Background(), id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("export: no prompt request %d", id)
+		} else if err != nil {
+			return err
+		}
+		_, err = io.WriteString(w, md)
+		return err
+	}
+
+	archive, err := queries.ExportArchive(context.Background())
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(archive); err != nil {
+		return fmt.Errorf("writing archive: %w", err)
+	}
+
+	if *out != "-" {
+		n := 0
+		for _, r := range archive.Repositories {
+			n += len(r.PromptRequests)
+		}
+		fmt.Fprintf(os.Stderr, "exported %d prompt requests to %s\n", n, *out)
+	}
+	return nil
+}
+
+// runImport implements "prompter import FILE", merging an archive into the
+// local database.
+func runImport(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: prompter import FILE (- for stdin)")
+	}
+
+	r := io.Reader(os.Stdin)
+	if name := fs.Arg(0); name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return fmt.Errorf("opening archive: %w", err)
+		}
+		defer f.Close()
+		r = f
+	}
+	var archive db.Archive
+	if err := json.NewDecoder(r).Decode(&archive); err != nil {
+		return fmt.Errorf("reading archive: %w", err)
+	}
+
+	queries, closeDB, err := openQueries()
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	result, err := queries.ImportArchive(context.Background(), &archive, repo.LocalPath, func() string { return uuid.New().String() })
+	if err != nil {
+		return err
+	}
+	fmt.Printf("imported %d new prompt requests, updated %d\n", result.Created, result.Updated)
+	return nil
+}
+
+func openQueries() (*db.Queries, func() error, error) {
+	dbPath, err := db.DBPath()
+	if err != nil {
+		return nil, nil, err
+	}
+	database, err := db.Open(dbPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	queries := db.NewQueries(database)
+	if err := useEncryption(queries); err != nil {
+		database.Close()
+		return nil, nil, err
+	}
+	return queries, database.Close, nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/esnunes/prompter/internal/db"
+)
+
+// runBackup implements "prompter backup": it snapshots the database into the
+// backup directory, rotating old copies, or to -o. With -list it shows the
+// backups available to "prompter restore".
+func runBackup(args []string) error {
+	fs := flag.NewFlagSet("backup", flag.ContinueOnError)
+	out := fs.String("o", "", "write the backup to this file instead of the backup directory")
+	list := fs.Bool("list", false, "list the backups in the backup directory")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	cfg, err := configFromEnv()
+	if err != nil {
+		return err
+	}
+	dir, err := db.BackupDir()
+	if err != nil {
+		return err
+	}
+
+	if *list {
+		backups, err := db.ListBackups(dir)
+		if err != nil {
+			return err
+		}
+		if len(backups) == 0 {
+			fmt.Printf("no backups in %s\n", dir)
+		}
+		for _, b := range backups {
+			fmt.Printf("%s  %8d KB  %s\n", b.CreatedAt.Format(time.DateTime), b.Size>>10, b.Path)
+		}
+		return nil
+	}
+
+	queries, closeDB, err := openQueries()
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	path := *out
+	if path != "" {
+		err = queries.Backup(context.Background(), path)
+	} else {
+		path, err = queries.CreateBackup(context.Background(), dir, max(cfg.BackupKeep, 1))
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("backed up to %s\n", path)
+	return nil
+}
+
+// runRestore implements "prompter restore FILE". The current database is
+// backed up first, so a restore can itself be undone.
+func runRestore(args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: prompter restore FILE (stop the server first; see prompter backup -list)")
+	}
+	src := fs.Arg(0)
+	if _, err := os.Stat(src); err != nil {
+		return fmt.Errorf("opening backup: %w", err)
+	}
+	dir, err := db.BackupDir()
+	if err != nil {
+		return err
+	}
+
+	dbPath, err := db.DBPath()
+	if err != nil {
+		return err
+	}
+	database, err := db.OpenUnmigrated(dbPath)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+	queries := db.NewQueries(database)
+
+	ctx := context.Background()
+	// Not rotated, which could delete the backup being restored.
+	safety := db.BackupPath(dir)
+	if err := queries.Backup(ctx, safety); err != nil {
+		return fmt.Errorf("backing up the current database: %w", err)
+	}
+	if err := queries.Restore(ctx, src); err != nil {
+		return err
+	}
+	if _, err := db.Migrate(database); err != nil {
+		return fmt.Errorf("migrating restored database: %w", err)
+	}
+	fmt.Printf("restored %s (the previous database was saved to %s)\n", src, safety)
+	return nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"path/filepath"
+
+	"github.com/esnunes/prompter/internal/db"
+	"github.com/esnunes/prompter/internal/paths"
+)
+
+// runDB implements "prompter db SUBCOMMAND"; check is the only one.
+func runDB(args []string) error {
+	if len(args) == 0 || args[0] != "check" {
+		return errors.New("usage: prompter db check [-repair]")
+	}
+	return runDBCheck(args[1:])
+}
+
+// runDBCheck implements "prompter db check": it checks the database's
+// integrity, reports rows orphaned by a missing parent and attachment files
+// no attachment refers to (cleaning both up with -repair), then vacuums and
+// checkpoints the WAL. Best run with the server stopped.
+func runDBCheck(args []string) error {
+	fs := flag.NewFlagSet("db check", flag.ContinueOnError)
+	repair := fs.Bool("repair", false, "delete orphaned rows and files, or clear a row's reference when it is optional")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	dbPath, err := db.DBPath()
+	if err != nil {
+		return err
+	}
+	database, err := db.OpenUnmigrated(dbPath)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	problems, err := db.IntegrityCheck(database)
+	if err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		for _, p := range problems {
+			fmt.Println("integrity:", p)
+		}
+		return fmt.Errorf("database is damaged; restore a backup (prompter backup -list)")
+	}
+	fmt.Println("integrity: ok")
+
+	var orphans []db.Orphan
+	if *repair {
+		orphans, err = db.RepairOrphans(database)
+	} else {
+		orphans, err = db.FindOrphans(database)
+	}
+	if err != nil {
+		return err
+	}
+	for _, o := range orphans {
+		action := "orphaned"
+		switch {
+		case *repair && o.Nullable:
+			action = "cleared"
+		case *repair:
+			action = "deleted"
+		}
+		fmt.Printf("%s: %s row %d (%s references a missing %s)\n", action, o.Table, o.RowID, o.Column, o.Parent)
+	}
+	if len(orphans) == 0 {
+		fmt.Println("orphaned rows: none")
+	}
+
+	cacheDir, err := paths.CacheDir()
+	if err != nil {
+		return err
+	}
+	files, err := db.OrphanedFiles(database, filepath.Join(cacheDir, "attachments"))
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if *repair {
+			fmt.Printf("deleted: %s (no attachment refers to it)\n", f)
+		} else {
+			fmt.Printf("orphaned: %s (no attachment refers to it)\n", f)
+		}
+	}
+	if *repair {
+		if err := db.RemoveFiles(files); err != nil {
+			return err
+		}
+	}
+	if len(files) == 0 {
+		fmt.Println("orphaned attachment files: none")
+	}
+
+	if err := db.Vacuum(database); err != nil {
+		return err
+	}
+	fmt.Println("vacuum: done")
+	frames, err := db.Checkpoint(database)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("wal checkpoint: %d frames\n", frames)
+
+	if n := len(orphans) + len(files); n > 0 && !*repair {
+		return fmt.Errorf("found %d orphaned rows and files; run prompter db check -repair to clean them up", n)
+	}
+	return nil
+}
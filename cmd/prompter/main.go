@@ -2,11 +2,16 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"net"
+	"net/url"
 	"os"
 	"os/exec"
 	"os/signal"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/esnunes/prompter/internal/db"
 	"github.com/esnunes/prompter/internal/github"
@@ -21,6 +26,25 @@ func main() {
 }
 
 func run() error {
+	dev := flag.Bool("dev", false, "serve templates and static assets from disk and reload templates on every request")
+	devDir := flag.String("dev-dir", "internal/server", "directory containing templates/ and static/ in dev mode")
+	flag.Parse()
+
+	switch flag.Arg(0) {
+	case "migrate":
+		return runMigrate(flag.Args()[1:])
+	case "export":
+		return runExport(flag.Args()[1:])
+	case "import":
+		return runImport(flag.Args()[1:])
+	case "backup":
+		return runBackup(flag.Args()[1:])
+	case "restore":
+		return runRestore(flag.Args()[1:])
+	case "db":
+		return runDB(flag.Args()[1:])
+	}
+
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
 	defer stop()
 
@@ -39,8 +63,20 @@ func run() error {
 	defer database.Close()
 
 	queries := db.NewQueries(database)
+	if err := useEncryption(queries); err != nil {
+		return err
+	}
 
-	srv, err := server.New(queries)
+	cfg, err := configFromEnv()
+	if err != nil {
+		return err
+	}
+	if *dev {
+		cfg.DevDir = *devDir
+		fmt.Printf("Dev mode: serving templates and static assets from %s\n", *devDir)
+	}
+
+	srv, err := server.New(queries, cfg)
 	if err != nil {
 		return fmt.Errorf("creating server: %w", err)
 	}
@@ -61,6 +97,162 @@ func run() error {
 	return srv.Serve(ctx)
 }
 
+// useEncryption turns on encryption at rest when
+// PROMPTER_ENCRYPTION_PASSPHRASE is set, and refuses to open an encrypted
+// database without it.
+func useEncryption(queries *db.Queries) error {
+	n, err := queries.UseEncryption(context.Background(), os.Getenv("PROMPTER_ENCRYPTION_PASSPHRASE"))
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		fmt.Fprintf(os.Stderr, "Encrypted %d existing records\n", n)
+	}
+	return nil
+}
+
+// configFromEnv builds the server configuration, applying PROMPTER_* overrides.
+func configFromEnv() (server.Config, error) {
+	cfg := server.DefaultConfig()
+	for _, rl := range []struct {
+		env   string
+		limit *server.RateLimit
+	}{
+		{"PROMPTER_RATE_LIMIT_SEND", &cfg.SendRateLimit},
+		{"PROMPTER_RATE_LIMIT_PUBLISH", &cfg.PublishRateLimit},
+		{"PROMPTER_RATE_LIMIT_STATUS", &cfg.StatusRateLimit},
+	} {
+		v := os.Getenv(rl.env)
+		if v == "" {
+			continue
+		}
+		limit, err := server.ParseRateLimit(v)
+		if err != nil {
+			return cfg, fmt.Errorf("%s: %w", rl.env, err)
+		}
+		*rl.limit = limit
+	}
+	if v := os.Getenv("PROMPTER_WORKERS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return cfg, fmt.Errorf("PROMPTER_WORKERS: invalid worker count %q", v)
+		}
+		cfg.Workers = n
+	}
+	if v := os.Getenv("PROMPTER_CLONE_WORKERS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return cfg, fmt.Errorf("PROMPTER_CLONE_WORKERS: invalid worker count %q", v)
+		}
+		cfg.CloneWorkers = n
+	}
+	if v := os.Getenv("PROMPTER_CLAUDE_PROCESSES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return cfg, fmt.Errorf("PROMPTER_CLAUDE_PROCESSES: invalid process count %q", v)
+		}
+		cfg.ClaudeProcesses = n
+	}
+	if v := os.Getenv("PROMPTER_PULL_FRESHNESS"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return cfg, fmt.Errorf("PROMPTER_PULL_FRESHNESS: invalid duration %q", v)
+		}
+		cfg.PullFreshness = d
+	}
+	if v := os.Getenv("PROMPTER_PREWARM"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return cfg, fmt.Errorf("PROMPTER_PREWARM: invalid boolean %q", v)
+		}
+		cfg.Prewarm = b
+	}
+	if v := os.Getenv("PROMPTER_JOB_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return cfg, fmt.Errorf("PROMPTER_JOB_TIMEOUT: invalid duration %q", v)
+		}
+		cfg.JobTimeout = d
+	}
+	if v := os.Getenv("PROMPTER_DRAFT_RETENTION_DAYS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return cfg, fmt.Errorf("PROMPTER_DRAFT_RETENTION_DAYS: invalid day count %q", v)
+		}
+		cfg.DraftRetention = time.Duration(n) * 24 * time.Hour
+	}
+	if v := os.Getenv("PROMPTER_SUMMARY_THRESHOLD"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return cfg, fmt.Errorf("PROMPTER_SUMMARY_THRESHOLD: invalid character count %q", v)
+		}
+		cfg.SummaryThreshold = n
+	}
+	if v := os.Getenv("PROMPTER_RECENT_WORK"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return cfg, fmt.Errorf("PROMPTER_RECENT_WORK: invalid count %q", v)
+		}
+		cfg.RecentWork = n
+	}
+	if v := os.Getenv("PROMPTER_OPEN_ISSUES"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return cfg, fmt.Errorf("PROMPTER_OPEN_ISSUES: invalid count %q", v)
+		}
+		cfg.OpenIssues = n
+	}
+	if v := os.Getenv("PROMPTER_BACKUP_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return cfg, fmt.Errorf("PROMPTER_BACKUP_INTERVAL: invalid duration %q", v)
+		}
+		cfg.BackupInterval = d
+	}
+	if v := os.Getenv("PROMPTER_BACKUP_KEEP"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return cfg, fmt.Errorf("PROMPTER_BACKUP_KEEP: invalid backup count %q", v)
+		}
+		cfg.BackupKeep = n
+	}
+	if v := os.Getenv("PROMPTER_ISSUE_WATCH_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return cfg, fmt.Errorf("PROMPTER_ISSUE_WATCH_INTERVAL: invalid duration %q", v)
+		}
+		cfg.IssueWatchInterval = d
+	}
+	if v := os.Getenv("PROMPTER_PUBLIC_URL"); v != "" {
+		u, err := url.Parse(v)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return cfg, fmt.Errorf("PROMPTER_PUBLIC_URL: invalid URL %q", v)
+		}
+		cfg.PublicURL = strings.TrimSuffix(v, "/")
+	}
+	if v := os.Getenv("PROMPTER_SHARE_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return cfg, fmt.Errorf("PROMPTER_SHARE_TTL: invalid duration %q", v)
+		}
+		cfg.ShareLinkTTL = d
+	}
+	if v, ok := os.LookupEnv("PROMPTER_ISSUE_TITLE_PREFIX"); ok {
+		cfg.IssueTitlePrefix = v
+	}
+	if v, ok := os.LookupEnv("PROMPTER_ISSUE_LABELS"); ok {
+		cfg.IssueLabels = server.ParseLabels(v)
+	}
+	if v := os.Getenv("PROMPTER_ISSUE_BODY_TEMPLATE"); v != "" {
+		b, err := os.ReadFile(v)
+		if err != nil {
+			return cfg, fmt.Errorf("PROMPTER_ISSUE_BODY_TEMPLATE: %w", err)
+		}
+		cfg.IssueBodyTemplate = string(b)
+	}
+	return cfg, nil
+}
+
 func checkDependencies(ctx context.Context) error {
 	for _, dep := range []struct {
 		name    string
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/esnunes/prompter/internal/db"
+)
+
+// runMigrate implements "prompter migrate": it applies pending database
+// migrations, or with -status lists every migration and when it was applied.
+func runMigrate(args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	status := fs.Bool("status", false, "list migrations and whether they have been applied, without applying any")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	dbPath, err := db.DBPath()
+	if err != nil {
+		return err
+	}
+	database, err := db.OpenUnmigrated(dbPath)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	if *status {
+		statuses, err := db.MigrationStatuses(database)
+		if err != nil {
+			return err
+		}
+		for _, m := range statuses {
+			applied := "pending"
+			if m.AppliedAt != nil {
+				applied = m.AppliedAt.Local().Format(time.DateTime)
+			}
+			fmt.Printf("%4d  %-19s  %s\n", m.Version, applied, m.Description)
+		}
+		return nil
+	}
+
+	applied, err := db.Migrate(database)
+	for _, m := range applied {
+		fmt.Printf("applied %d: %s\n", m.Version, m.Description)
+	}
+	if err != nil {
+		return err
+	}
+	if len(applied) == 0 {
+		fmt.Println("database is up to date")
+	}
+	return nil
+}
@@ -14,15 +14,17 @@ var connIDCounter atomic.Int64
 
 // Conn wraps a WebSocket connection with thread-safe writes.
 type Conn struct {
-	id int64
-	ws *websocket.Conn
-	mu sync.Mutex
+	id         int64
+	ws         *websocket.Conn
+	remoteAddr string
+	mu         sync.Mutex
 }
 
-func newConn(ws *websocket.Conn) *Conn {
+func newConn(ws *websocket.Conn, remoteAddr string) *Conn {
 	return &Conn{
-		id: connIDCounter.Add(1),
-		ws: ws,
+		id:         connIDCounter.Add(1),
+		ws:         ws,
+		remoteAddr: remoteAddr,
 	}
 }
 
@@ -31,6 +33,11 @@ func (c *Conn) ID() int64 {
 	return c.id
 }
 
+// RemoteAddr returns the network address of the client that opened the connection.
+func (c *Conn) RemoteAddr() string {
+	return c.remoteAddr
+}
+
 // Push sends server-initiated instructions (no ref).
 func (c *Conn) Push(ins []Instruction) error {
 	msg := wsResponse{Instructions: ins}
@@ -18,6 +18,12 @@ type Context struct {
 	instructions []Instruction
 	asyncCalls   []AsyncCall
 	templates    *template.Template
+	conn         *Conn
+}
+
+// Conn returns the connection the command arrived on, or nil outside a WebSocket.
+func (c *Context) Conn() *Conn {
+	return c.conn
 }
 
 // HTML produces an html instruction.
@@ -47,7 +53,7 @@ func (c *Context) Remove(target string) {
 func (c *Context) Template(source, target string) {
 	c.instructions = append(c.instructions, Instruction{
 		Op:     "template",
-		Source:  source,
+		Source: source,
 		Target: target,
 	})
 }
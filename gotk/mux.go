@@ -69,6 +69,11 @@ func (m *Mux) SetTemplates(t *template.Template) {
 // dispatch routes a command to the appropriate handler.
 // Returns instructions and an optional error string.
 func (m *Mux) dispatch(cmd string, payload map[string]any) ([]Instruction, string) {
+	return m.dispatchConn(nil, cmd, payload)
+}
+
+// dispatchConn is like dispatch but exposes the originating connection to the handler.
+func (m *Mux) dispatchConn(conn *Conn, cmd string, payload map[string]any) ([]Instruction, string) {
 	m.mu.RLock()
 	handler, ok := m.handlers[cmd]
 	navigateFn := m.navigateFn
@@ -77,6 +82,7 @@ func (m *Mux) dispatch(cmd string, payload map[string]any) ([]Instruction, strin
 
 	ctx := &Context{
 		Payload: NewPayload(payload),
+		conn:    conn,
 	}
 	ctx.setTemplates(tmpl)
 
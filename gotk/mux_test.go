@@ -81,3 +81,30 @@ func TestMux_Navigate_NoHandler(t *testing.T) {
 		t.Fatal("expected error when no navigate handler")
 	}
 }
+
+func TestMux_DispatchConn(t *testing.T) {
+	m := NewMux()
+	var got *Conn
+	m.Handle("who", func(ctx *Context) error {
+		got = ctx.Conn()
+		return nil
+	})
+
+	if _, errMsg := m.dispatch("who", nil); errMsg != "" {
+		t.Fatalf("unexpected error: %s", errMsg)
+	}
+	if got != nil {
+		t.Errorf("expected nil conn from dispatch, got %+v", got)
+	}
+
+	conn := &Conn{id: 7, remoteAddr: "127.0.0.1:5000"}
+	if _, errMsg := m.dispatchConn(conn, "who", nil); errMsg != "" {
+		t.Fatalf("unexpected error: %s", errMsg)
+	}
+	if got != conn {
+		t.Fatalf("expected handler to see conn, got %+v", got)
+	}
+	if got.RemoteAddr() != "127.0.0.1:5000" {
+		t.Errorf("RemoteAddr = %q", got.RemoteAddr())
+	}
+}
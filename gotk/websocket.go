@@ -34,7 +34,7 @@ func (m *Mux) ServeWebSocket(w http.ResponseWriter, r *http.Request) {
 	}
 	defer ws.CloseNow()
 
-	conn := newConn(ws)
+	conn := newConn(ws, r.RemoteAddr)
 
 	// Notify connect handler
 	m.mu.RLock()
@@ -72,7 +72,7 @@ func (m *Mux) ServeWebSocket(w http.ResponseWriter, r *http.Request) {
 			continue
 		}
 
-		ins, errMsg := m.dispatch(cmd.Cmd, cmd.Payload)
+		ins, errMsg := m.dispatchConn(conn, cmd.Cmd, cmd.Payload)
 
 		resp := wsResponse{
 			Ref:          cmd.Ref,
@@ -0,0 +1,104 @@
+package claude
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ChecklistItem is a quality check a generated prompt is validated against
+// before it is published.
+type ChecklistItem struct {
+	ID    string
+	Label string // shown to the user
+	Rule  string // told to Claude
+}
+
+// Checklist enforces the guidelines the system prompts only ask for.
+var Checklist = []ChecklistItem{
+	{"consistent", "Motivation and prompt agree",
+		"The motivation and the prompt describe the same request; neither contradicts the other or covers something the other leaves out"},
+	{"grounded", "Nothing the contributor didn't ask for",
+		"Every requirement, behavior and detail in the generated fields was stated or confirmed by the contributor in the conversation; assumptions are marked as such"},
+	{"self_contained", "Understandable without the conversation",
+		"A maintainer reading only the generated fields understands the request: no references to the conversation, undefined terms or missing context"},
+	{"no_implementation", "Leaves the implementation to maintainers",
+		"The prompt says what is wanted, not how to build it: no step-by-step implementation, code or lists of files to modify (a refactoring may name the code it restructures)"},
+}
+
+// CheckResult is Claude's verdict on one checklist item.
+type CheckResult struct {
+	ID     string `json:"id"`
+	Passed bool   `json:"passed"`
+	Fix    string `json:"fix"` // what to change when it didn't pass
+}
+
+func checklistPrompt() string {
+	var b strings.Builder
+	b.WriteString(`You review an issue generated from a conversation between an assistant and an open source contributor, before the contributor publishes it to the repository's maintainers.
+
+Check the generated issue against each item of this checklist:
+`)
+	for _, item := range Checklist {
+		fmt.Fprintf(&b, "- %s: %s\n", item.ID, item.Rule)
+	}
+	b.WriteString(`
+Give a verdict for every item. When an item doesn't pass, say in "fix" what the contributor should change or ask the assistant to change, quoting the offending part briefly; leave "fix" empty when it passes. Be strict but fair: only fail an item for a concrete problem.`)
+	return b.String()
+}
+
+func checklistSchema() string {
+	ids := make([]string, len(Checklist))
+	for i, item := range Checklist {
+		ids[i] = item.ID
+	}
+	enum, _ := json.Marshal(ids)
+	return `{
+  "type": "object",
+  "properties": {
+    "checks": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "properties": {
+          "id": { "type": "string", "enum": ` + string(enum) + ` },
+          "passed": { "type": "boolean" },
+          "fix": { "type": "string", "description": "What to change when the item doesn't pass" }
+        },
+        "required": ["id", "passed"]
+      }
+    }
+  },
+  "required": ["checks"]
+}`
+}
+
+// CheckPrompt has Claude validate a generated issue against the Checklist,
+// with the conversation it came from. It runs without a session so it
+// doesn't affect the conversation.
+func CheckPrompt(ctx context.Context, repoDir, transcript, generated string) ([]CheckResult, error) {
+	output, err := run(ctx, repoDir, []string{
+		"-p",
+		"--output-format", "json",
+		"--json-schema", checklistSchema(),
+		"--system-prompt", checklistPrompt(),
+		"--no-session-persistence",
+		transcript + "<generated_issue>\n" + generated + "\n</generated_issue>\n\nCheck the generated issue against the checklist.",
+	})
+	if err != nil {
+		return nil, err
+	}
+	var wrapper struct {
+		StructuredOutput *struct {
+			Checks []CheckResult `json:"checks"`
+		} `json:"structured_output"`
+	}
+	if err := json.Unmarshal(output, &wrapper); err != nil {
+		return nil, fmt.Errorf("parsing checks: %w", err)
+	}
+	if wrapper.StructuredOutput == nil || len(wrapper.StructuredOutput.Checks) == 0 {
+		return nil, fmt.Errorf("no checks returned")
+	}
+	return wrapper.StructuredOutput.Checks, nil
+}
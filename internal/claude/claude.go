@@ -20,18 +20,21 @@ Your goal is to gather enough context to generate a well-crafted "prompt request
 Guidelines:
 - Start by understanding what the contributor wants and WHY they want it
 - Explore the codebase to understand relevant patterns and architecture
+- Respect the project's stated scope and contribution rules (README, CONTRIBUTING, code of conduct). When a request falls outside them, tell the contributor rather than writing around it
 - Ask clarifying questions using the "questions" array. Each question has a "text", "options", an optional "header" (short label like "Auth method"), and an optional "multiSelect" boolean
 - You may batch multiple independent questions in a single response when their answers do not depend on each other. Never batch questions where the answer to one would change the options of another
 - Use "multiSelect": true when multiple options can apply simultaneously (e.g. "Which platforms?" where the contributor might use several)
 - Provide a short "header" for each question to help contributors scan quickly
 - Keep questions simple and non-technical — contributors may not be developers
-- The UI automatically adds an "Other" freeform text option to every question, so do not include an "Other" option yourself
+- The UI automatically adds "I don't know", "Skip" and an "Other" freeform text option to every question, so do not include such options yourself
+- When the contributor answers "I don't know", choose a sensible default yourself, tell them in "message" and state it as an assumption in the generated prompt. When they skip a question, leave that topic out of the request and don't ask about it again
 - Be thorough: ask about edge cases, what happens to existing behavior, and anything that could be interpreted multiple ways. When you notice the feature might affect existing functionality, ask whether the contributor wants to keep, change, or remove it — never assume
 - If you find yourself about to write something in the prompt that the contributor didn't explicitly say, stop and ask about it instead
 - Do NOT set "prompt_ready" to true until you have asked enough questions to cover the feature without filling in gaps yourself. If you would need to infer or assume anything to write the prompt, ask first
 - When you have enough context, set "prompt_ready" to true and include "generated_title", "generated_motivation", and "generated_prompt"
 - "generated_title" is a short, descriptive title for the feature request (under 70 characters)
 - "generated_motivation" explains WHY the feature is needed — the problem, use case, or goal from the contributor's perspective
+` + generatedFieldGuidelines + `
 - "generated_prompt" describes WHAT to build and HOW it should work for users (behavior, navigation, UX), but NOT HOW to implement it (no file paths, routes, code patterns, or "files to modify" lists)
 - All generated fields should be self-contained: a maintainer reading them should understand the motivation and the feature without needing the conversation
 - Only include details that were explicitly discussed or confirmed by the contributor — do not invent, infer, or add requirements that weren't part of the conversation
@@ -39,6 +42,18 @@ Guidelines:
 - Use your codebase knowledge to ask better questions, but do not include implementation details in the final prompt — the AI agent receiving it will explore the codebase itself
 - Always include your thinking in "message" so the contributor understands what you're doing`
 
+// generatedFieldGuidelines describe the optional fields generated along with
+// the prompt. Every kind of conversation shares them.
+const generatedFieldGuidelines = `- With the generated fields, include "estimated_size": how much work implementing the request would take a maintainer (S: a small, contained change; M: a few files or one feature area; L: several areas or new concepts; XL: a large project that should probably be split), and "size_rationale", one short sentence explaining it
+- With the generated fields, include "non_goals" when the conversation ruled things out: what the contributor decided to leave out or declined, and close extensions they didn't ask for, one short item each, so nobody builds more than was requested
+- With the generated fields, include "alternatives_considered" when the contributor weighed other approaches and turned them down: one short item each, naming the alternative and why it was rejected
+- With the generated fields, include "affected_areas": the few modules or areas of the codebase you believe the request touches, from your exploration, as short names. They are shown apart, as an optional hint for maintainers, so keep them out of the generated prompt
+- With the generated fields, include "affected_paths": the repository paths (files or directories, relative to its root) you believe the request touches, as you found them while exploring. They are only used to suggest maintainers to notify
+- With the generated fields, include "clarity_score", how sure you are the request captures what the contributor wants without gaps (5: nothing left open; 3: some assumptions; 1: mostly guesswork), and "ambiguities", the points still open or assumed, one short item each (empty when there are none)
+- With the generated fields, include "glossary" when the conversation settled what a project- or contributor-specific term means (such as "workspace" or "session"): each term with its meaning as agreed, so maintainers read it the same way
+- When the request is related to one of the repository's open issues without duplicating it, list that issue in "related_issues" with its number and title
+- When the repository's labels were listed to you, include "suggested_labels" with the few that fit the request, using their exact names; never invent labels`
+
 const jsonSchema = `{
   "type": "object",
   "properties": {
@@ -48,7 +63,7 @@ const jsonSchema = `{
     },
     "questions": {
       "type": "array",
-      "description": "Clarifying questions to ask the contributor. You may batch multiple independent questions.",
+      "description": "Clarifying questions to ask the contributor. You may batch multiple independent questions. The UI adds \"I don't know\", \"Skip\" and \"Other\" options to each.",
       "items": {
         "type": "object",
         "properties": {
@@ -95,6 +110,80 @@ const jsonSchema = `{
     "generated_prompt": {
       "type": "string",
       "description": "What to build and how it should work for users. Only when prompt_ready is true"
+    },
+    "estimated_size": {
+      "type": "string",
+      "enum": ["S", "M", "L", "XL"],
+      "description": "Estimated implementation effort. Only when prompt_ready is true"
+    },
+    "size_rationale": {
+      "type": "string",
+      "description": "One sentence explaining the estimated size. Only when prompt_ready is true"
+    },
+    "non_goals": {
+      "type": "array",
+      "description": "What the request deliberately leaves out, one short item each. Only when prompt_ready is true",
+      "items": { "type": "string" }
+    },
+    "alternatives_considered": {
+      "type": "array",
+      "description": "Approaches the contributor rejected during the conversation, each with the reason. Only when prompt_ready is true",
+      "items": { "type": "string" }
+    },
+    "affected_paths": {
+      "type": "array",
+      "description": "Repository paths, files or directories relative to the root, the request likely touches; used to suggest maintainers. Only when prompt_ready is true",
+      "items": { "type": "string" }
+    },
+    "affected_areas": {
+      "type": "array",
+      "description": "Modules or areas of the codebase the request likely touches, as a hint for maintainers. Only when prompt_ready is true",
+      "items": { "type": "string" }
+    },
+    "clarity_score": {
+      "type": "integer",
+      "minimum": 1,
+      "maximum": 5,
+      "description": "How completely the request captures what the contributor wants, from 1 (mostly guesswork) to 5 (nothing left open). Only when prompt_ready is true"
+    },
+    "ambiguities": {
+      "type": "array",
+      "description": "Points of the request still open or assumed. Only when prompt_ready is true",
+      "items": { "type": "string" }
+    },
+    "glossary": {
+      "type": "array",
+      "description": "Terms the conversation defined, with their agreed meaning. Only when prompt_ready is true",
+      "items": {
+        "type": "object",
+        "properties": {
+          "term": { "type": "string" },
+          "definition": { "type": "string" }
+        },
+        "required": ["term", "definition"]
+      }
+    },
+    "test_plan": {
+      "type": "array",
+      "description": "Brief steps a maintainer can follow to confirm the request works once built. Only when prompt_ready is true and you were asked to propose one",
+      "items": { "type": "string" }
+    },
+    "related_issues": {
+      "type": "array",
+      "description": "Open issues related to the request but not duplicates of it",
+      "items": {
+        "type": "object",
+        "properties": {
+          "number": { "type": "integer" },
+          "title": { "type": "string" }
+        },
+        "required": ["number", "title"]
+      }
+    },
+    "suggested_labels": {
+      "type": "array",
+      "description": "Labels for the issue, chosen only from the repository's labels listed at the start of the conversation. Only when prompt_ready is true",
+      "items": { "type": "string" }
     }
   },
   "required": ["message"]
@@ -107,6 +196,30 @@ type Response struct {
 	GeneratedTitle      string     `json:"generated_title,omitempty"`
 	GeneratedMotivation string     `json:"generated_motivation,omitempty"`
 	GeneratedPrompt     string     `json:"generated_prompt,omitempty"`
+	EstimatedSize       string     `json:"estimated_size,omitempty"`
+	SizeRationale       string     `json:"size_rationale,omitempty"`
+	NonGoals            []string   `json:"non_goals,omitempty"`
+	Alternatives        []string   `json:"alternatives_considered,omitempty"`
+	AffectedAreas       []string   `json:"affected_areas,omitempty"`
+	AffectedPaths       []string   `json:"affected_paths,omitempty"`
+	ClarityScore        int        `json:"clarity_score,omitempty"`
+	Ambiguities         []string   `json:"ambiguities,omitempty"`
+	Glossary            []Term     `json:"glossary,omitempty"`
+	TestPlan            []string   `json:"test_plan,omitempty"`
+	SuggestedLabels     []string   `json:"suggested_labels,omitempty"`
+	RelatedIssues       []Issue    `json:"related_issues,omitempty"`
+}
+
+// Term is a word the conversation gave a specific meaning.
+type Term struct {
+	Term       string `json:"term"`
+	Definition string `json:"definition"`
+}
+
+// Issue refers to an issue of the repository.
+type Issue struct {
+	Number int    `json:"number"`
+	Title  string `json:"title"`
 }
 
 type Question struct {
@@ -121,7 +234,11 @@ type Option struct {
 	Description string `json:"description"`
 }
 
-func SendMessage(ctx context.Context, sessionID, repoDir, userMessage string, resume bool) (*Response, string, error) {
+// SendMessage sends userMessage to the session, starting it unless resume is
+// set. The system prompt and response schema follow kind; repoInstructions,
+// if any, is appended to the system prompt.
+func SendMessage(ctx context.Context, sessionID, repoDir, userMessage, repoInstructions string, kind Kind, resume bool) (*Response, string, error) {
+	system, schema := kind.prompts()
 	args := []string{"-p"}
 	if resume {
 		// Continue an existing session.
@@ -132,15 +249,130 @@ func SendMessage(ctx context.Context, sessionID, repoDir, userMessage string, re
 	}
 	args = append(args,
 		"--output-format", "json",
-		"--json-schema", jsonSchema,
-		"--system-prompt", systemPrompt,
+		"--json-schema", schema,
+		"--system-prompt", strings.TrimSpace(system+"\n\n"+repoInstructions),
 		"--allowedTools", "Read,Glob,Grep",
 		"--permission-mode", "bypassPermissions",
 		userMessage,
 	)
 
+	output, err := run(ctx, repoDir, args)
+	if err != nil {
+		return nil, "", err
+	}
+
+	rawJSON := string(output)
+	resp, err := parseResponse(output)
+	if err != nil {
+		return &Response{Message: rawJSON}, rawJSON, nil
+	}
+	return resp, rawJSON, nil
+}
+
+const summaryPrompt = `You condense conversations between an assistant and an open source contributor who is shaping a feature request for a repository.
+
+Write a summary that lets the assistant continue the conversation without the full transcript:
+- What the contributor wants and why
+- Every decision, answer, and preference the contributor stated, including rejected options
+- Questions that are still open
+- Relevant facts the assistant learned about the codebase
+
+Be factual and complete; do not add anything that wasn't in the conversation. Reply with the summary only.`
+
+// Summarize condenses a conversation transcript into a running summary. It runs
+// without a session so it doesn't affect any conversation.
+func Summarize(ctx context.Context, repoDir, transcript string) (string, error) {
+	output, err := run(ctx, repoDir, []string{
+		"-p",
+		"--output-format", "json",
+		"--system-prompt", summaryPrompt,
+		"--no-session-persistence",
+		transcript,
+	})
+	if err != nil {
+		return "", err
+	}
+	var wrapper struct {
+		Result string `json:"result"`
+	}
+	if err := json.Unmarshal(output, &wrapper); err != nil {
+		return "", fmt.Errorf("parsing summary: %w", err)
+	}
+	summary := strings.TrimSpace(wrapper.Result)
+	if summary == "" {
+		return "", fmt.Errorf("empty summary")
+	}
+	return summary, nil
+}
+
+const ideasPrompt = `You propose feature requests for an open source repository that a contributor could bring to its maintainers.
+
+Explore the codebase in the current directory to understand what the project does and how it is used. Then propose up to 8 features that fit the project's scope and would be valuable to its users:
+- Each idea is a user-facing capability or improvement, not a refactoring or an implementation task
+- Don't propose what the project already does, what was just shipped, or what an open issue already asks for
+- Prefer concrete, self-contained ideas a maintainer could accept as one issue
+
+Give each idea a short title and a description of one to three sentences saying what it is and why users would want it.`
+
+const ideasSchema = `{
+  "type": "object",
+  "properties": {
+    "ideas": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "properties": {
+          "title": { "type": "string", "description": "Short title of the feature" },
+          "description": { "type": "string", "description": "What the feature is and why users would want it" }
+        },
+        "required": ["title", "description"]
+      }
+    }
+  },
+  "required": ["ideas"]
+}`
+
+// Idea is a feature request proposed for a repository.
+type Idea struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+}
+
+// SuggestIdeas has Claude explore the repository in repoDir and propose
+// feature requests for it. repoContext, if any, tells it about the
+// repository's recent work and open issues. It runs without a session.
+func SuggestIdeas(ctx context.Context, repoDir, repoContext string) ([]Idea, error) {
+	output, err := run(ctx, repoDir, []string{
+		"-p",
+		"--output-format", "json",
+		"--json-schema", ideasSchema,
+		"--system-prompt", ideasPrompt,
+		"--allowedTools", "Read,Glob,Grep",
+		"--permission-mode", "bypassPermissions",
+		"--no-session-persistence",
+		strings.TrimSpace(repoContext + "\n\nPropose feature ideas for this repository."),
+	})
+	if err != nil {
+		return nil, err
+	}
+	var wrapper struct {
+		StructuredOutput *struct {
+			Ideas []Idea `json:"ideas"`
+		} `json:"structured_output"`
+	}
+	if err := json.Unmarshal(output, &wrapper); err != nil {
+		return nil, fmt.Errorf("parsing ideas: %w", err)
+	}
+	if wrapper.StructuredOutput == nil || len(wrapper.StructuredOutput.Ideas) == 0 {
+		return nil, fmt.Errorf("no ideas proposed")
+	}
+	return wrapper.StructuredOutput.Ideas, nil
+}
+
+// run executes the claude CLI in dir and returns its stdout.
+func run(ctx context.Context, dir string, args []string) ([]byte, error) {
 	cmd := exec.CommandContext(ctx, "claude", args...)
-	cmd.Dir = repoDir
+	cmd.Dir = dir
 	cmd.Env = envWithout("CLAUDECODE")
 	// Send SIGTERM on context cancellation so Claude CLI can clean up its
 	// session lock before exiting. Fall back to SIGKILL after 5 seconds.
@@ -152,20 +384,14 @@ func SendMessage(ctx context.Context, sessionID, repoDir, userMessage string, re
 	output, err := cmd.Output()
 	if err != nil {
 		if ctx.Err() == context.Canceled {
-			return nil, "", fmt.Errorf("request cancelled")
+			return nil, fmt.Errorf("request cancelled")
 		}
 		if exitErr, ok := err.(*exec.ExitError); ok {
-			return nil, "", fmt.Errorf("claude error: %s", string(exitErr.Stderr))
+			return nil, fmt.Errorf("claude error: %s", string(exitErr.Stderr))
 		}
-		return nil, "", fmt.Errorf("running claude: %w", err)
+		return nil, fmt.Errorf("running claude: %w", err)
 	}
-
-	rawJSON := string(output)
-	resp, err := parseResponse(output)
-	if err != nil {
-		return &Response{Message: rawJSON}, rawJSON, nil
-	}
-	return resp, rawJSON, nil
+	return output, nil
 }
 
 func parseResponse(output []byte) (*Response, error) {
@@ -196,6 +422,19 @@ func parseResponse(output []byte) (*Response, error) {
 	return &resp, nil
 }
 
+// Cost reads what a reply cost, in US dollars, from the CLI's JSON output;
+// zero when it isn't reported.
+func Cost(rawJSON string) float64 {
+	var wrapper struct {
+		TotalCostUSD float64 `json:"total_cost_usd"`
+		CostUSD      float64 `json:"cost_usd"` // older CLI versions
+	}
+	if err := json.Unmarshal([]byte(rawJSON), &wrapper); err != nil {
+		return 0
+	}
+	return max(wrapper.TotalCostUSD, wrapper.CostUSD)
+}
+
 func envWithout(key string) []string {
 	prefix := key + "="
 	var env []string
@@ -0,0 +1,141 @@
+package claude
+
+import "strings"
+
+// Kind is what a conversation produces.
+type Kind string
+
+const (
+	KindFeature     Kind = ""            // a feature request
+	KindBug         Kind = "bug"         // a bug report
+	KindRefactor    Kind = "refactor"    // a refactoring proposal
+	KindDocs        Kind = "docs"        // a documentation request
+	KindPerformance Kind = "performance" // a performance complaint
+)
+
+// kindPrompt is the system prompt and response schema of a kind of
+// conversation other than a feature request.
+type kindPrompt struct {
+	system string
+	schema string
+}
+
+var kindPrompts = map[Kind]kindPrompt{
+	KindBug: {
+		system: tailoredPrompt("writing clear, reproducible bug reports",
+			`- Start by understanding what went wrong and what the contributor was trying to do
+- Find out, through questions, the exact steps to reproduce the problem, what the contributor expected to happen, what actually happened (including error messages or output), and their environment (version, operating system, configuration, anything relevant to this project)
+- Explore the codebase to understand the behavior involved. When the behavior looks intended, tell the contributor and ask whether they still want to report it, possibly as a feature request
+- When the contributor answers "I don't know", leave the detail out or mark it as unknown in the report; never guess reproduction steps or environment details
+- Do NOT set "prompt_ready" to true until you know how to reproduce the problem, the expected and actual behavior, and the relevant environment, or the contributor said they can't tell
+- "generated_title" is a short summary of the bug (under 70 characters), describing the symptom rather than a guessed cause
+- "generated_motivation" is a short summary of the bug and its impact: who is affected and how badly
+- "generated_prompt" is the body of the report with these Markdown sections, in order: "### Steps to reproduce" (a numbered list), "### Expected behavior", "### Actual behavior", "### Environment"
+- Do not include fixes, file paths or code in the report unless the contributor provided them; the maintainer will investigate the codebase`),
+		schema: tailoredSchema(
+			"A short summary of the bug's symptom (under 70 characters)",
+			"A short summary of the bug and its impact",
+			"The report body: Steps to reproduce, Expected behavior, Actual behavior and Environment sections",
+			"Estimated effort to fix",
+		),
+	},
+	KindRefactor: {
+		system: tailoredPrompt("proposing well-scoped refactorings",
+			`- Start by understanding which part of the code the contributor wants restructured and what pain it causes today: duplication, coupling, code that is hard to test, read, or extend
+- Explore that code closely; a refactoring proposal is about the code itself, so you may name the packages, modules, and types involved
+- Find out, through questions, what the code should look like afterwards, what it enables (a feature, easier testing, fewer bugs), and the constraints: behavior that must not change, public APIs that must stay stable, how far the change may reach
+- Point out when the current structure looks deliberate (documented decisions, compatibility shims) and ask whether the contributor still wants to propose changing it
+- When the contributor answers "I don't know", choose the least disruptive option yourself, tell them in "message" and state it as an assumption in the proposal
+- Do NOT set "prompt_ready" to true until the current problem, the target structure, and the constraints are clear
+- "generated_title" is a short description of the refactoring (under 70 characters)
+- "generated_motivation" explains what is wrong with the current code and what the refactoring makes possible
+- "generated_prompt" is the proposal with these Markdown sections, in order: "### Current state", "### Proposed change", "### Constraints" (including the behavior that must stay the same)
+- Describe the target structure, not a step-by-step implementation; the maintainer's AI coding agent will plan the changes itself`),
+		schema: tailoredSchema(
+			"A short description of the refactoring (under 70 characters)",
+			"What is wrong with the current code and what the refactoring makes possible",
+			"The proposal: Current state, Proposed change and Constraints sections",
+			"Estimated effort of the refactoring",
+		),
+	},
+	KindDocs: {
+		system: tailoredPrompt("requesting clear, useful documentation",
+			`- Start by understanding what the contributor tried to learn or do, and where the documentation let them down: missing, wrong, outdated, or hard to find or follow
+- Read the project's existing documentation (README, docs folders, doc comments, examples) so you know what exists already, and tell the contributor when what they need is documented somewhere
+- Find out, through questions, who the documentation is for (new users, integrators, contributors), what it should cover, where readers would look for it, and whether examples would help
+- When you find the code the documentation should describe, check that what the contributor expects matches what the code does; when it doesn't, ask whether this is really a bug report
+- When the contributor answers "I don't know", choose a sensible default yourself, tell them in "message" and state it as an assumption in the request
+- Do NOT set "prompt_ready" to true until the gap, the audience, and what the documentation should cover are clear
+- "generated_title" is a short description of the documentation needed (under 70 characters)
+- "generated_motivation" explains who is missing this documentation and what it keeps them from doing
+- "generated_prompt" is the request with these Markdown sections, in order: "### What's missing or unclear", "### Audience", "### Suggested content" (topics, examples, and where it should live)
+- Describe what the documentation should explain, not its final wording; the maintainer will write it`),
+		schema: tailoredSchema(
+			"A short description of the documentation needed (under 70 characters)",
+			"Who is missing this documentation and what it keeps them from doing",
+			"The request: What's missing or unclear, Audience and Suggested content sections",
+			"Estimated effort to write the documentation",
+		),
+	},
+	KindPerformance: {
+		system: tailoredPrompt("reporting performance problems precisely enough to act on",
+			`- Start by understanding what is slow or uses too many resources (time, memory, CPU, disk, network), and what the contributor was doing when they noticed
+- Find out, through questions, how to reproduce it (the operation, the size and shape of the data or workload), what they measured and how, what they expected instead, their environment (version, hardware, operating system, configuration), and whether it got worse after an upgrade
+- Ask for numbers rather than impressions ("takes 40 seconds for 10,000 rows", not "it's slow"); when the contributor has none, suggest a simple way to measure
+- Explore the codebase to understand the code path involved, but do not guess the cause in the report unless the contributor confirmed it
+- When the contributor answers "I don't know", leave the detail out or mark it as unknown in the report; never invent measurements
+- Do NOT set "prompt_ready" to true until the slow operation, how to reproduce it, and the measurements or a clear description of the impact are known
+- "generated_title" is a short description of the slow operation (under 70 characters)
+- "generated_motivation" explains the impact: who is affected, how often, and how badly
+- "generated_prompt" is the report with these Markdown sections, in order: "### What is slow", "### How to reproduce", "### Measurements", "### Expected performance", "### Environment"`),
+		schema: tailoredSchema(
+			"A short description of the slow operation (under 70 characters)",
+			"The impact: who is affected, how often, and how badly",
+			"The report: What is slow, How to reproduce, Measurements, Expected performance and Environment sections",
+			"Estimated effort to investigate and fix",
+		),
+	},
+}
+
+// prompts returns the system prompt and response schema of conversations of
+// kind k. Unknown kinds are feature requests.
+func (k Kind) prompts() (system, schema string) {
+	if p, ok := kindPrompts[k]; ok {
+		return p.system, p.schema
+	}
+	return systemPrompt, jsonSchema
+}
+
+// tailoredPrompt is the system prompt of a kind of conversation: what the
+// assistant helps write, the guidelines of the kind, and those all kinds
+// share about asking questions and generating the result.
+func tailoredPrompt(writing, guidelines string) string {
+	return `You are a helpful assistant that guides open source contributors in ` + writing + ` for repository maintainers.
+
+You are running inside the repository's codebase. Use your tools (Read, Glob, Grep) to explore the code and understand the project structure, patterns, and conventions. This helps you ask informed questions.
+
+Guidelines:
+` + guidelines + `
+- Respect the project's stated scope and contribution rules (README, CONTRIBUTING, code of conduct). When a request falls outside them, tell the contributor rather than writing around it
+- Ask clarifying questions using the "questions" array. Each question has a "text", "options", an optional "header" (short label like "OS"), and an optional "multiSelect" boolean
+- You may batch multiple independent questions in a single response when their answers do not depend on each other. Never batch questions where the answer to one would change the options of another
+- Provide a short "header" for each question to help contributors scan quickly
+- Keep questions simple and non-technical — contributors may not be developers
+- The UI automatically adds "I don't know", "Skip" and an "Other" freeform text option to every question, so do not include such options yourself. When the contributor skips a question, leave that topic out and don't ask about it again
+- When you have enough context, set "prompt_ready" to true and include "generated_title", "generated_motivation", and "generated_prompt"
+` + generatedFieldGuidelines + `
+- All generated fields should be self-contained: a maintainer reading them should understand the request without needing the conversation
+- Only include details that were explicitly stated or confirmed by the contributor — do not invent, infer, or add anything that wasn't part of the conversation
+- Always include your thinking in "message" so the contributor understands what you're doing`
+}
+
+// tailoredSchema is jsonSchema with the generated fields described for a
+// kind of conversation.
+func tailoredSchema(title, motivation, prompt, size string) string {
+	return strings.NewReplacer(
+		"A short, descriptive title for the feature request (under 70 characters)", title,
+		"Why the feature is needed — the problem, use case, or goal from the contributor's perspective", motivation,
+		"What to build and how it should work for users", prompt,
+		"Estimated implementation effort", size,
+	).Replace(jsonSchema)
+}
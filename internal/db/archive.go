@@ -0,0 +1,512 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/esnunes/prompter/internal/models"
+)
+
+// ArchiveVersion is the format version written by ExportArchive.
+const ArchiveVersion = 1
+
+// Archive is a portable copy of prompt requests for moving them between
+// machines. Attachments, file references, jobs and Claude sessions stay
+// behind: imported conversations continue in a fresh session seeded with
+// their transcript, like forks.
+type Archive struct {
+	Version      int                 `json:"version"`
+	ExportedAt   string              `json:"exported_at"`
+	Repositories []ArchiveRepository `json:"repositories"`
+}
+
+type ArchiveRepository struct {
+	URL               string                 `json:"url"`
+	IssueTitlePrefix  *string                `json:"issue_title_prefix,omitempty"`
+	IssueBodyTemplate string                 `json:"issue_body_template,omitempty"`
+	IncludeAreas      bool                   `json:"include_affected_areas,omitempty"`
+	OutputFormat      string                 `json:"output_format,omitempty"`
+	ProposeTestPlan   bool                   `json:"propose_test_plan,omitempty"`
+	Project           string                 `json:"project,omitempty"`
+	IssueLabels       *string                `json:"issue_labels,omitempty"`
+	PromptRequests    []ArchivePromptRequest `json:"prompt_requests"`
+}
+
+// ArchivePromptRequest identifies a prompt request by Origin, the session it
+// was first created with, which survives any number of round trips.
+type ArchivePromptRequest struct {
+	Origin            string            `json:"origin"`
+	Title             string            `json:"title"`
+	TitleEdited       bool              `json:"title_edited,omitempty"`
+	Status            string            `json:"status"`
+	IssueNumber       *int              `json:"issue_number,omitempty"`
+	IssueURL          *string           `json:"issue_url,omitempty"`
+	GistURL           *string           `json:"gist_url,omitempty"`
+	SourceIssueNumber *int              `json:"source_issue_number,omitempty"`
+	PublishTarget     string            `json:"publish_target,omitempty"`
+	Archived          bool              `json:"archived,omitempty"`
+	Pinned            bool              `json:"pinned,omitempty"`
+	Notes             string            `json:"notes,omitempty"`
+	IncludeTranscript bool              `json:"include_transcript,omitempty"`
+	LinkRelated       bool              `json:"link_related_issues,omitempty"`
+	IncludeAreas      *bool             `json:"include_affected_areas,omitempty"` // nil follows the repository
+	IssueTemplate     string            `json:"issue_template,omitempty"`
+	ConversationLang  string            `json:"conversation_language,omitempty"`
+	OutputLang        string            `json:"output_language,omitempty"`
+	QuestionMode      string            `json:"question_mode,omitempty"`
+	Kind              string            `json:"kind,omitempty"`
+	Persona           string            `json:"persona,omitempty"` // name; dropped on import unless a persona has it
+	ExportedAt        *string           `json:"exported_at,omitempty"`
+	CreatedAt         string            `json:"created_at"`
+	UpdatedAt         string            `json:"updated_at"`
+	Tags              []string          `json:"tags,omitempty"`
+	Messages          []ArchiveMessage  `json:"messages"`
+	Revisions         []ArchiveRevision `json:"revisions,omitempty"`
+}
+
+// ArchiveMessage leaves out messages superseded by an edit. Questions are
+// recovered from RawResponse; Answers records what was chosen for them.
+type ArchiveMessage struct {
+	Role        string          `json:"role"`
+	Content     string          `json:"content"`
+	RawResponse *string         `json:"raw_response,omitempty"`
+	CreatedAt   string          `json:"created_at"`
+	DurationMS  int64           `json:"duration_ms,omitempty"`
+	CostUSD     float64         `json:"cost_usd,omitempty"`
+	Answers     []ArchiveAnswer `json:"answers,omitempty"`
+}
+
+// ArchiveAnswer is the answer to the question at Position, given by the
+// message at index Message.
+type ArchiveAnswer struct {
+	Position int    `json:"position"`
+	Answer   string `json:"answer"`
+	Message  int    `json:"message"`
+}
+
+// ArchiveRevision refers to messages by their index in Messages.
+type ArchiveRevision struct {
+	Content       string `json:"content"`
+	PublishedAt   string `json:"published_at"`
+	AfterMessage  *int   `json:"after_message,omitempty"`
+	SourceMessage *int   `json:"source_message,omitempty"`
+}
+
+// ExportArchive copies every prompt request not in the trash.
+func (q *Queries) ExportArchive(ctx context.Context) (*Archive, error) {
+	a := &Archive{Version: ArchiveVersion, ExportedAt: formatTime(time.Now())}
+
+	rows, err := q.db.QueryContext(ctx, `SELECT id, url, issue_title_prefix, issue_body_template, include_affected_areas, output_format, propose_test_plan, project, issue_labels
+		 FROM repositories ORDER BY url`)
+	if err != nil {
+		return nil, fmt.Errorf("listing repositories: %w", err)
+	}
+	var repoIDs []int64
+	for rows.Next() {
+		var id int64
+		var r ArchiveRepository
+		if err := rows.Scan(&id, &r.URL, &r.IssueTitlePrefix, &r.IssueBodyTemplate, &r.IncludeAreas, &r.OutputFormat, &r.ProposeTestPlan, &r.Project, &r.IssueLabels); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scanning repository: %w", err)
+		}
+		repoIDs = append(repoIDs, id)
+		a.Repositories = append(a.Repositories, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i, repoID := range repoIDs {
+		prs, err := q.exportPromptRequests(ctx, repoID)
+		if err != nil {
+			return nil, err
+		}
+		a.Repositories[i].PromptRequests = prs
+	}
+	return a, nil
+}
+
+func (q *Queries) exportPromptRequests(ctx context.Context, repoID int64) ([]ArchivePromptRequest, error) {
+	rows, err := q.db.QueryContext(ctx,
+		`SELECT id, COALESCE(origin_session_id, session_id), title, title_edited, status, issue_number, issue_url, gist_url,
+		        source_issue_number, publish_target, archived, pinned, notes, include_transcript, issue_template,
+		        link_related_issues, include_affected_areas, conversation_language, output_language, question_mode, kind,
+		        COALESCE((SELECT name FROM personas WHERE id = persona_id), ''), exported_at, created_at, updated_at
+		 FROM prompt_requests WHERE repository_id = ? AND status != 'deleted'
+		 ORDER BY id`, repoID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("listing prompt requests: %w", err)
+	}
+	var ids []int64
+	var results []ArchivePromptRequest
+	for rows.Next() {
+		var id int64
+		var pr ArchivePromptRequest
+		err := rows.Scan(&id, &pr.Origin, &pr.Title, &pr.TitleEdited, &pr.Status, &pr.IssueNumber, &pr.IssueURL, &pr.GistURL,
+			&pr.SourceIssueNumber, &pr.PublishTarget, &pr.Archived, &pr.Pinned, &pr.Notes, &pr.IncludeTranscript, &pr.IssueTemplate,
+			&pr.LinkRelated, &pr.IncludeAreas, &pr.ConversationLang, &pr.OutputLang, &pr.QuestionMode, &pr.Kind, &pr.Persona,
+			&pr.ExportedAt, &pr.CreatedAt, &pr.UpdatedAt)
+		if err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scanning prompt request: %w", err)
+		}
+		if err = q.sealer.openAll(&pr.Title, &pr.Notes); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+		results = append(results, pr)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i, id := range ids {
+		pr := &results[i]
+		if pr.Tags, err = q.ListTagsForPromptRequest(ctx, id); err != nil {
+			return nil, err
+		}
+		msgs, err := q.ListMessagesWithRawResponses(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		index := make(map[int64]int, len(msgs))
+		for j, m := range msgs {
+			index[m.ID] = j
+			pr.Messages = append(pr.Messages, ArchiveMessage{
+				Role: m.Role, Content: m.Content, RawResponse: m.RawResponse, CreatedAt: formatTime(m.CreatedAt),
+				DurationMS: m.Duration.Milliseconds(), CostUSD: m.CostUSD,
+			})
+		}
+		if err := q.exportAnswers(ctx, pr, msgs, index); err != nil {
+			return nil, err
+		}
+		revs, err := q.ListRevisions(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		ref := func(msgID *int64) *int {
+			if msgID == nil {
+				return nil
+			}
+			if j, ok := index[*msgID]; ok {
+				return &j
+			}
+			return nil
+		}
+		for _, r := range revs {
+			pr.Revisions = append(pr.Revisions, ArchiveRevision{
+				Content: r.Content, PublishedAt: formatTime(r.PublishedAt),
+				AfterMessage: ref(r.AfterMessageID), SourceMessage: ref(r.SourceMessageID),
+			})
+		}
+	}
+	return results, nil
+}
+
+func (q *Queries) exportAnswers(ctx context.Context, pr *ArchivePromptRequest, msgs []models.Message, index map[int64]int) error {
+	for j, m := range msgs {
+		if m.Role != "assistant" {
+			continue
+		}
+		rows, err := q.db.QueryContext(ctx,
+			`SELECT position, answer, answer_message_id FROM questions WHERE message_id = ? AND answer IS NOT NULL ORDER BY position`, m.ID,
+		)
+		if err != nil {
+			return fmt.Errorf("listing answers: %w", err)
+		}
+		for rows.Next() {
+			var position int
+			var answer string
+			var answerMessageID int64
+			if err := rows.Scan(&position, &answer, &answerMessageID); err != nil {
+				rows.Close()
+				return fmt.Errorf("scanning answer: %w", err)
+			}
+			if answer, err = q.sealer.open(answer); err != nil {
+				rows.Close()
+				return err
+			}
+			if k, ok := index[answerMessageID]; ok {
+				pr.Messages[j].Answers = append(pr.Messages[j].Answers, ArchiveAnswer{Position: position, Answer: answer, Message: k})
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ImportResult counts what ImportArchive changed.
+type ImportResult struct {
+	Created int // prompt requests added
+	Updated int // existing prompt requests that gained messages, revisions or newer details
+}
+
+// ErrArchiveVersion is returned for archives written by a newer version.
+var ErrArchiveVersion = errors.New("unsupported archive version")
+
+// ImportArchive loads an archive in one transaction. A prompt request matches
+// an existing one with the same origin session or, failing that, the same
+// issue in the same repository. Matches gain the messages and revisions they
+// lack, and take the archive's title, status and flags when the archive's
+// copy was updated more recently; others are created. Prompt requests whose
+// conversation changed move to a new session from newSessionID, which replays
+// the transcript on the next message. localPath gives the clone directory of
+// repositories that don't exist yet.
+func (q *Queries) ImportArchive(ctx context.Context, a *Archive, localPath func(url string) (string, error), newSessionID func() string) (ImportResult, error) {
+	var result ImportResult
+	if a.Version != ArchiveVersion {
+		return result, fmt.Errorf("%w: %d", ErrArchiveVersion, a.Version)
+	}
+
+	tx, err := q.begin(ctx)
+	if err != nil {
+		return result, fmt.Errorf("beginning import: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, repo := range a.Repositories {
+		path, err := localPath(repo.URL)
+		if err != nil {
+			return result, err
+		}
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO repositories (url, local_path, issue_title_prefix, issue_body_template, include_affected_areas, output_format,
+			     propose_test_plan, project, issue_labels)
+			 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+			 ON CONFLICT(url) DO NOTHING`,
+			repo.URL, path, repo.IssueTitlePrefix, repo.IssueBodyTemplate, repo.IncludeAreas, repo.OutputFormat, repo.ProposeTestPlan, repo.Project, repo.IssueLabels,
+		)
+		if err != nil {
+			return result, fmt.Errorf("importing repository %s: %w", repo.URL, err)
+		}
+		var repoID int64
+		if err := tx.QueryRowContext(ctx, `SELECT id FROM repositories WHERE url = ?`, repo.URL).Scan(&repoID); err != nil {
+			return result, fmt.Errorf("importing repository %s: %w", repo.URL, err)
+		}
+
+		for i := range repo.PromptRequests {
+			created, updated, err := q.importPromptRequest(ctx, tx, repoID, &repo.PromptRequests[i], newSessionID)
+			if err != nil {
+				return result, fmt.Errorf("importing %q from %s: %w", repo.PromptRequests[i].Title, repo.URL, err)
+			}
+			if created {
+				result.Created++
+			} else if updated {
+				result.Updated++
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return result, fmt.Errorf("committing import: %w", err)
+	}
+	return result, nil
+}
+
+func (q *Queries) importPromptRequest(ctx context.Context, tx dbtx, repoID int64, pr *ArchivePromptRequest, newSessionID func() string) (created, updated bool, err error) {
+	var id int64
+	var localUpdatedAt string
+	err = tx.QueryRowContext(ctx,
+		`SELECT id, updated_at FROM prompt_requests
+		 WHERE status != 'deleted' AND (COALESCE(origin_session_id, session_id) = ?1
+		    OR (?2 IS NOT NULL AND repository_id = ?3 AND issue_number = ?2))
+		 ORDER BY COALESCE(origin_session_id, session_id) = ?1 DESC LIMIT 1`,
+		pr.Origin, pr.IssueNumber, repoID,
+	).Scan(&id, &localUpdatedAt)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		res, err := tx.ExecContext(ctx,
+			`INSERT INTO prompt_requests (repository_id, session_id, origin_session_id, created_at) VALUES (?, ?, ?, ?)`,
+			repoID, newSessionID(), pr.Origin, pr.CreatedAt,
+		)
+		if err != nil {
+			return false, false, err
+		}
+		id, _ = res.LastInsertId()
+		created = true
+	case err != nil:
+		return false, false, err
+	}
+
+	if created || pr.UpdatedAt > localUpdatedAt {
+		_, err := tx.ExecContext(ctx,
+			`UPDATE prompt_requests
+			 SET title = ?, title_edited = ?, status = ?, issue_number = ?, issue_url = ?, gist_url = ?, source_issue_number = ?,
+			     publish_target = ?, archived = ?, pinned = ?, notes = ?, include_transcript = ?, issue_template = ?,
+			     link_related_issues = ?, include_affected_areas = ?, conversation_language = ?, output_language = ?, question_mode = ?, kind = ?,
+			     persona_id = (SELECT id FROM personas WHERE name = ?), exported_at = ?, updated_at = ?
+			 WHERE id = ?`,
+			q.sealer.seal(pr.Title), pr.TitleEdited, pr.Status, pr.IssueNumber, pr.IssueURL, pr.GistURL, pr.SourceIssueNumber,
+			pr.PublishTarget, pr.Archived, pr.Pinned, q.sealer.seal(pr.Notes), pr.IncludeTranscript, pr.IssueTemplate,
+			pr.LinkRelated, pr.IncludeAreas, pr.ConversationLang, pr.OutputLang, pr.QuestionMode, pr.Kind, pr.Persona, pr.ExportedAt, pr.UpdatedAt, id,
+		)
+		if err != nil {
+			return false, false, fmt.Errorf("updating details: %w", err)
+		}
+		updated = true
+	}
+
+	for _, tag := range pr.Tags {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO tags (name) VALUES (?) ON CONFLICT(name) DO NOTHING`, tag); err != nil {
+			return false, false, fmt.Errorf("creating tag: %w", err)
+		}
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO prompt_request_tags (prompt_request_id, tag_id) SELECT ?, id FROM tags WHERE name = ?
+			 ON CONFLICT DO NOTHING`, id, tag,
+		)
+		if err != nil {
+			return false, false, fmt.Errorf("tagging: %w", err)
+		}
+	}
+
+	// Messages are matched in order on role, content and timestamp.
+	msgIDs := make([]*int64, len(pr.Messages))
+	var lastMatched int64
+	added := false
+	for i, m := range pr.Messages {
+		existing, err := q.matchMessage(ctx, tx, id, &m, lastMatched)
+		if err != nil {
+			return false, false, fmt.Errorf("matching message: %w", err)
+		}
+		if existing != 0 {
+			msgIDs[i] = &existing
+			lastMatched = existing
+			continue
+		}
+		res, err := tx.ExecContext(ctx,
+			`INSERT INTO messages (prompt_request_id, role, content, raw_response, created_at, duration_ms, cost_usd) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			id, m.Role, q.sealer.seal(m.Content), q.sealer.sealPtr(m.RawResponse), m.CreatedAt, m.DurationMS, m.CostUSD,
+		)
+		if err != nil {
+			return false, false, fmt.Errorf("adding message: %w", err)
+		}
+		msgID, _ := res.LastInsertId()
+		msgIDs[i] = &msgID
+		lastMatched = msgID
+		if m.Role == "assistant" && m.RawResponse != nil {
+			if resp := parseResponse(*m.RawResponse); resp != nil {
+				if err := saveResponse(ctx, tx, q.sealer, msgID, resp); err != nil {
+					return false, false, err
+				}
+			}
+		}
+		added = true
+	}
+
+	ref := func(i *int) *int64 {
+		if i == nil || *i < 0 || *i >= len(msgIDs) {
+			return nil
+		}
+		return msgIDs[*i]
+	}
+	for i, m := range pr.Messages {
+		for _, a := range m.Answers {
+			answerMessageID := ref(&a.Message)
+			if answerMessageID == nil {
+				continue
+			}
+			_, err := tx.ExecContext(ctx,
+				`UPDATE questions SET answer_message_id = ?, answer = ? WHERE message_id = ? AND position = ? AND answer IS NULL`,
+				*answerMessageID, q.sealer.seal(a.Answer), *msgIDs[i], a.Position,
+			)
+			if err != nil {
+				return false, false, fmt.Errorf("adding answer: %w", err)
+			}
+		}
+	}
+	for _, r := range pr.Revisions {
+		found, err := q.hasRevision(ctx, tx, id, &r)
+		if err != nil {
+			return false, false, fmt.Errorf("matching revision: %w", err)
+		}
+		if found {
+			continue
+		}
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO revisions (prompt_request_id, content, published_at, after_message_id, source_message_id) VALUES (?, ?, ?, ?, ?)`,
+			id, q.sealer.seal(r.Content), r.PublishedAt, ref(r.AfterMessage), ref(r.SourceMessage),
+		)
+		if err != nil {
+			return false, false, fmt.Errorf("adding revision: %w", err)
+		}
+		updated = true
+	}
+
+	if added {
+		_, err := tx.ExecContext(ctx,
+			`UPDATE prompt_requests
+			 SET session_id = ?1, prewarmed = 0, summary = '', summary_message_id = NULL,
+			     fork_message_id = (SELECT MAX(id) FROM messages WHERE prompt_request_id = ?2 AND superseded = 0)
+			 WHERE id = ?2`,
+			newSessionID(), id,
+		)
+		if err != nil {
+			return false, false, fmt.Errorf("starting new session: %w", err)
+		}
+		updated = true
+	}
+	return created, updated, nil
+}
+
+// matchMessage finds the first current message after the one with ID after
+// that has m's role, content and timestamp, or returns 0. Contents are
+// compared once decrypted, as encrypting the same text twice differs.
+func (q *Queries) matchMessage(ctx context.Context, tx dbtx, promptRequestID int64, m *ArchiveMessage, after int64) (int64, error) {
+	rows, err := tx.QueryContext(ctx,
+		`SELECT id, content FROM messages
+		 WHERE prompt_request_id = ? AND role = ? AND created_at = ? AND superseded = 0 AND id > ?
+		 ORDER BY id`, promptRequestID, m.Role, m.CreatedAt, after,
+	)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		var content string
+		if err := rows.Scan(&id, &content); err != nil {
+			return 0, err
+		}
+		if content, err = q.sealer.open(content); err != nil {
+			return 0, err
+		}
+		if content == m.Content {
+			return id, nil
+		}
+	}
+	return 0, rows.Err()
+}
+
+// hasRevision reports whether a prompt request has a revision with r's
+// content and publication time, comparing contents once decrypted.
+func (q *Queries) hasRevision(ctx context.Context, tx dbtx, promptRequestID int64, r *ArchiveRevision) (bool, error) {
+	rows, err := tx.QueryContext(ctx,
+		`SELECT content FROM revisions WHERE prompt_request_id = ? AND published_at = ?`, promptRequestID, r.PublishedAt,
+	)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var content string
+		if err := rows.Scan(&content); err != nil {
+			return false, err
+		}
+		if content, err = q.sealer.open(content); err != nil {
+			return false, err
+		}
+		if content == r.Content {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
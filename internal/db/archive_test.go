@@ -0,0 +1,119 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+// countRows returns the number of rows in each of tables.
+func countRows(t *testing.T, q *Queries, tables ...string) map[string]int {
+	t.Helper()
+	counts := map[string]int{}
+	for _, table := range tables {
+		var n int
+		if err := q.db.QueryRowContext(context.Background(), `SELECT COUNT(*) FROM `+table).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		counts[table] = n
+	}
+	return counts
+}
+
+func TestImportArchive_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	open := func(name string) *Queries {
+		database, err := Open(filepath.Join(t.TempDir(), name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { database.Close() })
+		return NewQueries(database)
+	}
+
+	src := open("src.db")
+	repo, err := src.UpsertRepository(ctx, "github.com/a/b", "/tmp/a/b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pr, err := src.CreatePromptRequest(ctx, repo.ID, "sess-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply := testReply
+	if _, err := src.CreateMessage(ctx, pr.ID, "user", "Add dark mode", nil); err != nil {
+		t.Fatal(err)
+	}
+	asked, err := src.CreateMessage(ctx, pr.ID, "assistant", "Which pages?", &reply)
+	if err != nil {
+		t.Fatal(err)
+	}
+	answer, err := src.CreateMessage(ctx, pr.ID, "user", "All", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := src.SaveAnswers(ctx, pr.ID, answer.ID, map[int]string{0: "All"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := src.CreateRevision(ctx, pr.ID, "Add a dark theme", &asked.ID, &asked.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	archive, err := src.ExportArchive(ctx)
+	if err != nil {
+		t.Fatalf("ExportArchive: %v", err)
+	}
+
+	dst := open("dst.db")
+	localPath := func(url string) (string, error) { return "/tmp/" + url, nil }
+	sessions := 0
+	newSessionID := func() string {
+		sessions++
+		return fmt.Sprintf("new-%d", sessions)
+	}
+	tables := []string{"prompt_requests", "messages", "questions", "question_options", "revisions", "related_issues"}
+
+	result, err := dst.ImportArchive(ctx, archive, localPath, newSessionID)
+	if err != nil {
+		t.Fatalf("first ImportArchive: %v", err)
+	}
+	if result.Created != 1 || result.Updated != 0 {
+		t.Errorf("first import = %+v, want 1 created", result)
+	}
+	want := countRows(t, src, tables...)
+	if got := countRows(t, dst, tables...); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("after first import: rows = %v, want %v", got, want)
+	}
+
+	result, err = dst.ImportArchive(ctx, archive, localPath, newSessionID)
+	if err != nil {
+		t.Fatalf("second ImportArchive: %v", err)
+	}
+	if result != (ImportResult{}) {
+		t.Errorf("second import = %+v, want nothing changed", result)
+	}
+	if got := countRows(t, dst, tables...); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("after second import: rows = %v, want %v", got, want)
+	}
+
+	// Importing into the database the archive came from changes nothing either.
+	result, err = src.ImportArchive(ctx, archive, localPath, newSessionID)
+	if err != nil {
+		t.Fatalf("ImportArchive into the source: %v", err)
+	}
+	if result != (ImportResult{}) {
+		t.Errorf("import into the source = %+v, want nothing changed", result)
+	}
+	if got := countRows(t, src, tables...); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("source after import: rows = %v, want %v", got, want)
+	}
+
+	questions, err := dst.ListQuestions(ctx, asked.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(questions) != 1 || questions[0].Answer != "All" {
+		t.Errorf("imported questions = %+v", questions)
+	}
+}
@@ -0,0 +1,114 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/esnunes/prompter/internal/models"
+)
+
+const attachmentColumns = `SELECT id, prompt_request_id, message_id, filename, content_type, path, size, remote_url, created_at
+		 FROM attachments`
+
+func scanAttachment(row rowScanner) (models.Attachment, error) {
+	var a models.Attachment
+	var createdAt string
+	err := row.Scan(&a.ID, &a.PromptRequestID, &a.MessageID, &a.Filename, &a.ContentType,
+		&a.Path, &a.Size, &a.RemoteURL, &createdAt)
+	if err != nil {
+		return a, err
+	}
+	a.CreatedAt = parseTime(createdAt)
+	return a, nil
+}
+
+// CreateAttachment records an uploaded file as pending for the next user message.
+func (q *Queries) CreateAttachment(ctx context.Context, promptRequestID int64, filename, contentType, path string, size int64) (*models.Attachment, error) {
+	res, err := q.db.ExecContext(ctx,
+		`INSERT INTO attachments (prompt_request_id, filename, content_type, path, size) VALUES (?, ?, ?, ?, ?)`,
+		promptRequestID, filename, contentType, path, size,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("creating attachment: %w", err)
+	}
+	id, _ := res.LastInsertId()
+	return q.GetAttachment(ctx, id)
+}
+
+func (q *Queries) GetAttachment(ctx context.Context, id int64) (*models.Attachment, error) {
+	a, err := scanAttachment(q.db.QueryRowContext(ctx, attachmentColumns+` WHERE id = ?`, id))
+	if err != nil {
+		return nil, fmt.Errorf("getting attachment: %w", err)
+	}
+	return &a, nil
+}
+
+// ListPendingAttachments lists uploads not yet sent with a message.
+func (q *Queries) ListPendingAttachments(ctx context.Context, promptRequestID int64) ([]models.Attachment, error) {
+	return q.listAttachments(ctx, attachmentColumns+` WHERE prompt_request_id = ? AND message_id IS NULL ORDER BY id`, promptRequestID)
+}
+
+// ListMessageAttachments lists attachments sent with messages, including
+// superseded ones.
+func (q *Queries) ListMessageAttachments(ctx context.Context, promptRequestID int64) ([]models.Attachment, error) {
+	return q.listAttachments(ctx, attachmentColumns+` WHERE prompt_request_id = ? AND message_id IS NOT NULL ORDER BY id`, promptRequestID)
+}
+
+func (q *Queries) listAttachments(ctx context.Context, query string, args ...any) ([]models.Attachment, error) {
+	rows, err := q.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("listing attachments: %w", err)
+	}
+	defer rows.Close()
+
+	var results []models.Attachment
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning attachment: %w", err)
+		}
+		results = append(results, a)
+	}
+	return results, rows.Err()
+}
+
+// AttachPendingAttachments links all pending uploads to a message.
+func (q *Queries) AttachPendingAttachments(ctx context.Context, promptRequestID, messageID int64) error {
+	_, err := q.db.ExecContext(ctx,
+		`UPDATE attachments SET message_id = ? WHERE prompt_request_id = ? AND message_id IS NULL`,
+		messageID, promptRequestID,
+	)
+	if err != nil {
+		return fmt.Errorf("attaching uploads: %w", err)
+	}
+	return nil
+}
+
+// DeletePendingAttachment removes an upload that hasn't been sent yet. It
+// reports whether the file on disk is no longer referenced by any attachment
+// (edited messages share their original's files).
+func (q *Queries) DeletePendingAttachment(ctx context.Context, id int64) (orphaned bool, err error) {
+	a, err := q.GetAttachment(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	res, err := q.db.ExecContext(ctx, `DELETE FROM attachments WHERE id = ? AND message_id IS NULL`, id)
+	if err != nil {
+		return false, fmt.Errorf("deleting attachment: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, fmt.Errorf("deleting attachment: %w", sql.ErrNoRows)
+	}
+	var refs int
+	if err := q.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM attachments WHERE path = ?`, a.Path).Scan(&refs); err != nil {
+		return false, fmt.Errorf("counting attachment references: %w", err)
+	}
+	return refs == 0, nil
+}
+
+// SetAttachmentRemoteURL records where a published attachment is hosted.
+func (q *Queries) SetAttachmentRemoteURL(ctx context.Context, id int64, url string) error {
+	_, err := q.db.ExecContext(ctx, `UPDATE attachments SET remote_url = ? WHERE id = ?`, url, id)
+	return err
+}
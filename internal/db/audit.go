@@ -0,0 +1,84 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/esnunes/prompter/internal/models"
+)
+
+// auditTable is append-only: triggers reject updates and deletes. It has no
+// foreign key to prompt_requests so entries outlive a purge.
+const auditTable = `
+CREATE TABLE audit_events (
+    id                INTEGER PRIMARY KEY AUTOINCREMENT,
+    prompt_request_id INTEGER NOT NULL,
+    repo_url          TEXT NOT NULL,
+    title             TEXT NOT NULL,
+    kind              TEXT NOT NULL,
+    detail            TEXT NOT NULL DEFAULT '',
+    duration_ms       INTEGER,
+    created_at        TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now'))
+);
+CREATE INDEX idx_audit_events_prompt_request ON audit_events(prompt_request_id);
+` + auditNoUpdate + `
+CREATE TRIGGER audit_events_no_delete BEFORE DELETE ON audit_events BEGIN
+    SELECT RAISE(ABORT, 'the audit log is append-only');
+END;`
+
+// auditNoUpdate rejects updates to the audit log. Encrypting existing entries
+// drops it for the length of a transaction.
+const auditNoUpdate = `CREATE TRIGGER audit_events_no_update BEFORE UPDATE ON audit_events BEGIN
+    SELECT RAISE(ABORT, 'the audit log is append-only');
+END;`
+
+// RecordEvent appends e to the audit log. A zero Duration is stored as none.
+func (q *Queries) RecordEvent(ctx context.Context, e models.AuditEvent) error {
+	var durationMS *int64
+	if e.Duration > 0 {
+		ms := e.Duration.Milliseconds()
+		durationMS = &ms
+	}
+	_, err := q.db.ExecContext(ctx,
+		`INSERT INTO audit_events (prompt_request_id, repo_url, title, kind, detail, duration_ms) VALUES (?, ?, ?, ?, ?, ?)`,
+		e.PromptRequestID, e.RepoURL, q.sealer.seal(e.Title), e.Kind, e.Detail, durationMS,
+	)
+	if err != nil {
+		return fmt.Errorf("recording %s event: %w", e.Kind, err)
+	}
+	return nil
+}
+
+// ListEvents lists the newest audit events first, only those of one prompt
+// request when promptRequestID isn't zero.
+func (q *Queries) ListEvents(ctx context.Context, promptRequestID int64, limit int) ([]models.AuditEvent, error) {
+	rows, err := q.db.QueryContext(ctx,
+		`SELECT id, prompt_request_id, repo_url, title, kind, detail, duration_ms, created_at
+		 FROM audit_events WHERE ?1 = 0 OR prompt_request_id = ?1
+		 ORDER BY id DESC LIMIT ?2`, promptRequestID, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("listing audit events: %w", err)
+	}
+	defer rows.Close()
+
+	var results []models.AuditEvent
+	for rows.Next() {
+		var e models.AuditEvent
+		var durationMS *int64
+		var createdAt string
+		if err := rows.Scan(&e.ID, &e.PromptRequestID, &e.RepoURL, &e.Title, &e.Kind, &e.Detail, &durationMS, &createdAt); err != nil {
+			return nil, fmt.Errorf("scanning audit event: %w", err)
+		}
+		if e.Title, err = q.sealer.open(e.Title); err != nil {
+			return nil, err
+		}
+		if durationMS != nil {
+			e.Duration = time.Duration(*durationMS) * time.Millisecond
+		}
+		e.CreatedAt = parseTime(createdAt)
+		results = append(results, e)
+	}
+	return results, rows.Err()
+}
@@ -0,0 +1,180 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"modernc.org/sqlite"
+
+	"github.com/esnunes/prompter/internal/paths"
+)
+
+// backupPrefix and backupLayout name the copies kept in the backup directory;
+// the names sort chronologically.
+const (
+	backupPrefix = "prompter-"
+	backupLayout = "20060102-150405"
+)
+
+// sqliteConn is the part of a modernc.org/sqlite connection exposing SQLite's
+// online backup API.
+type sqliteConn interface {
+	NewBackup(dstURI string) (*sqlite.Backup, error)
+	NewRestore(srcURI string) (*sqlite.Backup, error)
+}
+
+// BackupDir is where scheduled and on-demand backups are kept.
+func BackupDir() (string, error) {
+	dir, err := paths.CacheDir()
+	if err != nil {
+		return "", fmt.Errorf("getting cache directory: %w", err)
+	}
+	dir = filepath.Join(dir, "backups")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("creating backup directory: %w", err)
+	}
+	return dir, nil
+}
+
+// Backup copies the live database to dst with SQLite's online backup API,
+// which takes a consistent snapshot while the server keeps writing. The copy
+// is renamed into place once complete, so dst is never left half-written.
+func (q *Queries) Backup(ctx context.Context, dst string) error {
+	tmp := dst + ".tmp"
+	os.Remove(tmp)
+	err := q.withBackupAPI(ctx, func(c sqliteConn) (*sqlite.Backup, error) { return c.NewBackup(tmp) })
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("backing up database: %w", err)
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("saving backup: %w", err)
+	}
+	return nil
+}
+
+// Restore replaces the database contents with the backup at src, after
+// checking the backup's integrity. Nothing else should be using the database.
+func (q *Queries) Restore(ctx context.Context, src string) error {
+	if _, err := os.Stat(src); err != nil {
+		return fmt.Errorf("opening backup: %w", err)
+	}
+	if err := checkBackup(src); err != nil {
+		return err
+	}
+	err := q.withBackupAPI(ctx, func(c sqliteConn) (*sqlite.Backup, error) { return c.NewRestore(src) })
+	if err != nil {
+		return fmt.Errorf("restoring database: %w", err)
+	}
+	return nil
+}
+
+func (q *Queries) withBackupAPI(ctx context.Context, start func(sqliteConn) (*sqlite.Backup, error)) error {
+	conn, err := q.conn.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return conn.Raw(func(driverConn any) error {
+		c, ok := driverConn.(sqliteConn)
+		if !ok {
+			return fmt.Errorf("driver %T has no backup API", driverConn)
+		}
+		b, err := start(c)
+		if err != nil {
+			return err
+		}
+		for more := true; more; {
+			if more, err = b.Step(-1); err != nil {
+				b.Finish()
+				return err
+			}
+		}
+		return b.Finish()
+	})
+}
+
+// checkBackup runs an integrity check on a backup file before it is restored.
+func checkBackup(path string) error {
+	db, err := sql.Open("sqlite", "file:"+path+"?mode=ro")
+	if err != nil {
+		return fmt.Errorf("opening backup: %w", err)
+	}
+	defer db.Close()
+	var result string
+	if err := db.QueryRow(`PRAGMA integrity_check`).Scan(&result); err != nil {
+		return fmt.Errorf("checking backup: %w", err)
+	}
+	if result != "ok" {
+		return fmt.Errorf("backup %s is damaged: %s", path, result)
+	}
+	return nil
+}
+
+// BackupPath names a new backup in dir.
+func BackupPath(dir string) string {
+	return filepath.Join(dir, backupPrefix+time.Now().UTC().Format(backupLayout)+".db")
+}
+
+// CreateBackup writes a timestamped backup into dir and deletes all but the
+// newest keep backups there. It returns the new backup's path.
+func (q *Queries) CreateBackup(ctx context.Context, dir string, keep int) (string, error) {
+	path := BackupPath(dir)
+	if err := q.Backup(ctx, path); err != nil {
+		return "", err
+	}
+	backups, err := ListBackups(dir)
+	if err != nil {
+		return path, err
+	}
+	for _, old := range backups[min(keep, len(backups)):] {
+		if err := os.Remove(old.Path); err != nil {
+			return path, fmt.Errorf("rotating backups: %w", err)
+		}
+	}
+	return path, nil
+}
+
+// BackupFile is a backup in the backup directory.
+type BackupFile struct {
+	Path      string
+	CreatedAt time.Time
+	Size      int64
+}
+
+// ListBackups lists the backups in dir, newest first.
+func ListBackups(dir string) ([]BackupFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("listing backups: %w", err)
+	}
+	var backups []BackupFile
+	for _, e := range entries {
+		stamp, ok := strings.CutPrefix(e.Name(), backupPrefix)
+		if !ok || e.IsDir() {
+			continue
+		}
+		stamp, ok = strings.CutSuffix(stamp, ".db")
+		if !ok {
+			continue
+		}
+		t, err := time.Parse(backupLayout, stamp)
+		if err != nil {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		backups = append(backups, BackupFile{Path: filepath.Join(dir, e.Name()), CreatedAt: t.Local(), Size: info.Size()})
+	}
+	slices.SortFunc(backups, func(a, b BackupFile) int { return b.CreatedAt.Compare(a.CreatedAt) })
+	return backups, nil
+}
@@ -0,0 +1,76 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+)
+
+// onDelete lists what happens to a row when the row its foreign key points
+// at is deleted: dependent rows go with it, while mere links are cleared.
+// Purging a prompt request is then a single delete.
+var onDelete = []struct {
+	table, column, parent, action string
+}{
+	{"prompt_requests", "forked_from_id", "prompt_requests", "SET NULL"},
+	{"messages", "prompt_request_id", "prompt_requests", "CASCADE"},
+	{"messages", "merged_from_id", "prompt_requests", "SET NULL"},
+	{"revisions", "prompt_request_id", "prompt_requests", "CASCADE"},
+	{"revisions", "after_message_id", "messages", "SET NULL"},
+	{"revisions", "source_message_id", "messages", "SET NULL"},
+	{"jobs", "prompt_request_id", "prompt_requests", "CASCADE"},
+	{"prompt_request_tags", "prompt_request_id", "prompt_requests", "CASCADE"},
+	{"prompt_request_tags", "tag_id", "tags", "CASCADE"},
+	{"attachments", "prompt_request_id", "prompt_requests", "CASCADE"},
+	{"attachments", "message_id", "messages", "CASCADE"},
+	{"file_references", "prompt_request_id", "prompt_requests", "CASCADE"},
+	{"file_references", "message_id", "messages", "CASCADE"},
+	{"questions", "message_id", "messages", "CASCADE"},
+	{"questions", "answer_message_id", "messages", "SET NULL"},
+	{"question_options", "question_id", "questions", "CASCADE"},
+	{"generated_contents", "message_id", "messages", "CASCADE"},
+}
+
+// migrateCascades adds the onDelete actions to the foreign keys. Like column
+// defaults, foreign key clauses can't be altered in place, but they don't
+// affect the file format, so they are edited in the stored schema.
+func migrateCascades(tx *sql.Tx) error {
+	var schemaVersion int
+	if err := tx.QueryRow(`PRAGMA schema_version`).Scan(&schemaVersion); err != nil {
+		return fmt.Errorf("reading schema version: %w", err)
+	}
+
+	tables := map[string]string{}
+	for _, fk := range onDelete {
+		stmt, ok := tables[fk.table]
+		if !ok {
+			if err := tx.QueryRow(`SELECT sql FROM sqlite_master WHERE type = 'table' AND name = ?`, fk.table).Scan(&stmt); err != nil {
+				return fmt.Errorf("reading %s schema: %w", fk.table, err)
+			}
+		}
+		re := regexp.MustCompile(`(\b` + fk.column + `\s+INTEGER\b[^,)]*?\bREFERENCES\s+` + fk.parent + `\(id\))`)
+		if !re.MatchString(stmt) {
+			return fmt.Errorf("foreign key %s.%s not found", fk.table, fk.column)
+		}
+		tables[fk.table] = re.ReplaceAllString(stmt, "$1 ON DELETE "+fk.action)
+	}
+
+	if _, err := tx.Exec(`PRAGMA writable_schema = ON`); err != nil {
+		return err
+	}
+	for table, stmt := range tables {
+		if _, err := tx.Exec(`UPDATE sqlite_master SET sql = ? WHERE type = 'table' AND name = ?`, stmt, table); err != nil {
+			return fmt.Errorf("changing %s foreign keys: %w", table, err)
+		}
+	}
+	stmts := []string{
+		fmt.Sprintf(`PRAGMA schema_version = %d`, schemaVersion+1),
+		`PRAGMA writable_schema = OFF`,
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("changing foreign keys: %w", err)
+		}
+	}
+	return nil
+}
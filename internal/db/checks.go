@@ -0,0 +1,61 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/esnunes/prompter/internal/models"
+)
+
+// promptChecksTable holds the verdicts of the last quality check of each
+// generated prompt, one row per checklist item.
+const promptChecksTable = `
+CREATE TABLE prompt_checks (
+    message_id INTEGER NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+    item       TEXT NOT NULL,
+    passed     INTEGER NOT NULL,
+    fix        TEXT NOT NULL DEFAULT '',
+    checked_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+    PRIMARY KEY (message_id, item)
+)`
+
+// ListPromptChecks returns the quality check verdicts of the prompt generated
+// by an assistant message; none if it wasn't checked.
+func (q *Queries) ListPromptChecks(ctx context.Context, messageID int64) ([]models.PromptCheck, error) {
+	rows, err := q.db.QueryContext(ctx,
+		`SELECT item, passed, fix FROM prompt_checks WHERE message_id = ? ORDER BY rowid`, messageID)
+	if err != nil {
+		return nil, fmt.Errorf("listing prompt checks: %w", err)
+	}
+	defer rows.Close()
+
+	var checks []models.PromptCheck
+	for rows.Next() {
+		var c models.PromptCheck
+		if err := rows.Scan(&c.Item, &c.Passed, &c.Fix); err != nil {
+			return nil, fmt.Errorf("scanning prompt check: %w", err)
+		}
+		checks = append(checks, c)
+	}
+	return checks, rows.Err()
+}
+
+// ReplacePromptChecks records the verdicts of a new quality check of the
+// prompt generated by an assistant message.
+func (q *Queries) ReplacePromptChecks(ctx context.Context, messageID int64, checks []models.PromptCheck) error {
+	return q.InTx(ctx, func(tx *Queries) error {
+		if _, err := tx.db.ExecContext(ctx, `DELETE FROM prompt_checks WHERE message_id = ?`, messageID); err != nil {
+			return fmt.Errorf("dropping prompt checks: %w", err)
+		}
+		for _, c := range checks {
+			_, err := tx.db.ExecContext(ctx,
+				`INSERT INTO prompt_checks (message_id, item, passed, fix) VALUES (?, ?, ?, ?)
+				 ON CONFLICT(message_id, item) DO UPDATE SET passed = excluded.passed, fix = excluded.fix`,
+				messageID, c.Item, c.Passed, c.Fix)
+			if err != nil {
+				return fmt.Errorf("saving prompt check: %w", err)
+			}
+		}
+		return nil
+	})
+}
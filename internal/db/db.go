@@ -48,12 +48,129 @@ CREATE TABLE IF NOT EXISTS revisions (
     published_at      TEXT NOT NULL DEFAULT (datetime('now'))
 );
 
+CREATE TABLE IF NOT EXISTS jobs (
+    prompt_request_id INTEGER PRIMARY KEY REFERENCES prompt_requests(id),
+    status            TEXT NOT NULL,
+    error             TEXT NOT NULL DEFAULT '',
+    started_at        TEXT,
+    updated_at        TEXT NOT NULL DEFAULT (datetime('now'))
+);
+
+CREATE TABLE IF NOT EXISTS job_queue (
+    id           INTEGER PRIMARY KEY AUTOINCREMENT,
+    kind         TEXT NOT NULL,
+    ref          TEXT NOT NULL DEFAULT '',
+    payload      TEXT NOT NULL DEFAULT '{}',
+    status       TEXT NOT NULL DEFAULT 'queued' CHECK (status IN ('queued', 'running', 'done', 'failed')),
+    attempts     INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL DEFAULT 1,
+    last_error   TEXT NOT NULL DEFAULT '',
+    run_after    TEXT NOT NULL DEFAULT (datetime('now')),
+    locked_until TEXT,
+    created_at   TEXT NOT NULL DEFAULT (datetime('now')),
+    updated_at   TEXT NOT NULL DEFAULT (datetime('now'))
+);
+
+CREATE TABLE IF NOT EXISTS tags (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    name       TEXT NOT NULL UNIQUE,
+    created_at TEXT NOT NULL DEFAULT (datetime('now'))
+);
+
+CREATE TABLE IF NOT EXISTS prompt_request_tags (
+    prompt_request_id INTEGER NOT NULL REFERENCES prompt_requests(id),
+    tag_id            INTEGER NOT NULL REFERENCES tags(id),
+    PRIMARY KEY (prompt_request_id, tag_id)
+);
+
+CREATE TABLE IF NOT EXISTS attachments (
+    id                INTEGER PRIMARY KEY AUTOINCREMENT,
+    prompt_request_id INTEGER NOT NULL REFERENCES prompt_requests(id),
+    message_id        INTEGER REFERENCES messages(id),
+    filename          TEXT NOT NULL,
+    content_type      TEXT NOT NULL,
+    path              TEXT NOT NULL,
+    size              INTEGER NOT NULL,
+    remote_url        TEXT,
+    created_at        TEXT NOT NULL DEFAULT (datetime('now'))
+);
+
+CREATE TABLE IF NOT EXISTS file_references (
+    id                INTEGER PRIMARY KEY AUTOINCREMENT,
+    prompt_request_id INTEGER NOT NULL REFERENCES prompt_requests(id),
+    message_id        INTEGER REFERENCES messages(id),
+    path              TEXT NOT NULL,
+    start_line        INTEGER NOT NULL DEFAULT 0,
+    end_line          INTEGER NOT NULL DEFAULT 0,
+    created_at        TEXT NOT NULL DEFAULT (datetime('now'))
+);
+
 CREATE INDEX IF NOT EXISTS idx_prompt_requests_repository ON prompt_requests(repository_id);
 CREATE INDEX IF NOT EXISTS idx_prompt_requests_status ON prompt_requests(status);
 CREATE INDEX IF NOT EXISTS idx_messages_prompt_request ON messages(prompt_request_id);
 CREATE INDEX IF NOT EXISTS idx_revisions_prompt_request ON revisions(prompt_request_id);
+CREATE INDEX IF NOT EXISTS idx_job_queue_status ON job_queue(status, run_after);
+CREATE INDEX IF NOT EXISTS idx_prompt_request_tags_tag ON prompt_request_tags(tag_id);
+CREATE INDEX IF NOT EXISTS idx_attachments_prompt_request ON attachments(prompt_request_id);
+CREATE INDEX IF NOT EXISTS idx_file_references_prompt_request ON file_references(prompt_request_id);
 `
 
+// searchSchema keeps an FTS5 index of prompt request titles, message contents
+// and revision bodies in sync via triggers. Rows point back to their prompt
+// request so search results can be grouped per conversation.
+const searchSchema = `
+CREATE VIRTUAL TABLE IF NOT EXISTS search_index USING fts5(
+    body,
+    prompt_request_id UNINDEXED,
+    source UNINDEXED,
+    source_id UNINDEXED,
+    tokenize = 'porter unicode61'
+);
+
+CREATE TRIGGER IF NOT EXISTS search_prompt_requests_ai AFTER INSERT ON prompt_requests BEGIN
+    INSERT INTO search_index (body, prompt_request_id, source, source_id) VALUES (new.title, new.id, 'title', new.id);
+END;
+CREATE TRIGGER IF NOT EXISTS search_prompt_requests_au AFTER UPDATE OF title ON prompt_requests BEGIN
+    UPDATE search_index SET body = new.title WHERE source = 'title' AND source_id = new.id;
+END;
+CREATE TRIGGER IF NOT EXISTS search_prompt_requests_ad AFTER DELETE ON prompt_requests BEGIN
+    DELETE FROM search_index WHERE prompt_request_id = old.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS search_messages_ai AFTER INSERT ON messages BEGIN
+    INSERT INTO search_index (body, prompt_request_id, source, source_id) VALUES (new.content, new.prompt_request_id, 'message', new.id);
+END;
+CREATE TRIGGER IF NOT EXISTS search_messages_au AFTER UPDATE OF content ON messages BEGIN
+    UPDATE search_index SET body = new.content WHERE source = 'message' AND source_id = new.id;
+END;
+CREATE TRIGGER IF NOT EXISTS search_messages_ad AFTER DELETE ON messages BEGIN
+    DELETE FROM search_index WHERE source = 'message' AND source_id = old.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS search_revisions_ai AFTER INSERT ON revisions BEGIN
+    INSERT INTO search_index (body, prompt_request_id, source, source_id) VALUES (new.content, new.prompt_request_id, 'revision', new.id);
+END;
+CREATE TRIGGER IF NOT EXISTS search_revisions_au AFTER UPDATE OF content ON revisions BEGIN
+    UPDATE search_index SET body = new.content WHERE source = 'revision' AND source_id = new.id;
+END;
+CREATE TRIGGER IF NOT EXISTS search_revisions_ad AFTER DELETE ON revisions BEGIN
+    DELETE FROM search_index WHERE source = 'revision' AND source_id = old.id;
+END;
+`
+
+// backfillSearchIndex populates an empty search index from existing rows, so
+// databases created before the index existed become searchable.
+const backfillSearchIndex = `
+INSERT INTO search_index (body, prompt_request_id, source, source_id)
+SELECT body, prompt_request_id, source, source_id FROM (
+    SELECT title AS body, id AS prompt_request_id, 'title' AS source, id AS source_id FROM prompt_requests
+    UNION ALL
+    SELECT content, prompt_request_id, 'message', id FROM messages
+    UNION ALL
+    SELECT content, prompt_request_id, 'revision', id FROM revisions
+)
+WHERE NOT EXISTS (SELECT 1 FROM search_index)`
+
 func DBPath() (string, error) {
 	dir, err := paths.CacheDir()
 	if err != nil {
@@ -65,25 +182,28 @@ func DBPath() (string, error) {
 	return filepath.Join(dir, "prompter.db"), nil
 }
 
+// Open opens the database and applies pending migrations.
 func Open(dbPath string) (*sql.DB, error) {
-	db, err := sql.Open("sqlite", dbPath+"?_pragma=journal_mode(wal)&_pragma=foreign_keys(on)")
+	db, err := OpenUnmigrated(dbPath)
 	if err != nil {
-		return nil, fmt.Errorf("opening database: %w", err)
+		return nil, err
 	}
-	if _, err := db.Exec(schema); err != nil {
+	if _, err := Migrate(db); err != nil {
 		db.Close()
-		return nil, fmt.Errorf("running schema migration: %w", err)
+		return nil, fmt.Errorf("migrating database: %w", err)
 	}
+	return db, nil
+}
 
-	// Migration: add after_message_id to revisions (nullable, safe for existing rows).
-	// SQLite ALTER TABLE doesn't support IF NOT EXISTS, so ignore "duplicate column" errors.
-	db.Exec(`ALTER TABLE revisions ADD COLUMN after_message_id INTEGER REFERENCES messages(id)`)
-
-	// Migration: add last_viewed_at for unread tracking in prompt list sidebar.
-	db.Exec(`ALTER TABLE prompt_requests ADD COLUMN last_viewed_at TEXT`)
-
-	// Migration: add archived flag for archiving prompt requests.
-	db.Exec(`ALTER TABLE prompt_requests ADD COLUMN archived INTEGER NOT NULL DEFAULT 0`)
-
+// OpenUnmigrated opens the database without changing its schema, for
+// inspecting and applying migrations explicitly.
+func OpenUnmigrated(dbPath string) (*sql.DB, error) {
+	// Writers wait for each other instead of failing with SQLITE_BUSY, and
+	// transactions take the write lock up front: a read transaction can't
+	// be upgraded once another connection has written.
+	db, err := sql.Open("sqlite", dbPath+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(wal)&_pragma=foreign_keys(on)&_txlock=immediate")
+	if err != nil {
+		return nil, fmt.Errorf("opening database: %w", err)
+	}
 	return db, nil
 }
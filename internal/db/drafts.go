@@ -0,0 +1,84 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/esnunes/prompter/internal/models"
+)
+
+// draftsTable keeps what the user has typed but not sent yet, so closing the
+// tab or restarting the server doesn't lose it. Like messages, drafts are
+// encrypted at rest.
+const draftsTable = `
+CREATE TABLE drafts (
+    prompt_request_id  INTEGER PRIMARY KEY REFERENCES prompt_requests(id) ON DELETE CASCADE,
+    message            TEXT NOT NULL DEFAULT '',
+    answers            TEXT NOT NULL DEFAULT '',
+    answers_message_id INTEGER REFERENCES messages(id) ON DELETE SET NULL,
+    updated_at         TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now'))
+)`
+
+// GetDraft returns a prompt request's unsent input, empty if there is none.
+func (q *Queries) GetDraft(ctx context.Context, promptRequestID int64) (*models.Draft, error) {
+	d := &models.Draft{}
+	var answersMessageID sql.NullInt64
+	err := q.db.QueryRowContext(ctx,
+		`SELECT message, answers, answers_message_id FROM drafts WHERE prompt_request_id = ?`, promptRequestID,
+	).Scan(&d.Message, &d.Answers, &answersMessageID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return d, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading draft: %w", err)
+	}
+	if d.Message, err = q.sealer.open(d.Message); err != nil {
+		return nil, err
+	}
+	if d.Answers, err = q.sealer.open(d.Answers); err != nil {
+		return nil, err
+	}
+	d.AnswersMessageID = answersMessageID.Int64
+	return d, nil
+}
+
+// SaveDraftMessage stores the unsent message of a prompt request. An empty
+// message clears it.
+func (q *Queries) SaveDraftMessage(ctx context.Context, promptRequestID int64, message string) error {
+	if message != "" {
+		message = q.sealer.seal(message)
+	}
+	_, err := q.db.ExecContext(ctx,
+		`INSERT INTO drafts (prompt_request_id, message) VALUES (?, ?)
+		 ON CONFLICT(prompt_request_id) DO UPDATE SET message = excluded.message,
+		     updated_at = strftime('%Y-%m-%dT%H:%M:%SZ', 'now')`,
+		promptRequestID, message,
+	)
+	if err != nil {
+		return fmt.Errorf("saving draft message: %w", err)
+	}
+	return nil
+}
+
+// SaveDraftAnswers stores the answers picked so far to the questions of an
+// assistant message, as form-encoded values. Empty answers clear them.
+func (q *Queries) SaveDraftAnswers(ctx context.Context, promptRequestID, messageID int64, answers string) error {
+	var answersMessageID *int64
+	if answers != "" {
+		answers = q.sealer.seal(answers)
+		answersMessageID = &messageID
+	}
+	_, err := q.db.ExecContext(ctx,
+		`INSERT INTO drafts (prompt_request_id, answers, answers_message_id) VALUES (?, ?, ?)
+		 ON CONFLICT(prompt_request_id) DO UPDATE SET answers = excluded.answers,
+		     answers_message_id = excluded.answers_message_id,
+		     updated_at = strftime('%Y-%m-%dT%H:%M:%SZ', 'now')`,
+		promptRequestID, answers, answersMessageID,
+	)
+	if err != nil {
+		return fmt.Errorf("saving draft answers: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,329 @@
+package db
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/esnunes/prompter/internal/models"
+)
+
+// Conversation content (see sealedColumns) can be encrypted at rest with
+// AES-GCM, under a key derived from a passphrase. Encrypted values are stored
+// as encryptedPrefix followed by the base64 nonce and ciphertext, so plaintext
+// written before encryption was turned on stays readable. Empty values carry
+// nothing to hide and are stored as is.
+const encryptedPrefix = "enc:v1:"
+
+// keyIterations is the PBKDF2-SHA256 work factor for new databases.
+const keyIterations = 600_000
+
+// checkPlaintext is sealed into the encryption table so a wrong passphrase is
+// caught before anything is written with it.
+const checkPlaintext = "prompter"
+
+var (
+	ErrEncrypted       = errors.New("database is encrypted; set PROMPTER_ENCRYPTION_PASSPHRASE")
+	ErrWrongPassphrase = errors.New("wrong encryption passphrase")
+)
+
+const encryptionTable = `
+CREATE TABLE encryption (
+    id          INTEGER PRIMARY KEY CHECK (id = 1),
+    salt        BLOB NOT NULL,
+    iterations  INTEGER NOT NULL,
+    check_value TEXT NOT NULL,
+    created_at  TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now'))
+)`
+
+// searchEncryptedMessages keeps encrypted message contents out of the search
+// index, which would otherwise hold them in the clear.
+const searchEncryptedMessages = `
+DROP TRIGGER search_messages_ai;
+DROP TRIGGER search_messages_au;
+CREATE TRIGGER search_messages_ai AFTER INSERT ON messages BEGIN
+    INSERT INTO search_index (body, prompt_request_id, source, source_id)
+    VALUES (CASE WHEN new.content LIKE 'enc:v1:%' THEN '' ELSE new.content END, new.prompt_request_id, 'message', new.id);
+END;
+CREATE TRIGGER search_messages_au AFTER UPDATE OF content ON messages BEGIN
+    UPDATE search_index SET body = CASE WHEN new.content LIKE 'enc:v1:%' THEN '' ELSE new.content END
+    WHERE source = 'message' AND source_id = new.id;
+END;`
+
+// searchEncryptedRevisions does the same for published revisions.
+const searchEncryptedRevisions = `
+DROP TRIGGER search_revisions_ai;
+DROP TRIGGER search_revisions_au;
+CREATE TRIGGER search_revisions_ai AFTER INSERT ON revisions BEGIN
+    INSERT INTO search_index (body, prompt_request_id, source, source_id)
+    VALUES (CASE WHEN new.content LIKE 'enc:v1:%' THEN '' ELSE new.content END, new.prompt_request_id, 'revision', new.id);
+END;
+CREATE TRIGGER search_revisions_au AFTER UPDATE OF content ON revisions BEGIN
+    UPDATE search_index SET body = CASE WHEN new.content LIKE 'enc:v1:%' THEN '' ELSE new.content END
+    WHERE source = 'revision' AND source_id = new.id;
+END;`
+
+// searchEncryptedTitles does the same for prompt request titles.
+const searchEncryptedTitles = `
+DROP TRIGGER search_prompt_requests_ai;
+DROP TRIGGER search_prompt_requests_au;
+CREATE TRIGGER search_prompt_requests_ai AFTER INSERT ON prompt_requests BEGIN
+    INSERT INTO search_index (body, prompt_request_id, source, source_id)
+    VALUES (CASE WHEN new.title LIKE 'enc:v1:%' THEN '' ELSE new.title END, new.id, 'title', new.id);
+END;
+CREATE TRIGGER search_prompt_requests_au AFTER UPDATE OF title ON prompt_requests BEGIN
+    UPDATE search_index SET body = CASE WHEN new.title LIKE 'enc:v1:%' THEN '' ELSE new.title END
+    WHERE source = 'title' AND source_id = new.id;
+END;`
+
+// sealer encrypts and decrypts stored values. A nil sealer stores plaintext.
+type sealer struct {
+	aead cipher.AEAD
+}
+
+func newSealer(passphrase string, salt []byte, iterations int) (*sealer, error) {
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, iterations, 32)
+	if err != nil {
+		return nil, fmt.Errorf("deriving encryption key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &sealer{aead: aead}, nil
+}
+
+func (s *sealer) seal(v string) string {
+	if s == nil || v == "" {
+		return v
+	}
+	nonce := make([]byte, s.aead.NonceSize())
+	rand.Read(nonce)
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(s.aead.Seal(nonce, nonce, []byte(v), nil))
+}
+
+func (s *sealer) sealPtr(v *string) *string {
+	if v == nil {
+		return nil
+	}
+	sealed := s.seal(*v)
+	return &sealed
+}
+
+func (s *sealer) open(v string) (string, error) {
+	data, ok := strings.CutPrefix(v, encryptedPrefix)
+	if !ok {
+		return v, nil
+	}
+	if s == nil {
+		return "", ErrEncrypted
+	}
+	raw, err := base64.StdEncoding.DecodeString(data)
+	if err != nil || len(raw) < s.aead.NonceSize() {
+		return "", errors.New("decrypting: malformed value")
+	}
+	plain, err := s.aead.Open(nil, raw[:s.aead.NonceSize()], raw[s.aead.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("decrypting: %w", err)
+	}
+	return string(plain), nil
+}
+
+// openAll decrypts each value in place.
+func (s *sealer) openAll(vs ...*string) error {
+	for _, v := range vs {
+		plain, err := s.open(*v)
+		if err != nil {
+			return err
+		}
+		*v = plain
+	}
+	return nil
+}
+
+func (s *sealer) openMessage(m *models.Message) error {
+	var err error
+	if m.Content, err = s.open(m.Content); err != nil {
+		return err
+	}
+	if m.RawResponse != nil {
+		raw, err := s.open(*m.RawResponse)
+		if err != nil {
+			return err
+		}
+		m.RawResponse = &raw
+	}
+	return nil
+}
+
+// UseEncryption sets up encryption at rest. With a passphrase, conversation
+// content and titles are encrypted from now on, and existing plaintext rows
+// are encrypted in place; it returns how many. Encrypted content and titles
+// are left out of the search index, so they can't be searched. The first
+// passphrase used becomes the database's, and later ones must match it.
+// Without one, it fails with ErrEncrypted if the database has been encrypted.
+func (q *Queries) UseEncryption(ctx context.Context, passphrase string) (int64, error) {
+	var salt []byte
+	var iterations int
+	var check string
+	err := q.db.QueryRowContext(ctx, `SELECT salt, iterations, check_value FROM encryption`).Scan(&salt, &iterations, &check)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		if passphrase == "" {
+			return 0, nil
+		}
+		salt, iterations = make([]byte, 16), keyIterations
+		rand.Read(salt)
+	case err != nil:
+		return 0, fmt.Errorf("reading encryption settings: %w", err)
+	case passphrase == "":
+		return 0, ErrEncrypted
+	}
+
+	s, err := newSealer(passphrase, salt, iterations)
+	if err != nil {
+		return 0, err
+	}
+	if check == "" {
+		_, err := q.db.ExecContext(ctx, `INSERT INTO encryption (id, salt, iterations, check_value) VALUES (1, ?, ?, ?)`,
+			salt, iterations, s.seal(checkPlaintext))
+		if err != nil {
+			return 0, fmt.Errorf("saving encryption settings: %w", err)
+		}
+	} else if plain, err := s.open(check); err != nil || plain != checkPlaintext {
+		return 0, ErrWrongPassphrase
+	}
+	q.sealer = s
+	return q.encryptExisting(ctx)
+}
+
+// sealedColumns lists the columns holding conversation content and titles,
+// by table, with the column identifying a row. Titles in the search index
+// are cleared by its triggers as the titles are sealed.
+var sealedColumns = []struct {
+	table, key string
+	columns    []string
+}{
+	{"messages", "id", []string{"content", "raw_response"}},
+	{"questions", "id", []string{"header", "text", "answer"}},
+	{"question_options", "rowid", []string{"label", "description"}},
+	{"generated_contents", "message_id", []string{"title", "motivation", "prompt", "suggested_labels", "size_rationale",
+		"non_goals", "alternatives", "affected_areas", "ambiguities", "glossary", "test_plan", "affected_paths"}},
+	{"related_issues", "rowid", []string{"title"}},
+	{"revisions", "id", []string{"content"}},
+	{"prompt_requests", "id", []string{"title", "notes", "summary"}},
+	{"drafts", "prompt_request_id", []string{"message", "answers"}},
+	{"repo_ideas", "id", []string{"title", "description"}},
+	{"audit_events", "id", []string{"title"}},
+}
+
+// encryptExisting encrypts conversation content stored in plaintext and
+// returns how many rows it changed. The file is vacuumed afterwards so the
+// plaintext doesn't linger in free pages; backups taken before still hold it.
+func (q *Queries) encryptExisting(ctx context.Context) (int64, error) {
+	tx, err := q.begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("beginning encryption: %w", err)
+	}
+	defer tx.Rollback()
+
+	// The audit log rejects updates; let these through, in this transaction
+	// only.
+	if _, err := tx.ExecContext(ctx, `DROP TRIGGER audit_events_no_update`); err != nil {
+		return 0, fmt.Errorf("allowing audit log updates: %w", err)
+	}
+	var total int64
+	for _, t := range sealedColumns {
+		n, err := q.encryptTable(ctx, tx, t.table, t.key, t.columns)
+		if err != nil {
+			return 0, fmt.Errorf("encrypting %s: %w", t.table, err)
+		}
+		total += n
+	}
+	if _, err := tx.ExecContext(ctx, auditNoUpdate); err != nil {
+		return 0, fmt.Errorf("restoring audit log triggers: %w", err)
+	}
+	if total == 0 {
+		return 0, nil
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO search_index (search_index) VALUES ('optimize')`); err != nil {
+		return 0, fmt.Errorf("rebuilding search index: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("committing encryption: %w", err)
+	}
+	if _, err := q.db.ExecContext(ctx, `VACUUM`); err != nil {
+		return 0, fmt.Errorf("vacuuming: %w", err)
+	}
+	return total, nil
+}
+
+// encryptTable encrypts the plaintext values of a table's columns.
+func (q *Queries) encryptTable(ctx context.Context, tx dbtx, table, key string, columns []string) (int64, error) {
+	plaintext := make([]string, len(columns))
+	for i, c := range columns {
+		plaintext[i] = fmt.Sprintf(`(%s != '' AND %s NOT LIKE '%s%%')`, c, c, encryptedPrefix)
+	}
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf(`SELECT %s, %s FROM %s WHERE %s`,
+		key, strings.Join(columns, ", "), table, strings.Join(plaintext, " OR ")))
+	if err != nil {
+		return 0, fmt.Errorf("listing plaintext rows: %w", err)
+	}
+	type row struct {
+		key    int64
+		values []sql.NullString
+	}
+	var plain []row
+	for rows.Next() {
+		r := row{values: make([]sql.NullString, len(columns))}
+		dest := []any{&r.key}
+		for i := range r.values {
+			dest = append(dest, &r.values[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("scanning row: %w", err)
+		}
+		plain = append(plain, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	set := make([]string, len(columns))
+	for i, c := range columns {
+		set[i] = c + " = ?"
+	}
+	update := fmt.Sprintf(`UPDATE %s SET %s WHERE %s = ?`, table, strings.Join(set, ", "), key)
+	for _, r := range plain {
+		args := make([]any, 0, len(columns)+1)
+		for _, v := range r.values {
+			if !v.Valid {
+				args = append(args, nil)
+				continue
+			}
+			text, err := q.sealer.open(v.String)
+			if err != nil {
+				return 0, err
+			}
+			args = append(args, q.sealer.seal(text))
+		}
+		if _, err := tx.ExecContext(ctx, update, append(args, r.key)...); err != nil {
+			return 0, fmt.Errorf("encrypting row: %w", err)
+		}
+	}
+	return int64(len(plain)), nil
+}
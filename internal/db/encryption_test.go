@@ -0,0 +1,191 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/esnunes/prompter/internal/models"
+)
+
+const testReply = `{"structured_output":{"message":"Which pages?","questions":[{"header":"Scope","text":"Which pages?","options":[{"label":"All","description":"Every page"}]}],
+	"generated_title":"Dark mode","generated_prompt":"Add a dark theme","non_goals":["High contrast"],
+	"related_issues":[{"number":3,"title":"Theme support"}]}}`
+
+// assertSealed fails the test for every non-empty value of sealedColumns
+// stored in the clear.
+func assertSealed(t *testing.T, q *Queries) {
+	t.Helper()
+	for _, table := range sealedColumns {
+		for _, c := range table.columns {
+			var n int
+			err := q.db.QueryRowContext(context.Background(), fmt.Sprintf(
+				`SELECT COUNT(*) FROM %s WHERE %s != '' AND %s NOT LIKE '%s%%'`, table.table, c, c, encryptedPrefix,
+			)).Scan(&n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n > 0 {
+				t.Errorf("%s.%s: %d values in the clear", table.table, c, n)
+			}
+		}
+	}
+	var n int
+	if err := q.db.QueryRowContext(context.Background(), `SELECT COUNT(*) FROM search_index WHERE body != ''`).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n > 0 {
+		t.Errorf("search index: %d values in the clear", n)
+	}
+}
+
+func TestUseEncryption_SealsConversationContent(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "prompter.db")
+	database, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	q := NewQueries(database)
+
+	repo, err := q.UpsertRepository(ctx, "github.com/a/b", "/tmp/a/b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pr, err := q.CreatePromptRequest(ctx, repo.ID, "sess-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply := testReply
+	if _, err := q.CreateMessage(ctx, pr.ID, "user", "Add dark mode", nil); err != nil {
+		t.Fatal(err)
+	}
+	asked, err := q.CreateMessage(ctx, pr.ID, "assistant", "Which pages?", &reply)
+	if err != nil {
+		t.Fatal(err)
+	}
+	answer, err := q.CreateMessage(ctx, pr.ID, "user", "All", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := q.SaveAnswers(ctx, pr.ID, answer.ID, map[int]string{0: "All"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.CreateRevision(ctx, pr.ID, "Add a dark theme", &asked.ID, &asked.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.UpdatePromptRequestNotes(ctx, pr.ID, "ask about mobile"); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.SaveDraftMessage(ctx, pr.ID, "Also the"); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.RenamePromptRequest(ctx, pr.ID, "Dark mode"); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.ReplaceRepoIdeas(ctx, repo.ID, []models.RepoIdea{{Title: "Themes", Description: "Let users pick a theme"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.RecordEvent(ctx, models.AuditEvent{PromptRequestID: pr.ID, RepoURL: repo.URL, Title: "Dark mode", Kind: "created"}); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := q.UseEncryption(ctx, "secret")
+	if err != nil {
+		t.Fatalf("UseEncryption: %v", err)
+	}
+	if n == 0 {
+		t.Error("UseEncryption encrypted nothing")
+	}
+	assertSealed(t, q)
+
+	// Written once encryption is on.
+	if _, err := q.CreateMessage(ctx, pr.ID, "assistant", "Which pages?", &reply); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.CreateRevision(ctx, pr.ID, "Add a dark theme, v2", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.RecordEvent(ctx, models.AuditEvent{PromptRequestID: pr.ID, RepoURL: repo.URL, Title: "Dark mode", Kind: "published"}); err != nil {
+		t.Fatal(err)
+	}
+	forked, err := q.ForkPromptRequest(ctx, pr.ID, "sess-2", "Dark mode (fork)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSealed(t, q)
+
+	questions, err := q.ListQuestions(ctx, asked.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(questions) != 1 || questions[0].Text != "Which pages?" || questions[0].Answer != "All" || questions[0].Options[0].Label != "All" {
+		t.Errorf("questions = %+v", questions)
+	}
+	gc, err := q.GetGeneratedContent(ctx, pr.ID, asked.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gc.Title != "Dark mode" || gc.Prompt != "Add a dark theme" || strings.Join(gc.NonGoals, ",") != "High contrast" {
+		t.Errorf("generated content = %+v", gc)
+	}
+	related, err := q.ListRelatedIssues(ctx, pr.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(related) != 1 || related[0].Title != "Theme support" {
+		t.Errorf("related issues = %+v", related)
+	}
+	revs, err := q.ListRevisions(ctx, pr.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revs) != 2 || revs[0].Content != "Add a dark theme" {
+		t.Errorf("revisions = %+v", revs)
+	}
+	got, err := q.GetPromptRequest(ctx, pr.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Notes != "ask about mobile" || got.Title != "Dark mode" {
+		t.Errorf("notes, title = %q, %q", got.Notes, got.Title)
+	}
+	prs, _, err := q.ListPromptRequests(ctx, false, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range prs {
+		if want := map[int64]string{pr.ID: "Dark mode", forked.ID: "Dark mode (fork)"}[p.ID]; p.Title != want {
+			t.Errorf("listed title = %q, want %q", p.Title, want)
+		}
+	}
+	ideas, err := q.ListRepoIdeas(ctx, repo.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ideas) != 1 || ideas[0].Title != "Themes" || ideas[0].Description != "Let users pick a theme" {
+		t.Errorf("ideas = %+v", ideas)
+	}
+	events, err := q.ListEvents(ctx, pr.ID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].Title != "Dark mode" || events[1].Title != "Dark mode" {
+		t.Errorf("audit events = %+v", events)
+	}
+	// The audit log is append-only again.
+	if _, err := q.db.ExecContext(ctx, `UPDATE audit_events SET kind = 'deleted'`); err == nil {
+		t.Error("updating the audit log succeeded")
+	}
+
+	if n, err := q.UseEncryption(ctx, "secret"); err != nil || n != 0 {
+		t.Errorf("second UseEncryption = %d, %v; want 0, nil", n, err)
+	}
+	if _, err := NewQueries(database).UseEncryption(ctx, ""); !errors.Is(err, ErrEncrypted) {
+		t.Errorf("UseEncryption without a passphrase = %v, want ErrEncrypted", err)
+	}
+}
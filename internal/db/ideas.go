@@ -0,0 +1,83 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/esnunes/prompter/internal/models"
+)
+
+// repoIdeasTable holds the feature requests Claude last proposed for each
+// repository.
+const repoIdeasTable = `
+CREATE TABLE repo_ideas (
+    id            INTEGER PRIMARY KEY AUTOINCREMENT,
+    repository_id INTEGER NOT NULL REFERENCES repositories(id) ON DELETE CASCADE,
+    title         TEXT NOT NULL,
+    description   TEXT NOT NULL DEFAULT '',
+    created_at    TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now'))
+);
+CREATE INDEX idx_repo_ideas_repository ON repo_ideas(repository_id);`
+
+// ListRepoIdeas returns the ideas proposed for a repository, in the order
+// they were proposed.
+func (q *Queries) ListRepoIdeas(ctx context.Context, repositoryID int64) ([]models.RepoIdea, error) {
+	rows, err := q.db.QueryContext(ctx,
+		`SELECT id, repository_id, title, description, created_at FROM repo_ideas
+		 WHERE repository_id = ? ORDER BY id`, repositoryID)
+	if err != nil {
+		return nil, fmt.Errorf("listing ideas: %w", err)
+	}
+	defer rows.Close()
+
+	var ideas []models.RepoIdea
+	for rows.Next() {
+		var i models.RepoIdea
+		var createdAt string
+		if err := rows.Scan(&i.ID, &i.RepositoryID, &i.Title, &i.Description, &createdAt); err != nil {
+			return nil, fmt.Errorf("scanning idea: %w", err)
+		}
+		if err := q.sealer.openAll(&i.Title, &i.Description); err != nil {
+			return nil, err
+		}
+		i.CreatedAt = parseTime(createdAt)
+		ideas = append(ideas, i)
+	}
+	return ideas, rows.Err()
+}
+
+// GetRepoIdea returns an idea of a repository.
+func (q *Queries) GetRepoIdea(ctx context.Context, repositoryID, id int64) (*models.RepoIdea, error) {
+	i := &models.RepoIdea{}
+	var createdAt string
+	err := q.db.QueryRowContext(ctx,
+		`SELECT id, repository_id, title, description, created_at FROM repo_ideas
+		 WHERE id = ? AND repository_id = ?`, id, repositoryID,
+	).Scan(&i.ID, &i.RepositoryID, &i.Title, &i.Description, &createdAt)
+	if err != nil {
+		return nil, fmt.Errorf("getting idea: %w", err)
+	}
+	if err := q.sealer.openAll(&i.Title, &i.Description); err != nil {
+		return nil, err
+	}
+	i.CreatedAt = parseTime(createdAt)
+	return i, nil
+}
+
+// ReplaceRepoIdeas swaps the ideas of a repository for a newly proposed set.
+func (q *Queries) ReplaceRepoIdeas(ctx context.Context, repositoryID int64, ideas []models.RepoIdea) error {
+	return q.InTx(ctx, func(tx *Queries) error {
+		if _, err := tx.db.ExecContext(ctx, `DELETE FROM repo_ideas WHERE repository_id = ?`, repositoryID); err != nil {
+			return fmt.Errorf("dropping ideas: %w", err)
+		}
+		for _, i := range ideas {
+			_, err := tx.db.ExecContext(ctx,
+				`INSERT INTO repo_ideas (repository_id, title, description) VALUES (?, ?, ?)`,
+				repositoryID, tx.sealer.seal(i.Title), tx.sealer.seal(i.Description))
+			if err != nil {
+				return fmt.Errorf("saving idea: %w", err)
+			}
+		}
+		return nil
+	})
+}
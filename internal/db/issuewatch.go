@@ -0,0 +1,157 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/esnunes/prompter/internal/models"
+)
+
+// issueWatchTables keep what was last seen on published issues and the
+// changes found since, which the notifications page lists.
+const issueWatchTables = `
+CREATE TABLE issue_watches (
+    prompt_request_id INTEGER PRIMARY KEY REFERENCES prompt_requests(id) ON DELETE CASCADE,
+    comment_count     INTEGER NOT NULL DEFAULT 0,
+    state             TEXT NOT NULL DEFAULT '',
+    labels            TEXT NOT NULL DEFAULT '',
+    checked_at        TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now'))
+);
+CREATE TABLE issue_notifications (
+    id                INTEGER PRIMARY KEY AUTOINCREMENT,
+    prompt_request_id INTEGER NOT NULL REFERENCES prompt_requests(id) ON DELETE CASCADE,
+    kind              TEXT NOT NULL,
+    author            TEXT NOT NULL DEFAULT '',
+    detail            TEXT NOT NULL DEFAULT '',
+    url               TEXT NOT NULL DEFAULT '',
+    created_at        TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+    read              INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX idx_issue_notifications_prompt_request ON issue_notifications(prompt_request_id);`
+
+// ListWatchedPromptRequests lists the published prompt requests whose issue
+// is watched for replies: those not archived.
+func (q *Queries) ListWatchedPromptRequests(ctx context.Context) ([]models.PromptRequest, error) {
+	rows, err := q.db.QueryContext(ctx,
+		listPromptRequestsQuery+` AND pr.status = 'published' AND pr.issue_number IS NOT NULL AND pr.archived = 0
+		 ORDER BY pr.id`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("listing watched prompt requests: %w", err)
+	}
+	defer rows.Close()
+
+	var results []models.PromptRequest
+	for rows.Next() {
+		pr, err := q.scanPromptRequest(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning prompt request: %w", err)
+		}
+		results = append(results, pr)
+	}
+	return results, rows.Err()
+}
+
+// GetIssueWatch returns what was last seen on a prompt request's issue, or
+// nil if it was never checked.
+func (q *Queries) GetIssueWatch(ctx context.Context, promptRequestID int64) (*models.IssueWatch, error) {
+	w := &models.IssueWatch{PromptRequestID: promptRequestID}
+	var labels, checkedAt string
+	err := q.db.QueryRowContext(ctx,
+		`SELECT comment_count, state, labels, checked_at FROM issue_watches WHERE prompt_request_id = ?`, promptRequestID,
+	).Scan(&w.CommentCount, &w.State, &labels, &checkedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting issue watch: %w", err)
+	}
+	w.Labels = splitList(labels)
+	w.CheckedAt = parseTime(checkedAt)
+	return w, nil
+}
+
+// SaveIssueWatch records what was seen on a prompt request's issue, with the
+// notifications for what changed since the last check, in one transaction.
+func (q *Queries) SaveIssueWatch(ctx context.Context, w models.IssueWatch, notifications []models.IssueNotification) error {
+	tx, err := q.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO issue_watches (prompt_request_id, comment_count, state, labels) VALUES (?, ?, ?, ?)
+		 ON CONFLICT(prompt_request_id) DO UPDATE SET
+		   comment_count = excluded.comment_count, state = excluded.state, labels = excluded.labels,
+		   checked_at = strftime('%Y-%m-%dT%H:%M:%SZ', 'now')`,
+		w.PromptRequestID, w.CommentCount, w.State, joinList(w.Labels),
+	)
+	if err != nil {
+		return fmt.Errorf("saving issue watch: %w", err)
+	}
+	for _, n := range notifications {
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO issue_notifications (prompt_request_id, kind, author, detail, url) VALUES (?, ?, ?, ?, ?)`,
+			w.PromptRequestID, n.Kind, n.Author, n.Detail, n.URL,
+		)
+		if err != nil {
+			return fmt.Errorf("saving issue notification: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// ListIssueNotifications lists the newest notifications first.
+func (q *Queries) ListIssueNotifications(ctx context.Context, limit int) ([]models.IssueNotification, error) {
+	rows, err := q.db.QueryContext(ctx,
+		`SELECT n.id, n.prompt_request_id, n.kind, n.author, n.detail, n.url, n.created_at, n.read, pr.title, r.url
+		 FROM issue_notifications n
+		 JOIN prompt_requests pr ON pr.id = n.prompt_request_id
+		 JOIN repositories r ON r.id = pr.repository_id
+		 WHERE pr.status != 'deleted'
+		 ORDER BY n.id DESC LIMIT ?`, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("listing issue notifications: %w", err)
+	}
+	defer rows.Close()
+
+	var results []models.IssueNotification
+	for rows.Next() {
+		var n models.IssueNotification
+		var createdAt string
+		if err := rows.Scan(&n.ID, &n.PromptRequestID, &n.Kind, &n.Author, &n.Detail, &n.URL, &createdAt, &n.Read,
+			&n.Title, &n.RepoURL); err != nil {
+			return nil, fmt.Errorf("scanning issue notification: %w", err)
+		}
+		if n.Title, err = q.sealer.open(n.Title); err != nil {
+			return nil, err
+		}
+		n.CreatedAt = parseTime(createdAt)
+		results = append(results, n)
+	}
+	return results, rows.Err()
+}
+
+// CountUnreadIssueNotifications counts the notifications not yet seen on the
+// notifications page.
+func (q *Queries) CountUnreadIssueNotifications(ctx context.Context) (int, error) {
+	var n int
+	err := q.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM issue_notifications n JOIN prompt_requests pr ON pr.id = n.prompt_request_id
+		 WHERE n.read = 0 AND pr.status != 'deleted'`,
+	).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("counting issue notifications: %w", err)
+	}
+	return n, nil
+}
+
+// MarkIssueNotificationsRead marks every notification seen.
+func (q *Queries) MarkIssueNotificationsRead(ctx context.Context) error {
+	_, err := q.db.ExecContext(ctx, `UPDATE issue_notifications SET read = 1 WHERE read = 0`)
+	return err
+}
@@ -0,0 +1,212 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// IntegrityCheck runs PRAGMA integrity_check and returns the problems it
+// reports, or nil if the database is sound.
+func IntegrityCheck(db *sql.DB) ([]string, error) {
+	rows, err := db.Query(`PRAGMA integrity_check`)
+	if err != nil {
+		return nil, fmt.Errorf("checking integrity: %w", err)
+	}
+	defer rows.Close()
+
+	var problems []string
+	for rows.Next() {
+		var result string
+		if err := rows.Scan(&result); err != nil {
+			return nil, fmt.Errorf("checking integrity: %w", err)
+		}
+		if result != "ok" {
+			problems = append(problems, result)
+		}
+	}
+	return problems, rows.Err()
+}
+
+// Orphan is a row whose foreign key points at a row that no longer exists,
+// such as a message whose prompt request was purged while foreign keys
+// weren't enforced.
+type Orphan struct {
+	Table  string
+	RowID  int64
+	Column string
+	Parent string
+	// Nullable orphans are repaired by clearing Column; others are deleted.
+	Nullable bool
+}
+
+// FindOrphans lists the rows violating a foreign key.
+func FindOrphans(db *sql.DB) ([]Orphan, error) {
+	return findOrphans(context.Background(), db)
+}
+
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+func findOrphans(ctx context.Context, q querier) ([]Orphan, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT c."table", c.rowid, c.parent, fk."from", ti."notnull" OR ti.pk > 0
+		FROM pragma_foreign_key_check AS c
+		JOIN pragma_foreign_key_list(c."table") AS fk ON fk.id = c.fkid
+		JOIN pragma_table_info(c."table") AS ti ON ti.name = fk."from"
+		WHERE c.rowid IS NOT NULL
+		ORDER BY c."table", c.rowid`)
+	if err != nil {
+		return nil, fmt.Errorf("checking foreign keys: %w", err)
+	}
+	defer rows.Close()
+
+	var orphans []Orphan
+	for rows.Next() {
+		var o Orphan
+		var required bool
+		if err := rows.Scan(&o.Table, &o.RowID, &o.Parent, &o.Column, &required); err != nil {
+			return nil, fmt.Errorf("checking foreign keys: %w", err)
+		}
+		o.Nullable = !required
+		orphans = append(orphans, o)
+	}
+	return orphans, rows.Err()
+}
+
+// RepairOrphans clears or deletes orphaned rows until no foreign key is
+// violated, and returns what it repaired. Deleting an orphan can orphan its
+// own children (the questions of a deleted message), which are picked up on
+// the next pass; foreign keys are off meanwhile so the deletes don't fail on
+// them.
+func RepairOrphans(db *sql.DB) ([]Orphan, error) {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, `PRAGMA foreign_keys = OFF`); err != nil {
+		return nil, fmt.Errorf("disabling foreign keys: %w", err)
+	}
+	defer conn.ExecContext(ctx, `PRAGMA foreign_keys = ON`)
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var repaired []Orphan
+	for {
+		orphans, err := findOrphans(ctx, tx)
+		if err != nil {
+			return nil, err
+		}
+		if len(orphans) == 0 {
+			break
+		}
+		// Rows that are deleted anyway don't need a reference cleared.
+		deleted := map[Orphan]bool{}
+		for _, o := range orphans {
+			if !o.Nullable {
+				deleted[Orphan{Table: o.Table, RowID: o.RowID}] = true
+			}
+		}
+		for _, o := range orphans {
+			q := fmt.Sprintf(`DELETE FROM %q WHERE rowid = ?`, o.Table)
+			if o.Nullable {
+				if deleted[Orphan{Table: o.Table, RowID: o.RowID}] {
+					continue
+				}
+				q = fmt.Sprintf(`UPDATE %q SET %q = NULL WHERE rowid = ?`, o.Table, o.Column)
+			}
+			if _, err := tx.ExecContext(ctx, q, o.RowID); err != nil {
+				return nil, fmt.Errorf("repairing %s row %d: %w", o.Table, o.RowID, err)
+			}
+			repaired = append(repaired, o)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return repaired, nil
+}
+
+// OrphanedFiles lists the files under dir, the attachments directory, that no
+// attachment refers to, such as those of prompt requests purged before
+// their files were removed.
+func OrphanedFiles(db *sql.DB, dir string) ([]string, error) {
+	rows, err := db.Query(`SELECT path FROM attachments`)
+	if err != nil {
+		return nil, fmt.Errorf("listing attachments: %w", err)
+	}
+	defer rows.Close()
+	known := map[string]bool{}
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			return nil, fmt.Errorf("listing attachments: %w", err)
+		}
+		known[filepath.Clean(path)] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var orphans []string
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && !known[path] {
+			orphans = append(orphans, path)
+		}
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("listing attachment files: %w", err)
+	}
+	return orphans, nil
+}
+
+// RemoveFiles deletes files, and the directories they leave empty.
+func RemoveFiles(files []string) error {
+	for _, f := range files {
+		if err := os.Remove(f); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		os.Remove(filepath.Dir(f)) // fails unless empty
+	}
+	return nil
+}
+
+// Vacuum rebuilds the database file, reclaiming the space left by deleted
+// rows.
+func Vacuum(db *sql.DB) error {
+	if _, err := db.Exec(`VACUUM`); err != nil {
+		return fmt.Errorf("vacuuming: %w", err)
+	}
+	return nil
+}
+
+// Checkpoint copies the write-ahead log into the database file and truncates
+// it. It returns the number of WAL frames checkpointed.
+func Checkpoint(db *sql.DB) (int, error) {
+	var busy, logFrames, checkpointed int
+	if err := db.QueryRow(`PRAGMA wal_checkpoint(TRUNCATE)`).Scan(&busy, &logFrames, &checkpointed); err != nil {
+		return 0, fmt.Errorf("checkpointing WAL: %w", err)
+	}
+	if busy != 0 {
+		return checkpointed, fmt.Errorf("checkpointing WAL: database is busy; is the server running?")
+	}
+	return checkpointed, nil
+}
@@ -0,0 +1,100 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ExportMarkdown renders a prompt request as a Markdown transcript for
+// archiving or pasting elsewhere: its messages, the questions the AI asked
+// with the answers chosen, and every published revision.
+func (q *Queries) ExportMarkdown(ctx context.Context, id int64) (string, error) {
+	pr, err := q.GetPromptRequest(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	msgs, err := q.ListMessages(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	revs, err := q.ListRevisions(ctx, id)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	title := pr.Title
+	if title == "" {
+		title = "Untitled"
+	}
+	fmt.Fprintf(&b, "# %s\n\n", title)
+	fmt.Fprintf(&b, "- Repository: %s\n", pr.RepoURL)
+	fmt.Fprintf(&b, "- Status: %s\n", pr.Status)
+	if pr.IssueURL != nil {
+		fmt.Fprintf(&b, "- Issue: %s\n", *pr.IssueURL)
+	}
+	fmt.Fprintf(&b, "- Started: %s\n", markdownTime(pr.CreatedAt))
+
+	b.WriteString("\n## Conversation\n")
+	for _, m := range msgs {
+		role := "You"
+		if m.Role == "assistant" {
+			role = "Assistant"
+		}
+		fmt.Fprintf(&b, "\n### %s — %s\n\n%s\n", role, markdownTime(m.CreatedAt), strings.TrimSpace(m.Content))
+		if m.Role != "assistant" {
+			continue
+		}
+		questions, err := q.ListQuestions(ctx, m.ID)
+		if err != nil {
+			return "", err
+		}
+		for _, qu := range questions {
+			b.WriteString("\n")
+			if qu.Header != "" {
+				fmt.Fprintf(&b, "**%s:** ", qu.Header)
+			}
+			fmt.Fprintf(&b, "%s\n\n", qu.Text)
+			for _, o := range qu.Options {
+				if o.Description != "" {
+					fmt.Fprintf(&b, "- %s — %s\n", o.Label, o.Description)
+				} else {
+					fmt.Fprintf(&b, "- %s\n", o.Label)
+				}
+			}
+			if qu.Answer != "" {
+				fmt.Fprintf(&b, "\n> Answer: %s\n", strings.ReplaceAll(qu.Answer, "\n", "\n> "))
+			}
+		}
+	}
+
+	if len(revs) > 0 {
+		b.WriteString("\n## Revisions\n")
+		for i, r := range revs {
+			fence := markdownFence(r.Content)
+			fmt.Fprintf(&b, "\n### Revision %d — %s\n\n%smarkdown\n%s\n%s\n", i+1, markdownTime(r.PublishedAt), fence, strings.TrimSpace(r.Content), fence)
+		}
+	}
+	return b.String(), nil
+}
+
+func markdownTime(t time.Time) string {
+	return t.UTC().Format("Jan 2, 2006 15:04 UTC")
+}
+
+// markdownFence returns a code fence longer than any run of backticks in s,
+// so s can't close it.
+func markdownFence(s string) string {
+	longest, run := 0, 0
+	for _, c := range s {
+		if c == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	return strings.Repeat("`", max(3, longest+1))
+}
@@ -0,0 +1,323 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/esnunes/prompter/internal/claude"
+)
+
+// migration is one numbered schema change. Pending migrations run in order,
+// each in its own transaction, and are recorded in schema_version. Append new
+// migrations at the end; never edit or renumber one that has shipped.
+type migration struct {
+	version     int
+	description string
+	up          func(tx *sql.Tx) error
+}
+
+// migrations brings any database up to the current schema. Databases created
+// before versioning existed replay them all: the base schema and the search
+// index use IF NOT EXISTS and addColumn skips columns that are already there.
+var migrations = []migration{
+	{1, "base schema", execSQL(schema)},
+	{2, "revisions.after_message_id for inline revision markers",
+		addColumn("revisions", "after_message_id", "INTEGER REFERENCES messages(id)")},
+	{3, "prompt_requests.last_viewed_at for unread tracking",
+		addColumn("prompt_requests", "last_viewed_at", "TEXT")},
+	{4, "prompt_requests.archived",
+		addColumn("prompt_requests", "archived", "INTEGER NOT NULL DEFAULT 0")},
+	{5, "prompt_requests.title_edited so renamed titles aren't overwritten",
+		addColumn("prompt_requests", "title_edited", "INTEGER NOT NULL DEFAULT 0")},
+	// fork_message_id is the last message copied from the source; earlier
+	// messages aren't part of the fork's Claude session.
+	{6, "fork tracking", steps(
+		addColumn("prompt_requests", "forked_from_id", "INTEGER REFERENCES prompt_requests(id)"),
+		addColumn("prompt_requests", "fork_message_id", "INTEGER"),
+	)},
+	{7, "messages.merged_from_id for messages copied in by a merge",
+		addColumn("messages", "merged_from_id", "INTEGER REFERENCES prompt_requests(id)")},
+	{8, "prompt_requests.pinned for the dashboard's Pinned section",
+		addColumn("prompt_requests", "pinned", "INTEGER NOT NULL DEFAULT 0")},
+	{9, "prompt_requests.auto_archived_at for the retention policy's undo",
+		addColumn("prompt_requests", "auto_archived_at", "TEXT")},
+	{10, "prompt_requests.notes",
+		addColumn("prompt_requests", "notes", "TEXT NOT NULL DEFAULT ''")},
+	{11, "messages.superseded for edited messages",
+		addColumn("messages", "superseded", "INTEGER NOT NULL DEFAULT 0")},
+	{12, "running conversation summary", steps(
+		addColumn("prompt_requests", "summary", "TEXT NOT NULL DEFAULT ''"),
+		addColumn("prompt_requests", "summary_message_id", "INTEGER"),
+	)},
+	{13, "prompt requests imported from an existing issue", steps(
+		addColumn("prompt_requests", "source_issue_number", "INTEGER"),
+		addColumn("prompt_requests", "publish_target", "TEXT NOT NULL DEFAULT ''"),
+	)},
+	{14, "revisions.source_message_id for the generated prompt each revision published",
+		addColumn("revisions", "source_message_id", "INTEGER REFERENCES messages(id)")},
+	{15, "prompt_requests.exported_at for bodies copied out instead of published",
+		addColumn("prompt_requests", "exported_at", "TEXT")},
+	// A NULL prefix and an empty template fall back to the global settings.
+	{16, "per-repository issue format", steps(
+		addColumn("repositories", "issue_title_prefix", "TEXT"),
+		addColumn("repositories", "issue_body_template", "TEXT NOT NULL DEFAULT ''"),
+	)},
+	{17, "prompt_requests.include_transcript",
+		addColumn("prompt_requests", "include_transcript", "INTEGER NOT NULL DEFAULT 0")},
+	{18, "full-text search index", steps(execSQL(searchSchema), execSQL(backfillSearchIndex))},
+	{19, "questions, answers and generated content in their own tables", steps(
+		addColumn("messages", "prompt_ready", "INTEGER NOT NULL DEFAULT 0"),
+		migrateResponses,
+	)},
+	{20, "RFC 3339 timestamps", migrateTimestamps},
+	// The session a prompt request was first created with, kept across
+	// imports so archives moved back and forth match up.
+	{21, "prompt_requests.origin_session_id for archive imports",
+		addColumn("prompt_requests", "origin_session_id", "TEXT")},
+	{22, "encryption at rest", steps(execSQL(encryptionTable), execSQL(searchEncryptedMessages))},
+	{23, "audit log", execSQL(auditTable)},
+	{24, "cascading deletes", migrateCascades},
+	// issue_template_sent is the template the Claude session was last told
+	// about, so a change is passed on with the next message.
+	{25, "issue templates", steps(
+		addColumn("prompt_requests", "issue_template", "TEXT NOT NULL DEFAULT ''"),
+		addColumn("prompt_requests", "issue_template_sent", "TEXT NOT NULL DEFAULT ''"),
+	)},
+	// Both hold newline-separated label names. Suggested labels are applied
+	// on publish unless the user dismissed them.
+	{26, "labels suggested by Claude", steps(
+		addColumn("generated_contents", "suggested_labels", "TEXT NOT NULL DEFAULT ''"),
+		addColumn("prompt_requests", "dismissed_labels", "TEXT NOT NULL DEFAULT ''"),
+	)},
+	{27, "estimated size of generated prompts", steps(
+		addColumn("generated_contents", "estimated_size", "TEXT NOT NULL DEFAULT ''"),
+		addColumn("generated_contents", "size_rationale", "TEXT NOT NULL DEFAULT ''"),
+	)},
+	{28, "related issues", steps(
+		execSQL(relatedIssuesTable),
+		addColumn("prompt_requests", "link_related_issues", "INTEGER NOT NULL DEFAULT 0"),
+	)},
+	{29, "repository metadata", steps(
+		addColumn("repositories", "description", "TEXT NOT NULL DEFAULT ''"),
+		addColumn("repositories", "stars", "INTEGER NOT NULL DEFAULT 0"),
+		addColumn("repositories", "language", "TEXT NOT NULL DEFAULT ''"),
+		addColumn("repositories", "license", "TEXT NOT NULL DEFAULT ''"),
+		addColumn("repositories", "open_issues", "INTEGER NOT NULL DEFAULT 0"),
+		addColumn("repositories", "metadata_fetched_at", "TEXT"),
+	)},
+	{30, "prompt_requests.issue_activity_at for unread tracking",
+		addColumn("prompt_requests", "issue_activity_at", "TEXT")},
+	{31, "preferences", execSQL(preferencesTable)},
+	{32, "issue watch", execSQL(issueWatchTables)},
+	{33, "drafts", execSQL(draftsTable)},
+	{34, "prompt_requests conversation and output languages", steps(
+		addColumn("prompt_requests", "conversation_language", "TEXT NOT NULL DEFAULT ''"),
+		addColumn("prompt_requests", "output_language", "TEXT NOT NULL DEFAULT ''"),
+	)},
+	{35, "share links", execSQL(shareLinksTable)},
+	{36, "messages duration and cost", steps(
+		addColumn("messages", "duration_ms", "INTEGER NOT NULL DEFAULT 0"),
+		addColumn("messages", "cost_usd", "REAL NOT NULL DEFAULT 0"),
+	)},
+	{37, "dashboard listing indexes", execSQL(listingIndexes)},
+	{38, "repositories.pulled_at for pull throttling",
+		addColumn("repositories", "pulled_at", "TEXT")},
+	{39, "prompt_requests.prewarmed for sessions started ahead of the first message",
+		addColumn("prompt_requests", "prewarmed", "INTEGER NOT NULL DEFAULT 0")},
+	{40, "prompt_requests.question_mode",
+		addColumn("prompt_requests", "question_mode", "TEXT NOT NULL DEFAULT ''")},
+	{41, "repository feature ideas", execSQL(repoIdeasTable)},
+	{42, "prompt_requests.kind for bug reports",
+		addColumn("prompt_requests", "kind", "TEXT NOT NULL DEFAULT ''")},
+	{43, "personas", steps(
+		execSQL(personasTable),
+		addColumn("prompt_requests", "persona_id", "INTEGER REFERENCES personas(id) ON DELETE SET NULL"),
+	)},
+	// Newline-separated, like suggested_labels.
+	{44, "generated_contents.non_goals",
+		addColumn("generated_contents", "non_goals", "TEXT NOT NULL DEFAULT ''")},
+	{45, "generated_contents.alternatives",
+		addColumn("generated_contents", "alternatives", "TEXT NOT NULL DEFAULT ''")},
+	// A NULL prompt_requests.include_affected_areas follows the repository's.
+	{46, "areas likely affected", steps(
+		addColumn("generated_contents", "affected_areas", "TEXT NOT NULL DEFAULT ''"),
+		addColumn("repositories", "include_affected_areas", "INTEGER NOT NULL DEFAULT 0"),
+		addColumn("prompt_requests", "include_affected_areas", "INTEGER"),
+	)},
+	{47, "clarity of generated prompts", steps(
+		addColumn("generated_contents", "clarity_score", "INTEGER NOT NULL DEFAULT 0"),
+		addColumn("generated_contents", "ambiguities", "TEXT NOT NULL DEFAULT ''"),
+	)},
+	{48, "prompt quality checks", execSQL(promptChecksTable)},
+	{49, "generated_contents.glossary",
+		addColumn("generated_contents", "glossary", "TEXT NOT NULL DEFAULT ''")},
+	{50, "repositories.output_format",
+		addColumn("repositories", "output_format", "TEXT NOT NULL DEFAULT ''")},
+	{51, "test plans", steps(
+		addColumn("generated_contents", "test_plan", "TEXT NOT NULL DEFAULT ''"),
+		addColumn("repositories", "propose_test_plan", "INTEGER NOT NULL DEFAULT 0"),
+	)},
+	{52, "prompt_requests.gist_url",
+		addColumn("prompt_requests", "gist_url", "TEXT")},
+	{53, "repositories.project",
+		addColumn("repositories", "project", "TEXT NOT NULL DEFAULT ''")},
+	{54, "repositories.issue_labels",
+		addColumn("repositories", "issue_labels", "TEXT")},
+	{55, "maintainer mentions", steps(
+		addColumn("generated_contents", "affected_paths", "TEXT NOT NULL DEFAULT ''"),
+		addColumn("prompt_requests", "mentions", "TEXT NOT NULL DEFAULT ''"),
+	)},
+	{56, "keep encrypted revisions out of the search index", execSQL(searchEncryptedRevisions)},
+	// Migrations 57 and on fill columns added since migration 19 from the
+	// replies stored before them; databases upgraded while those columns
+	// were added without a backfill have them empty.
+	{57, "backfill suggested labels of stored replies",
+		backfillGenerated("suggested_labels", func(r *claude.Response) any { return joinList(r.SuggestedLabels) })},
+	{58, "backfill estimated sizes of stored replies", steps(
+		backfillGenerated("estimated_size", func(r *claude.Response) any { return r.EstimatedSize }),
+		backfillGenerated("size_rationale", func(r *claude.Response) any { return r.SizeRationale }),
+	)},
+	{59, "backfill related issues of stored replies", backfillRelatedIssues},
+	{60, "backfill non-goals of stored replies",
+		backfillGenerated("non_goals", func(r *claude.Response) any { return joinList(r.NonGoals) })},
+	{61, "backfill alternatives of stored replies",
+		backfillGenerated("alternatives", func(r *claude.Response) any { return joinList(r.Alternatives) })},
+	{62, "backfill affected areas of stored replies",
+		backfillGenerated("affected_areas", func(r *claude.Response) any { return joinList(r.AffectedAreas) })},
+	{63, "backfill clarity of stored replies", steps(
+		backfillGenerated("clarity_score", func(r *claude.Response) any { return r.ClarityScore }),
+		backfillGenerated("ambiguities", func(r *claude.Response) any { return joinList(r.Ambiguities) }),
+	)},
+	{64, "backfill glossaries of stored replies",
+		backfillGenerated("glossary", func(r *claude.Response) any { return joinGlossary(r.Glossary) })},
+	{65, "backfill test plans of stored replies",
+		backfillGenerated("test_plan", func(r *claude.Response) any { return joinList(r.TestPlan) })},
+	{66, "backfill affected paths of stored replies",
+		backfillGenerated("affected_paths", func(r *claude.Response) any { return joinList(r.AffectedPaths) })},
+	{67, "keep encrypted titles out of the search index", execSQL(searchEncryptedTitles)},
+}
+
+// listingIndexes cover the dashboard's message and revision aggregates and
+// its ordering, so listing reads neither table's rows.
+const listingIndexes = `
+CREATE INDEX IF NOT EXISTS idx_messages_listing ON messages(superseded, prompt_request_id, role, created_at);
+CREATE INDEX IF NOT EXISTS idx_revisions_listing ON revisions(prompt_request_id, published_at);
+CREATE INDEX IF NOT EXISTS idx_prompt_requests_listing ON prompt_requests(archived, updated_at);`
+
+const schemaVersionTable = `
+CREATE TABLE IF NOT EXISTS schema_version (
+    version     INTEGER PRIMARY KEY,
+    description TEXT NOT NULL,
+    applied_at  TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now'))
+)`
+
+func execSQL(query string) func(*sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		_, err := tx.Exec(query)
+		return err
+	}
+}
+
+// addColumn adds a column unless the table already has it; SQLite's ALTER
+// TABLE has no IF NOT EXISTS.
+func addColumn(table, column, definition string) func(*sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		var n int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, table, column).Scan(&n); err != nil {
+			return err
+		}
+		if n > 0 {
+			return nil
+		}
+		_, err := tx.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, column, definition))
+		return err
+	}
+}
+
+func steps(fns ...func(*sql.Tx) error) func(*sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		for _, fn := range fns {
+			if err := fn(tx); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// MigrationStatus describes a migration; AppliedAt is nil while it is pending.
+type MigrationStatus struct {
+	Version     int
+	Description string
+	AppliedAt   *time.Time
+}
+
+// MigrationStatuses lists every known migration and when it was applied.
+func MigrationStatuses(db *sql.DB) ([]MigrationStatus, error) {
+	if _, err := db.Exec(schemaVersionTable); err != nil {
+		return nil, fmt.Errorf("creating schema_version: %w", err)
+	}
+	rows, err := db.Query(`SELECT version, applied_at FROM schema_version`)
+	if err != nil {
+		return nil, fmt.Errorf("reading schema_version: %w", err)
+	}
+	defer rows.Close()
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt string
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("scanning schema_version: %w", err)
+		}
+		applied[version] = parseTime(appliedAt)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, len(migrations))
+	for i, m := range migrations {
+		statuses[i] = MigrationStatus{Version: m.version, Description: m.description}
+		if t, ok := applied[m.version]; ok {
+			statuses[i].AppliedAt = &t
+		}
+	}
+	return statuses, nil
+}
+
+// Migrate applies pending migrations in order and returns the ones it applied.
+// A failing migration is rolled back and stops the run.
+func Migrate(db *sql.DB) ([]MigrationStatus, error) {
+	statuses, err := MigrationStatuses(db)
+	if err != nil {
+		return nil, err
+	}
+	var done []MigrationStatus
+	for i, m := range migrations {
+		if statuses[i].AppliedAt != nil {
+			continue
+		}
+		if err := applyMigration(db, m); err != nil {
+			return done, fmt.Errorf("migration %d (%s): %w", m.version, m.description, err)
+		}
+		now := time.Now().UTC()
+		done = append(done, MigrationStatus{Version: m.version, Description: m.description, AppliedAt: &now})
+	}
+	return done, nil
+}
+
+func applyMigration(db *sql.DB, m migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := m.up(tx); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`INSERT INTO schema_version (version, description) VALUES (?, ?)`, m.version, m.description); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
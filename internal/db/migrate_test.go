@@ -0,0 +1,276 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// baselineSchema is the schema databases had before numbered migrations,
+// including the columns the old Open added with ALTER TABLE.
+const baselineSchema = `
+CREATE TABLE repositories (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    url         TEXT NOT NULL UNIQUE,
+    local_path  TEXT NOT NULL,
+    created_at  TEXT NOT NULL DEFAULT (datetime('now')),
+    updated_at  TEXT NOT NULL DEFAULT (datetime('now'))
+);
+
+CREATE TABLE prompt_requests (
+    id              INTEGER PRIMARY KEY AUTOINCREMENT,
+    repository_id   INTEGER NOT NULL REFERENCES repositories(id),
+    title           TEXT NOT NULL DEFAULT '',
+    status          TEXT NOT NULL DEFAULT 'draft' CHECK (status IN ('draft', 'published', 'deleted')),
+    session_id      TEXT NOT NULL,
+    issue_number    INTEGER,
+    issue_url       TEXT,
+    created_at      TEXT NOT NULL DEFAULT (datetime('now')),
+    updated_at      TEXT NOT NULL DEFAULT (datetime('now'))
+);
+
+CREATE TABLE messages (
+    id                INTEGER PRIMARY KEY AUTOINCREMENT,
+    prompt_request_id INTEGER NOT NULL REFERENCES prompt_requests(id),
+    role              TEXT NOT NULL CHECK (role IN ('user', 'assistant')),
+    content           TEXT NOT NULL,
+    raw_response      TEXT,
+    created_at        TEXT NOT NULL DEFAULT (datetime('now'))
+);
+
+CREATE TABLE revisions (
+    id                INTEGER PRIMARY KEY AUTOINCREMENT,
+    prompt_request_id INTEGER NOT NULL REFERENCES prompt_requests(id),
+    content           TEXT NOT NULL,
+    published_at      TEXT NOT NULL DEFAULT (datetime('now'))
+);
+
+CREATE INDEX idx_prompt_requests_repository ON prompt_requests(repository_id);
+CREATE INDEX idx_prompt_requests_status ON prompt_requests(status);
+CREATE INDEX idx_messages_prompt_request ON messages(prompt_request_id);
+CREATE INDEX idx_revisions_prompt_request ON revisions(prompt_request_id);
+
+ALTER TABLE revisions ADD COLUMN after_message_id INTEGER REFERENCES messages(id);
+ALTER TABLE prompt_requests ADD COLUMN last_viewed_at TEXT;
+ALTER TABLE prompt_requests ADD COLUMN archived INTEGER NOT NULL DEFAULT 0;
+`
+
+const baselineRows = `
+INSERT INTO repositories (url, local_path) VALUES ('github.com/a/b', '/tmp/a/b');
+INSERT INTO prompt_requests (repository_id, title, status, session_id, issue_number, issue_url)
+VALUES (1, 'Dark mode', 'published', 'sess-1', 7, 'https://github.com/a/b/issues/7');
+INSERT INTO messages (prompt_request_id, role, content) VALUES (1, 'user', 'Add dark mode');
+INSERT INTO messages (prompt_request_id, role, content, raw_response) VALUES (1, 'assistant', 'Which pages?',
+  '{"structured_output":{"message":"Which pages?","questions":[{"header":"Scope","text":"Which pages?","options":[{"label":"All","description":"Every page"},{"label":"Settings"}]}]}}');
+INSERT INTO messages (prompt_request_id, role, content) VALUES (1, 'user', 'All');
+INSERT INTO messages (prompt_request_id, role, content, raw_response) VALUES (1, 'assistant', 'Ready',
+  '{"structured_output":{"message":"Ready","prompt_ready":true,"generated_title":"Dark mode","generated_motivation":"Eyes hurt","generated_prompt":"Add a dark theme",
+    "suggested_labels":["enhancement"],"estimated_size":"M","size_rationale":"Touches every page",
+    "related_issues":[{"number":3,"title":"Theme support"}],
+    "non_goals":["High contrast"],"alternatives_considered":["Browser extension"],"affected_areas":["UI"],
+    "clarity_score":4,"ambiguities":["Default theme"],"glossary":[{"term":"Theme","definition":"Color scheme"}],
+    "test_plan":["Toggle the theme"],"affected_paths":["web/static"]}}');
+INSERT INTO revisions (prompt_request_id, content, after_message_id) VALUES (1, 'Add a dark theme', 4);
+`
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	database, err := OpenUnmigrated(filepath.Join(t.TempDir(), "prompter.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+	return database
+}
+
+func TestMigrate_FromBaseline(t *testing.T) {
+	ctx := context.Background()
+	database := openTestDB(t)
+	if _, err := database.Exec(baselineSchema + baselineRows); err != nil {
+		t.Fatalf("creating baseline database: %v", err)
+	}
+
+	applied, err := Migrate(database)
+	if err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	if len(applied) != len(migrations) {
+		t.Errorf("applied %d migrations, want %d", len(applied), len(migrations))
+	}
+
+	q := NewQueries(database)
+	pr, err := q.GetPromptRequest(ctx, 1)
+	if err != nil {
+		t.Fatalf("GetPromptRequest: %v", err)
+	}
+	if pr.Title != "Dark mode" || pr.IssueNumber == nil || *pr.IssueNumber != 7 {
+		t.Errorf("prompt request = %+v", pr)
+	}
+
+	msgs, err := q.ListMessages(ctx, 1)
+	if err != nil {
+		t.Fatalf("ListMessages: %v", err)
+	}
+	if len(msgs) != 4 {
+		t.Fatalf("got %d messages, want 4", len(msgs))
+	}
+	questions, err := q.ListQuestions(ctx, 2)
+	if err != nil {
+		t.Fatalf("ListQuestions: %v", err)
+	}
+	if len(questions) != 1 || len(questions[0].Options) != 2 || questions[0].Answer != "All" {
+		t.Errorf("questions = %+v", questions)
+	}
+
+	gc, err := q.GetLatestGeneratedContent(ctx, 1)
+	if err != nil {
+		t.Fatalf("GetLatestGeneratedContent: %v", err)
+	}
+	if gc.Title != "Dark mode" || gc.Motivation != "Eyes hurt" || gc.Prompt != "Add a dark theme" {
+		t.Errorf("generated content = %+v", gc)
+	}
+	checks := []struct {
+		name      string
+		got, want []string
+	}{
+		{"labels", gc.Labels, []string{"enhancement"}},
+		{"non-goals", gc.NonGoals, []string{"High contrast"}},
+		{"alternatives", gc.Alternatives, []string{"Browser extension"}},
+		{"areas", gc.Areas, []string{"UI"}},
+		{"ambiguities", gc.Ambiguities, []string{"Default theme"}},
+		{"test plan", gc.TestPlan, []string{"Toggle the theme"}},
+		{"paths", gc.Paths, []string{"web/static"}},
+	}
+	for _, c := range checks {
+		if !slices.Equal(c.got, c.want) {
+			t.Errorf("%s = %q, want %q", c.name, c.got, c.want)
+		}
+	}
+	if gc.Size != "M" || gc.SizeReason != "Touches every page" {
+		t.Errorf("size = %q (%q)", gc.Size, gc.SizeReason)
+	}
+	if gc.Clarity != 4 {
+		t.Errorf("clarity = %d, want 4", gc.Clarity)
+	}
+	if len(gc.Glossary) != 1 || gc.Glossary[0].Term != "Theme" || gc.Glossary[0].Definition != "Color scheme" {
+		t.Errorf("glossary = %+v", gc.Glossary)
+	}
+	related, err := q.ListRelatedIssues(ctx, 1)
+	if err != nil {
+		t.Fatalf("ListRelatedIssues: %v", err)
+	}
+	if len(related) != 1 || related[0].Number != 3 {
+		t.Errorf("related issues = %+v", related)
+	}
+}
+
+func TestMigrate_SecondRunDoesNothing(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		setup string
+	}{
+		{"new database", ""},
+		{"baseline database", baselineSchema + baselineRows},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			database := openTestDB(t)
+			if tt.setup != "" {
+				if _, err := database.Exec(tt.setup); err != nil {
+					t.Fatalf("creating baseline database: %v", err)
+				}
+			}
+			if _, err := Migrate(database); err != nil {
+				t.Fatalf("first Migrate: %v", err)
+			}
+			applied, err := Migrate(database)
+			if err != nil {
+				t.Fatalf("second Migrate: %v", err)
+			}
+			if len(applied) != 0 {
+				t.Errorf("second Migrate applied %d migrations, want none", len(applied))
+			}
+			statuses, err := MigrationStatuses(database)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, s := range statuses {
+				if s.AppliedAt == nil {
+					t.Errorf("migration %d (%s) is still pending", s.Version, s.Description)
+				}
+			}
+		})
+	}
+}
+
+// TestMigrate_BackfillsEarlierUpgrades covers databases that added the
+// columns generated content gained after migration 19 while those
+// migrations didn't backfill them yet.
+func TestMigrate_BackfillsEarlierUpgrades(t *testing.T) {
+	ctx := context.Background()
+	database := openTestDB(t)
+	if _, err := database.Exec(baselineSchema + baselineRows); err != nil {
+		t.Fatalf("creating baseline database: %v", err)
+	}
+	if _, err := Migrate(database); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	_, err := database.Exec(`
+UPDATE generated_contents SET suggested_labels = '';
+UPDATE generated_contents SET estimated_size = '', size_rationale = '';
+DELETE FROM related_issues;
+UPDATE generated_contents SET non_goals = '';
+UPDATE generated_contents SET alternatives = '';
+UPDATE generated_contents SET affected_areas = '';
+UPDATE generated_contents SET clarity_score = 0, ambiguities = '';
+UPDATE generated_contents SET glossary = '';
+UPDATE generated_contents SET test_plan = '';
+UPDATE generated_contents SET affected_paths = '';
+DELETE FROM schema_version WHERE version >= 57;`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Migrate(database); err != nil {
+		t.Fatalf("second Migrate: %v", err)
+	}
+
+	gc, err := NewQueries(database).GetLatestGeneratedContent(ctx, 1)
+	if err != nil {
+		t.Fatalf("GetLatestGeneratedContent: %v", err)
+	}
+	if !slices.Equal(gc.Labels, []string{"enhancement"}) {
+		t.Errorf("labels = %q, want [enhancement]", gc.Labels)
+	}
+	if gc.Size != "M" || gc.SizeReason != "Touches every page" {
+		t.Errorf("size = %q (%q)", gc.Size, gc.SizeReason)
+	}
+	related, err := NewQueries(database).ListRelatedIssues(ctx, 1)
+	if err != nil {
+		t.Fatalf("ListRelatedIssues: %v", err)
+	}
+	if len(related) != 1 || related[0].Number != 3 {
+		t.Errorf("related issues = %+v", related)
+	}
+	if !slices.Equal(gc.NonGoals, []string{"High contrast"}) {
+		t.Errorf("non-goals = %q, want [High contrast]", gc.NonGoals)
+	}
+	if !slices.Equal(gc.Alternatives, []string{"Browser extension"}) {
+		t.Errorf("alternatives = %q, want [Browser extension]", gc.Alternatives)
+	}
+	if !slices.Equal(gc.Areas, []string{"UI"}) {
+		t.Errorf("areas = %q, want [UI]", gc.Areas)
+	}
+	if gc.Clarity != 4 || !slices.Equal(gc.Ambiguities, []string{"Default theme"}) {
+		t.Errorf("clarity = %d, ambiguities = %q", gc.Clarity, gc.Ambiguities)
+	}
+	if len(gc.Glossary) != 1 || gc.Glossary[0].Term != "Theme" {
+		t.Errorf("glossary = %+v", gc.Glossary)
+	}
+	if !slices.Equal(gc.TestPlan, []string{"Toggle the theme"}) {
+		t.Errorf("test plan = %q, want [Toggle the theme]", gc.TestPlan)
+	}
+	if !slices.Equal(gc.Paths, []string{"web/static"}) {
+		t.Errorf("paths = %q, want [web/static]", gc.Paths)
+	}
+}
@@ -0,0 +1,116 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/esnunes/prompter/internal/models"
+)
+
+// personasTable holds the named instructions a prompt request can have
+// Claude follow, such as the team's way of eliciting and phrasing requests.
+// Built-in personas come with Prompter and can't be edited.
+const personasTable = `
+CREATE TABLE personas (
+    id           INTEGER PRIMARY KEY AUTOINCREMENT,
+    name         TEXT NOT NULL UNIQUE COLLATE NOCASE,
+    instructions TEXT NOT NULL,
+    builtin      INTEGER NOT NULL DEFAULT 0,
+    created_at   TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+    updated_at   TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now'))
+);
+INSERT INTO personas (name, instructions, builtin) VALUES
+    ('Product manager', 'Approach the request like a product manager. Start from the problem and who has it, ask how the contributor would tell the feature succeeded, and write the generated prompt around user outcomes, ending with acceptance criteria as a checklist.', 1),
+    ('Maintainer-friendly', 'Keep the request small and easy for maintainers to accept. Ask whether it can be split into smaller requests, prefer what fits the project''s existing conventions, and write the generated prompt tersely, without selling the idea.', 1),
+    ('Plain language', 'The contributor is not a developer. Avoid jargon in questions and options, explain trade-offs with everyday examples, and write the generated prompt so non-specialists can read it while staying precise about behavior.', 1),
+    ('Accessibility-minded', 'Ask how the change should work for people using a keyboard, a screen reader, zoom, or reduced motion, and include the accessibility expectations the contributor confirms in the generated prompt.', 1);`
+
+const personaColumns = `id, name, instructions, builtin, created_at, updated_at`
+
+func scanPersona(row interface{ Scan(...any) error }) (models.Persona, error) {
+	var p models.Persona
+	var builtin int
+	var createdAt, updatedAt string
+	if err := row.Scan(&p.ID, &p.Name, &p.Instructions, &builtin, &createdAt, &updatedAt); err != nil {
+		return p, err
+	}
+	p.Builtin = builtin != 0
+	p.CreatedAt = parseTime(createdAt)
+	p.UpdatedAt = parseTime(updatedAt)
+	return p, nil
+}
+
+// ListPersonas returns the built-in personas, then the user's, by name.
+func (q *Queries) ListPersonas(ctx context.Context) ([]models.Persona, error) {
+	rows, err := q.db.QueryContext(ctx, `SELECT `+personaColumns+` FROM personas ORDER BY builtin DESC, name`)
+	if err != nil {
+		return nil, fmt.Errorf("listing personas: %w", err)
+	}
+	defer rows.Close()
+
+	var personas []models.Persona
+	for rows.Next() {
+		p, err := scanPersona(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning persona: %w", err)
+		}
+		personas = append(personas, p)
+	}
+	return personas, rows.Err()
+}
+
+// GetPersona returns a persona, or an error wrapping sql.ErrNoRows.
+func (q *Queries) GetPersona(ctx context.Context, id int64) (*models.Persona, error) {
+	p, err := scanPersona(q.db.QueryRowContext(ctx, `SELECT `+personaColumns+` FROM personas WHERE id = ?`, id))
+	if err != nil {
+		return nil, fmt.Errorf("getting persona: %w", err)
+	}
+	return &p, nil
+}
+
+// CreatePersona adds a user-defined persona.
+func (q *Queries) CreatePersona(ctx context.Context, name, instructions string) (*models.Persona, error) {
+	res, err := q.db.ExecContext(ctx, `INSERT INTO personas (name, instructions) VALUES (?, ?)`, name, instructions)
+	if err != nil {
+		return nil, fmt.Errorf("creating persona: %w", err)
+	}
+	id, _ := res.LastInsertId()
+	return q.GetPersona(ctx, id)
+}
+
+// UpdatePersona changes a user-defined persona. Built-in ones are left
+// alone and reported as sql.ErrNoRows.
+func (q *Queries) UpdatePersona(ctx context.Context, id int64, name, instructions string) error {
+	res, err := q.db.ExecContext(ctx,
+		`UPDATE personas SET name = ?, instructions = ?, updated_at = strftime('%Y-%m-%dT%H:%M:%SZ', 'now')
+		 WHERE id = ? AND builtin = 0`, name, instructions, id)
+	if err != nil {
+		return fmt.Errorf("updating persona: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("updating persona: %w", sql.ErrNoRows)
+	}
+	return nil
+}
+
+// DeletePersona deletes a user-defined persona; prompt requests that used
+// it go back to none. Built-in ones are left alone and reported as
+// sql.ErrNoRows.
+func (q *Queries) DeletePersona(ctx context.Context, id int64) error {
+	res, err := q.db.ExecContext(ctx, `DELETE FROM personas WHERE id = ? AND builtin = 0`, id)
+	if err != nil {
+		return fmt.Errorf("deleting persona: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("deleting persona: %w", sql.ErrNoRows)
+	}
+	return nil
+}
+
+// SetPromptRequestPersona sets the persona Claude follows in a prompt
+// request's conversation; nil for none.
+func (q *Queries) SetPromptRequestPersona(ctx context.Context, id int64, personaID *int64) error {
+	_, err := q.db.ExecContext(ctx, `UPDATE prompt_requests SET persona_id = ? WHERE id = ?`, personaID, id)
+	return err
+}
@@ -0,0 +1,36 @@
+package db
+
+import (
+	"context"
+	"fmt"
+)
+
+// preferencesTable holds the user's settings made in the web UI, as opposed
+// to the server configuration read from the environment at startup.
+const preferencesTable = `
+CREATE TABLE preferences (
+    name  TEXT PRIMARY KEY,
+    value TEXT NOT NULL
+)`
+
+// Preference returns a setting's value, or "" if it was never set.
+func (q *Queries) Preference(ctx context.Context, name string) (string, error) {
+	var value string
+	err := q.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(value), '') FROM preferences WHERE name = ?`, name).Scan(&value)
+	if err != nil {
+		return "", fmt.Errorf("reading preference %s: %w", name, err)
+	}
+	return value, nil
+}
+
+// SetPreference stores a setting's value.
+func (q *Queries) SetPreference(ctx context.Context, name, value string) error {
+	_, err := q.db.ExecContext(ctx,
+		`INSERT INTO preferences (name, value) VALUES (?, ?)
+		 ON CONFLICT(name) DO UPDATE SET value = excluded.value`, name, value,
+	)
+	if err != nil {
+		return fmt.Errorf("saving preference %s: %w", name, err)
+	}
+	return nil
+}
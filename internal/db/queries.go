@@ -1,26 +1,46 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/esnunes/prompter/internal/models"
 )
 
+// Queries runs the application's queries. Every method takes the caller's
+// context, so a query stops when the request that needed it goes away.
 type Queries struct {
-	db *sql.DB
+	db     dbtx        // conn, or the transaction of InTx
+	conn   *preparedDB // the database
+	tx     *sql.Tx     // set within InTx
+	sealer *sealer
 }
 
 func NewQueries(db *sql.DB) *Queries {
-	return &Queries{db: db}
+	conn := newPreparedDB(db)
+	return &Queries{db: conn, conn: conn}
+}
+
+// Ping verifies the database connection is alive and can answer a query.
+func (q *Queries) Ping(ctx context.Context) error {
+	var n int
+	if err := q.db.QueryRowContext(ctx, `SELECT 1`).Scan(&n); err != nil {
+		return fmt.Errorf("pinging database: %w", err)
+	}
+	return nil
 }
 
 // Repositories
 
-func (q *Queries) ListRepositories() ([]models.Repository, error) {
-	rows, err := q.db.Query(`SELECT id, url, local_path, created_at, updated_at FROM repositories ORDER BY url ASC`)
+func (q *Queries) ListRepositories(ctx context.Context) ([]models.Repository, error) {
+	rows, err := q.db.QueryContext(ctx, `SELECT id, url, local_path, created_at, updated_at, `+repoMetadataColumns+`
+		FROM repositories r ORDER BY url ASC`)
 	if err != nil {
 		return nil, fmt.Errorf("listing repositories: %w", err)
 	}
@@ -30,21 +50,30 @@ func (q *Queries) ListRepositories() ([]models.Repository, error) {
 	for rows.Next() {
 		var r models.Repository
 		var createdAt, updatedAt string
-		if err := rows.Scan(&r.ID, &r.URL, &r.LocalPath, &createdAt, &updatedAt); err != nil {
+		var m models.RepoMetadata
+		var fetchedAt *string
+		if err := rows.Scan(&r.ID, &r.URL, &r.LocalPath, &createdAt, &updatedAt,
+			&m.Description, &m.Stars, &m.Language, &m.License, &m.OpenIssues, &fetchedAt); err != nil {
 			return nil, fmt.Errorf("scanning repository: %w", err)
 		}
-		r.CreatedAt, _ = time.Parse(time.DateTime, createdAt)
-		r.UpdatedAt, _ = time.Parse(time.DateTime, updatedAt)
+		r.CreatedAt = parseTime(createdAt)
+		r.UpdatedAt = parseTime(updatedAt)
+		if fetchedAt != nil {
+			m.FetchedAt = parseTime(*fetchedAt)
+			r.Metadata = &m
+		}
 		results = append(results, r)
 	}
 	return results, rows.Err()
 }
 
-func (q *Queries) ListRepositorySummaries() ([]models.RepositorySummary, error) {
-	rows, err := q.db.Query(`
+func (q *Queries) ListRepositorySummaries(ctx context.Context) ([]models.RepositorySummary, error) {
+	rows, err := q.db.QueryContext(ctx, `
 		SELECT r.id, r.url,
 		       COUNT(CASE WHEN pr.archived = 0 THEN 1 END) as active_pr_count,
-		       MAX(pr.updated_at) as last_activity
+		       COUNT(CASE WHEN pr.archived = 0 AND `+unreadCondition+` THEN 1 END) as unread_count,
+		       MAX(pr.updated_at) as last_activity,
+		       `+repoMetadataColumns+`
 		FROM repositories r
 		JOIN prompt_requests pr ON pr.repository_id = r.id
 		WHERE pr.status != 'deleted'
@@ -59,45 +88,110 @@ func (q *Queries) ListRepositorySummaries() ([]models.RepositorySummary, error)
 	for rows.Next() {
 		var rs models.RepositorySummary
 		var lastActivity string
-		if err := rows.Scan(&rs.ID, &rs.URL, &rs.ActivePRCount, &lastActivity); err != nil {
+		var m models.RepoMetadata
+		var fetchedAt *string
+		if err := rows.Scan(&rs.ID, &rs.URL, &rs.ActivePRCount, &rs.UnreadCount, &lastActivity,
+			&m.Description, &m.Stars, &m.Language, &m.License, &m.OpenIssues, &fetchedAt); err != nil {
 			return nil, fmt.Errorf("scanning repository summary: %w", err)
 		}
-		rs.LastActivity, _ = time.Parse(time.DateTime, lastActivity)
+		rs.LastActivity = parseTime(lastActivity)
+		if fetchedAt != nil {
+			m.FetchedAt = parseTime(*fetchedAt)
+			rs.Metadata = &m
+		}
 		results = append(results, rs)
 	}
 	return results, rows.Err()
 }
 
-func (q *Queries) UpsertRepository(url, localPath string) (*models.Repository, error) {
-	_, err := q.db.Exec(
+func (q *Queries) UpsertRepository(ctx context.Context, url, localPath string) (*models.Repository, error) {
+	_, err := q.db.ExecContext(ctx,
 		`INSERT INTO repositories (url, local_path) VALUES (?, ?)
-		 ON CONFLICT(url) DO UPDATE SET local_path = excluded.local_path, updated_at = datetime('now')`,
+		 ON CONFLICT(url) DO UPDATE SET local_path = excluded.local_path, updated_at = strftime('%Y-%m-%dT%H:%M:%SZ', 'now')`,
 		url, localPath,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("upserting repository: %w", err)
 	}
-	return q.GetRepositoryByURL(url)
+	return q.GetRepositoryByURL(ctx, url)
 }
 
-func (q *Queries) GetRepositoryByURL(url string) (*models.Repository, error) {
+func (q *Queries) GetRepositoryByURL(ctx context.Context, url string) (*models.Repository, error) {
 	r := &models.Repository{}
 	var createdAt, updatedAt string
-	err := q.db.QueryRow(
-		`SELECT id, url, local_path, created_at, updated_at FROM repositories WHERE url = ?`, url,
-	).Scan(&r.ID, &r.URL, &r.LocalPath, &createdAt, &updatedAt)
+	var m models.RepoMetadata
+	var fetchedAt, pulledAt *string
+	err := q.db.QueryRowContext(ctx,
+		`SELECT id, url, local_path, created_at, updated_at, issue_title_prefix, issue_body_template, include_affected_areas, output_format, propose_test_plan, project, issue_labels, pulled_at,
+		        `+repoMetadataColumns+`
+		 FROM repositories r WHERE url = ?`, url,
+	).Scan(&r.ID, &r.URL, &r.LocalPath, &createdAt, &updatedAt, &r.IssueTitlePrefix, &r.IssueBodyTemplate, &r.IncludeAffectedAreas, &r.OutputFormat, &r.ProposeTestPlan, &r.Project, &r.IssueLabels, &pulledAt,
+		&m.Description, &m.Stars, &m.Language, &m.License, &m.OpenIssues, &fetchedAt)
 	if err != nil {
 		return nil, fmt.Errorf("getting repository: %w", err)
 	}
-	r.CreatedAt, _ = time.Parse(time.DateTime, createdAt)
-	r.UpdatedAt, _ = time.Parse(time.DateTime, updatedAt)
+	r.CreatedAt = parseTime(createdAt)
+	r.UpdatedAt = parseTime(updatedAt)
+	if pulledAt != nil {
+		t := parseTime(*pulledAt)
+		r.PulledAt = &t
+	}
+	if fetchedAt != nil {
+		m.FetchedAt = parseTime(*fetchedAt)
+		r.Metadata = &m
+	}
 	return r, nil
 }
 
+// repoMetadataColumns selects the cached GitHub metadata of repositories r.
+const repoMetadataColumns = `r.description, r.stars, r.language, r.license, r.open_issues, r.metadata_fetched_at`
+
+// SetRepositoryMetadata caches a repository's metadata from GitHub.
+func (q *Queries) SetRepositoryMetadata(ctx context.Context, id int64, m models.RepoMetadata) error {
+	_, err := q.db.ExecContext(ctx,
+		`UPDATE repositories
+		 SET description = ?, stars = ?, language = ?, license = ?, open_issues = ?,
+		     metadata_fetched_at = strftime('%Y-%m-%dT%H:%M:%SZ', 'now')
+		 WHERE id = ?`,
+		m.Description, m.Stars, m.Language, m.License, m.OpenIssues, id,
+	)
+	return err
+}
+
+// SetRepositoryPulled records that the repository's local copy was just
+// cloned or pulled.
+func (q *Queries) SetRepositoryPulled(ctx context.Context, url string) error {
+	_, err := q.db.ExecContext(ctx,
+		`UPDATE repositories SET pulled_at = strftime('%Y-%m-%dT%H:%M:%SZ', 'now') WHERE url = ?`, url,
+	)
+	return err
+}
+
+// IssueOptions are the settings of a repository's issue format besides the
+// title prefix and body template.
+type IssueOptions struct {
+	IncludeAffectedAreas bool    // issues get the areas likely affected by default
+	OutputFormat         string  // how feature request prompts are written
+	ProposeTestPlan      bool    // Claude proposes how to verify each request
+	Project              string  // GitHub Project new issues are added to
+	Labels               *string // labels of every new issue; nil inherits
+}
+
+// SetRepositoryIssueFormat stores a repository's issue title prefix and body
+// template overrides; a nil prefix and an empty template inherit the defaults.
+func (q *Queries) SetRepositoryIssueFormat(ctx context.Context, id int64, titlePrefix *string, bodyTemplate string, opts IssueOptions) error {
+	_, err := q.db.ExecContext(ctx,
+		`UPDATE repositories SET issue_title_prefix = ?, issue_body_template = ?, include_affected_areas = ?, output_format = ?,
+		        propose_test_plan = ?, project = ?, issue_labels = ?, updated_at = strftime('%Y-%m-%dT%H:%M:%SZ', 'now') WHERE id = ?`,
+		titlePrefix, bodyTemplate, opts.IncludeAffectedAreas, opts.OutputFormat, opts.ProposeTestPlan, opts.Project, opts.Labels, id,
+	)
+	return err
+}
+
 // Prompt Requests
 
-func (q *Queries) CreatePromptRequest(repoID int64, sessionID string) (*models.PromptRequest, error) {
-	res, err := q.db.Exec(
+func (q *Queries) CreatePromptRequest(ctx context.Context, repoID int64, sessionID string) (*models.PromptRequest, error) {
+	res, err := q.db.ExecContext(ctx,
 		`INSERT INTO prompt_requests (repository_id, session_id) VALUES (?, ?)`,
 		repoID, sessionID,
 	)
@@ -105,103 +199,251 @@ func (q *Queries) CreatePromptRequest(repoID int64, sessionID string) (*models.P
 		return nil, fmt.Errorf("creating prompt request: %w", err)
 	}
 	id, _ := res.LastInsertId()
-	return q.GetPromptRequest(id)
+	return q.GetPromptRequest(ctx, id)
+}
+
+// ForkPromptRequest creates a draft in the same repository with a new session,
+// copying the source's messages up to its latest assistant reply.
+func (q *Queries) ForkPromptRequest(ctx context.Context, srcID int64, sessionID, title string) (*models.PromptRequest, error) {
+	tx, err := q.begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("beginning fork: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx,
+		`INSERT INTO prompt_requests (repository_id, title, session_id, forked_from_id, kind, persona_id)
+		 SELECT repository_id, ?, ?, id, kind, persona_id FROM prompt_requests WHERE id = ?`,
+		q.sealer.seal(title), sessionID, srcID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("creating fork: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, fmt.Errorf("creating fork: %w", sql.ErrNoRows)
+	}
+	id, _ := res.LastInsertId()
+
+	// A trailing user message has no reply yet, so it isn't part of the history.
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO messages (prompt_request_id, role, content, raw_response, created_at)
+		 SELECT ?, role, content, raw_response, created_at FROM messages
+		 WHERE prompt_request_id = ? AND superseded = 0
+		   AND id <= (SELECT MAX(id) FROM messages WHERE prompt_request_id = ? AND role = 'assistant' AND superseded = 0)
+		 ORDER BY id`,
+		id, srcID, srcID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("copying messages: %w", err)
+	}
+	err = indexResponses(ctx, tx, q.sealer,
+		`SELECT id, raw_response FROM messages WHERE prompt_request_id = ? AND role = 'assistant' AND raw_response IS NOT NULL`, id)
+	if err != nil {
+		return nil, fmt.Errorf("copying responses: %w", err)
+	}
+	_, err = tx.ExecContext(ctx,
+		`UPDATE prompt_requests SET fork_message_id = (SELECT MAX(id) FROM messages WHERE prompt_request_id = ?)
+		 WHERE id = ?`, id, id,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("recording fork point: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("committing fork: %w", err)
+	}
+	return q.GetPromptRequest(ctx, id)
 }
 
-func (q *Queries) GetPromptRequest(id int64) (*models.PromptRequest, error) {
+// MergePromptRequests copies the source's messages into the target, attributed
+// via merged_from_id and keeping their original timestamps so the combined
+// history reads chronologically, then archives the source. Copies carry no raw
+// response so the source's questions and generated prompt don't surface as the
+// target's own.
+func (q *Queries) MergePromptRequests(ctx context.Context, targetID, sourceID int64) error {
+	tx, err := q.begin(ctx)
+	if err != nil {
+		return fmt.Errorf("beginning merge: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO messages (prompt_request_id, role, content, created_at, merged_from_id)
+		 SELECT ?, role, content, created_at, prompt_request_id FROM messages
+		 WHERE prompt_request_id = ? AND superseded = 0
+		 ORDER BY created_at, id`,
+		targetID, sourceID,
+	)
+	if err != nil {
+		return fmt.Errorf("copying messages: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE prompt_requests SET archived = 1 WHERE id = ?`, sourceID); err != nil {
+		return fmt.Errorf("archiving merged prompt request: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE prompt_requests SET updated_at = strftime('%Y-%m-%dT%H:%M:%SZ', 'now') WHERE id = ?`, targetID); err != nil {
+		return fmt.Errorf("touching prompt request: %w", err)
+	}
+	return tx.Commit()
+}
+
+func (q *Queries) GetPromptRequest(ctx context.Context, id int64) (*models.PromptRequest, error) {
 	pr := &models.PromptRequest{}
 	var createdAt, updatedAt string
-	var archived int
-	err := q.db.QueryRow(
+	var archived, pinned, titleEdited, includeTranscript, linkRelated, prewarmed, includeAreas int
+	var exportedAt *string
+	var dismissedLabels, mentions string
+	err := q.db.QueryRowContext(ctx,
 		`SELECT pr.id, pr.repository_id, pr.title, pr.status, pr.session_id,
 		        pr.issue_number, pr.issue_url, pr.created_at, pr.updated_at,
-		        r.url, r.local_path, pr.archived
+		        r.url, r.local_path, pr.archived, pr.pinned, pr.title_edited,
+		        pr.forked_from_id, pr.fork_message_id, pr.notes,
+		        pr.summary, pr.summary_message_id, pr.source_issue_number, pr.publish_target,
+		        pr.exported_at, r.issue_title_prefix, r.issue_body_template, pr.include_transcript,
+		        pr.issue_template, pr.issue_template_sent, pr.dismissed_labels, pr.link_related_issues,
+		        pr.conversation_language, pr.output_language, pr.prewarmed, pr.question_mode, pr.kind, pr.persona_id,
+		        COALESCE(pr.include_affected_areas, r.include_affected_areas), r.output_format, r.propose_test_plan,
+		        pr.gist_url, r.project, r.issue_labels, pr.mentions
 		 FROM prompt_requests pr
 		 JOIN repositories r ON r.id = pr.repository_id
 		 WHERE pr.id = ?`, id,
 	).Scan(&pr.ID, &pr.RepositoryID, &pr.Title, &pr.Status, &pr.SessionID,
 		&pr.IssueNumber, &pr.IssueURL, &createdAt, &updatedAt, &pr.RepoURL, &pr.RepoLocalPath,
-		&archived)
+		&archived, &pinned, &titleEdited, &pr.ForkedFromID, &pr.ForkMessageID, &pr.Notes,
+		&pr.Summary, &pr.SummaryMessageID, &pr.SourceIssueNumber, &pr.PublishTarget,
+		&exportedAt, &pr.RepoTitlePrefix, &pr.RepoBodyTemplate, &includeTranscript,
+		&pr.IssueTemplate, &pr.IssueTemplateSent, &dismissedLabels, &linkRelated,
+		&pr.ConversationLanguage, &pr.OutputLanguage, &prewarmed, &pr.QuestionMode, &pr.Kind, &pr.PersonaID,
+		&includeAreas, &pr.RepoOutputFormat, &pr.RepoTestPlan,
+		&pr.GistURL, &pr.RepoProject, &pr.RepoIssueLabels, &mentions)
 	if err != nil {
 		return nil, fmt.Errorf("getting prompt request: %w", err)
 	}
+	if err := q.sealer.openAll(&pr.Title, &pr.Notes, &pr.Summary); err != nil {
+		return nil, err
+	}
+	if exportedAt != nil {
+		t := parseTime(*exportedAt)
+		pr.ExportedAt = &t
+	}
 	pr.Archived = archived != 0
-	pr.CreatedAt, _ = time.Parse(time.DateTime, createdAt)
-	pr.UpdatedAt, _ = time.Parse(time.DateTime, updatedAt)
+	pr.Pinned = pinned != 0
+	pr.TitleEdited = titleEdited != 0
+	pr.IncludeTranscript = includeTranscript != 0
+	pr.DismissedLabels = splitList(dismissedLabels)
+	pr.Mentions = splitList(mentions)
+	pr.LinkRelatedIssues = linkRelated != 0
+	pr.IncludeAffectedAreas = includeAreas != 0
+	pr.Prewarmed = prewarmed != 0
+	pr.CreatedAt = parseTime(createdAt)
+	pr.UpdatedAt = parseTime(updatedAt)
 	return pr, nil
 }
 
-const listPromptRequestsQuery = `SELECT pr.id, pr.repository_id, pr.title, pr.status, pr.session_id,
+// promptRequestListColumns selects the dashboard's rows. Message and revision
+// counts, and whether a prompt was generated, come from one aggregate pass
+// over each table rather than a subquery per row, so long lists stay fast.
+const promptRequestListColumns = `SELECT pr.id, pr.repository_id, pr.title, pr.status, pr.session_id,
 		        pr.issue_number, pr.issue_url, pr.created_at, pr.updated_at,
 		        r.url,
-		        (SELECT COUNT(*) FROM messages WHERE prompt_request_id = pr.id) as message_count,
-		        (SELECT COUNT(*) FROM revisions WHERE prompt_request_id = pr.id) as revision_count,
-		        pr.last_viewed_at,
-		        (SELECT MAX(created_at) FROM messages WHERE prompt_request_id = pr.id AND role = 'assistant') as latest_assistant_at,
-		        pr.archived
+		        COALESCE(m.message_count, 0), COALESCE(rv.revision_count, 0),
+		        pr.last_viewed_at, m.latest_assistant_at, rv.latest_revision_at,
+		        pr.archived, pr.pinned, pr.issue_activity_at, pr.kind,
+		        COALESCE(m.has_generated_prompt, 0)
 		 FROM prompt_requests pr
 		 JOIN repositories r ON r.id = pr.repository_id
+		 LEFT JOIN (SELECT messages.prompt_request_id, COUNT(*) AS message_count,
+		                   MAX(CASE WHEN messages.role = 'assistant' THEN messages.created_at END) AS latest_assistant_at,
+		                   COUNT(g.message_id) > 0 AS has_generated_prompt
+		            FROM messages LEFT JOIN generated_contents g ON g.message_id = messages.id
+		            WHERE messages.superseded = 0 GROUP BY messages.prompt_request_id) m ON m.prompt_request_id = pr.id
+		 LEFT JOIN (SELECT prompt_request_id, COUNT(*) AS revision_count, MAX(published_at) AS latest_revision_at
+		            FROM revisions GROUP BY prompt_request_id) rv ON rv.prompt_request_id = pr.id`
+
+const listPromptRequestsQuery = promptRequestListColumns + `
 		 WHERE pr.status != 'deleted'`
 
-func scanPromptRequest(rows *sql.Rows) (models.PromptRequest, error) {
+func (q *Queries) scanPromptRequest(rows *sql.Rows) (models.PromptRequest, error) {
 	var pr models.PromptRequest
 	var createdAt, updatedAt string
-	var lastViewedAt, latestAssistantAt *string
-	var archived int
+	var lastViewedAt, latestAssistantAt, latestRevisionAt, issueActivityAt *string
+	var archived, pinned, hasGeneratedPrompt int
 	if err := rows.Scan(&pr.ID, &pr.RepositoryID, &pr.Title, &pr.Status, &pr.SessionID,
 		&pr.IssueNumber, &pr.IssueURL, &createdAt, &updatedAt, &pr.RepoURL,
-		&pr.MessageCount, &pr.RevisionCount, &lastViewedAt, &latestAssistantAt,
-		&archived); err != nil {
+		&pr.MessageCount, &pr.RevisionCount, &lastViewedAt, &latestAssistantAt, &latestRevisionAt,
+		&archived, &pinned, &issueActivityAt, &pr.Kind, &hasGeneratedPrompt); err != nil {
 		return pr, err
 	}
 	pr.Archived = archived != 0
-	pr.CreatedAt, _ = time.Parse(time.DateTime, createdAt)
-	pr.UpdatedAt, _ = time.Parse(time.DateTime, updatedAt)
+	pr.Pinned = pinned != 0
+	pr.HasGeneratedPrompt = hasGeneratedPrompt != 0
+	var err error
+	if pr.Title, err = q.sealer.open(pr.Title); err != nil {
+		return pr, err
+	}
+	pr.CreatedAt = parseTime(createdAt)
+	pr.UpdatedAt = parseTime(updatedAt)
 	if lastViewedAt != nil {
-		t, _ := time.Parse(time.DateTime, *lastViewedAt)
+		t := parseTime(*lastViewedAt)
 		pr.LastViewedAt = &t
 	}
 	if latestAssistantAt != nil {
-		t, _ := time.Parse(time.DateTime, *latestAssistantAt)
+		t := parseTime(*latestAssistantAt)
 		pr.LatestAssistantAt = &t
 	}
+	if latestRevisionAt != nil {
+		t := parseTime(*latestRevisionAt)
+		pr.LatestRevision = &t
+	}
+	if issueActivityAt != nil {
+		t := parseTime(*issueActivityAt)
+		pr.IssueActivityAt = &t
+	}
 	return pr, nil
 }
 
-func (q *Queries) ListPromptRequests(archivedOnly bool) ([]models.PromptRequest, error) {
+// ListPromptRequests lists prompt requests across all repositories, drafts first.
+// A positive limit returns at most that many rows; hasMore reports whether more exist.
+func (q *Queries) ListPromptRequests(ctx context.Context, archivedOnly bool, limit, offset int) (results []models.PromptRequest, hasMore bool, err error) {
 	archivedVal := 0
 	if archivedOnly {
 		archivedVal = 1
 	}
-	rows, err := q.db.Query(
+	// Fetch one extra row to detect whether another page exists.
+	sqlLimit := -1
+	if limit > 0 {
+		sqlLimit = limit + 1
+	}
+	rows, err := q.db.QueryContext(ctx,
 		listPromptRequestsQuery+` AND pr.archived = ?
 		 ORDER BY
 		   CASE WHEN pr.status = 'draft' THEN 0 ELSE 1 END ASC,
-		   pr.updated_at DESC`,
-		archivedVal,
+		   pr.updated_at DESC, pr.id DESC
+		 LIMIT ? OFFSET ?`,
+		archivedVal, sqlLimit, offset,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("listing prompt requests: %w", err)
+		return nil, false, fmt.Errorf("listing prompt requests: %w", err)
 	}
 	defer rows.Close()
 
-	var results []models.PromptRequest
 	for rows.Next() {
-		pr, err := scanPromptRequest(rows)
+		pr, err := q.scanPromptRequest(rows)
 		if err != nil {
-			return nil, fmt.Errorf("scanning prompt request: %w", err)
+			return nil, false, fmt.Errorf("scanning prompt request: %w", err)
 		}
 		results = append(results, pr)
 	}
-	return results, rows.Err()
+	if limit > 0 && len(results) > limit {
+		results, hasMore = results[:limit], true
+	}
+	return results, hasMore, rows.Err()
 }
 
-func (q *Queries) ListPromptRequestsByRepoURL(repoURL string, archivedOnly bool) ([]models.PromptRequest, error) {
+func (q *Queries) ListPromptRequestsByRepoURL(ctx context.Context, repoURL string, archivedOnly bool) ([]models.PromptRequest, error) {
 	archivedVal := 0
 	if archivedOnly {
 		archivedVal = 1
 	}
-	rows, err := q.db.Query(
+	rows, err := q.db.QueryContext(ctx,
 		listPromptRequestsQuery+` AND r.url = ? AND pr.archived = ?
 		 ORDER BY
 		   CASE WHEN pr.status = 'draft' THEN 0 ELSE 1 END ASC,
@@ -214,7 +456,7 @@ func (q *Queries) ListPromptRequestsByRepoURL(repoURL string, archivedOnly bool)
 
 	var results []models.PromptRequest
 	for rows.Next() {
-		pr, err := scanPromptRequest(rows)
+		pr, err := q.scanPromptRequest(rows)
 		if err != nil {
 			return nil, fmt.Errorf("scanning prompt request: %w", err)
 		}
@@ -223,160 +465,550 @@ func (q *Queries) ListPromptRequestsByRepoURL(repoURL string, archivedOnly bool)
 	return results, rows.Err()
 }
 
-func (q *Queries) UpdatePromptRequestTitle(id int64, title string) error {
-	_, err := q.db.Exec(
-		`UPDATE prompt_requests SET title = ?, updated_at = datetime('now') WHERE id = ?`,
-		title, id,
+// ftsQuery turns free text into an FTS5 query matching rows that contain every
+// term. Terms are quoted so FTS5 operators in user input are treated literally.
+func ftsQuery(text string) string {
+	var terms []string
+	for _, t := range strings.Fields(text) {
+		terms = append(terms, `"`+strings.ReplaceAll(t, `"`, `""`)+`"`)
+	}
+	return strings.Join(terms, " ")
+}
+
+// SearchPromptRequests finds non-deleted prompt requests whose title, messages
+// or revisions match text, best match first. Each prompt request appears once,
+// with the excerpt from its best matching row.
+func (q *Queries) SearchPromptRequests(ctx context.Context, text string, limit int) ([]models.SearchResult, error) {
+	match := ftsQuery(text)
+	if match == "" {
+		return nil, nil
+	}
+	rows, err := q.db.QueryContext(ctx,
+		`SELECT pr.id, pr.title, pr.status, r.url, si.source,
+		        snippet(search_index, 0, ?, ?, '…', 16), pr.updated_at
+		 FROM search_index si
+		 JOIN prompt_requests pr ON pr.id = si.prompt_request_id
+		 JOIN repositories r ON r.id = pr.repository_id
+		 WHERE search_index MATCH ? AND pr.status != 'deleted'
+		 ORDER BY si.rank`,
+		models.SnippetMarkStart, models.SnippetMarkEnd, match,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("searching prompt requests: %w", err)
+	}
+	defer rows.Close()
+
+	var results []models.SearchResult
+	seen := map[int64]bool{}
+	for rows.Next() && len(results) < limit {
+		var sr models.SearchResult
+		var updatedAt string
+		if err := rows.Scan(&sr.PromptRequestID, &sr.Title, &sr.Status, &sr.RepoURL,
+			&sr.Source, &sr.Snippet, &updatedAt); err != nil {
+			return nil, fmt.Errorf("scanning search result: %w", err)
+		}
+		if sr.Title, err = q.sealer.open(sr.Title); err != nil {
+			return nil, err
+		}
+		if seen[sr.PromptRequestID] {
+			continue
+		}
+		seen[sr.PromptRequestID] = true
+		sr.UpdatedAt = parseTime(updatedAt)
+		results = append(results, sr)
+	}
+	return results, rows.Err()
+}
+
+// UpdatePromptRequestTitle sets a generated title. It is a no-op once the user
+// has renamed the prompt request.
+func (q *Queries) UpdatePromptRequestTitle(ctx context.Context, id int64, title string) error {
+	_, err := q.db.ExecContext(ctx,
+		`UPDATE prompt_requests SET title = ?, updated_at = strftime('%Y-%m-%dT%H:%M:%SZ', 'now') WHERE id = ? AND title_edited = 0`,
+		q.sealer.seal(title), id,
 	)
 	return err
 }
 
-func (q *Queries) UpdatePromptRequestStatus(id int64, status string) error {
-	_, err := q.db.Exec(
-		`UPDATE prompt_requests SET status = ?, updated_at = datetime('now') WHERE id = ?`,
+// RenamePromptRequest sets a user-chosen title, which generated titles no longer replace.
+func (q *Queries) RenamePromptRequest(ctx context.Context, id int64, title string) error {
+	_, err := q.db.ExecContext(ctx,
+		`UPDATE prompt_requests SET title = ?, title_edited = 1, updated_at = strftime('%Y-%m-%dT%H:%M:%SZ', 'now') WHERE id = ?`,
+		q.sealer.seal(title), id,
+	)
+	if err != nil {
+		return fmt.Errorf("renaming prompt request: %w", err)
+	}
+	return nil
+}
+
+func (q *Queries) UpdatePromptRequestStatus(ctx context.Context, id int64, status string) error {
+	_, err := q.db.ExecContext(ctx,
+		`UPDATE prompt_requests SET status = ?, updated_at = strftime('%Y-%m-%dT%H:%M:%SZ', 'now') WHERE id = ?`,
 		status, id,
 	)
 	return err
 }
 
-func (q *Queries) UpdatePromptRequestIssue(id int64, issueNumber int, issueURL string) error {
-	_, err := q.db.Exec(
-		`UPDATE prompt_requests SET issue_number = ?, issue_url = ?, status = 'published', updated_at = datetime('now') WHERE id = ?`,
+func (q *Queries) UpdatePromptRequestIssue(ctx context.Context, id int64, issueNumber int, issueURL string) error {
+	_, err := q.db.ExecContext(ctx,
+		`UPDATE prompt_requests SET issue_number = ?, issue_url = ?, status = 'published', updated_at = strftime('%Y-%m-%dT%H:%M:%SZ', 'now') WHERE id = ?`,
 		issueNumber, issueURL, id,
 	)
 	return err
 }
 
-func (q *Queries) DeletePromptRequest(id int64) error {
-	return q.UpdatePromptRequestStatus(id, "deleted")
+// SetPromptRequestGistURL records the gist a prompt request was published to.
+func (q *Queries) SetPromptRequestGistURL(ctx context.Context, id int64, gistURL string) error {
+	_, err := q.db.ExecContext(ctx,
+		`UPDATE prompt_requests SET gist_url = ?, updated_at = strftime('%Y-%m-%dT%H:%M:%SZ', 'now') WHERE id = ?`,
+		gistURL, id,
+	)
+	return err
 }
 
-func (q *Queries) ArchivePromptRequest(id int64) error {
-	_, err := q.db.Exec(
-		`UPDATE prompt_requests SET archived = 1 WHERE id = ?`, id,
+// UnpublishPromptRequest unlinks the published issue and reverts the prompt
+// request to a draft; its revisions are kept.
+func (q *Queries) UnpublishPromptRequest(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx,
+		`UPDATE prompt_requests SET issue_number = NULL, issue_url = NULL, status = 'draft', updated_at = strftime('%Y-%m-%dT%H:%M:%SZ', 'now') WHERE id = ?`,
+		id,
 	)
 	return err
 }
 
-func (q *Queries) UnarchivePromptRequest(id int64) error {
-	_, err := q.db.Exec(
-		`UPDATE prompt_requests SET archived = 0 WHERE id = ?`, id,
+// SetPromptRequestSourceIssue links a prompt request to the issue it was
+// imported from and how publishing should update it.
+func (q *Queries) SetPromptRequestSourceIssue(ctx context.Context, id int64, issueNumber int, publishTarget string) error {
+	_, err := q.db.ExecContext(ctx,
+		`UPDATE prompt_requests SET source_issue_number = ?, publish_target = ? WHERE id = ?`,
+		issueNumber, publishTarget, id,
 	)
 	return err
 }
 
-func (q *Queries) UpdateLastViewedAt(id int64) error {
-	_, err := q.db.Exec(
-		`UPDATE prompt_requests SET last_viewed_at = datetime('now') WHERE id = ?`, id,
+// SetPromptRequestIssueTemplate sets the issue template the generated issue
+// follows ("" for none).
+func (q *Queries) SetPromptRequestIssueTemplate(ctx context.Context, id int64, path string) error {
+	_, err := q.db.ExecContext(ctx, `UPDATE prompt_requests SET issue_template = ? WHERE id = ?`, path, id)
+	return err
+}
+
+// SetPromptRequestLanguages sets the languages Claude talks with the user
+// in and writes the generated issue in ("" for no preference).
+func (q *Queries) SetPromptRequestLanguages(ctx context.Context, id int64, conversation, output string) error {
+	_, err := q.db.ExecContext(ctx,
+		`UPDATE prompt_requests SET conversation_language = ?, output_language = ? WHERE id = ?`,
+		conversation, output, id,
 	)
 	return err
 }
 
-// GeneratedContent holds the title, motivation, and prompt extracted from a Claude response.
-type GeneratedContent struct {
-	Title      string
-	Motivation string
-	Prompt     string
+// SetPromptRequestKind sets what the conversation produces: a feature
+// request or a bug report.
+func (q *Queries) SetPromptRequestKind(ctx context.Context, id int64, kind string) error {
+	_, err := q.db.ExecContext(ctx, `UPDATE prompt_requests SET kind = ? WHERE id = ?`, kind, id)
+	return err
+}
+
+// SetPromptRequestQuestionMode sets how thoroughly Claude questions the contributor.
+func (q *Queries) SetPromptRequestQuestionMode(ctx context.Context, id int64, mode string) error {
+	_, err := q.db.ExecContext(ctx, `UPDATE prompt_requests SET question_mode = ? WHERE id = ?`, mode, id)
+	return err
+}
+
+// SetPromptRequestIssueTemplateSent records the issue template the Claude
+// session has been told about.
+func (q *Queries) SetPromptRequestIssueTemplateSent(ctx context.Context, id int64, path string) error {
+	_, err := q.db.ExecContext(ctx, `UPDATE prompt_requests SET issue_template_sent = ? WHERE id = ?`, path, id)
+	return err
+}
+
+// SetPromptRequestLinkRelatedIssues sets whether publishing links the related
+// issues.
+func (q *Queries) SetPromptRequestLinkRelatedIssues(ctx context.Context, id int64, link bool) error {
+	_, err := q.db.ExecContext(ctx, `UPDATE prompt_requests SET link_related_issues = ? WHERE id = ?`, link, id)
+	return err
+}
+
+// SetPromptRequestIncludeAffectedAreas sets whether publishing appends the
+// areas likely affected, overriding the repository's default.
+func (q *Queries) SetPromptRequestIncludeAffectedAreas(ctx context.Context, id int64, include bool) error {
+	_, err := q.db.ExecContext(ctx, `UPDATE prompt_requests SET include_affected_areas = ? WHERE id = ?`, include, id)
+	return err
+}
+
+// SetPromptRequestDismissedLabels records the suggested labels the user chose
+// not to apply.
+func (q *Queries) SetPromptRequestDismissedLabels(ctx context.Context, id int64, labels []string) error {
+	_, err := q.db.ExecContext(ctx, `UPDATE prompt_requests SET dismissed_labels = ? WHERE id = ?`, joinList(labels), id)
+	return err
+}
+
+// SetPromptRequestMentions records the suggested maintainers the user chose
+// to mention in the issue.
+func (q *Queries) SetPromptRequestMentions(ctx context.Context, id int64, logins []string) error {
+	_, err := q.db.ExecContext(ctx, `UPDATE prompt_requests SET mentions = ? WHERE id = ?`, joinList(logins), id)
+	return err
 }
 
-// GetLatestGeneratedContent finds the most recent generated_motivation and generated_prompt from assistant messages.
-func (q *Queries) GetLatestGeneratedContent(promptRequestID int64) (*GeneratedContent, error) {
-	rows, err := q.db.Query(
-		`SELECT raw_response FROM messages
-		 WHERE prompt_request_id = ? AND role = 'assistant' AND raw_response IS NOT NULL
-		 ORDER BY created_at DESC`, promptRequestID,
+// MarkPromptRequestExported records that the issue body was copied out by hand.
+func (q *Queries) MarkPromptRequestExported(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx,
+		`UPDATE prompt_requests SET exported_at = strftime('%Y-%m-%dT%H:%M:%SZ', 'now'), updated_at = strftime('%Y-%m-%dT%H:%M:%SZ', 'now') WHERE id = ?`, id,
 	)
+	return err
+}
+
+// SetPromptRequestIncludeTranscript sets whether publishing appends the transcript.
+func (q *Queries) SetPromptRequestIncludeTranscript(ctx context.Context, id int64, include bool) error {
+	val := 0
+	if include {
+		val = 1
+	}
+	_, err := q.db.ExecContext(ctx,
+		`UPDATE prompt_requests SET include_transcript = ? WHERE id = ?`, val, id,
+	)
+	return err
+}
+
+func (q *Queries) DeletePromptRequest(ctx context.Context, id int64) error {
+	return q.UpdatePromptRequestStatus(ctx, id, "deleted")
+}
+
+// ListDeletedPromptRequests lists soft-deleted prompt requests, most recently deleted first.
+func (q *Queries) ListDeletedPromptRequests(ctx context.Context) ([]models.PromptRequest, error) {
+	rows, err := q.db.QueryContext(ctx, promptRequestListColumns+`
+		 WHERE pr.status = 'deleted'
+		 ORDER BY pr.updated_at DESC, pr.id DESC`)
 	if err != nil {
-		return nil, fmt.Errorf("querying messages: %w", err)
+		return nil, fmt.Errorf("listing deleted prompt requests: %w", err)
 	}
 	defer rows.Close()
 
+	var results []models.PromptRequest
 	for rows.Next() {
-		var raw string
-		if err := rows.Scan(&raw); err != nil {
-			continue
-		}
-		if gc := extractGeneratedContent(raw); gc != nil {
-			return gc, nil
+		pr, err := q.scanPromptRequest(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning prompt request: %w", err)
 		}
+		results = append(results, pr)
 	}
-	return nil, fmt.Errorf("no generated prompt found")
+	return results, rows.Err()
 }
 
-func extractGeneratedContent(rawJSON string) *GeneratedContent {
-	type resp struct {
-		GeneratedTitle      string `json:"generated_title"`
-		GeneratedMotivation string `json:"generated_motivation"`
-		GeneratedPrompt     string `json:"generated_prompt"`
+// RestorePromptRequest undoes a soft delete. The status is derived from whether
+// the prompt request was ever published to an issue.
+func (q *Queries) RestorePromptRequest(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx,
+		`UPDATE prompt_requests
+		 SET status = CASE WHEN issue_number IS NULL THEN 'draft' ELSE 'published' END,
+		     updated_at = strftime('%Y-%m-%dT%H:%M:%SZ', 'now')
+		 WHERE id = ? AND status = 'deleted'`, id,
+	)
+	return err
+}
+
+// PurgePromptRequest permanently removes a soft-deleted prompt request. Its
+// messages, revisions, tags, attachments and the like are deleted with it by
+// the foreign keys; queued jobs refer to it by value and are deleted here.
+func (q *Queries) PurgePromptRequest(ctx context.Context, id int64) error {
+	tx, err := q.begin(ctx)
+	if err != nil {
+		return fmt.Errorf("beginning purge: %w", err)
 	}
+	defer tx.Rollback()
 
-	extract := func(r *resp) *GeneratedContent {
-		if r != nil && r.GeneratedPrompt != "" {
-			return &GeneratedContent{Title: r.GeneratedTitle, Motivation: r.GeneratedMotivation, Prompt: r.GeneratedPrompt}
-		}
-		return nil
+	var status string
+	if err := tx.QueryRowContext(ctx, `SELECT status FROM prompt_requests WHERE id = ?`, id).Scan(&status); err != nil {
+		return fmt.Errorf("getting prompt request: %w", err)
+	}
+	if status != "deleted" {
+		return fmt.Errorf("prompt request %d is not in the trash", id)
 	}
 
-	// The raw JSON is the full claude CLI output: {"type":"result","structured_output":{...},...}
-	var wrapper struct {
-		StructuredOutput *resp  `json:"structured_output"`
-		Result           string `json:"result"`
+	if _, err := tx.ExecContext(ctx, `DELETE FROM job_queue WHERE ref = CAST(? AS TEXT)`, id); err != nil {
+		return fmt.Errorf("purging queued jobs: %w", err)
 	}
-	if err := json.Unmarshal([]byte(rawJSON), &wrapper); err == nil {
-		if gc := extract(wrapper.StructuredOutput); gc != nil {
-			return gc
-		}
-		if wrapper.Result != "" {
-			var r resp
-			if json.Unmarshal([]byte(wrapper.Result), &r) == nil {
-				if gc := extract(&r); gc != nil {
-					return gc
-				}
-			}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM jobs WHERE prompt_request_id = ?`, id); err != nil {
+		return fmt.Errorf("purging job status: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM prompt_requests WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("purging prompt request: %w", err)
+	}
+	return tx.Commit()
+}
+
+func (q *Queries) ArchivePromptRequest(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx,
+		`UPDATE prompt_requests SET archived = 1 WHERE id = ?`, id,
+	)
+	return err
+}
+
+func (q *Queries) UnarchivePromptRequest(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx,
+		`UPDATE prompt_requests SET archived = 0, auto_archived_at = NULL WHERE id = ?`, id,
+	)
+	return err
+}
+
+// AutoArchiveStaleDrafts archives unpinned drafts not updated within maxAge
+// and returns how many were archived.
+func (q *Queries) AutoArchiveStaleDrafts(ctx context.Context, maxAge time.Duration) (int64, error) {
+	res, err := q.db.ExecContext(ctx,
+		`UPDATE prompt_requests SET archived = 1, auto_archived_at = strftime('%Y-%m-%dT%H:%M:%SZ', 'now')
+		 WHERE status = 'draft' AND archived = 0 AND pinned = 0
+		   AND updated_at < strftime('%Y-%m-%dT%H:%M:%SZ', 'now', ?)`,
+		fmt.Sprintf("-%d seconds", int64(maxAge.Seconds())),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("archiving stale drafts: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// ListAutoArchivedPromptRequests lists prompt requests archived by the
+// retention policy that the user hasn't acknowledged yet.
+func (q *Queries) ListAutoArchivedPromptRequests(ctx context.Context) ([]models.PromptRequest, error) {
+	rows, err := q.db.QueryContext(ctx,
+		listPromptRequestsQuery+` AND pr.archived = 1 AND pr.auto_archived_at IS NOT NULL
+		 ORDER BY pr.updated_at DESC, pr.id DESC`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("listing auto-archived prompt requests: %w", err)
+	}
+	defer rows.Close()
+
+	var results []models.PromptRequest
+	for rows.Next() {
+		pr, err := q.scanPromptRequest(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning prompt request: %w", err)
 		}
+		results = append(results, pr)
 	}
+	return results, rows.Err()
+}
 
-	// Try direct parse
-	var r resp
-	if json.Unmarshal([]byte(rawJSON), &r) == nil {
-		return extract(&r)
+// UndoAutoArchive restores every unacknowledged auto-archived prompt request.
+// updated_at is bumped so they aren't archived again on the next sweep.
+func (q *Queries) UndoAutoArchive(ctx context.Context) error {
+	_, err := q.db.ExecContext(ctx,
+		`UPDATE prompt_requests
+		 SET archived = 0, auto_archived_at = NULL, updated_at = strftime('%Y-%m-%dT%H:%M:%SZ', 'now')
+		 WHERE archived = 1 AND auto_archived_at IS NOT NULL`,
+	)
+	return err
+}
+
+// DismissAutoArchive acknowledges auto-archived prompt requests, leaving them archived.
+func (q *Queries) DismissAutoArchive(ctx context.Context) error {
+	_, err := q.db.ExecContext(ctx,
+		`UPDATE prompt_requests SET auto_archived_at = NULL WHERE auto_archived_at IS NOT NULL`,
+	)
+	return err
+}
+
+// UpdatePromptRequestNotes replaces a prompt request's private notes.
+// updated_at is left alone: notes aren't conversation activity.
+func (q *Queries) UpdatePromptRequestNotes(ctx context.Context, id int64, notes string) error {
+	_, err := q.db.ExecContext(ctx,
+		`UPDATE prompt_requests SET notes = ? WHERE id = ?`, q.sealer.seal(notes), id,
+	)
+	return err
+}
+
+// SetPromptRequestPinned pins or unpins a prompt request on the dashboard.
+func (q *Queries) SetPromptRequestPinned(ctx context.Context, id int64, pinned bool) error {
+	val := 0
+	if pinned {
+		val = 1
 	}
+	_, err := q.db.ExecContext(ctx,
+		`UPDATE prompt_requests SET pinned = ? WHERE id = ?`, val, id,
+	)
+	return err
+}
 
-	return nil
+// ListPinnedPromptRequests lists active pinned prompt requests, most recently updated first.
+func (q *Queries) ListPinnedPromptRequests(ctx context.Context) ([]models.PromptRequest, error) {
+	rows, err := q.db.QueryContext(ctx,
+		listPromptRequestsQuery+` AND pr.archived = 0 AND pr.pinned = 1
+		 ORDER BY pr.updated_at DESC, pr.id DESC`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("listing pinned prompt requests: %w", err)
+	}
+	defer rows.Close()
+
+	var results []models.PromptRequest
+	for rows.Next() {
+		pr, err := q.scanPromptRequest(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning prompt request: %w", err)
+		}
+		results = append(results, pr)
+	}
+	return results, rows.Err()
+}
+
+// unreadCondition holds for prompt requests pr with an assistant reply or
+// issue activity the user hasn't viewed yet.
+const unreadCondition = `(COALESCE(pr.issue_activity_at, '') > COALESCE(pr.last_viewed_at, '')
+		OR EXISTS (SELECT 1 FROM messages m WHERE m.prompt_request_id = pr.id AND m.role = 'assistant' AND m.superseded = 0
+		           AND m.created_at > COALESCE(pr.last_viewed_at, '')))`
+
+// RecordIssueActivity notes activity on the published issue of a prompt
+// request, such as a new comment, seen at the given time.
+func (q *Queries) RecordIssueActivity(ctx context.Context, id int64, at time.Time) error {
+	_, err := q.db.ExecContext(ctx,
+		`UPDATE prompt_requests SET issue_activity_at = MAX(COALESCE(issue_activity_at, ''), ?) WHERE id = ?`,
+		formatTime(at), id,
+	)
+	return err
+}
+
+func (q *Queries) UpdateLastViewedAt(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx,
+		`UPDATE prompt_requests SET last_viewed_at = strftime('%Y-%m-%dT%H:%M:%SZ', 'now') WHERE id = ?`, id,
+	)
+	return err
 }
 
 // Messages
 
-func (q *Queries) CreateMessage(promptRequestID int64, role, content string, rawResponse *string) (*models.Message, error) {
-	res, err := q.db.Exec(
+// CreateMessage saves a message. An assistant message's raw response is kept
+// as is, and its questions and generated prompt are recorded alongside.
+func (q *Queries) CreateMessage(ctx context.Context, promptRequestID int64, role, content string, rawResponse *string) (*models.Message, error) {
+	tx, err := q.begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("beginning message: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx,
 		`INSERT INTO messages (prompt_request_id, role, content, raw_response) VALUES (?, ?, ?, ?)`,
-		promptRequestID, role, content, rawResponse,
+		promptRequestID, role, q.sealer.seal(content), q.sealer.sealPtr(rawResponse),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("creating message: %w", err)
 	}
 	id, _ := res.LastInsertId()
-	return q.GetMessage(id)
+	if role == "assistant" && rawResponse != nil {
+		if resp := parseResponse(*rawResponse); resp != nil {
+			if err := saveResponse(ctx, tx, q.sealer, id, resp); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("committing message: %w", err)
+	}
+	return q.GetMessage(ctx, id)
 }
 
-func (q *Queries) GetMessage(id int64) (*models.Message, error) {
+func (q *Queries) GetMessage(ctx context.Context, id int64) (*models.Message, error) {
 	m := &models.Message{}
 	var createdAt string
-	err := q.db.QueryRow(
-		`SELECT id, prompt_request_id, role, content, raw_response, created_at FROM messages WHERE id = ?`, id,
-	).Scan(&m.ID, &m.PromptRequestID, &m.Role, &m.Content, &m.RawResponse, &createdAt)
+	var durationMS int64
+	err := q.db.QueryRowContext(ctx,
+		`SELECT `+messageColumns+` FROM messages WHERE id = ?`, id,
+	).Scan(&m.ID, &m.PromptRequestID, &m.Role, &m.Content, &createdAt, &m.MergedFromID, &m.Superseded, &m.PromptReady, &durationMS, &m.CostUSD)
 	if err != nil {
 		return nil, fmt.Errorf("getting message: %w", err)
 	}
-	m.CreatedAt, _ = time.Parse(time.DateTime, createdAt)
+	if err := q.sealer.openMessage(m); err != nil {
+		return nil, fmt.Errorf("getting message: %w", err)
+	}
+	m.CreatedAt = parseTime(createdAt)
+	m.Duration = time.Duration(durationMS) * time.Millisecond
 	return m, nil
 }
 
-func (q *Queries) ListMessages(promptRequestID int64) ([]models.Message, error) {
-	rows, err := q.db.Query(
-		`SELECT id, prompt_request_id, role, content, raw_response, created_at
-		 FROM messages WHERE prompt_request_id = ? ORDER BY created_at ASC`, promptRequestID,
-	)
+// SetMessageUsage records how long Claude took to write an assistant reply
+// and what it cost.
+func (q *Queries) SetMessageUsage(ctx context.Context, id int64, d time.Duration, costUSD float64) error {
+	if _, err := q.db.ExecContext(ctx, `UPDATE messages SET duration_ms = ?, cost_usd = ? WHERE id = ?`, d.Milliseconds(), costUSD, id); err != nil {
+		return fmt.Errorf("recording message usage: %w", err)
+	}
+	return nil
+}
+
+// PromptRequestUsage adds up how long Claude spent on a prompt request's
+// replies and what they cost, including replies later superseded by an edit.
+func (q *Queries) PromptRequestUsage(ctx context.Context, promptRequestID int64) (time.Duration, float64, error) {
+	var durationMS int64
+	var costUSD float64
+	err := q.db.QueryRowContext(ctx,
+		`SELECT COALESCE(SUM(duration_ms), 0), COALESCE(SUM(cost_usd), 0) FROM messages WHERE prompt_request_id = ?`, promptRequestID,
+	).Scan(&durationMS, &costUSD)
+	if err != nil {
+		return 0, 0, fmt.Errorf("summing message usage: %w", err)
+	}
+	return time.Duration(durationMS) * time.Millisecond, costUSD, nil
+}
+
+// messageColumns selects a message without its raw response, which can be
+// large and is only needed to export the conversation.
+const messageColumns = `id, prompt_request_id, role, content, created_at, merged_from_id, superseded, prompt_ready, duration_ms, cost_usd`
+
+// ListMessages lists the conversation's current messages, oldest first.
+// Messages superseded by an edit are left out.
+func (q *Queries) ListMessages(ctx context.Context, promptRequestID int64) ([]models.Message, error) {
+	return q.queryMessages(ctx, false, `prompt_request_id = ? AND superseded = 0 ORDER BY created_at ASC, id ASC`, promptRequestID)
+}
+
+// ListMessagesWithSuperseded lists every message, including those superseded
+// by an edit, for displaying the full history.
+func (q *Queries) ListMessagesWithSuperseded(ctx context.Context, promptRequestID int64) ([]models.Message, error) {
+	return q.queryMessages(ctx, false, `prompt_request_id = ? ORDER BY created_at ASC, id ASC`, promptRequestID)
+}
+
+// ListMessagesWithRawResponses is ListMessages with each reply's raw response.
+func (q *Queries) ListMessagesWithRawResponses(ctx context.Context, promptRequestID int64) ([]models.Message, error) {
+	return q.queryMessages(ctx, true, `prompt_request_id = ? AND superseded = 0 ORDER BY created_at ASC, id ASC`, promptRequestID)
+}
+
+// ListMessagePage lists up to limit messages of the full history, oldest
+// first: the latest ones, or with before set those preceding that message.
+// more reports whether there are earlier messages still.
+func (q *Queries) ListMessagePage(ctx context.Context, promptRequestID, before int64, limit int) (msgs []models.Message, more bool, err error) {
+	where := `prompt_request_id = ?1`
+	if before != 0 {
+		where += ` AND (created_at, id) < (SELECT created_at, id FROM messages WHERE id = ?2)`
+	}
+	msgs, err = q.queryMessages(ctx, false, where+` ORDER BY created_at DESC, id DESC LIMIT ?3`, promptRequestID, before, limit+1)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(msgs) > limit {
+		msgs, more = msgs[:limit], true
+	}
+	slices.Reverse(msgs)
+	return msgs, more, nil
+}
+
+// HasUserMessages reports whether the conversation has a current user message.
+func (q *Queries) HasUserMessages(ctx context.Context, promptRequestID int64) (bool, error) {
+	var exists bool
+	err := q.db.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM messages WHERE prompt_request_id = ? AND role = 'user' AND superseded = 0)`, promptRequestID,
+	).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("checking for user messages: %w", err)
+	}
+	return exists, nil
+}
+
+// queryMessages lists the messages matching where, which may end in ORDER BY
+// and LIMIT clauses.
+func (q *Queries) queryMessages(ctx context.Context, withRaw bool, where string, args ...any) ([]models.Message, error) {
+	raw := `NULL`
+	if withRaw {
+		raw = `raw_response`
+	}
+	rows, err := q.db.QueryContext(ctx, `SELECT `+messageColumns+`, `+raw+` FROM messages WHERE `+where, args...)
 	if err != nil {
 		return nil, fmt.Errorf("listing messages: %w", err)
 	}
@@ -386,41 +1018,174 @@ func (q *Queries) ListMessages(promptRequestID int64) ([]models.Message, error)
 	for rows.Next() {
 		var m models.Message
 		var createdAt string
-		if err := rows.Scan(&m.ID, &m.PromptRequestID, &m.Role, &m.Content, &m.RawResponse, &createdAt); err != nil {
+		var durationMS int64
+		if err := rows.Scan(&m.ID, &m.PromptRequestID, &m.Role, &m.Content, &createdAt, &m.MergedFromID, &m.Superseded, &m.PromptReady, &durationMS, &m.CostUSD, &m.RawResponse); err != nil {
+			return nil, fmt.Errorf("scanning message: %w", err)
+		}
+		if err := q.sealer.openMessage(&m); err != nil {
 			return nil, fmt.Errorf("scanning message: %w", err)
 		}
-		m.CreatedAt, _ = time.Parse(time.DateTime, createdAt)
+		m.CreatedAt = parseTime(createdAt)
+		m.Duration = time.Duration(durationMS) * time.Millisecond
 		results = append(results, m)
 	}
 	return results, rows.Err()
 }
 
+// Tags
+
+// AddTag attaches a tag to a prompt request, creating the tag if needed.
+func (q *Queries) AddTag(ctx context.Context, promptRequestID int64, name string) error {
+	if _, err := q.db.ExecContext(ctx, `INSERT INTO tags (name) VALUES (?) ON CONFLICT(name) DO NOTHING`, name); err != nil {
+		return fmt.Errorf("creating tag: %w", err)
+	}
+	_, err := q.db.ExecContext(ctx,
+		`INSERT INTO prompt_request_tags (prompt_request_id, tag_id)
+		 SELECT ?, id FROM tags WHERE name = ?
+		 ON CONFLICT DO NOTHING`, promptRequestID, name,
+	)
+	if err != nil {
+		return fmt.Errorf("tagging prompt request: %w", err)
+	}
+	return nil
+}
+
+// RemoveTag detaches a tag from a prompt request. Tags no longer in use are deleted.
+func (q *Queries) RemoveTag(ctx context.Context, promptRequestID int64, name string) error {
+	_, err := q.db.ExecContext(ctx,
+		`DELETE FROM prompt_request_tags
+		 WHERE prompt_request_id = ? AND tag_id = (SELECT id FROM tags WHERE name = ?)`,
+		promptRequestID, name,
+	)
+	if err != nil {
+		return fmt.Errorf("untagging prompt request: %w", err)
+	}
+	_, err = q.db.ExecContext(ctx, `DELETE FROM tags WHERE id NOT IN (SELECT tag_id FROM prompt_request_tags)`)
+	if err != nil {
+		return fmt.Errorf("deleting unused tags: %w", err)
+	}
+	return nil
+}
+
+// ListTagsForPromptRequest returns a prompt request's tag names, alphabetically.
+func (q *Queries) ListTagsForPromptRequest(ctx context.Context, promptRequestID int64) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx,
+		`SELECT t.name FROM tags t
+		 JOIN prompt_request_tags ptr ON ptr.tag_id = t.id
+		 WHERE ptr.prompt_request_id = ?
+		 ORDER BY t.name`, promptRequestID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("listing tags: %w", err)
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("scanning tag: %w", err)
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// ListTagsByPromptRequest returns every prompt request's tag names, keyed by prompt request ID.
+func (q *Queries) ListTagsByPromptRequest(ctx context.Context) (map[int64][]string, error) {
+	rows, err := q.db.QueryContext(ctx,
+		`SELECT ptr.prompt_request_id, t.name FROM prompt_request_tags ptr
+		 JOIN tags t ON t.id = ptr.tag_id
+		 ORDER BY t.name`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("listing tags: %w", err)
+	}
+	defer rows.Close()
+
+	tags := map[int64][]string{}
+	for rows.Next() {
+		var id int64
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, fmt.Errorf("scanning tag: %w", err)
+		}
+		tags[id] = append(tags[id], name)
+	}
+	return tags, rows.Err()
+}
+
+// ListTagCounts returns all tags with the number of active prompt requests using each.
+func (q *Queries) ListTagCounts(ctx context.Context) ([]models.TagCount, error) {
+	rows, err := q.db.QueryContext(ctx,
+		`SELECT t.name, COUNT(pr.id) FROM tags t
+		 JOIN prompt_request_tags ptr ON ptr.tag_id = t.id
+		 JOIN prompt_requests pr ON pr.id = ptr.prompt_request_id
+		 WHERE pr.status != 'deleted' AND pr.archived = 0
+		 GROUP BY t.id
+		 ORDER BY t.name`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("listing tag counts: %w", err)
+	}
+	defer rows.Close()
+
+	var results []models.TagCount
+	for rows.Next() {
+		var tc models.TagCount
+		if err := rows.Scan(&tc.Name, &tc.Count); err != nil {
+			return nil, fmt.Errorf("scanning tag count: %w", err)
+		}
+		results = append(results, tc)
+	}
+	return results, rows.Err()
+}
+
+// ListPromptRequestsByTag lists active prompt requests carrying a tag, drafts first.
+func (q *Queries) ListPromptRequestsByTag(ctx context.Context, name string) ([]models.PromptRequest, error) {
+	rows, err := q.db.QueryContext(ctx,
+		listPromptRequestsQuery+` AND pr.archived = 0
+		 AND pr.id IN (SELECT ptr.prompt_request_id FROM prompt_request_tags ptr
+		               JOIN tags t ON t.id = ptr.tag_id WHERE t.name = ?)
+		 ORDER BY
+		   CASE WHEN pr.status = 'draft' THEN 0 ELSE 1 END ASC,
+		   pr.updated_at DESC`, name,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("listing prompt requests by tag: %w", err)
+	}
+	defer rows.Close()
+
+	var results []models.PromptRequest
+	for rows.Next() {
+		pr, err := q.scanPromptRequest(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning prompt request: %w", err)
+		}
+		results = append(results, pr)
+	}
+	return results, rows.Err()
+}
+
 // Revisions
 
-func (q *Queries) CreateRevision(promptRequestID int64, content string, afterMessageID *int64) (*models.Revision, error) {
-	res, err := q.db.Exec(
-		`INSERT INTO revisions (prompt_request_id, content, after_message_id) VALUES (?, ?, ?)`,
-		promptRequestID, content, afterMessageID,
+// CreateRevision records a published body. sourceMessageID is the assistant
+// message whose generated prompt was published.
+func (q *Queries) CreateRevision(ctx context.Context, promptRequestID int64, content string, afterMessageID, sourceMessageID *int64) (*models.Revision, error) {
+	res, err := q.db.ExecContext(ctx,
+		`INSERT INTO revisions (prompt_request_id, content, after_message_id, source_message_id) VALUES (?, ?, ?, ?)`,
+		promptRequestID, q.sealer.seal(content), afterMessageID, sourceMessageID,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("creating revision: %w", err)
 	}
 	id, _ := res.LastInsertId()
-	r := &models.Revision{}
-	var publishedAt string
-	err = q.db.QueryRow(
-		`SELECT id, prompt_request_id, content, after_message_id, published_at FROM revisions WHERE id = ?`, id,
-	).Scan(&r.ID, &r.PromptRequestID, &r.Content, &r.AfterMessageID, &publishedAt)
-	if err != nil {
-		return nil, fmt.Errorf("getting revision: %w", err)
-	}
-	r.PublishedAt, _ = time.Parse(time.DateTime, publishedAt)
-	return r, nil
+	return q.GetRevision(ctx, id)
 }
 
-func (q *Queries) ListRevisions(promptRequestID int64) ([]models.Revision, error) {
-	rows, err := q.db.Query(
-		`SELECT id, prompt_request_id, content, after_message_id, published_at
+func (q *Queries) ListRevisions(ctx context.Context, promptRequestID int64) ([]models.Revision, error) {
+	rows, err := q.db.QueryContext(ctx,
+		`SELECT id, prompt_request_id, content, after_message_id, source_message_id, published_at
 		 FROM revisions WHERE prompt_request_id = ? ORDER BY published_at ASC`, promptRequestID,
 	)
 	if err != nil {
@@ -432,30 +1197,509 @@ func (q *Queries) ListRevisions(promptRequestID int64) ([]models.Revision, error
 	for rows.Next() {
 		var r models.Revision
 		var publishedAt string
-		if err := rows.Scan(&r.ID, &r.PromptRequestID, &r.Content, &r.AfterMessageID, &publishedAt); err != nil {
+		if err := rows.Scan(&r.ID, &r.PromptRequestID, &r.Content, &r.AfterMessageID, &r.SourceMessageID, &publishedAt); err != nil {
 			return nil, fmt.Errorf("scanning revision: %w", err)
 		}
-		r.PublishedAt, _ = time.Parse(time.DateTime, publishedAt)
+		if r.Content, err = q.sealer.open(r.Content); err != nil {
+			return nil, err
+		}
+		r.PublishedAt = parseTime(publishedAt)
 		results = append(results, r)
 	}
 	return results, rows.Err()
 }
 
-func (q *Queries) DeleteMessage(id int64) error {
-	_, err := q.db.Exec(`DELETE FROM messages WHERE id = ?`, id)
-	return err
+func (q *Queries) GetRevision(ctx context.Context, id int64) (*models.Revision, error) {
+	r := &models.Revision{}
+	var publishedAt string
+	err := q.db.QueryRowContext(ctx,
+		`SELECT id, prompt_request_id, content, after_message_id, source_message_id, published_at FROM revisions WHERE id = ?`, id,
+	).Scan(&r.ID, &r.PromptRequestID, &r.Content, &r.AfterMessageID, &r.SourceMessageID, &publishedAt)
+	if err != nil {
+		return nil, fmt.Errorf("getting revision: %w", err)
+	}
+	if r.Content, err = q.sealer.open(r.Content); err != nil {
+		return nil, err
+	}
+	r.PublishedAt = parseTime(publishedAt)
+	return r, nil
+}
+
+// dropStaleSummary clears a running summary that covers messages which were
+// superseded or deleted.
+const dropStaleSummary = `UPDATE prompt_requests SET summary = '', summary_message_id = NULL
+	 WHERE id = ?1 AND summary_message_id IS NOT NULL
+	   AND summary_message_id NOT IN (SELECT id FROM messages WHERE prompt_request_id = ?1 AND superseded = 0)`
+
+// StartSummarizedSession stores a running summary covering messages up to
+// summaryMessageID and moves the prompt request to a fresh Claude session
+// whose history ends at forkMessageID.
+func (q *Queries) StartSummarizedSession(ctx context.Context, promptRequestID int64, sessionID, summary string, summaryMessageID, forkMessageID int64) error {
+	_, err := q.db.ExecContext(ctx,
+		`UPDATE prompt_requests
+		 SET session_id = ?, summary = ?, summary_message_id = ?, fork_message_id = ?, prewarmed = 0
+		 WHERE id = ?`,
+		sessionID, q.sealer.seal(summary), summaryMessageID, forkMessageID, promptRequestID,
+	)
+	if err != nil {
+		return fmt.Errorf("starting summarized session: %w", err)
+	}
+	return nil
 }
 
-func (q *Queries) GetLastMessage(promptRequestID int64) (*models.Message, error) {
+// StartPrewarmedSession moves a prompt request Claude hasn't replied to yet
+// to the session sessionID, started ahead of its first message. It reports
+// false, changing nothing, if the prompt request moved off fromSessionID or
+// got a reply meanwhile.
+func (q *Queries) StartPrewarmedSession(ctx context.Context, promptRequestID int64, fromSessionID, sessionID string) (bool, error) {
+	res, err := q.db.ExecContext(ctx,
+		`UPDATE prompt_requests SET session_id = ?, prewarmed = 1
+		 WHERE id = ? AND session_id = ?
+		   AND NOT EXISTS (SELECT 1 FROM messages WHERE prompt_request_id = prompt_requests.id AND role = 'assistant')`,
+		sessionID, promptRequestID, fromSessionID,
+	)
+	if err != nil {
+		return false, fmt.Errorf("starting prewarmed session: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// BranchFromMessage replaces a user message with edited content. The message
+// and everything after it are marked superseded, the prompt request moves to
+// a fresh Claude session, and the remaining messages become the prefix that
+// is replayed to it (recorded as the fork point). Returns the new message.
+func (q *Queries) BranchFromMessage(ctx context.Context, promptRequestID, messageID int64, sessionID, content string) (*models.Message, error) {
+	tx, err := q.begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("beginning branch: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx,
+		`UPDATE messages SET superseded = 1
+		 WHERE prompt_request_id = ?1 AND superseded = 0
+		   AND (created_at, id) >= (SELECT created_at, id FROM messages
+		                            WHERE id = ?2 AND prompt_request_id = ?1 AND role = 'user' AND superseded = 0)`,
+		promptRequestID, messageID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("superseding messages: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, fmt.Errorf("superseding messages: %w", sql.ErrNoRows)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`UPDATE prompt_requests
+		 SET session_id = ?1, prewarmed = 0, updated_at = strftime('%Y-%m-%dT%H:%M:%SZ', 'now'),
+		     fork_message_id = (SELECT MAX(id) FROM messages WHERE prompt_request_id = ?2 AND superseded = 0)
+		 WHERE id = ?2`,
+		sessionID, promptRequestID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("starting new session: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, dropStaleSummary, promptRequestID); err != nil {
+		return nil, fmt.Errorf("dropping summary: %w", err)
+	}
+
+	res, err = tx.ExecContext(ctx,
+		`INSERT INTO messages (prompt_request_id, role, content) VALUES (?, 'user', ?)`,
+		promptRequestID, q.sealer.seal(content),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("creating message: %w", err)
+	}
+	id, _ := res.LastInsertId()
+
+	// The edited message keeps the original's attachments and file references.
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO attachments (prompt_request_id, message_id, filename, content_type, path, size, remote_url)
+		 SELECT prompt_request_id, ?, filename, content_type, path, size, remote_url
+		 FROM attachments WHERE message_id = ?`,
+		id, messageID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("copying attachments: %w", err)
+	}
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO file_references (prompt_request_id, message_id, path, start_line, end_line)
+		 SELECT prompt_request_id, ?, path, start_line, end_line
+		 FROM file_references WHERE message_id = ?`,
+		id, messageID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("copying file references: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("committing branch: %w", err)
+	}
+	return q.GetMessage(ctx, id)
+}
+
+// ErrExchangePublished is returned when undoing an exchange that a published
+// revision was generated from.
+var ErrExchangePublished = errors.New("exchange has been published")
+
+// UndoLastExchange deletes the latest user message and any replies to it.
+// Like BranchFromMessage, the prompt request moves to a fresh Claude session
+// that is replayed the remaining messages.
+func (q *Queries) UndoLastExchange(ctx context.Context, promptRequestID int64, sessionID string) error {
+	tx, err := q.begin(ctx)
+	if err != nil {
+		return fmt.Errorf("beginning undo: %w", err)
+	}
+	defer tx.Rollback()
+
+	const exchange = `SELECT id FROM messages
+		 WHERE prompt_request_id = ?1 AND superseded = 0
+		   AND (created_at, id) >= (SELECT created_at, id FROM messages
+		                            WHERE prompt_request_id = ?1 AND role = 'user' AND superseded = 0
+		                            ORDER BY created_at DESC, id DESC LIMIT 1)`
+
+	var published int
+	err = tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM revisions WHERE after_message_id IN (`+exchange+`)`, promptRequestID).Scan(&published)
+	if err != nil {
+		return fmt.Errorf("checking revisions: %w", err)
+	}
+	if published > 0 {
+		return ErrExchangePublished
+	}
+
+	// Attachments and file references go back to the composer so they can be
+	// sent again.
+	_, err = tx.ExecContext(ctx, `UPDATE attachments SET message_id = NULL WHERE message_id IN (`+exchange+`)`, promptRequestID)
+	if err != nil {
+		return fmt.Errorf("detaching attachments: %w", err)
+	}
+	_, err = tx.ExecContext(ctx, `UPDATE file_references SET message_id = NULL WHERE message_id IN (`+exchange+`)`, promptRequestID)
+	if err != nil {
+		return fmt.Errorf("detaching file references: %w", err)
+	}
+
+	if err := deleteResponses(ctx, tx, `SELECT id FROM messages WHERE id IN (`+exchange+`)`, promptRequestID); err != nil {
+		return err
+	}
+	res, err := tx.ExecContext(ctx, `DELETE FROM messages WHERE id IN (`+exchange+`)`, promptRequestID)
+	if err != nil {
+		return fmt.Errorf("deleting messages: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("deleting messages: %w", sql.ErrNoRows)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`UPDATE prompt_requests
+		 SET session_id = ?1, prewarmed = 0, updated_at = strftime('%Y-%m-%dT%H:%M:%SZ', 'now'),
+		     fork_message_id = (SELECT MAX(id) FROM messages WHERE prompt_request_id = ?2 AND superseded = 0)
+		 WHERE id = ?2`,
+		sessionID, promptRequestID,
+	)
+	if err != nil {
+		return fmt.Errorf("starting new session: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, dropStaleSummary, promptRequestID); err != nil {
+		return fmt.Errorf("dropping summary: %w", err)
+	}
+	return tx.Commit()
+}
+
+func (q *Queries) DeleteMessage(ctx context.Context, id int64) error {
+	tx, err := q.begin(ctx)
+	if err != nil {
+		return fmt.Errorf("beginning delete: %w", err)
+	}
+	defer tx.Rollback()
+	if err := deleteResponses(ctx, tx, `SELECT ?`, id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM messages WHERE id = ?`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (q *Queries) GetLastMessage(ctx context.Context, promptRequestID int64) (*models.Message, error) {
 	m := &models.Message{}
 	var createdAt string
-	err := q.db.QueryRow(
-		`SELECT id, prompt_request_id, role, content, raw_response, created_at
-		 FROM messages WHERE prompt_request_id = ? ORDER BY created_at DESC LIMIT 1`, promptRequestID,
-	).Scan(&m.ID, &m.PromptRequestID, &m.Role, &m.Content, &m.RawResponse, &createdAt)
+	var durationMS int64
+	err := q.db.QueryRowContext(ctx,
+		`SELECT `+messageColumns+`
+		 FROM messages WHERE prompt_request_id = ? AND superseded = 0
+		 ORDER BY created_at DESC, id DESC LIMIT 1`, promptRequestID,
+	).Scan(&m.ID, &m.PromptRequestID, &m.Role, &m.Content, &createdAt, &m.MergedFromID, &m.Superseded, &m.PromptReady, &durationMS, &m.CostUSD)
 	if err != nil {
 		return nil, fmt.Errorf("getting last message: %w", err)
 	}
-	m.CreatedAt, _ = time.Parse(time.DateTime, createdAt)
+	if err := q.sealer.openMessage(m); err != nil {
+		return nil, fmt.Errorf("getting last message: %w", err)
+	}
+	m.CreatedAt = parseTime(createdAt)
+	m.Duration = time.Duration(durationMS) * time.Millisecond
 	return m, nil
 }
+
+// Jobs
+
+// SetJobStatus records the current async operation state for a prompt request.
+func (q *Queries) SetJobStatus(ctx context.Context, promptRequestID int64, status, errMsg string, startedAt *time.Time) error {
+	var started *string
+	if startedAt != nil {
+		v := formatTime(*startedAt)
+		started = &v
+	}
+	_, err := q.db.ExecContext(ctx,
+		`INSERT INTO jobs (prompt_request_id, status, error, started_at) VALUES (?, ?, ?, ?)
+		 ON CONFLICT(prompt_request_id) DO UPDATE SET
+		   status = excluded.status, error = excluded.error,
+		   started_at = excluded.started_at, updated_at = strftime('%Y-%m-%dT%H:%M:%SZ', 'now')`,
+		promptRequestID, status, errMsg, started,
+	)
+	if err != nil {
+		return fmt.Errorf("setting job status: %w", err)
+	}
+	return nil
+}
+
+// DeleteIdleJobs forgets the status of archived or trashed prompt requests
+// that have nothing in flight. Restoring one finds its status from scratch.
+func (q *Queries) DeleteIdleJobs(ctx context.Context) (int64, error) {
+	res, err := q.db.ExecContext(ctx,
+		`DELETE FROM jobs
+		 WHERE status NOT IN ('cloning', 'pulling', 'processing')
+		   AND prompt_request_id IN (SELECT id FROM prompt_requests WHERE archived = 1 OR status = 'deleted')`,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("deleting idle jobs: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+func (q *Queries) GetJob(ctx context.Context, promptRequestID int64) (*models.Job, error) {
+	var j models.Job
+	var startedAt *string
+	var updatedAt string
+	err := q.db.QueryRowContext(ctx,
+		`SELECT prompt_request_id, status, error, started_at, updated_at FROM jobs WHERE prompt_request_id = ?`,
+		promptRequestID,
+	).Scan(&j.PromptRequestID, &j.Status, &j.Error, &startedAt, &updatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("getting job: %w", err)
+	}
+	if startedAt != nil {
+		t := parseTime(*startedAt)
+		j.StartedAt = &t
+	}
+	j.UpdatedAt = parseTime(updatedAt)
+	return &j, nil
+}
+
+func (q *Queries) DeleteJob(ctx context.Context, promptRequestID int64) error {
+	_, err := q.db.ExecContext(ctx, `DELETE FROM jobs WHERE prompt_request_id = ?`, promptRequestID)
+	return err
+}
+
+// ListInFlightJobs returns jobs that were cloning, pulling, or processing. After a
+// restart these have no goroutine driving them and need to be reconciled.
+func (q *Queries) ListInFlightJobs(ctx context.Context) ([]models.Job, error) {
+	rows, err := q.db.QueryContext(ctx,
+		`SELECT j.prompt_request_id, j.status, j.error, j.updated_at, r.url
+		 FROM jobs j
+		 JOIN prompt_requests pr ON pr.id = j.prompt_request_id
+		 JOIN repositories r ON r.id = pr.repository_id
+		 WHERE j.status IN ('cloning', 'pulling', 'processing')`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("listing in-flight jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var results []models.Job
+	for rows.Next() {
+		var j models.Job
+		var updatedAt string
+		if err := rows.Scan(&j.PromptRequestID, &j.Status, &j.Error, &updatedAt, &j.RepoURL); err != nil {
+			return nil, fmt.Errorf("scanning job: %w", err)
+		}
+		j.UpdatedAt = parseTime(updatedAt)
+		results = append(results, j)
+	}
+	return results, rows.Err()
+}
+
+// Job Queue
+
+const queuedJobColumns = `id, kind, ref, payload, status, attempts, max_attempts, last_error,
+		        run_after, locked_until, created_at, updated_at`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanQueuedJob(row rowScanner) (*models.QueuedJob, error) {
+	j := &models.QueuedJob{}
+	var runAfter, createdAt, updatedAt string
+	var lockedUntil *string
+	if err := row.Scan(&j.ID, &j.Kind, &j.Ref, &j.Payload, &j.Status, &j.Attempts, &j.MaxAttempts,
+		&j.LastError, &runAfter, &lockedUntil, &createdAt, &updatedAt); err != nil {
+		return nil, err
+	}
+	j.RunAfter = parseTime(runAfter)
+	j.CreatedAt = parseTime(createdAt)
+	j.UpdatedAt = parseTime(updatedAt)
+	if lockedUntil != nil {
+		t := parseTime(*lockedUntil)
+		j.LockedUntil = &t
+	}
+	return j, nil
+}
+
+// EnqueueJob adds a job to the queue unless an identical kind/ref is already
+// queued or running. Returns whether a new job was inserted.
+func (q *Queries) EnqueueJob(ctx context.Context, kind, ref, payload string, maxAttempts int) (bool, error) {
+	res, err := q.db.ExecContext(ctx,
+		`INSERT INTO job_queue (kind, ref, payload, max_attempts)
+		 SELECT ?, ?, ?, ?
+		 WHERE NOT EXISTS (
+		   SELECT 1 FROM job_queue WHERE kind = ? AND ref = ? AND status IN ('queued', 'running')
+		 )`,
+		kind, ref, payload, maxAttempts, kind, ref,
+	)
+	if err != nil {
+		return false, fmt.Errorf("enqueueing job: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// ClaimQueuedJob locks the oldest runnable job for the visibility timeout and
+// returns it. Running jobs whose lock has expired are reclaimed. maxRunning
+// caps how many jobs of a kind run at once; kinds it leaves out are only
+// limited by the number of workers. Returns nil when no job can run.
+func (q *Queries) ClaimQueuedJob(ctx context.Context, visibility time.Duration, maxRunning map[string]int) (*models.QueuedJob, error) {
+	limits, err := json.Marshal(maxRunning)
+	if err != nil {
+		return nil, fmt.Errorf("claiming job: %w", err)
+	}
+	row := q.db.QueryRowContext(ctx,
+		`UPDATE job_queue
+		 SET status = 'running', attempts = attempts + 1,
+		     locked_until = strftime('%Y-%m-%dT%H:%M:%SZ', 'now', ?1), updated_at = strftime('%Y-%m-%dT%H:%M:%SZ', 'now')
+		 WHERE id = (
+		   SELECT id FROM job_queue j
+		   WHERE ((status = 'queued' AND run_after <= strftime('%Y-%m-%dT%H:%M:%SZ', 'now'))
+		      OR (status = 'running' AND locked_until < strftime('%Y-%m-%dT%H:%M:%SZ', 'now')))
+		     AND COALESCE((SELECT value FROM json_each(?2) WHERE key = j.kind) > (
+		           SELECT COUNT(*) FROM job_queue r
+		           WHERE r.kind = j.kind AND r.status = 'running' AND r.locked_until >= strftime('%Y-%m-%dT%H:%M:%SZ', 'now')
+		         ), 1)
+		   ORDER BY run_after ASC, id ASC
+		   LIMIT 1
+		 )
+		 RETURNING `+queuedJobColumns,
+		fmt.Sprintf("+%d seconds", int(visibility.Seconds())), string(limits),
+	)
+	j, err := scanQueuedJob(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("claiming job: %w", err)
+	}
+	return j, nil
+}
+
+func (q *Queries) CompleteQueuedJob(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx,
+		`UPDATE job_queue SET status = 'done', last_error = '', locked_until = NULL, updated_at = strftime('%Y-%m-%dT%H:%M:%SZ', 'now') WHERE id = ?`, id,
+	)
+	return err
+}
+
+// FailQueuedJob records a failed attempt. The job is re-queued after retryIn
+// unless it has used all its attempts, in which case it is marked failed.
+func (q *Queries) FailQueuedJob(ctx context.Context, id int64, errMsg string, retryIn time.Duration) error {
+	_, err := q.db.ExecContext(ctx,
+		`UPDATE job_queue
+		 SET status = CASE WHEN attempts >= max_attempts THEN 'failed' ELSE 'queued' END,
+		     last_error = ?, locked_until = NULL,
+		     run_after = strftime('%Y-%m-%dT%H:%M:%SZ', 'now', ?), updated_at = strftime('%Y-%m-%dT%H:%M:%SZ', 'now')
+		 WHERE id = ?`,
+		errMsg, fmt.Sprintf("+%d seconds", int(retryIn.Seconds())), id,
+	)
+	return err
+}
+
+// AbandonQueuedJob marks a job failed without using its remaining attempts,
+// for failures retrying can't fix.
+func (q *Queries) AbandonQueuedJob(ctx context.Context, id int64, errMsg string) error {
+	_, err := q.db.ExecContext(ctx,
+		`UPDATE job_queue SET status = 'failed', last_error = ?, locked_until = NULL, updated_at = strftime('%Y-%m-%dT%H:%M:%SZ', 'now')
+		 WHERE id = ?`, errMsg, id,
+	)
+	return err
+}
+
+// QueuedJobsAhead reports whether the kind/ref job is waiting in the queue
+// rather than running, and how many jobs of its kind will be run before it.
+func (q *Queries) QueuedJobsAhead(ctx context.Context, kind, ref string) (waiting bool, ahead int, err error) {
+	err = q.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM job_queue j, job_queue o
+		 WHERE j.kind = ? AND j.ref = ? AND j.status = 'queued'
+		   AND o.kind = j.kind AND o.status = 'queued' AND (o.run_after, o.id) < (j.run_after, j.id)`, kind, ref,
+	).Scan(&ahead)
+	if err != nil {
+		return false, 0, fmt.Errorf("finding job in queue: %w", err)
+	}
+	err = q.db.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM job_queue WHERE kind = ? AND ref = ? AND status = 'queued')`, kind, ref,
+	).Scan(&waiting)
+	if err != nil {
+		return false, 0, fmt.Errorf("finding job in queue: %w", err)
+	}
+	return waiting, ahead, nil
+}
+
+// RetryQueuedJob puts a failed job back in the queue with a fresh set of attempts.
+func (q *Queries) RetryQueuedJob(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx,
+		`UPDATE job_queue
+		 SET status = 'queued', attempts = 0, run_after = strftime('%Y-%m-%dT%H:%M:%SZ', 'now'), updated_at = strftime('%Y-%m-%dT%H:%M:%SZ', 'now')
+		 WHERE id = ? AND status = 'failed'`, id,
+	)
+	return err
+}
+
+// RequeueRunningJobs returns every running job to the queue. Only one process
+// uses the database, so at startup any running job was interrupted.
+func (q *Queries) RequeueRunningJobs(ctx context.Context) (int64, error) {
+	res, err := q.db.ExecContext(ctx,
+		`UPDATE job_queue SET status = 'queued', attempts = MAX(attempts - 1, 0), locked_until = NULL, updated_at = strftime('%Y-%m-%dT%H:%M:%SZ', 'now')
+		 WHERE status = 'running'`,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("requeueing running jobs: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+func (q *Queries) ListQueuedJobs(ctx context.Context, limit int) ([]models.QueuedJob, error) {
+	rows, err := q.db.QueryContext(ctx,
+		`SELECT `+queuedJobColumns+` FROM job_queue ORDER BY id DESC LIMIT ?`, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("listing queued jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var results []models.QueuedJob
+	for rows.Next() {
+		j, err := scanQueuedJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning queued job: %w", err)
+		}
+		results = append(results, *j)
+	}
+	return results, rows.Err()
+}
@@ -0,0 +1,96 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/esnunes/prompter/internal/models"
+)
+
+const fileReferenceColumns = `SELECT id, prompt_request_id, message_id, path, start_line, end_line, created_at
+		 FROM file_references`
+
+func scanFileReference(row rowScanner) (models.FileReference, error) {
+	var f models.FileReference
+	var createdAt string
+	err := row.Scan(&f.ID, &f.PromptRequestID, &f.MessageID, &f.Path, &f.StartLine, &f.EndLine, &createdAt)
+	if err != nil {
+		return f, err
+	}
+	f.CreatedAt = parseTime(createdAt)
+	return f, nil
+}
+
+// CreateFileReference records a file reference as pending for the next user message.
+func (q *Queries) CreateFileReference(ctx context.Context, promptRequestID int64, path string, startLine, endLine int) error {
+	_, err := q.db.ExecContext(ctx,
+		`INSERT INTO file_references (prompt_request_id, path, start_line, end_line) VALUES (?, ?, ?, ?)`,
+		promptRequestID, path, startLine, endLine,
+	)
+	if err != nil {
+		return fmt.Errorf("creating file reference: %w", err)
+	}
+	return nil
+}
+
+func (q *Queries) GetFileReference(ctx context.Context, id int64) (*models.FileReference, error) {
+	f, err := scanFileReference(q.db.QueryRowContext(ctx, fileReferenceColumns+` WHERE id = ?`, id))
+	if err != nil {
+		return nil, fmt.Errorf("getting file reference: %w", err)
+	}
+	return &f, nil
+}
+
+// ListPendingFileReferences lists references not yet sent with a message.
+func (q *Queries) ListPendingFileReferences(ctx context.Context, promptRequestID int64) ([]models.FileReference, error) {
+	return q.listFileReferences(ctx, fileReferenceColumns+` WHERE prompt_request_id = ? AND message_id IS NULL ORDER BY id`, promptRequestID)
+}
+
+// ListMessageFileReferences lists references sent with messages, including
+// superseded ones.
+func (q *Queries) ListMessageFileReferences(ctx context.Context, promptRequestID int64) ([]models.FileReference, error) {
+	return q.listFileReferences(ctx, fileReferenceColumns+` WHERE prompt_request_id = ? AND message_id IS NOT NULL ORDER BY id`, promptRequestID)
+}
+
+func (q *Queries) listFileReferences(ctx context.Context, query string, args ...any) ([]models.FileReference, error) {
+	rows, err := q.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("listing file references: %w", err)
+	}
+	defer rows.Close()
+
+	var results []models.FileReference
+	for rows.Next() {
+		f, err := scanFileReference(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning file reference: %w", err)
+		}
+		results = append(results, f)
+	}
+	return results, rows.Err()
+}
+
+// AttachPendingFileReferences links all pending references to a message.
+func (q *Queries) AttachPendingFileReferences(ctx context.Context, promptRequestID, messageID int64) error {
+	_, err := q.db.ExecContext(ctx,
+		`UPDATE file_references SET message_id = ? WHERE prompt_request_id = ? AND message_id IS NULL`,
+		messageID, promptRequestID,
+	)
+	if err != nil {
+		return fmt.Errorf("attaching file references: %w", err)
+	}
+	return nil
+}
+
+// DeletePendingFileReference removes a reference that hasn't been sent yet.
+func (q *Queries) DeletePendingFileReference(ctx context.Context, id int64) error {
+	res, err := q.db.ExecContext(ctx, `DELETE FROM file_references WHERE id = ? AND message_id IS NULL`, id)
+	if err != nil {
+		return fmt.Errorf("deleting file reference: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("deleting file reference: %w", sql.ErrNoRows)
+	}
+	return nil
+}
@@ -0,0 +1,695 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/esnunes/prompter/internal/claude"
+	"github.com/esnunes/prompter/internal/models"
+)
+
+// Assistant replies are stored verbatim in messages.raw_response; the parts
+// the UI needs (questions and their options, whether the prompt is ready, the
+// generated title/motivation/prompt) are copied into their own tables when
+// the message is saved, so pages never re-parse the JSON.
+const responseTables = `
+CREATE TABLE questions (
+    id                INTEGER PRIMARY KEY AUTOINCREMENT,
+    message_id        INTEGER NOT NULL REFERENCES messages(id),
+    position          INTEGER NOT NULL,
+    header            TEXT NOT NULL DEFAULT '',
+    text              TEXT NOT NULL,
+    multi_select      INTEGER NOT NULL DEFAULT 0,
+    answer_message_id INTEGER REFERENCES messages(id),
+    answer            TEXT,
+    UNIQUE (message_id, position)
+);
+
+CREATE TABLE question_options (
+    question_id INTEGER NOT NULL REFERENCES questions(id),
+    position    INTEGER NOT NULL,
+    label       TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (question_id, position)
+);
+
+CREATE TABLE generated_contents (
+    message_id INTEGER PRIMARY KEY REFERENCES messages(id),
+    title      TEXT NOT NULL DEFAULT '',
+    motivation TEXT NOT NULL DEFAULT '',
+    prompt     TEXT NOT NULL
+);
+
+CREATE INDEX idx_questions_answer_message ON questions(answer_message_id);
+`
+
+// parseResponse extracts the structured reply from a raw_response: the
+// claude CLI output with the reply in structured_output or as a JSON result
+// string, or the bare reply. Replies from before multiple questions were
+// supported carry a single "question" instead.
+func parseResponse(raw string) *claude.Response {
+	var resp *claude.Response
+	var wrapper struct {
+		StructuredOutput *claude.Response `json:"structured_output"`
+		Result           string           `json:"result"`
+	}
+	if err := json.Unmarshal([]byte(raw), &wrapper); err == nil {
+		if wrapper.StructuredOutput != nil {
+			resp = wrapper.StructuredOutput
+		} else if wrapper.Result != "" {
+			var r claude.Response
+			if json.Unmarshal([]byte(wrapper.Result), &r) == nil {
+				resp = &r
+			}
+		}
+	}
+	if resp == nil {
+		var r claude.Response
+		if json.Unmarshal([]byte(raw), &r) != nil || (r.Message == "" && r.GeneratedPrompt == "") {
+			return nil
+		}
+		resp = &r
+	}
+
+	if len(resp.Questions) == 0 {
+		var legacy struct {
+			StructuredOutput *struct {
+				Question *claude.Question `json:"question"`
+			} `json:"structured_output"`
+		}
+		if json.Unmarshal([]byte(raw), &legacy) == nil && legacy.StructuredOutput != nil && legacy.StructuredOutput.Question != nil {
+			resp.Questions = []claude.Question{*legacy.StructuredOutput.Question}
+		}
+	}
+	return resp
+}
+
+// saveResponse records the structured parts of an assistant message's reply,
+// encrypting their text with s.
+func saveResponse(ctx context.Context, tx dbtx, s *sealer, messageID int64, resp *claude.Response) error {
+	if resp.PromptReady {
+		if _, err := tx.ExecContext(ctx, `UPDATE messages SET prompt_ready = 1 WHERE id = ?`, messageID); err != nil {
+			return fmt.Errorf("marking prompt ready: %w", err)
+		}
+	}
+	for i, question := range resp.Questions {
+		res, err := tx.ExecContext(ctx,
+			`INSERT INTO questions (message_id, position, header, text, multi_select) VALUES (?, ?, ?, ?, ?)`,
+			messageID, i, s.seal(question.Header), s.seal(question.Text), question.MultiSelect,
+		)
+		if err != nil {
+			return fmt.Errorf("saving question: %w", err)
+		}
+		questionID, _ := res.LastInsertId()
+		for j, opt := range question.Options {
+			_, err := tx.ExecContext(ctx,
+				`INSERT INTO question_options (question_id, position, label, description) VALUES (?, ?, ?, ?)`,
+				questionID, j, s.seal(opt.Label), s.seal(opt.Description),
+			)
+			if err != nil {
+				return fmt.Errorf("saving question option: %w", err)
+			}
+		}
+	}
+	for _, issue := range resp.RelatedIssues {
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO related_issues (message_id, number, title) VALUES (?, ?, ?) ON CONFLICT DO NOTHING`,
+			messageID, issue.Number, s.seal(issue.Title),
+		)
+		if err != nil {
+			return fmt.Errorf("saving related issue: %w", err)
+		}
+	}
+	if resp.GeneratedPrompt != "" {
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO generated_contents (message_id, title, motivation, prompt, suggested_labels, estimated_size, size_rationale, non_goals, alternatives,
+			     affected_areas, clarity_score, ambiguities, glossary, test_plan, affected_paths)
+			 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			messageID, s.seal(resp.GeneratedTitle), s.seal(resp.GeneratedMotivation), s.seal(resp.GeneratedPrompt),
+			s.seal(joinList(resp.SuggestedLabels)), resp.EstimatedSize, s.seal(resp.SizeRationale),
+			s.seal(joinList(resp.NonGoals)), s.seal(joinList(resp.Alternatives)),
+			s.seal(joinList(resp.AffectedAreas)), resp.ClarityScore, s.seal(joinList(resp.Ambiguities)),
+			s.seal(joinGlossary(resp.Glossary)), s.seal(joinList(resp.TestPlan)), s.seal(joinList(resp.AffectedPaths)),
+		)
+		if err != nil {
+			return fmt.Errorf("saving generated content: %w", err)
+		}
+	}
+	return nil
+}
+
+// indexResponses runs saveResponse for the assistant messages the query
+// selects as (id, raw_response) pairs, decrypting and encrypting with s.
+func indexResponses(ctx context.Context, tx dbtx, s *sealer, query string, args ...any) error {
+	return eachResponse(ctx, tx, s, func(id int64, resp *claude.Response) error {
+		return saveResponse(ctx, tx, s, id, resp)
+	}, query, args...)
+}
+
+// eachResponse calls fn with the parsed reply of every assistant message the
+// query selects as (id, raw_response) pairs, decrypting them with s.
+func eachResponse(ctx context.Context, tx dbtx, s *sealer, fn func(id int64, resp *claude.Response) error, query string, args ...any) error {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("querying responses: %w", err)
+	}
+	type pending struct {
+		id  int64
+		raw string
+	}
+	var all []pending
+	for rows.Next() {
+		var p pending
+		if err := rows.Scan(&p.id, &p.raw); err != nil {
+			rows.Close()
+			return fmt.Errorf("scanning response: %w", err)
+		}
+		all = append(all, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, p := range all {
+		raw, err := s.open(p.raw)
+		if err != nil {
+			return err
+		}
+		if resp := parseResponse(raw); resp != nil {
+			if err := fn(p.id, resp); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// plaintextResponses selects the replies migrations can read. They run
+// without the passphrase, so encrypted replies are left out; their generated
+// content keeps the defaults of columns added after they were saved.
+const plaintextResponses = `SELECT id, raw_response FROM messages
+	 WHERE role = 'assistant' AND raw_response IS NOT NULL AND raw_response NOT LIKE '` + encryptedPrefix + `%'
+	 ORDER BY id`
+
+// saveResponseV19 is saveResponse as migration 19 shipped it: it writes only
+// the columns that migration creates. Later migrations backfill their own
+// columns, so it must not change along with saveResponse.
+func saveResponseV19(tx *sql.Tx, messageID int64, resp *claude.Response) error {
+	if resp.PromptReady {
+		if _, err := tx.Exec(`UPDATE messages SET prompt_ready = 1 WHERE id = ?`, messageID); err != nil {
+			return fmt.Errorf("marking prompt ready: %w", err)
+		}
+	}
+	for i, question := range resp.Questions {
+		res, err := tx.Exec(
+			`INSERT INTO questions (message_id, position, header, text, multi_select) VALUES (?, ?, ?, ?, ?)`,
+			messageID, i, question.Header, question.Text, question.MultiSelect,
+		)
+		if err != nil {
+			return fmt.Errorf("saving question: %w", err)
+		}
+		questionID, _ := res.LastInsertId()
+		for j, opt := range question.Options {
+			_, err := tx.Exec(
+				`INSERT INTO question_options (question_id, position, label, description) VALUES (?, ?, ?, ?)`,
+				questionID, j, opt.Label, opt.Description,
+			)
+			if err != nil {
+				return fmt.Errorf("saving question option: %w", err)
+			}
+		}
+	}
+	if resp.GeneratedPrompt != "" {
+		_, err := tx.Exec(
+			`INSERT INTO generated_contents (message_id, title, motivation, prompt) VALUES (?, ?, ?, ?)`,
+			messageID, resp.GeneratedTitle, resp.GeneratedMotivation, resp.GeneratedPrompt,
+		)
+		if err != nil {
+			return fmt.Errorf("saving generated content: %w", err)
+		}
+	}
+	return nil
+}
+
+// backfillGenerated fills a generated_contents column added after migration
+// 19 from the stored replies, with the value saveResponse would have written.
+// Values other than the column's empty default are left alone.
+func backfillGenerated(column string, value func(*claude.Response) any) func(*sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		return eachResponse(context.Background(), tx, nil, func(id int64, resp *claude.Response) error {
+			if resp.GeneratedPrompt == "" {
+				return nil
+			}
+			_, err := tx.Exec(
+				fmt.Sprintf(`UPDATE generated_contents SET %[1]s = ? WHERE message_id = ? AND %[1]s IN ('', 0)`, column),
+				value(resp), id,
+			)
+			if err != nil {
+				return fmt.Errorf("backfilling %s: %w", column, err)
+			}
+			return nil
+		}, plaintextResponses)
+	}
+}
+
+// migrateResponses creates the response tables and fills them from every
+// stored reply. Answers are recovered from the user message that followed
+// each set of questions, in the format the answer form sends them.
+func migrateResponses(tx *sql.Tx) error {
+	if _, err := tx.Exec(responseTables); err != nil {
+		return err
+	}
+	err := eachResponse(context.Background(), tx, nil, func(id int64, resp *claude.Response) error {
+		return saveResponseV19(tx, id, resp)
+	}, plaintextResponses)
+	if err != nil {
+		return err
+	}
+
+	rows, err := tx.Query(
+		`SELECT q.message_id, q.position, q.header, m.id, m.content
+		 FROM questions q
+		 JOIN messages a ON a.id = q.message_id
+		 JOIN messages m ON m.id = (SELECT MIN(id) FROM messages
+		                            WHERE prompt_request_id = a.prompt_request_id AND role = 'user' AND id > a.id)
+		 ORDER BY q.message_id, q.position`,
+	)
+	if err != nil {
+		return fmt.Errorf("querying answered questions: %w", err)
+	}
+	type answered struct {
+		questionMessageID int64
+		position          int
+		header            string
+		answerMessageID   int64
+		content           string
+	}
+	var all []answered
+	counts := make(map[int64]int)
+	for rows.Next() {
+		var a answered
+		if err := rows.Scan(&a.questionMessageID, &a.position, &a.header, &a.answerMessageID, &a.content); err != nil {
+			rows.Close()
+			return fmt.Errorf("scanning answered question: %w", err)
+		}
+		all = append(all, a)
+		counts[a.questionMessageID]++
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, a := range all {
+		answer, ok := a.content, true
+		if counts[a.questionMessageID] > 1 {
+			answer, ok = historicalAnswer(a.content, a.header, a.position)
+		}
+		if !ok {
+			continue
+		}
+		_, err := tx.Exec(
+			`UPDATE questions SET answer_message_id = ?, answer = ? WHERE message_id = ? AND position = ?`,
+			a.answerMessageID, answer, a.questionMessageID, a.position,
+		)
+		if err != nil {
+			return fmt.Errorf("saving answer: %w", err)
+		}
+	}
+	return nil
+}
+
+// historicalAnswer finds one question's answer in a multi-question reply,
+// where each answer is on its own line prefixed by the question's header or,
+// without one, by "Q<n>".
+func historicalAnswer(content, header string, position int) (string, bool) {
+	prefix := header
+	if prefix == "" {
+		prefix = fmt.Sprintf("Q%d", position+1)
+	}
+	for _, line := range strings.Split(content, "\n") {
+		if answer, ok := strings.CutPrefix(line, prefix+": "); ok {
+			return answer, true
+		}
+	}
+	return "", false
+}
+
+// ListQuestions returns the questions an assistant message asked, with their
+// options and answers, in the order they were asked.
+func (q *Queries) ListQuestions(ctx context.Context, messageID int64) ([]models.Question, error) {
+	rows, err := q.db.QueryContext(ctx,
+		`SELECT q.id, q.message_id, q.position, q.header, q.text, q.multi_select, COALESCE(q.answer, ''),
+		        o.label, o.description
+		 FROM questions q LEFT JOIN question_options o ON o.question_id = q.id
+		 WHERE q.message_id = ?
+		 ORDER BY q.position, o.position`, messageID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("listing questions: %w", err)
+	}
+	defer rows.Close()
+
+	var results []models.Question
+	for rows.Next() {
+		var qu models.Question
+		var label, description sql.NullString
+		if err := rows.Scan(&qu.ID, &qu.MessageID, &qu.Position, &qu.Header, &qu.Text, &qu.MultiSelect, &qu.Answer, &label, &description); err != nil {
+			return nil, fmt.Errorf("scanning question: %w", err)
+		}
+		if n := len(results); n == 0 || results[n-1].ID != qu.ID {
+			if err := q.sealer.openAll(&qu.Header, &qu.Text, &qu.Answer); err != nil {
+				return nil, err
+			}
+			results = append(results, qu)
+		}
+		if label.Valid {
+			if err := q.sealer.openAll(&label.String, &description.String); err != nil {
+				return nil, err
+			}
+			last := &results[len(results)-1]
+			last.Options = append(last.Options, models.QuestionOption{Label: label.String, Description: description.String})
+		}
+	}
+	return results, rows.Err()
+}
+
+// CountQuestions counts the questions asked in a prompt request's
+// conversation before the message beforeID; zero counts them all.
+func (q *Queries) CountQuestions(ctx context.Context, promptRequestID, beforeID int64) (int, error) {
+	var n int
+	err := q.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM questions q JOIN messages m ON m.id = q.message_id
+		 WHERE m.prompt_request_id = ? AND m.superseded = 0 AND (? = 0 OR m.id < ?)`,
+		promptRequestID, beforeID, beforeID,
+	).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("counting questions: %w", err)
+	}
+	return n, nil
+}
+
+// ListAnsweredQuestions returns the questions of a prompt request's
+// conversation that were answered, with their options, in the order they
+// were asked.
+func (q *Queries) ListAnsweredQuestions(ctx context.Context, promptRequestID int64) ([]models.Question, error) {
+	rows, err := q.db.QueryContext(ctx,
+		`SELECT q.id, q.message_id, q.position, q.header, q.text, q.multi_select, q.answer,
+		        o.label, o.description
+		 FROM questions q
+		 JOIN messages m ON m.id = q.message_id
+		 LEFT JOIN question_options o ON o.question_id = q.id
+		 WHERE m.prompt_request_id = ? AND m.superseded = 0 AND q.answer IS NOT NULL
+		 ORDER BY q.message_id, q.position, o.position`, promptRequestID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("listing answered questions: %w", err)
+	}
+	defer rows.Close()
+
+	var results []models.Question
+	for rows.Next() {
+		var qu models.Question
+		var label, description sql.NullString
+		if err := rows.Scan(&qu.ID, &qu.MessageID, &qu.Position, &qu.Header, &qu.Text, &qu.MultiSelect, &qu.Answer, &label, &description); err != nil {
+			return nil, fmt.Errorf("scanning question: %w", err)
+		}
+		if n := len(results); n == 0 || results[n-1].ID != qu.ID {
+			if err := q.sealer.openAll(&qu.Header, &qu.Text, &qu.Answer); err != nil {
+				return nil, err
+			}
+			results = append(results, qu)
+		}
+		if label.Valid {
+			if err := q.sealer.openAll(&label.String, &description.String); err != nil {
+				return nil, err
+			}
+			last := &results[len(results)-1]
+			last.Options = append(last.Options, models.QuestionOption{Label: label.String, Description: description.String})
+		}
+	}
+	return results, rows.Err()
+}
+
+// ChangeAnswer replaces the recorded answer to a question, keeping the
+// message that first answered it.
+func (q *Queries) ChangeAnswer(ctx context.Context, questionID int64, answer string) error {
+	_, err := q.db.ExecContext(ctx, `UPDATE questions SET answer = ? WHERE id = ?`, q.sealer.seal(answer), questionID)
+	if err != nil {
+		return fmt.Errorf("changing answer: %w", err)
+	}
+	return nil
+}
+
+// SaveAnswers records a user message's answers to the questions of the
+// assistant message right before it, keyed by question position.
+func (q *Queries) SaveAnswers(ctx context.Context, promptRequestID, messageID int64, answers map[int]string) error {
+	if len(answers) == 0 {
+		return nil
+	}
+	tx, err := q.begin(ctx)
+	if err != nil {
+		return fmt.Errorf("beginning answers: %w", err)
+	}
+	defer tx.Rollback()
+	for position, answer := range answers {
+		_, err := tx.ExecContext(ctx,
+			`UPDATE questions SET answer_message_id = ?1, answer = ?2
+			 WHERE position = ?3
+			   AND message_id = (SELECT MAX(id) FROM messages
+			                     WHERE prompt_request_id = ?4 AND role = 'assistant' AND id < ?1)`,
+			messageID, q.sealer.seal(answer), position, promptRequestID,
+		)
+		if err != nil {
+			return fmt.Errorf("saving answer: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// GeneratedContent holds the title, motivation, and prompt of a Claude response.
+type GeneratedContent struct {
+	MessageID  int64 // the assistant message that produced it
+	Title      string
+	Motivation string
+	Prompt     string
+	Labels     []string // suggested for the issue; not yet checked against the repository's
+	Size       string   // estimated effort: "S", "M", "L", "XL", or "" if not estimated
+	SizeReason string
+	CreatedAt  time.Time
+
+	NonGoals     []string // what the request deliberately leaves out
+	Alternatives []string // approaches the contributor rejected, with why
+	Areas        []string // of the codebase the request likely touches
+	Paths        []string // files or directories the request likely touches
+
+	Clarity     int      // how completely the request is captured, 1 to 5, or 0 if not rated
+	Ambiguities []string // points still open or assumed
+
+	Glossary []models.GlossaryTerm // terms the conversation defined
+	TestPlan []string              // steps to confirm the request works once built
+}
+
+const generatedContentColumns = `SELECT g.message_id, g.title, g.motivation, g.prompt, g.suggested_labels,
+	        g.estimated_size, g.size_rationale, g.non_goals,
+	        g.alternatives, g.affected_areas, g.clarity_score, g.ambiguities, g.glossary,
+	        g.test_plan, g.affected_paths, m.created_at
+	 FROM generated_contents g JOIN messages m ON m.id = g.message_id
+	 WHERE m.prompt_request_id = ? AND m.superseded = 0`
+
+func (q *Queries) scanGeneratedContent(s interface{ Scan(...any) error }) (*GeneratedContent, error) {
+	gc := &GeneratedContent{}
+	var labels, nonGoals, alternatives, areas, ambiguities, glossary, testPlan, paths, createdAt string
+	if err := s.Scan(&gc.MessageID, &gc.Title, &gc.Motivation, &gc.Prompt, &labels, &gc.Size, &gc.SizeReason,
+		&nonGoals, &alternatives, &areas, &gc.Clarity, &ambiguities, &glossary, &testPlan, &paths, &createdAt); err != nil {
+		return nil, err
+	}
+	if err := q.sealer.openAll(&gc.Title, &gc.Motivation, &gc.Prompt, &labels, &gc.SizeReason,
+		&nonGoals, &alternatives, &areas, &ambiguities, &glossary, &testPlan, &paths); err != nil {
+		return nil, err
+	}
+	gc.Labels = splitList(labels)
+	gc.NonGoals = splitList(nonGoals)
+	gc.Alternatives = splitList(alternatives)
+	gc.Areas = splitList(areas)
+	gc.Paths = splitList(paths)
+	gc.Ambiguities = splitList(ambiguities)
+	gc.Glossary = splitGlossary(glossary)
+	gc.TestPlan = splitList(testPlan)
+	gc.CreatedAt = parseTime(createdAt)
+	return gc, nil
+}
+
+// GetLatestGeneratedContent returns the most recently generated prompt.
+func (q *Queries) GetLatestGeneratedContent(ctx context.Context, promptRequestID int64) (*GeneratedContent, error) {
+	gc, err := q.scanGeneratedContent(q.db.QueryRowContext(ctx,
+		generatedContentColumns+` ORDER BY m.created_at DESC, m.id DESC LIMIT 1`, promptRequestID,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("no generated prompt found")
+	} else if err != nil {
+		return nil, fmt.Errorf("getting generated content: %w", err)
+	}
+	return gc, nil
+}
+
+// GetGeneratedContent returns the generated content of one assistant message.
+func (q *Queries) GetGeneratedContent(ctx context.Context, promptRequestID, messageID int64) (*GeneratedContent, error) {
+	gc, err := q.scanGeneratedContent(q.db.QueryRowContext(ctx,
+		generatedContentColumns+` AND g.message_id = ?`, promptRequestID, messageID,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("no generated prompt found in message %d", messageID)
+	} else if err != nil {
+		return nil, fmt.Errorf("getting generated content: %w", err)
+	}
+	return gc, nil
+}
+
+// ListGeneratedContents lists every prompt generated in the active
+// conversation, oldest first.
+func (q *Queries) ListGeneratedContents(ctx context.Context, promptRequestID int64) ([]GeneratedContent, error) {
+	rows, err := q.db.QueryContext(ctx, generatedContentColumns+` ORDER BY m.created_at, m.id`, promptRequestID)
+	if err != nil {
+		return nil, fmt.Errorf("querying generated content: %w", err)
+	}
+	defer rows.Close()
+
+	var results []GeneratedContent
+	for rows.Next() {
+		gc, err := q.scanGeneratedContent(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning generated content: %w", err)
+		}
+		results = append(results, *gc)
+	}
+	return results, rows.Err()
+}
+
+// relatedIssuesTable records the open issues Claude found related to the
+// request. Replies from before it existed have none, so responses indexed by
+// earlier migrations never need it.
+const relatedIssuesTable = `
+CREATE TABLE related_issues (
+    message_id INTEGER NOT NULL REFERENCES messages(id) ON DELETE CASCADE,
+    number     INTEGER NOT NULL,
+    title      TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (message_id, number)
+)`
+
+// backfillRelatedIssues records the related issues of replies stored before
+// the related_issues table existed.
+func backfillRelatedIssues(tx *sql.Tx) error {
+	return eachResponse(context.Background(), tx, nil, func(id int64, resp *claude.Response) error {
+		for _, issue := range resp.RelatedIssues {
+			_, err := tx.Exec(
+				`INSERT INTO related_issues (message_id, number, title) VALUES (?, ?, ?) ON CONFLICT DO NOTHING`,
+				id, issue.Number, issue.Title,
+			)
+			if err != nil {
+				return fmt.Errorf("backfilling related issue: %w", err)
+			}
+		}
+		return nil
+	}, plaintextResponses)
+}
+
+// ListRelatedIssues lists the issues Claude found related to the active
+// conversation, by number.
+func (q *Queries) ListRelatedIssues(ctx context.Context, promptRequestID int64) ([]models.RelatedIssue, error) {
+	rows, err := q.db.QueryContext(ctx,
+		`SELECT r.number, MAX(r.title) FROM related_issues r JOIN messages m ON m.id = r.message_id
+		 WHERE m.prompt_request_id = ? AND m.superseded = 0
+		 GROUP BY r.number ORDER BY r.number`, promptRequestID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("listing related issues: %w", err)
+	}
+	defer rows.Close()
+
+	var results []models.RelatedIssue
+	for rows.Next() {
+		var r models.RelatedIssue
+		if err := rows.Scan(&r.Number, &r.Title); err != nil {
+			return nil, fmt.Errorf("scanning related issue: %w", err)
+		}
+		if r.Title, err = q.sealer.open(r.Title); err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
+
+// deleteResponses removes the recorded questions and generated content of the
+// messages the query selects, and clears answers those messages gave, so the
+// messages themselves can be deleted.
+func deleteResponses(ctx context.Context, tx dbtx, messageIDs string, args ...any) error {
+	stmts := []string{
+		`DELETE FROM generated_contents WHERE message_id IN (` + messageIDs + `)`,
+		`DELETE FROM related_issues WHERE message_id IN (` + messageIDs + `)`,
+		`DELETE FROM question_options WHERE question_id IN (SELECT id FROM questions WHERE message_id IN (` + messageIDs + `))`,
+		`DELETE FROM questions WHERE message_id IN (` + messageIDs + `)`,
+		`UPDATE questions SET answer_message_id = NULL, answer = NULL WHERE answer_message_id IN (` + messageIDs + `)`,
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.ExecContext(ctx, stmt, args...); err != nil {
+			return fmt.Errorf("deleting responses: %w", err)
+		}
+	}
+	return nil
+}
+
+// joinList and splitList store a list of strings in a text column as a JSON
+// array, so items keep the newlines they contain. An empty list is stored as
+// the empty string; lists stored before JSON hold one item per line.
+func joinList(items []string) string {
+	if len(items) == 0 {
+		return ""
+	}
+	b, _ := json.Marshal(items)
+	return string(b)
+}
+
+func splitList(s string) []string {
+	if s == "" {
+		return nil
+	}
+	var items []string
+	if strings.HasPrefix(s, "[") && json.Unmarshal([]byte(s), &items) == nil {
+		return items
+	}
+	return strings.Split(s, "\n")
+}
+
+// joinGlossary stores glossary terms one per line, each term and its
+// definition separated by a tab.
+func joinGlossary(terms []claude.Term) string {
+	lines := make([]string, 0, len(terms))
+	for _, t := range terms {
+		term := strings.Join(strings.Fields(t.Term), " ")
+		if term == "" {
+			continue
+		}
+		lines = append(lines, term+"\t"+strings.Join(strings.Fields(t.Definition), " "))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func splitGlossary(s string) []models.GlossaryTerm {
+	if s == "" {
+		return nil
+	}
+	var terms []models.GlossaryTerm
+	for _, line := range strings.Split(s, "\n") {
+		term, definition, _ := strings.Cut(line, "\t")
+		terms = append(terms, models.GlossaryTerm{Term: term, Definition: definition})
+	}
+	return terms
+}
@@ -0,0 +1,106 @@
+package db
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestGeneratedContent_ListItemsKeepNewlines(t *testing.T) {
+	ctx := context.Background()
+	database, err := Open(filepath.Join(t.TempDir(), "prompter.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	q := NewQueries(database)
+
+	repo, err := q.UpsertRepository(ctx, "github.com/a/b", "/tmp/a/b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pr, err := q.CreatePromptRequest(ctx, repo.ID, "sess-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply := `{"structured_output":{"message":"Ready","prompt_ready":true,"generated_title":"Dark mode","generated_prompt":"Add a dark theme",
+		"non_goals":["High contrast\nor any other\n  accessibility theme","", "Print styles"],
+		"test_plan":["Toggle the theme\r\nand reload"]}}`
+	msg, err := q.CreateMessage(ctx, pr.ID, "assistant", "Ready", &reply)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gc, err := q.GetGeneratedContent(ctx, pr.ID, msg.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"High contrast\nor any other\n  accessibility theme", "", "Print styles"}; !slices.Equal(gc.NonGoals, want) {
+		t.Errorf("non-goals = %q, want %q", gc.NonGoals, want)
+	}
+	if want := []string{"Toggle the theme\r\nand reload"}; !slices.Equal(gc.TestPlan, want) {
+		t.Errorf("test plan = %q, want %q", gc.TestPlan, want)
+	}
+
+	// Lists stored before they were JSON hold one item per line.
+	_, err = q.db.ExecContext(ctx, `UPDATE generated_contents SET test_plan = 'Toggle the theme' || char(10) || 'Reload' WHERE message_id = ?`, msg.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gc, err = q.GetGeneratedContent(ctx, pr.ID, msg.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"Toggle the theme", "Reload"}; !slices.Equal(gc.TestPlan, want) {
+		t.Errorf("newline-separated test plan = %q, want %q", gc.TestPlan, want)
+	}
+}
+
+func TestListPromptRequests_HasGeneratedPrompt(t *testing.T) {
+	ctx := context.Background()
+	database, err := Open(filepath.Join(t.TempDir(), "prompter.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	q := NewQueries(database)
+
+	repo, err := q.UpsertRepository(ctx, "github.com/a/b", "/tmp/a/b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ready, err := q.CreatePromptRequest(ctx, repo.ID, "sess-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply := `{"structured_output":{"message":"Ready","prompt_ready":true,"generated_title":"Dark mode","generated_prompt":"Add a dark theme"}}`
+	if _, err := q.CreateMessage(ctx, ready.ID, "assistant", "Ready", &reply); err != nil {
+		t.Fatal(err)
+	}
+	draft, err := q.CreatePromptRequest(ctx, repo.ID, "sess-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	question := `{"structured_output":{"message":"Which pages?","prompt_ready":false}}`
+	if _, err := q.CreateMessage(ctx, draft.ID, "assistant", "Which pages?", &question); err != nil {
+		t.Fatal(err)
+	}
+
+	prs, _, err := q.ListPromptRequests(ctx, false, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[int64]bool{ready.ID: true, draft.ID: false}
+	for _, pr := range prs {
+		if pr.HasGeneratedPrompt != want[pr.ID] {
+			t.Errorf("prompt request %d: HasGeneratedPrompt = %v, want %v", pr.ID, pr.HasGeneratedPrompt, want[pr.ID])
+		}
+		if pr.MessageCount != 1 {
+			t.Errorf("prompt request %d: MessageCount = %d, want 1", pr.ID, pr.MessageCount)
+		}
+	}
+	if len(prs) != 2 {
+		t.Errorf("listed %d prompt requests, want 2", len(prs))
+	}
+}
@@ -0,0 +1,92 @@
+package db
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/esnunes/prompter/internal/models"
+)
+
+// shareLinksTable holds the links that show a read-only copy of a prompt
+// request to anyone who has them, until they expire.
+const shareLinksTable = `
+CREATE TABLE share_links (
+    token             TEXT PRIMARY KEY,
+    prompt_request_id INTEGER NOT NULL REFERENCES prompt_requests(id) ON DELETE CASCADE,
+    expires_at        TEXT NOT NULL,
+    created_at        TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now'))
+);
+CREATE INDEX idx_share_links_prompt_request ON share_links(prompt_request_id);`
+
+// CreateShareLink creates a link to a prompt request that works until
+// expiresAt. Expired links of any prompt request are dropped on the way.
+func (q *Queries) CreateShareLink(ctx context.Context, promptRequestID int64, expiresAt time.Time) (*models.ShareLink, error) {
+	link := &models.ShareLink{Token: rand.Text(), PromptRequestID: promptRequestID, ExpiresAt: expiresAt.UTC().Truncate(time.Second)}
+	if _, err := q.db.ExecContext(ctx, `DELETE FROM share_links WHERE expires_at <= strftime('%Y-%m-%dT%H:%M:%SZ', 'now')`); err != nil {
+		return nil, fmt.Errorf("dropping expired share links: %w", err)
+	}
+	_, err := q.db.ExecContext(ctx,
+		`INSERT INTO share_links (token, prompt_request_id, expires_at) VALUES (?, ?, ?)`,
+		link.Token, promptRequestID, formatTime(link.ExpiresAt),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("creating share link: %w", err)
+	}
+	return link, nil
+}
+
+// GetShareLink returns the unexpired link with the given token, or
+// sql.ErrNoRows.
+func (q *Queries) GetShareLink(ctx context.Context, token string) (*models.ShareLink, error) {
+	link := &models.ShareLink{Token: token}
+	var expiresAt string
+	err := q.db.QueryRowContext(ctx,
+		`SELECT prompt_request_id, expires_at FROM share_links
+		 WHERE token = ? AND expires_at > strftime('%Y-%m-%dT%H:%M:%SZ', 'now')`, token,
+	).Scan(&link.PromptRequestID, &expiresAt)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			err = fmt.Errorf("reading share link: %w", err)
+		}
+		return nil, err
+	}
+	link.ExpiresAt = parseTime(expiresAt)
+	return link, nil
+}
+
+// RevokeShareLinks removes every link to a prompt request.
+func (q *Queries) RevokeShareLinks(ctx context.Context, promptRequestID int64) error {
+	if _, err := q.db.ExecContext(ctx, `DELETE FROM share_links WHERE prompt_request_id = ?`, promptRequestID); err != nil {
+		return fmt.Errorf("revoking share links: %w", err)
+	}
+	return nil
+}
+
+// ListShareLinks lists the unexpired links to a prompt request, newest first.
+func (q *Queries) ListShareLinks(ctx context.Context, promptRequestID int64) ([]models.ShareLink, error) {
+	rows, err := q.db.QueryContext(ctx,
+		`SELECT token, expires_at FROM share_links
+		 WHERE prompt_request_id = ? AND expires_at > strftime('%Y-%m-%dT%H:%M:%SZ', 'now')
+		 ORDER BY created_at DESC, rowid DESC`, promptRequestID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("listing share links: %w", err)
+	}
+	defer rows.Close()
+
+	var links []models.ShareLink
+	for rows.Next() {
+		link := models.ShareLink{PromptRequestID: promptRequestID}
+		var expiresAt string
+		if err := rows.Scan(&link.Token, &expiresAt); err != nil {
+			return nil, fmt.Errorf("scanning share link: %w", err)
+		}
+		link.ExpiresAt = parseTime(expiresAt)
+		links = append(links, link)
+	}
+	return links, rows.Err()
+}
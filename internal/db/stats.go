@@ -0,0 +1,125 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/esnunes/prompter/internal/models"
+)
+
+// GetStats computes aggregate statistics over all non-deleted prompt requests.
+// Publishes are bucketed by month for the last months months.
+func (q *Queries) GetStats(ctx context.Context, months int) (*models.Stats, error) {
+	var st models.Stats
+
+	err := q.db.QueryRowContext(ctx, `
+		SELECT COUNT(CASE WHEN status = 'draft' THEN 1 END),
+		       COUNT(CASE WHEN status = 'published' THEN 1 END)
+		FROM prompt_requests
+		WHERE status != 'deleted'`).Scan(&st.Drafts, &st.Published)
+	if err != nil {
+		return nil, fmt.Errorf("counting prompt requests by status: %w", err)
+	}
+
+	if st.Repos, err = q.repoStats(ctx); err != nil {
+		return nil, err
+	}
+
+	// Messages up to and including the first publish of each published prompt request.
+	err = q.db.QueryRowContext(ctx, `
+		SELECT COALESCE(AVG(n), 0) FROM (
+		    SELECT (SELECT COUNT(*) FROM messages m
+		            WHERE m.prompt_request_id = pr.id AND m.created_at <= fr.first_published) AS n
+		    FROM prompt_requests pr
+		    JOIN (SELECT prompt_request_id, MIN(published_at) AS first_published
+		          FROM revisions GROUP BY prompt_request_id) fr ON fr.prompt_request_id = pr.id
+		    WHERE pr.status != 'deleted'
+		)`).Scan(&st.AvgMessagesToPublish)
+	if err != nil {
+		return nil, fmt.Errorf("averaging messages to publish: %w", err)
+	}
+
+	var avgSeconds float64
+	err = q.db.QueryRowContext(ctx, `
+		SELECT COALESCE(AVG(secs), 0) FROM (
+		    SELECT (julianday(MAX(m.created_at)) - julianday(MIN(m.created_at))) * 86400 AS secs
+		    FROM messages m
+		    JOIN prompt_requests pr ON pr.id = m.prompt_request_id
+		    WHERE pr.status != 'deleted'
+		    GROUP BY m.prompt_request_id
+		    HAVING COUNT(*) > 1
+		)`).Scan(&avgSeconds)
+	if err != nil {
+		return nil, fmt.Errorf("averaging conversation duration: %w", err)
+	}
+	st.AvgConversationDuration = time.Duration(avgSeconds * float64(time.Second)).Round(time.Second)
+
+	if st.PublishesByMonth, err = q.publishesByMonth(ctx, months); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+func (q *Queries) repoStats(ctx context.Context) ([]models.RepoStats, error) {
+	rows, err := q.db.QueryContext(ctx, `
+		SELECT r.url,
+		       COUNT(*),
+		       COUNT(CASE WHEN pr.status = 'draft' THEN 1 END),
+		       COUNT(CASE WHEN pr.status = 'published' THEN 1 END)
+		FROM repositories r
+		JOIN prompt_requests pr ON pr.repository_id = r.id
+		WHERE pr.status != 'deleted'
+		GROUP BY r.id
+		ORDER BY COUNT(*) DESC, r.url`)
+	if err != nil {
+		return nil, fmt.Errorf("counting prompt requests per repo: %w", err)
+	}
+	defer rows.Close()
+
+	var results []models.RepoStats
+	for rows.Next() {
+		var rs models.RepoStats
+		if err := rows.Scan(&rs.URL, &rs.Total, &rs.Drafts, &rs.Published); err != nil {
+			return nil, fmt.Errorf("scanning repo stats: %w", err)
+		}
+		results = append(results, rs)
+	}
+	return results, rows.Err()
+}
+
+// publishesByMonth counts revisions published per month, including empty
+// months, for the last months months ending with the current one.
+func (q *Queries) publishesByMonth(ctx context.Context, months int) ([]models.PeriodCount, error) {
+	rows, err := q.db.QueryContext(ctx, `
+		SELECT strftime('%Y-%m', published_at) AS month, COUNT(*)
+		FROM revisions
+		WHERE published_at >= date('now', 'start of month', ?)
+		GROUP BY month`, fmt.Sprintf("-%d months", months-1))
+	if err != nil {
+		return nil, fmt.Errorf("counting publishes by month: %w", err)
+	}
+	defer rows.Close()
+
+	counts := map[string]int{}
+	for rows.Next() {
+		var month string
+		var n int
+		if err := rows.Scan(&month, &n); err != nil {
+			return nil, fmt.Errorf("scanning publish count: %w", err)
+		}
+		counts[month] = n
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -(months - 1), 0)
+	results := make([]models.PeriodCount, 0, months)
+	for i := range months {
+		month := start.AddDate(0, i, 0).Format("2006-01")
+		results = append(results, models.PeriodCount{Period: month, Count: counts[month]})
+	}
+	return results, nil
+}
@@ -0,0 +1,78 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"sync"
+)
+
+// maxPreparedStatements bounds the statement cache; queries built with a
+// varying number of placeholders would otherwise grow it without end.
+const maxPreparedStatements = 512
+
+// preparedDB runs queries through prepared statements, preparing each SQL
+// string once and reusing it afterwards. Transactions and everything else
+// go straight to the embedded *sql.DB.
+type preparedDB struct {
+	*sql.DB
+
+	mu    sync.Mutex
+	stmts map[string]*sql.Stmt
+}
+
+func newPreparedDB(db *sql.DB) *preparedDB {
+	return &preparedDB{DB: db, stmts: make(map[string]*sql.Stmt)}
+}
+
+// stmt returns the prepared statement for query, or nil when it isn't
+// cached: it holds several statements, the cache is full or preparing failed.
+func (d *preparedDB) stmt(ctx context.Context, query string) *sql.Stmt {
+	if strings.Contains(strings.TrimSpace(query), ";") {
+		return nil
+	}
+	d.mu.Lock()
+	stmt, ok := d.stmts[query]
+	full := len(d.stmts) >= maxPreparedStatements
+	d.mu.Unlock()
+	if ok {
+		return stmt
+	}
+	if full {
+		return nil
+	}
+
+	stmt, err := d.DB.PrepareContext(ctx, query)
+	if err != nil {
+		return nil // running the query unprepared reports the error
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if cached, ok := d.stmts[query]; ok {
+		stmt.Close()
+		return cached
+	}
+	d.stmts[query] = stmt
+	return stmt
+}
+
+func (d *preparedDB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	if stmt := d.stmt(ctx, query); stmt != nil {
+		return stmt.ExecContext(ctx, args...)
+	}
+	return d.DB.ExecContext(ctx, query, args...)
+}
+
+func (d *preparedDB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	if stmt := d.stmt(ctx, query); stmt != nil {
+		return stmt.QueryContext(ctx, args...)
+	}
+	return d.DB.QueryContext(ctx, query, args...)
+}
+
+func (d *preparedDB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	if stmt := d.stmt(ctx, query); stmt != nil {
+		return stmt.QueryRowContext(ctx, args...)
+	}
+	return d.DB.QueryRowContext(ctx, query, args...)
+}
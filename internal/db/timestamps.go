@@ -0,0 +1,76 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Timestamps are stored as RFC 3339 in UTC, written in SQL with
+// strftime('%Y-%m-%dT%H:%M:%SZ', 'now') so they sort and compare as text.
+
+// parseTime reads a stored timestamp into local time. Values written before
+// timestamps carried a zone are SQLite datetime() strings, which are UTC.
+func parseTime(s string) time.Time {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		t, _ = time.Parse(time.DateTime, s)
+	}
+	return t.Local()
+}
+
+// formatTime is the stored form of t.
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// timestampColumns lists every column holding a timestamp, by table.
+var timestampColumns = map[string][]string{
+	"repositories":    {"created_at", "updated_at"},
+	"prompt_requests": {"created_at", "updated_at", "last_viewed_at", "auto_archived_at", "exported_at"},
+	"messages":        {"created_at"},
+	"revisions":       {"published_at"},
+	"jobs":            {"started_at", "updated_at"},
+	"job_queue":       {"run_after", "locked_until", "created_at", "updated_at"},
+	"tags":            {"created_at"},
+	"attachments":     {"created_at"},
+	"file_references": {"created_at"},
+	"schema_version":  {"applied_at"},
+}
+
+// migrateTimestamps rewrites stored timestamps as RFC 3339 and changes the
+// column defaults to match. SQLite can't alter a column's default, but
+// editing it in the stored schema is one of the changes its documentation
+// allows through writable_schema.
+func migrateTimestamps(tx *sql.Tx) error {
+	var schemaVersion int
+	if err := tx.QueryRow(`PRAGMA schema_version`).Scan(&schemaVersion); err != nil {
+		return fmt.Errorf("reading schema version: %w", err)
+	}
+	stmts := []string{
+		`PRAGMA writable_schema = ON`,
+		`UPDATE sqlite_master
+		 SET sql = replace(sql, 'DEFAULT (datetime(''now''))', 'DEFAULT (strftime(''%Y-%m-%dT%H:%M:%SZ'', ''now''))')
+		 WHERE type = 'table'`,
+		fmt.Sprintf(`PRAGMA schema_version = %d`, schemaVersion+1),
+		`PRAGMA writable_schema = OFF`,
+	}
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("changing timestamp defaults: %w", err)
+		}
+	}
+
+	for table, columns := range timestampColumns {
+		for _, column := range columns {
+			_, err := tx.Exec(fmt.Sprintf(
+				`UPDATE %[1]s SET %[2]s = strftime('%%Y-%%m-%%dT%%H:%%M:%%SZ', %[2]s) WHERE %[2]s NOT LIKE '%%T%%'`,
+				table, column,
+			))
+			if err != nil {
+				return fmt.Errorf("converting %s.%s: %w", table, column, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync/atomic"
+)
+
+// dbtx is what queries run against: the statement cache, or the transaction
+// of a Queries handed to an InTx callback.
+type dbtx interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// txn is a transaction begun by a method: a real one, or a savepoint when
+// the method runs inside InTx.
+type txn interface {
+	dbtx
+	Commit() error
+	Rollback() error
+}
+
+// InTx runs fn with a Queries whose reads and writes all belong to one
+// transaction, committed when fn returns nil and rolled back otherwise.
+// Methods called on it that need a transaction of their own get a savepoint
+// instead. fn must not use q itself: SQLite allows one writer at a time.
+func (q *Queries) InTx(ctx context.Context, fn func(tx *Queries) error) error {
+	t, err := q.begin(ctx)
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer t.Rollback()
+
+	inner := *q
+	inner.db = t
+	inner.tx = q.tx
+	if inner.tx == nil {
+		inner.tx = t.(*sql.Tx)
+	}
+	if err := fn(&inner); err != nil {
+		return err
+	}
+	return t.Commit()
+}
+
+// begin starts a transaction, or a savepoint within the current one.
+func (q *Queries) begin(ctx context.Context) (txn, error) {
+	if q.tx == nil {
+		tx, err := q.conn.BeginTx(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		return tx, nil
+	}
+	sp := &savepoint{Tx: q.tx, ctx: ctx, name: fmt.Sprintf("sp%d", savepointSeq.Add(1))}
+	if _, err := q.tx.ExecContext(ctx, `SAVEPOINT `+sp.name); err != nil {
+		return nil, err
+	}
+	return sp, nil
+}
+
+var savepointSeq atomic.Int64
+
+// savepoint nests a transaction inside another; committing it releases the
+// savepoint and leaves the outcome to the enclosing transaction.
+type savepoint struct {
+	*sql.Tx
+	ctx  context.Context
+	name string
+	done bool
+}
+
+func (sp *savepoint) Commit() error {
+	if sp.done {
+		return sql.ErrTxDone
+	}
+	sp.done = true
+	_, err := sp.Tx.ExecContext(sp.ctx, `RELEASE `+sp.name)
+	return err
+}
+
+func (sp *savepoint) Rollback() error {
+	if sp.done {
+		return sql.ErrTxDone
+	}
+	sp.done = true
+	if _, err := sp.Tx.ExecContext(sp.ctx, `ROLLBACK TO `+sp.name); err != nil {
+		return err
+	}
+	_, err := sp.Tx.ExecContext(sp.ctx, `RELEASE `+sp.name)
+	return err
+}
@@ -3,14 +3,14 @@ package github
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/url"
 	"os/exec"
 	"strconv"
 	"strings"
 )
 
-const LabelName = "prompter"
-
 type Issue struct {
 	Number int    `json:"number"`
 	URL    string `json:"url"`
@@ -31,6 +31,39 @@ func EnsureLabel(ctx context.Context, repoURL, name string) error {
 	return nil
 }
 
+// ErrIssuesUnavailable is returned by CreateIssue when the repository doesn't
+// accept new issues: they are disabled, or the user may not open them.
+var ErrIssuesUnavailable = errors.New("issues can't be created in this repository")
+
+// issuesUnavailable reports whether gh's stderr says the repository refuses
+// new issues rather than a transient failure.
+func issuesUnavailable(stderr string) bool {
+	stderr = strings.ToLower(stderr)
+	for _, s := range []string{"has disabled issues", "issues are disabled", "does not have the correct permissions", "resource not accessible"} {
+		if strings.Contains(stderr, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrUnreachable is returned when gh couldn't connect to GitHub, so the
+// request never reached it and trying again later is safe.
+var ErrUnreachable = errors.New("couldn't reach GitHub")
+
+// unreachable reports whether gh's output says it failed to connect, before
+// sending its request. Failures after connecting, timeouts included, may
+// have taken effect on GitHub.
+func unreachable(output string) bool {
+	output = strings.ToLower(output)
+	for _, s := range []string{"error connecting to", "no such host", "connection refused", "network is unreachable"} {
+		if strings.Contains(output, s) {
+			return true
+		}
+	}
+	return false
+}
+
 func CreateIssue(ctx context.Context, repoURL, title, body string, labels []string) (*Issue, error) {
 	ghRepo := toGHRepo(repoURL)
 
@@ -48,6 +81,12 @@ func CreateIssue(ctx context.Context, repoURL, title, body string, labels []stri
 	output, err := cmd.Output()
 	if err != nil {
 		if exitErr, ok := err.(*exec.ExitError); ok {
+			if issuesUnavailable(string(exitErr.Stderr)) {
+				return nil, fmt.Errorf("creating issue: %w: %s", ErrIssuesUnavailable, strings.TrimSpace(string(exitErr.Stderr)))
+			}
+			if unreachable(string(exitErr.Stderr)) {
+				return nil, fmt.Errorf("creating issue: %w: %s", ErrUnreachable, strings.TrimSpace(string(exitErr.Stderr)))
+			}
 			return nil, fmt.Errorf("creating issue: %s", string(exitErr.Stderr))
 		}
 		return nil, fmt.Errorf("creating issue: %w", err)
@@ -65,6 +104,117 @@ func CreateIssue(ctx context.Context, repoURL, title, body string, labels []stri
 	return &Issue{Number: number, URL: issueURL}, nil
 }
 
+// CreateGist publishes content as a secret gist holding a single file named
+// filename and returns the gist's URL. Secret gists aren't listed publicly
+// but anyone with the URL can read them.
+func CreateGist(ctx context.Context, filename, description, content string) (string, error) {
+	cmd := exec.CommandContext(ctx, "gh", "gist", "create",
+		"--filename", filename,
+		"--desc", description,
+		"-",
+	)
+	cmd.Stdin = strings.NewReader(content)
+
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return "", fmt.Errorf("creating gist: %s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("creating gist: %w", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// ErrProjectNotFound is returned when the owner has no project with the
+// number: AddToProject looks for GitHub Projects only, AddToClassicProject
+// for classic projects only.
+var ErrProjectNotFound = errors.New("project not found")
+
+// AddToProject adds an issue or pull request, by URL, to the GitHub Project
+// (v2) number owned by owner. gh needs the "project" scope for it.
+func AddToProject(ctx context.Context, owner string, number int, itemURL string) error {
+	cmd := exec.CommandContext(ctx, "gh", "project", "item-add", strconv.Itoa(number),
+		"--owner", owner,
+		"--url", itemURL,
+	)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		if strings.Contains(string(output), "Could not resolve to a ProjectV2") {
+			return fmt.Errorf("adding to project %s/%d: %w", owner, number, ErrProjectNotFound)
+		}
+		return fmt.Errorf("adding to project %s/%d: %s", owner, number, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// AddToClassicProject adds an issue, by URL, as a card in the first column of
+// the classic project number. The project belongs to the repository owner/repo,
+// or to the organization or user owner when repo is empty.
+func AddToClassicProject(ctx context.Context, owner, repo string, number int, issueURL string) error {
+	ref := fmt.Sprintf("%s/%d", owner, number)
+	if repo != "" {
+		ref = fmt.Sprintf("%s/%s/%d", owner, repo, number)
+	}
+	projectID, err := classicProjectID(ctx, owner, repo, number)
+	if err != nil {
+		return fmt.Errorf("adding to classic project %s: %w", ref, err)
+	}
+	columnID, err := ghAPI(ctx, "projects/"+projectID+"/columns", "--jq", ".[0].id")
+	if err != nil {
+		return fmt.Errorf("listing columns of classic project %s: %w", ref, err)
+	}
+	if columnID == "" {
+		return fmt.Errorf("adding to classic project %s: it has no columns", ref)
+	}
+	// https://github.com/OWNER/REPO/issues/NUMBER → repos/OWNER/REPO/issues/NUMBER
+	_, issuePath, _ := strings.Cut(issueURL, "github.com/")
+	issueID, err := ghAPI(ctx, "repos/"+issuePath, "--jq", ".id")
+	if err != nil {
+		return fmt.Errorf("getting issue %s: %w", issueURL, err)
+	}
+	_, err = ghAPI(ctx, "projects/columns/"+columnID+"/cards", "-f", "content_type=Issue", "-F", "content_id="+issueID)
+	if err != nil {
+		return fmt.Errorf("adding to classic project %s: %w", ref, err)
+	}
+	return nil
+}
+
+// classicProjectID looks up the ID of a classic project by its number. An
+// owner without a repository is tried as an organization, then as a user.
+func classicProjectID(ctx context.Context, owner, repo string, number int) (string, error) {
+	scopes := []string{"orgs/" + owner, "users/" + owner}
+	if repo != "" {
+		scopes = []string{"repos/" + owner + "/" + repo}
+	}
+	var errs []error
+	for _, scope := range scopes {
+		id, err := ghAPI(ctx, scope+"/projects?state=all&per_page=100", "--paginate",
+			"--jq", fmt.Sprintf(".[] | select(.number == %d) | .id", number))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if id == "" {
+			return "", ErrProjectNotFound
+		}
+		return id, nil
+	}
+	return "", errors.Join(errs...)
+}
+
+// ghAPI calls a GitHub REST API endpoint with gh and returns its trimmed
+// output.
+func ghAPI(ctx context.Context, endpoint string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "gh", append([]string{"api", endpoint}, args...)...)
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return "", errors.New(strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
 func EditIssue(ctx context.Context, repoURL string, issueNumber int, body string) error {
 	ghRepo := toGHRepo(repoURL)
 
@@ -75,11 +225,436 @@ func EditIssue(ctx context.Context, repoURL string, issueNumber int, body string
 	)
 
 	if output, err := cmd.CombinedOutput(); err != nil {
+		if unreachable(string(output)) {
+			return fmt.Errorf("editing issue: %w: %s", ErrUnreachable, strings.TrimSpace(string(output)))
+		}
 		return fmt.Errorf("editing issue: %s", string(output))
 	}
 	return nil
 }
 
+// EditIssueTitle changes the title of an existing issue.
+func EditIssueTitle(ctx context.Context, repoURL string, issueNumber int, title string) error {
+	ghRepo := toGHRepo(repoURL)
+
+	cmd := exec.CommandContext(ctx, "gh", "issue", "edit",
+		strconv.Itoa(issueNumber),
+		"--repo", ghRepo,
+		"--title", title,
+	)
+
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("editing issue title: %s", string(output))
+	}
+	return nil
+}
+
+// IssueDetails is an existing issue with its discussion.
+type IssueDetails struct {
+	Number   int            `json:"number"`
+	Title    string         `json:"title"`
+	Body     string         `json:"body"`
+	URL      string         `json:"url"`
+	Comments []IssueComment `json:"comments"`
+}
+
+type IssueComment struct {
+	Author struct {
+		Login string `json:"login"`
+	} `json:"author"`
+	Body      string `json:"body"`
+	URL       string `json:"url"`
+	CreatedAt string `json:"createdAt"`
+}
+
+// IssueActivity is what can change on an issue after it is published.
+type IssueActivity struct {
+	State  string `json:"state"` // "OPEN" or "CLOSED"
+	Labels []struct {
+		Name string `json:"name"`
+	} `json:"labels"`
+	Comments []IssueComment `json:"comments"` // oldest first
+}
+
+// ViewIssueActivity fetches an issue's state, labels and comments.
+func ViewIssueActivity(ctx context.Context, repoURL string, issueNumber int) (*IssueActivity, error) {
+	ghRepo := toGHRepo(repoURL)
+
+	cmd := exec.CommandContext(ctx, "gh", "issue", "view",
+		strconv.Itoa(issueNumber),
+		"--repo", ghRepo,
+		"--json", "state,labels,comments",
+	)
+
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("viewing issue: %s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("viewing issue: %w", err)
+	}
+
+	var activity IssueActivity
+	if err := json.Unmarshal(output, &activity); err != nil {
+		return nil, fmt.Errorf("parsing issue: %w", err)
+	}
+	return &activity, nil
+}
+
+// CurrentUser returns the login of the user gh is authenticated as.
+func CurrentUser(ctx context.Context) (string, error) {
+	cmd := exec.CommandContext(ctx, "gh", "api", "user", "--jq", ".login")
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return "", fmt.Errorf("getting current user: %s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("getting current user: %w", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// PathAuthors returns the logins of the authors of the latest limit commits
+// touching path on the default branch, newest first, one entry per commit.
+// Commits whose author has no GitHub account are left out.
+func PathAuthors(ctx context.Context, repoURL, path string, limit int) ([]string, error) {
+	ghRepo := toGHRepo(repoURL)
+	endpoint := fmt.Sprintf("repos/%s/commits?path=%s&per_page=%d", ghRepo, url.QueryEscape(path), limit)
+	cmd := exec.CommandContext(ctx, "gh", "api", endpoint)
+
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("listing commits of %s: %s", path, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("listing commits of %s: %w", path, err)
+	}
+
+	var commits []struct {
+		Author *struct {
+			Login string `json:"login"`
+			Type  string `json:"type"`
+		} `json:"author"`
+	}
+	if err := json.Unmarshal(output, &commits); err != nil {
+		return nil, fmt.Errorf("parsing commits: %w", err)
+	}
+	var logins []string
+	for _, c := range commits {
+		// Bots such as dependabot can't be asked for a review.
+		if c.Author != nil && c.Author.Login != "" && c.Author.Type != "Bot" {
+			logins = append(logins, c.Author.Login)
+		}
+	}
+	return logins, nil
+}
+
+// ViewIssue fetches an issue's title, body and comments.
+func ViewIssue(ctx context.Context, repoURL string, issueNumber int) (*IssueDetails, error) {
+	ghRepo := toGHRepo(repoURL)
+
+	cmd := exec.CommandContext(ctx, "gh", "issue", "view",
+		strconv.Itoa(issueNumber),
+		"--repo", ghRepo,
+		"--json", "number,title,body,url,comments",
+	)
+
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			if unreachable(string(exitErr.Stderr)) {
+				return nil, fmt.Errorf("viewing issue: %w: %s", ErrUnreachable, strings.TrimSpace(string(exitErr.Stderr)))
+			}
+			return nil, fmt.Errorf("viewing issue: %s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("viewing issue: %w", err)
+	}
+
+	var issue IssueDetails
+	if err := json.Unmarshal(output, &issue); err != nil {
+		return nil, fmt.Errorf("parsing issue: %w", err)
+	}
+	return &issue, nil
+}
+
+type Label struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// ListLabels returns the labels defined in the repository.
+func ListLabels(ctx context.Context, repoURL string) ([]Label, error) {
+	ghRepo := toGHRepo(repoURL)
+
+	cmd := exec.CommandContext(ctx, "gh", "label", "list",
+		"--repo", ghRepo,
+		"--limit", "200",
+		"--json", "name,description",
+	)
+
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("listing labels: %s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("listing labels: %w", err)
+	}
+
+	var labels []Label
+	if err := json.Unmarshal(output, &labels); err != nil {
+		return nil, fmt.Errorf("parsing labels: %w", err)
+	}
+	return labels, nil
+}
+
+// IssueSummary is an issue as listed, without its body.
+type IssueSummary struct {
+	Number int    `json:"number"`
+	Title  string `json:"title"`
+}
+
+// ListOpenIssues returns the most recently created open issues, newest first.
+func ListOpenIssues(ctx context.Context, repoURL string, limit int) ([]IssueSummary, error) {
+	ghRepo := toGHRepo(repoURL)
+
+	cmd := exec.CommandContext(ctx, "gh", "issue", "list",
+		"--repo", ghRepo,
+		"--state", "open",
+		"--limit", strconv.Itoa(limit),
+		"--json", "number,title",
+	)
+
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("listing issues: %s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("listing issues: %w", err)
+	}
+
+	var issues []IssueSummary
+	if err := json.Unmarshal(output, &issues); err != nil {
+		return nil, fmt.Errorf("parsing issues: %w", err)
+	}
+	return issues, nil
+}
+
+type PullRequest struct {
+	Number   int    `json:"number"`
+	Title    string `json:"title"`
+	MergedAt string `json:"mergedAt"`
+}
+
+// ListMergedPullRequests returns the most recently merged pull requests,
+// newest first.
+func ListMergedPullRequests(ctx context.Context, repoURL string, limit int) ([]PullRequest, error) {
+	ghRepo := toGHRepo(repoURL)
+
+	cmd := exec.CommandContext(ctx, "gh", "pr", "list",
+		"--repo", ghRepo,
+		"--state", "merged",
+		"--limit", strconv.Itoa(limit),
+		"--json", "number,title,mergedAt",
+	)
+
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("listing pull requests: %s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("listing pull requests: %w", err)
+	}
+
+	var prs []PullRequest
+	if err := json.Unmarshal(output, &prs); err != nil {
+		return nil, fmt.Errorf("parsing pull requests: %w", err)
+	}
+	return prs, nil
+}
+
+// CommentOnIssue adds a comment to an existing issue.
+func CommentOnIssue(ctx context.Context, repoURL string, issueNumber int, body string) error {
+	ghRepo := toGHRepo(repoURL)
+
+	cmd := exec.CommandContext(ctx, "gh", "issue", "comment",
+		strconv.Itoa(issueNumber),
+		"--repo", ghRepo,
+		"--body", body,
+	)
+
+	if output, err := cmd.CombinedOutput(); err != nil {
+		if unreachable(string(output)) {
+			return fmt.Errorf("commenting on issue: %w: %s", ErrUnreachable, strings.TrimSpace(string(output)))
+		}
+		return fmt.Errorf("commenting on issue: %s", string(output))
+	}
+	return nil
+}
+
+// CloseIssue closes an issue as not planned, optionally explaining why in a comment.
+func CloseIssue(ctx context.Context, repoURL string, issueNumber int, comment string) error {
+	ghRepo := toGHRepo(repoURL)
+
+	args := []string{"issue", "close",
+		strconv.Itoa(issueNumber),
+		"--repo", ghRepo,
+		"--reason", "not planned",
+	}
+	if comment != "" {
+		args = append(args, "--comment", comment)
+	}
+	cmd := exec.CommandContext(ctx, "gh", args...)
+
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("closing issue: %s", string(output))
+	}
+	return nil
+}
+
+// Repository is a repository's description and statistics as GitHub shows
+// them.
+type Repository struct {
+	Description string
+	Stars       int
+	Language    string // primary language, or "" if GitHub detected none
+	License     string // SPDX identifier, or "" without a recognized license
+	OpenIssues  int
+}
+
+// ViewRepo returns the description and statistics of a repository. It fails
+// if the repository doesn't exist or isn't accessible.
+func ViewRepo(ctx context.Context, repoURL string) (*Repository, error) {
+	ghRepo := toGHRepo(repoURL)
+
+	cmd := exec.CommandContext(ctx, "gh", "repo", "view", ghRepo,
+		"--json", "description,stargazerCount,primaryLanguage,licenseInfo,issues",
+	)
+
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("viewing repository: %s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("viewing repository: %w", err)
+	}
+
+	var view struct {
+		Description     string `json:"description"`
+		StargazerCount  int    `json:"stargazerCount"`
+		PrimaryLanguage *struct {
+			Name string `json:"name"`
+		} `json:"primaryLanguage"`
+		LicenseInfo *struct {
+			SpdxID string `json:"spdxId"`
+			Name   string `json:"name"`
+		} `json:"licenseInfo"`
+		Issues struct {
+			TotalCount int `json:"totalCount"`
+		} `json:"issues"`
+	}
+	if err := json.Unmarshal(output, &view); err != nil {
+		return nil, fmt.Errorf("parsing repository: %w", err)
+	}
+	r := &Repository{Description: view.Description, Stars: view.StargazerCount, OpenIssues: view.Issues.TotalCount}
+	if view.PrimaryLanguage != nil {
+		r.Language = view.PrimaryLanguage.Name
+	}
+	if l := view.LicenseInfo; l != nil {
+		r.License = l.SpdxID
+		if r.License == "" || r.License == "NOASSERTION" {
+			r.License = l.Name
+		}
+	}
+	return r, nil
+}
+
+// RepoSummary is a repository as listed by a search or a user's repository
+// list.
+type RepoSummary struct {
+	Name        string `json:"nameWithOwner"` // "owner/repo"
+	Description string `json:"description"`
+}
+
+// ListUserRepos returns the authenticated user's own repositories, most
+// recently pushed first.
+func ListUserRepos(ctx context.Context, limit int) ([]RepoSummary, error) {
+	cmd := exec.CommandContext(ctx, "gh", "repo", "list",
+		"--limit", strconv.Itoa(limit),
+		"--json", "nameWithOwner,description",
+	)
+
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("listing repositories: %s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("listing repositories: %w", err)
+	}
+
+	var repos []RepoSummary
+	if err := json.Unmarshal(output, &repos); err != nil {
+		return nil, fmt.Errorf("parsing repositories: %w", err)
+	}
+	return repos, nil
+}
+
+// ListStarredRepos returns the repositories the authenticated user starred,
+// most recently starred first. At most 100 are returned.
+func ListStarredRepos(ctx context.Context) ([]RepoSummary, error) {
+	cmd := exec.CommandContext(ctx, "gh", "api", "user/starred?per_page=100")
+
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("listing starred repositories: %s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("listing starred repositories: %w", err)
+	}
+
+	var starred []struct {
+		FullName    string `json:"full_name"`
+		Description string `json:"description"`
+	}
+	if err := json.Unmarshal(output, &starred); err != nil {
+		return nil, fmt.Errorf("parsing starred repositories: %w", err)
+	}
+	repos := make([]RepoSummary, len(starred))
+	for i, r := range starred {
+		repos[i] = RepoSummary{Name: r.FullName, Description: r.Description}
+	}
+	return repos, nil
+}
+
+// SearchRepos searches all of GitHub's repositories, best matches first.
+func SearchRepos(ctx context.Context, query string, limit int) ([]RepoSummary, error) {
+	cmd := exec.CommandContext(ctx, "gh", "search", "repos",
+		"--limit", strconv.Itoa(limit),
+		"--json", "fullName,description",
+		"--", query,
+	)
+
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("searching repositories: %s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("searching repositories: %w", err)
+	}
+
+	var found []struct {
+		FullName    string `json:"fullName"`
+		Description string `json:"description"`
+	}
+	if err := json.Unmarshal(output, &found); err != nil {
+		return nil, fmt.Errorf("parsing repositories: %w", err)
+	}
+	repos := make([]RepoSummary, len(found))
+	for i, r := range found {
+		repos[i] = RepoSummary{Name: r.FullName, Description: r.Description}
+	}
+	return repos, nil
+}
+
 // VerifyRepo checks if a repository exists on GitHub using the gh CLI.
 func VerifyRepo(ctx context.Context, org, repo string) error {
 	cmd := exec.CommandContext(ctx, "gh", "api", fmt.Sprintf("repos/%s/%s", org, repo), "--silent")
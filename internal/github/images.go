@@ -0,0 +1,114 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// UploadImages hosts local image files so they can be embedded in an issue
+// body. GitHub has no API for issue attachments, so the files are pushed to a
+// new secret gist (binary files can only be added through git) and the raw
+// gist URLs are returned in the same order as files.
+func UploadImages(ctx context.Context, files []string) ([]string, error) {
+	if len(files) == 0 {
+		return nil, nil
+	}
+
+	tmp, err := os.MkdirTemp("", "prompter-gist-")
+	if err != nil {
+		return nil, fmt.Errorf("creating temp dir: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	readme := filepath.Join(tmp, "README.md")
+	if err := os.WriteFile(readme, []byte("Images attached to a prompt request.\n"), 0o644); err != nil {
+		return nil, fmt.Errorf("writing gist readme: %w", err)
+	}
+	output, err := exec.CommandContext(ctx, "gh", "gist", "create", readme,
+		"--desc", "Prompter attachments").Output()
+	if err != nil {
+		return nil, fmt.Errorf("creating gist: %s", commandError(err))
+	}
+	gistURL := strings.TrimSpace(string(output))
+	gistID := gistURL[strings.LastIndex(gistURL, "/")+1:]
+
+	dir := filepath.Join(tmp, "gist")
+	if output, err := exec.CommandContext(ctx, "gh", "gist", "clone", gistID, dir).CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("cloning gist: %s", strings.TrimSpace(string(output)))
+	}
+
+	// Gist files live in a flat namespace, so prefix names to keep them unique.
+	names := make([]string, len(files))
+	for i, f := range files {
+		names[i] = fmt.Sprintf("%d-%s", i+1, filepath.Base(f))
+		if err := copyFile(f, filepath.Join(dir, names[i])); err != nil {
+			return nil, err
+		}
+	}
+
+	git := func(args ...string) error {
+		cmd := exec.CommandContext(ctx, "git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=Prompter", "GIT_AUTHOR_EMAIL=prompter@localhost",
+			"GIT_COMMITTER_NAME=Prompter", "GIT_COMMITTER_EMAIL=prompter@localhost",
+		)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(string(output)))
+		}
+		return nil
+	}
+	if err := git("add", "."); err != nil {
+		return nil, err
+	}
+	if err := git("commit", "-m", "Add attachments"); err != nil {
+		return nil, err
+	}
+	if err := git("-c", "credential.helper=", "-c", "credential.helper=!gh auth git-credential", "push"); err != nil {
+		return nil, err
+	}
+
+	output, err = exec.CommandContext(ctx, "gh", "api", "gists/"+gistID, "--jq", ".owner.login").Output()
+	if err != nil {
+		return nil, fmt.Errorf("getting gist owner: %s", commandError(err))
+	}
+	owner := strings.TrimSpace(string(output))
+
+	urls := make([]string, len(names))
+	for i, name := range names {
+		urls[i] = fmt.Sprintf("https://gist.githubusercontent.com/%s/%s/raw/%s", owner, gistID, url.PathEscape(name))
+	}
+	return urls, nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("opening %s: %w", src, err)
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("creating %s: %w", dst, err)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return fmt.Errorf("copying %s: %w", src, err)
+	}
+	return out.Close()
+}
+
+// commandError returns the stderr of a failed command, or the error itself.
+func commandError(err error) string {
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return strings.TrimSpace(string(exitErr.Stderr))
+	}
+	return err.Error()
+}
@@ -8,6 +8,45 @@ type Repository struct {
 	LocalPath string
 	CreatedAt time.Time
 	UpdatedAt time.Time
+
+	// Issue format overrides; nil and "" use the global settings.
+	IssueTitlePrefix  *string
+	IssueBodyTemplate string
+
+	// IncludeAffectedAreas appends the areas of the codebase Claude believes
+	// a request touches to its issues, unless a prompt request says otherwise.
+	IncludeAffectedAreas bool
+
+	// OutputFormat is how Claude writes the prompt of feature requests: ""
+	// for a plain prompt, "stories" for user stories or "gherkin" for
+	// Given/When/Then scenarios.
+	OutputFormat string
+
+	// ProposeTestPlan has Claude propose how maintainers can verify each
+	// request once built.
+	ProposeTestPlan bool
+
+	// Project is the GitHub Project ("OWNER/NUMBER") new issues are added
+	// to, or "" for none.
+	Project string
+
+	// IssueLabels are the comma-separated labels applied to every issue
+	// created for the repository; nil inherits the configured ones.
+	IssueLabels *string
+
+	PulledAt *time.Time    // last clone or pull of the local copy
+	Metadata *RepoMetadata // nil until fetched from GitHub
+}
+
+// RepoMetadata is a repository's description and statistics from GitHub,
+// cached so pages can show them without asking GitHub each time.
+type RepoMetadata struct {
+	Description string
+	Stars       int
+	Language    string
+	License     string
+	OpenIssues  int
+	FetchedAt   time.Time
 }
 
 type PromptRequest struct {
@@ -21,23 +60,199 @@ type PromptRequest struct {
 	CreatedAt    time.Time
 	UpdatedAt    time.Time
 
-	Archived bool
+	Archived    bool
+	Pinned      bool   // always listed in the dashboard's Pinned section
+	TitleEdited bool   // user renamed it; generated titles no longer apply
+	Notes       string // private Markdown notes, never sent to Claude or published
+
+	// Set on duplicates: the source prompt request and the last message copied from it.
+	ForkedFromID  *int64
+	ForkMessageID *int64
+
+	// Running summary of the conversation up to SummaryMessageID, used to seed
+	// a fresh Claude session when the transcript grows too long.
+	Summary          string
+	SummaryMessageID *int64
+
+	// Prewarmed is set while the Claude session was started ahead of the
+	// first message, exploring the repository, so that message resumes it.
+	Prewarmed bool
+
+	// Set when started from an existing issue. PublishTarget says how that
+	// issue is updated on publish: "" opens a new issue referencing it,
+	// "edit" replaces its body, "comment" adds a comment.
+	SourceIssueNumber *int
+	PublishTarget     string
+
+	// Last time the composed issue body was copied as Markdown.
+	ExportedAt *time.Time
+
+	// GistURL is the gist the issue body was published to when the
+	// repository doesn't accept issues.
+	GistURL *string
+
+	// IncludeTranscript appends the Q&A transcript to the published issue.
+	IncludeTranscript bool
+
+	// IssueTemplate is the path of the repository's issue template the
+	// generated issue follows ("" for none); IssueTemplateSent is the one the
+	// Claude session was last told about.
+	IssueTemplate     string
+	IssueTemplateSent string
+
+	// LinkRelatedIssues appends a "Related:" line with the issues Claude found
+	// related to the published issue.
+	LinkRelatedIssues bool
+
+	// IncludeAffectedAreas appends the areas of the codebase Claude believes
+	// the request touches; it follows the repository's setting until changed.
+	IncludeAffectedAreas bool
+
+	// ConversationLanguage is the language Claude talks with the user in and
+	// OutputLanguage the one it writes the generated issue in; "" leaves it
+	// to Claude.
+	ConversationLanguage string
+	OutputLanguage       string
+
+	// Kind is what the conversation produces: "" a feature request, or a
+	// "bug", "refactor", "docs" or "performance" request.
+	Kind string
+
+	// PersonaID is the persona whose instructions Claude follows, if any.
+	PersonaID *int64
+
+	// QuestionMode is how many questions Claude asks: "" (thorough) covers
+	// every edge case, "quick" only the essentials.
+	QuestionMode string
+
+	// DismissedLabels are labels suggested by Claude that the user chose not
+	// to apply to the issue.
+	DismissedLabels []string
+
+	// Mentions are the suggested maintainers the user chose to @-mention in
+	// the issue.
+	Mentions []string
 
 	// Joined fields (not stored directly)
 	RepoURL           string
 	RepoLocalPath     string
+	RepoTitlePrefix   *string // repository's issue format overrides
+	RepoBodyTemplate  string
+	RepoOutputFormat  string
+	RepoTestPlan      bool
+	RepoProject       string
+	RepoIssueLabels   *string
 	MessageCount      int
 	RevisionCount     int
 	LatestRevision    *time.Time
 	LastViewedAt      *time.Time
 	LatestAssistantAt *time.Time
+	IssueActivityAt   *time.Time // latest activity seen on the published issue
+	Tags              []string
+	// HasGeneratedPrompt is set in listings when Claude has generated a
+	// prompt, making a draft ready to publish.
+	HasGeneratedPrompt bool
+}
+
+// ShareLink shows a read-only copy of a prompt request to anyone who has it,
+// until it expires.
+type ShareLink struct {
+	Token           string
+	PromptRequestID int64
+	ExpiresAt       time.Time
+}
+
+// Persona is a named set of instructions for Claude, shaping how it
+// elicits and phrases prompt requests.
+type Persona struct {
+	ID           int64
+	Name         string
+	Instructions string
+	Builtin      bool // comes with Prompter and can't be edited
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// RepoIdea is a feature request Claude proposed for a repository, which
+// can be turned into a prompt request.
+type RepoIdea struct {
+	ID           int64
+	RepositoryID int64
+	Title        string
+	Description  string
+	CreatedAt    time.Time
+}
+
+// GlossaryTerm is a term a prompt request's conversation defined, with the
+// meaning the contributor gave it.
+type GlossaryTerm struct {
+	Term       string
+	Definition string
+}
+
+// PromptCheck is the verdict on one quality checklist item of a generated
+// prompt.
+type PromptCheck struct {
+	Item   string // checklist item ID
+	Passed bool
+	Fix    string // suggested change when it didn't pass
+}
+
+// Draft is the input of a prompt request's conversation that hasn't been
+// sent yet.
+type Draft struct {
+	Message          string
+	Answers          string // form-encoded answers picked so far
+	AnswersMessageID int64  // the assistant message whose questions Answers answers
+}
+
+// IssueWatch is what was last seen on the published issue of a prompt
+// request, to tell what changed since.
+type IssueWatch struct {
+	PromptRequestID int64
+	CommentCount    int
+	State           string // "OPEN" or "CLOSED"
+	Labels          []string
+	CheckedAt       time.Time
+}
+
+// IssueNotification is a change on a published issue made by someone else:
+// a comment, added labels, or the issue being closed or reopened.
+type IssueNotification struct {
+	ID              int64
+	PromptRequestID int64
+	Kind            string // "comment", "labeled", "closed", "reopened"
+	Author          string // commenter's login; "" for other kinds
+	Detail          string // comment excerpt or label names
+	URL             string // the comment, or the issue
+	CreatedAt       time.Time
+	Read            bool
+
+	// Joined fields
+	Title   string
+	RepoURL string
+}
+
+// RelatedIssue is an open issue Claude found related to a prompt request,
+// without duplicating it.
+type RelatedIssue struct {
+	Number int
+	Title  string
+}
+
+// TagCount is a local tag and how many active prompt requests carry it.
+type TagCount struct {
+	Name  string
+	Count int
 }
 
 type RepositorySummary struct {
 	ID            int64
 	URL           string
 	ActivePRCount int
+	UnreadCount   int // active prompt requests with a reply or issue activity not yet viewed
 	LastActivity  time.Time
+	Metadata      *RepoMetadata // nil until fetched from GitHub
 }
 
 type Message struct {
@@ -47,6 +262,59 @@ type Message struct {
 	Content         string
 	RawResponse     *string
 	CreatedAt       time.Time
+	MergedFromID    *int64 // set on messages copied in from a merged prompt request
+	Superseded      bool   // replaced by editing an earlier user message; kept for display only
+	PromptReady     bool   // the assistant reply carries a generated prompt ready to publish
+
+	// How long Claude took to write an assistant reply and what it cost, zero
+	// for replies from before they were recorded.
+	Duration time.Duration
+	CostUSD  float64
+}
+
+// Question is one of the clarifying questions an assistant message asked.
+// Answer is what the following user message chose, empty until answered.
+type Question struct {
+	ID          int64
+	MessageID   int64
+	Position    int
+	Header      string
+	Text        string
+	MultiSelect bool
+	Options     []QuestionOption
+	Answer      string
+}
+
+type QuestionOption struct {
+	Label       string
+	Description string
+}
+
+// Attachment is an image uploaded to a conversation. MessageID is nil while
+// it waits to be sent with the next user message.
+type Attachment struct {
+	ID              int64
+	PromptRequestID int64
+	MessageID       *int64
+	Filename        string
+	ContentType     string
+	Path            string // location on disk
+	Size            int64
+	RemoteURL       *string // where it is hosted once published to GitHub
+	CreatedAt       time.Time
+}
+
+// FileReference points the AI at a file, or a line range of it, in the
+// cloned repository. Lines are 1-based and inclusive; zero means the whole
+// file. MessageID is nil while it waits to be sent.
+type FileReference struct {
+	ID              int64
+	PromptRequestID int64
+	MessageID       *int64
+	Path            string
+	StartLine       int
+	EndLine         int
+	CreatedAt       time.Time
 }
 
 type Revision struct {
@@ -54,5 +322,90 @@ type Revision struct {
 	PromptRequestID int64
 	Content         string
 	AfterMessageID  *int64
+	SourceMessageID *int64 // assistant message whose generated prompt was published
 	PublishedAt     time.Time
 }
+
+// Job is the state of the async operation (clone/pull or AI processing) for a prompt request.
+type Job struct {
+	PromptRequestID int64
+	Status          string // "cloning", "pulling", "ready", "processing", "responded", "cancelled", "error"
+	Error           string
+	StartedAt       *time.Time
+	UpdatedAt       time.Time
+
+	// Joined fields (not stored directly)
+	RepoURL string
+}
+
+// AuditEvent is an entry in the append-only log of significant actions. The
+// prompt request's repository and title are copied in, so the entry still
+// reads after the prompt request is purged.
+type AuditEvent struct {
+	ID              int64
+	PromptRequestID int64
+	RepoURL         string
+	Title           string
+	Kind            string // "created", "message", "claude", "published", "issue-edited", "deleted", "restored", "purged", "unpublished"
+	Detail          string
+	Duration        time.Duration // how long an AI call took
+	CreatedAt       time.Time
+}
+
+// QueuedJob is a unit of background work in the job queue.
+type QueuedJob struct {
+	ID          int64
+	Kind        string // "clone", "claude-send", "publish"
+	Ref         string // what the job acts on, e.g. a prompt request ID
+	Payload     string // JSON
+	Status      string // "queued", "running", "done", "failed"
+	Attempts    int
+	MaxAttempts int
+	LastError   string
+	RunAfter    time.Time
+	LockedUntil *time.Time
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// SearchResult is a prompt request matching a full-text search, with the best
+// matching excerpt.
+type SearchResult struct {
+	PromptRequestID int64
+	Title           string
+	Status          string
+	RepoURL         string
+	Source          string // "title", "message", "revision"
+	Snippet         string // excerpt with matches wrapped in SnippetMarkStart/SnippetMarkEnd
+	UpdatedAt       time.Time
+}
+
+// Markers delimiting matched terms inside SearchResult.Snippet.
+const (
+	SnippetMarkStart = "\x02"
+	SnippetMarkEnd   = "\x03"
+)
+
+// Stats aggregates prompt request activity for the statistics page.
+type Stats struct {
+	Drafts                  int
+	Published               int
+	Repos                   []RepoStats
+	AvgMessagesToPublish    float64       // messages exchanged before the first publish
+	AvgConversationDuration time.Duration // first to last message, for conversations with replies
+	PublishesByMonth        []PeriodCount // oldest first
+}
+
+// RepoStats counts prompt requests for a single repository.
+type RepoStats struct {
+	URL       string
+	Total     int
+	Drafts    int
+	Published int
+}
+
+// PeriodCount is a count for a time bucket such as "2026-01".
+type PeriodCount struct {
+	Period string
+	Count  int
+}
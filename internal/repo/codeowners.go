@@ -0,0 +1,103 @@
+package repo
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// codeownersDirs are where GitHub looks for a CODEOWNERS file, in order.
+var codeownersDirs = []string{".github", "", "docs"}
+
+// CodeownersRule is one line of a CODEOWNERS file: a gitignore-style
+// pattern and the users or teams owning what it matches.
+type CodeownersRule struct {
+	Pattern string
+	Owners  []string // "@user" or "@org/team"; email owners are left out
+}
+
+// Codeowners reads the rules of a cloned repository's CODEOWNERS file, or
+// none if it has no such file.
+func Codeowners(localPath string) ([]CodeownersRule, error) {
+	for _, dir := range codeownersDirs {
+		b, err := os.ReadFile(filepath.Join(localPath, dir, "CODEOWNERS"))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return parseCodeowners(string(b)), nil
+	}
+	return nil, nil
+}
+
+func parseCodeowners(content string) []CodeownersRule {
+	var rules []CodeownersRule
+	for _, line := range strings.Split(content, "\n") {
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		rule := CodeownersRule{Pattern: fields[0]}
+		for _, owner := range fields[1:] {
+			if strings.HasPrefix(owner, "@") {
+				rule.Owners = append(rule.Owners, owner)
+			}
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// Owners returns the owners of a path, relative to the repository root.
+// As on GitHub the last matching rule wins, and a matching rule without
+// owners leaves the path unowned.
+func Owners(rules []CodeownersRule, p string) []string {
+	p = strings.Trim(filepath.ToSlash(p), "/")
+	for i := len(rules) - 1; i >= 0; i-- {
+		if codeownersMatch(rules[i].Pattern, p) {
+			return rules[i].Owners
+		}
+	}
+	return nil
+}
+
+// codeownersMatch reports whether a CODEOWNERS pattern matches p or one of
+// the directories containing it. It covers the common forms: anchored and
+// unanchored patterns, directories, "*" and leading or trailing "**".
+func codeownersMatch(pattern, p string) bool {
+	if pattern == "*" || pattern == "**" {
+		return true
+	}
+	anchored := strings.HasPrefix(pattern, "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+	if rest, ok := strings.CutPrefix(pattern, "**/"); ok {
+		pattern, anchored = rest, false
+	}
+	pattern = strings.TrimSuffix(strings.TrimSuffix(pattern, "/**"), "/")
+	// A slash other than a trailing one anchors the pattern to the root.
+	if strings.Contains(pattern, "/") {
+		anchored = true
+	}
+
+	segments := strings.Split(p, "/")
+	for end := len(segments); end > 0; end-- {
+		if anchored {
+			if ok, _ := path.Match(pattern, strings.Join(segments[:end], "/")); ok {
+				return true
+			}
+			continue
+		}
+		if ok, _ := path.Match(pattern, segments[end-1]); ok {
+			return true
+		}
+	}
+	return false
+}
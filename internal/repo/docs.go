@@ -0,0 +1,100 @@
+package repo
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxDocSize caps how much of a documentation file is read, so a huge README
+// doesn't crowd out the conversation.
+const maxDocSize = 16 << 10
+
+// docDirs are where GitHub looks for community health files, in order.
+var docDirs = []string{"", ".github", "docs"}
+
+// Doc is a documentation file read from a cloned repository.
+type Doc struct {
+	Path      string // relative to the repository root
+	Content   string
+	Truncated bool // only the first maxDocSize bytes were read
+}
+
+// Guidelines reads the files in which a project states its scope and its
+// rules for contributors: the README, contributing guide and code of conduct.
+func Guidelines(localPath string) ([]Doc, error) {
+	return findDocs(localPath, "README", "CONTRIBUTING", "CODE_OF_CONDUCT")
+}
+
+// AgentInstructions reads the instructions a project wrote for AI coding
+// agents: CLAUDE.md, AGENTS.md, .cursorrules and GitHub Copilot's.
+func AgentInstructions(localPath string) ([]Doc, error) {
+	return findDocs(localPath, "CLAUDE", "AGENTS", ".cursorrules", "copilot-instructions")
+}
+
+// findDocs reads, for each name, the first file called name with any single
+// extension or none, ignoring case, found in docDirs. Translations such as
+// README.de.md don't match. Dotfiles are named in full.
+func findDocs(localPath string, names ...string) ([]Doc, error) {
+	var docs []Doc
+	for _, name := range names {
+		path, err := findDoc(localPath, name)
+		if err != nil {
+			return nil, err
+		}
+		if path == "" {
+			continue
+		}
+		doc, err := readDoc(localPath, path)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
+
+func findDoc(localPath, name string) (string, error) {
+	for _, dir := range docDirs {
+		entries, err := os.ReadDir(filepath.Join(localPath, dir))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		for _, e := range entries {
+			base := strings.TrimSuffix(e.Name(), filepath.Ext(e.Name()))
+			if e.Type().IsRegular() && (strings.EqualFold(base, name) || e.Name() == name) {
+				return filepath.Join(dir, e.Name()), nil
+			}
+		}
+	}
+	return "", nil
+}
+
+func readDoc(localPath, path string) (Doc, error) {
+	f, err := os.Open(filepath.Join(localPath, path))
+	if err != nil {
+		return Doc{}, err
+	}
+	defer f.Close()
+	b, err := io.ReadAll(io.LimitReader(f, maxDocSize+1))
+	if err != nil {
+		return Doc{}, err
+	}
+	doc := Doc{Path: filepath.ToSlash(path)}
+	if len(b) > maxDocSize {
+		// Cut at a line boundary rather than mid-sentence.
+		b = b[:maxDocSize]
+		if i := strings.LastIndexByte(string(b), '\n'); i > 0 {
+			b = b[:i]
+		}
+		doc.Truncated = true
+	}
+	doc.Content = strings.TrimSpace(string(b))
+	return doc, nil
+}
@@ -0,0 +1,134 @@
+package repo
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// issueTemplateDir holds a repository's issue templates and issue forms.
+const issueTemplateDir = ".github/ISSUE_TEMPLATE"
+
+// IssueTemplate is a Markdown issue template or a YAML issue form.
+type IssueTemplate struct {
+	Path   string // relative to the repository root; identifies the template
+	Name   string
+	About  string
+	Title  string // default issue title, usually a prefix such as "[Bug]: "
+	Labels []string
+	Form   bool   // a YAML issue form rather than a Markdown template
+	Body   string // the Markdown template's body, or the whole form definition
+}
+
+// IssueTemplates lists the issue templates and forms of a cloned repository,
+// ordered by file name like GitHub's template chooser.
+func IssueTemplates(localPath string) ([]IssueTemplate, error) {
+	entries, err := os.ReadDir(filepath.Join(localPath, issueTemplateDir))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var templates []IssueTemplate
+	for _, e := range entries {
+		ext := strings.ToLower(path.Ext(e.Name()))
+		base := strings.TrimSuffix(e.Name(), path.Ext(e.Name()))
+		if !e.Type().IsRegular() || strings.EqualFold(base, "config") ||
+			(ext != ".md" && ext != ".yml" && ext != ".yaml") {
+			continue
+		}
+		doc, err := readDoc(localPath, path.Join(issueTemplateDir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		t := IssueTemplate{Path: doc.Path, Form: ext != ".md", Body: doc.Content}
+		header := doc.Content
+		if !t.Form {
+			// Markdown templates carry their settings in YAML front matter.
+			header = ""
+			if rest, ok := strings.CutPrefix(doc.Content, "---\n"); ok {
+				if fm, body, ok := strings.Cut(rest, "\n---"); ok {
+					header, t.Body = fm, strings.TrimSpace(body)
+				}
+			}
+		}
+		fields := yamlTopLevel(header)
+		t.Name = fields["name"]
+		if t.Name == "" {
+			t.Name = base
+		}
+		t.About = fields["about"]
+		if t.Form {
+			t.About = fields["description"]
+		}
+		t.Title = fields["title"]
+		for _, l := range strings.Split(strings.Trim(fields["labels"], "[]"), ",") {
+			if l = unquote(strings.TrimSpace(l)); l != "" {
+				t.Labels = append(t.Labels, l)
+			}
+		}
+		templates = append(templates, t)
+	}
+	return templates, nil
+}
+
+// FindIssueTemplate returns the template at path, or nil if the repository
+// doesn't have it (anymore).
+func FindIssueTemplate(localPath, path string) (*IssueTemplate, error) {
+	templates, err := IssueTemplates(localPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range templates {
+		if t.Path == path {
+			return &t, nil
+		}
+	}
+	return nil, nil
+}
+
+// yamlTopLevel reads the top-level scalar keys of a YAML document, which is
+// all the template settings need. A block sequence ("labels:" followed by
+// "- bug" lines) is returned comma-separated.
+func yamlTopLevel(doc string) map[string]string {
+	fields := map[string]string{}
+	var list string // the key whose block sequence is being read
+	for _, line := range strings.Split(doc, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if line[0] == ' ' || line[0] == '\t' || line[0] == '-' {
+			if item, ok := strings.CutPrefix(trimmed, "- "); ok && list != "" {
+				if fields[list] != "" {
+					fields[list] += ","
+				}
+				fields[list] += item
+			}
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			list = ""
+			continue
+		}
+		value = strings.TrimSpace(value)
+		fields[key] = unquote(value)
+		list = ""
+		if value == "" {
+			list = key
+		}
+	}
+	return fields
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
@@ -7,6 +7,8 @@ import (
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"strconv"
+	"strings"
 
 	"github.com/esnunes/prompter/internal/paths"
 )
@@ -74,3 +76,32 @@ func pull(ctx context.Context, localPath string) error {
 	}
 	return nil
 }
+
+// RecentCommits returns the subjects of the latest n commits on the current
+// branch, merges left out, newest first, as "hash date subject" lines.
+func RecentCommits(ctx context.Context, localPath string, n int) ([]string, error) {
+	cmd := exec.CommandContext(ctx, "git", "log", "-n", strconv.Itoa(n), "--no-merges", "--format=%h %as %s")
+	cmd.Dir = localPath
+
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("listing recent commits: %w", err)
+	}
+	if len(output) == 0 {
+		return nil, nil
+	}
+	return strings.Split(strings.TrimSuffix(string(output), "\n"), "\n"), nil
+}
+
+// ListFiles returns the paths of the files tracked in a cloned repository,
+// relative to its root.
+func ListFiles(ctx context.Context, localPath string) ([]string, error) {
+	cmd := exec.CommandContext(ctx, "git", "ls-files", "-z")
+	cmd.Dir = localPath
+
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("listing repository files: %w", err)
+	}
+	return strings.Split(strings.TrimSuffix(string(output), "\x00"), "\x00"), nil
+}
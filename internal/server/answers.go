@@ -0,0 +1,119 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/esnunes/prompter/internal/db"
+)
+
+// answeredQuestion is a question of the conversation with the answer given,
+// numbered in the order the questions were asked.
+type answeredQuestion struct {
+	Number  int
+	ID      int64
+	Header  string
+	Text    string
+	Answer  string
+	Options []string // suggested when changing the answer
+}
+
+// answerHistory lists the questions answered so far in a prompt request.
+func (s *Server) answerHistory(ctx context.Context, prID int64) []answeredQuestion {
+	questions, err := s.queries.ListAnsweredQuestions(ctx, prID)
+	if err != nil {
+		log.Printf("listing answered questions of prompt request %d: %v", prID, err)
+		return nil
+	}
+	var result []answeredQuestion
+	for i, q := range questions {
+		aq := answeredQuestion{Number: i + 1, ID: q.ID, Header: q.Header, Text: q.Text, Answer: q.Answer}
+		for _, opt := range q.Options {
+			aq.Options = append(aq.Options, opt.Label)
+		}
+		result = append(result, aq)
+	}
+	return result
+}
+
+// answerCorrection is the message telling Claude an earlier answer changed.
+func answerCorrection(q answeredQuestion, answer string) string {
+	return fmt.Sprintf("Changing my answer to Q%d (%q) from %q to %q. "+
+		"Revisit anything that depended on the old answer, and generate the prompt again if you already had.",
+		q.Number, q.Text, q.Answer, answer)
+}
+
+// handleChangeAnswer changes the answer to an earlier question: it records
+// the new answer and sends Claude a correction, like a message the user typed.
+func (s *Server) handleChangeAnswer(w http.ResponseWriter, r *http.Request) {
+	org := r.PathValue("org")
+	repoName := r.PathValue("repo")
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	questionID, err := strconv.ParseInt(r.PathValue("question"), 10, 64)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	if _, err := s.queries.GetPromptRequest(r.Context(), id); err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	var question *answeredQuestion
+	for _, q := range s.answerHistory(r.Context(), id) {
+		if q.ID == questionID {
+			question = &q
+			break
+		}
+	}
+	if question == nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	if s.getRepoStatus(r.Context(), id).Status == stateProcessing {
+		http.Error(w, "Wait for the AI to finish responding before changing an answer.", http.StatusConflict)
+		return
+	}
+	answer := strings.TrimSpace(r.FormValue("answer"))
+	if answer == "" {
+		http.Error(w, "Answer is required", http.StatusBadRequest)
+		return
+	}
+	if answer == question.Answer {
+		http.Error(w, "That is already your answer.", http.StatusBadRequest)
+		return
+	}
+
+	err = s.queries.InTx(r.Context(), func(tx *db.Queries) error {
+		if _, err := tx.CreateMessage(r.Context(), id, "user", answerCorrection(*question, answer), nil); err != nil {
+			return err
+		}
+		return tx.ChangeAnswer(r.Context(), questionID, answer)
+	})
+	if err != nil {
+		log.Printf("changing answer of prompt request %d: %v", id, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	s.audit(id, "message", fmt.Sprintf("changed the answer to Q%d", question.Number), 0)
+
+	// While the repository is still cloning, the correction is sent once it's ready.
+	s.queueSendMessage(id)
+
+	redirectURL := fmt.Sprintf("/github.com/%s/%s/prompt-requests/%d", org, repoName, id)
+	if r.Header.Get("HX-Request") == "true" {
+		w.Header().Set("HX-Redirect", redirectURL)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+}
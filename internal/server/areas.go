@@ -0,0 +1,42 @@
+package server
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// areasAppendix is the collapsed section listing the areas of the codebase
+// Claude believes the request touches. It is kept apart from the request so
+// it doesn't read as implementation instructions.
+func areasAppendix(areas []string) string {
+	var b strings.Builder
+	b.WriteString("\n\n<details>\n<summary>Areas likely affected (AI hint)</summary>\n\n")
+	b.WriteString("Parts of the codebase the AI came across while exploring it; a starting point for maintainers, not part of the request.\n\n")
+	for _, a := range areas {
+		b.WriteString("- " + sanitizeTranscriptText(a) + "\n")
+	}
+	b.WriteString("\n</details>")
+	return b.String()
+}
+
+// handleIncludeAffectedAreas toggles the areas appendix and re-renders the
+// publish preview with it.
+func (s *Server) handleIncludeAffectedAreas(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	if _, err := s.queries.GetPromptRequest(r.Context(), id); err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	if err := s.queries.SetPromptRequestIncludeAffectedAreas(r.Context(), id, r.FormValue("include_areas") == "1"); err != nil {
+		log.Printf("updating areas option for prompt request %d: %v", id, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	s.handlePublishPreview(w, r)
+}
@@ -0,0 +1,363 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/esnunes/prompter/internal/db"
+	"github.com/esnunes/prompter/internal/github"
+	"github.com/esnunes/prompter/internal/models"
+	"github.com/esnunes/prompter/internal/paths"
+)
+
+// maxAttachmentSize bounds a single uploaded image.
+const maxAttachmentSize = 10 << 20
+
+// attachmentExtensions maps the accepted image types to file extensions.
+var attachmentExtensions = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+type attachmentsFragmentData struct {
+	Org     string
+	Repo    string
+	ID      int64
+	Pending []models.Attachment
+	Error   string
+}
+
+// attachmentsDir is where a prompt request's uploaded files are stored.
+func attachmentsDir(prID int64) (string, error) {
+	dir, err := paths.CacheDir()
+	if err != nil {
+		return "", fmt.Errorf("getting cache directory: %w", err)
+	}
+	return filepath.Join(dir, "attachments", strconv.FormatInt(prID, 10)), nil
+}
+
+// removeAttachments deletes the stored files of a purged prompt request.
+func removeAttachments(prID int64) {
+	dir, err := attachmentsDir(prID)
+	if err != nil {
+		log.Printf("removing attachments for prompt request %d: %v", prID, err)
+		return
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		log.Printf("removing attachments for prompt request %d: %v", prID, err)
+	}
+}
+
+func (s *Server) newAttachmentsFragmentData(ctx context.Context, org, repoName string, prID int64) (attachmentsFragmentData, error) {
+	pending, err := s.queries.ListPendingAttachments(ctx, prID)
+	if err != nil {
+		return attachmentsFragmentData{}, err
+	}
+	return attachmentsFragmentData{Org: org, Repo: repoName, ID: prID, Pending: pending}, nil
+}
+
+// messageAttachments groups a prompt request's sent attachments by message.
+func (s *Server) messageAttachments(ctx context.Context, prID int64) (map[int64][]models.Attachment, error) {
+	atts, err := s.queries.ListMessageAttachments(ctx, prID)
+	if err != nil {
+		return nil, err
+	}
+	byMessage := make(map[int64][]models.Attachment)
+	for _, a := range atts {
+		byMessage[*a.MessageID] = append(byMessage[*a.MessageID], a)
+	}
+	return byMessage, nil
+}
+
+// sendPendingAttachments links the pending uploads to a newly sent user
+// message and returns them.
+func sendPendingAttachments(ctx context.Context, q *db.Queries, prID, messageID int64) ([]models.Attachment, error) {
+	pending, err := q.ListPendingAttachments(ctx, prID)
+	if err != nil {
+		return nil, fmt.Errorf("listing pending attachments: %w", err)
+	}
+	if len(pending) == 0 {
+		return nil, nil
+	}
+	if err := q.AttachPendingAttachments(ctx, prID, messageID); err != nil {
+		return nil, fmt.Errorf("attaching uploads to message %d: %w", messageID, err)
+	}
+	return pending, nil
+}
+
+// attachmentThumbsHTML renders attachment thumbnails for gotk pushes; it
+// mirrors the attachment_thumbs.html template.
+func attachmentThumbsHTML(atts []models.Attachment) string {
+	if len(atts) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString(`<div class="message-attachments">`)
+	for _, a := range atts {
+		name := template.HTMLEscapeString(a.Filename)
+		fmt.Fprintf(&b, `<a href="/attachments/%d" target="_blank" rel="noopener"><img src="/attachments/%d" alt="%s" class="attachment-thumb" loading="lazy"></a>`, a.ID, a.ID, name)
+	}
+	b.WriteString(`</div>`)
+	return b.String()
+}
+
+// attachmentPaths lists the files on disk, for telling Claude where to find them.
+func attachmentPaths(atts []models.Attachment) []string {
+	files := make([]string, len(atts))
+	for i, a := range atts {
+		files[i] = a.Path
+	}
+	return files
+}
+
+// handleUploadAttachment stores an uploaded image as pending; it is sent with
+// the next user message.
+func (s *Server) handleUploadAttachment(w http.ResponseWriter, r *http.Request) {
+	org := r.PathValue("org")
+	repoName := r.PathValue("repo")
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	if _, err := s.queries.GetPromptRequest(r.Context(), id); err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	invalid, err := s.saveAttachment(w, r, id)
+	if err != nil {
+		log.Printf("saving attachment for prompt request %d: %v", id, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	if r.Header.Get("HX-Request") != "true" {
+		if invalid != "" {
+			http.Error(w, invalid, http.StatusBadRequest)
+			return
+		}
+		http.Redirect(w, r, fmt.Sprintf("/github.com/%s/%s/prompt-requests/%d", org, repoName, id), http.StatusSeeOther)
+		return
+	}
+	data, err := s.newAttachmentsFragmentData(r.Context(), org, repoName, id)
+	if err != nil {
+		log.Printf("listing attachments for prompt request %d: %v", id, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	data.Error = invalid
+	s.renderFragment(w, "attachments_fragment.html", data)
+}
+
+// saveAttachment writes the "image" upload to disk and records it. It returns
+// a user-facing message for invalid uploads, or an error for failures.
+func (s *Server) saveAttachment(w http.ResponseWriter, r *http.Request, prID int64) (string, error) {
+	tooLarge := fmt.Sprintf("Images must be at most %d MB.", maxAttachmentSize>>20)
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxAttachmentSize+1<<20)
+	file, header, err := r.FormFile("image")
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return tooLarge, nil
+		}
+		return "Choose an image to attach.", nil
+	}
+	defer file.Close()
+
+	if header.Size > maxAttachmentSize {
+		return tooLarge, nil
+	}
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(file, head)
+	contentType := http.DetectContentType(head[:n])
+	ext, ok := attachmentExtensions[contentType]
+	if !ok {
+		return "Only PNG, JPEG, GIF, and WebP images can be attached.", nil
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", fmt.Errorf("rewinding upload: %w", err)
+	}
+
+	dir, err := attachmentsDir(prID)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("creating attachments directory: %w", err)
+	}
+	out, err := os.CreateTemp(dir, "*"+ext)
+	if err != nil {
+		return "", fmt.Errorf("creating attachment file: %w", err)
+	}
+	size, err := io.Copy(out, file)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(out.Name())
+		return "", fmt.Errorf("writing attachment file: %w", err)
+	}
+
+	name := filepath.Base(header.Filename)
+	if name == "." || name == string(filepath.Separator) {
+		name = "image" + ext
+	}
+	if _, err := s.queries.CreateAttachment(r.Context(), prID, name, contentType, out.Name(), size); err != nil {
+		os.Remove(out.Name())
+		return "", err
+	}
+	return "", nil
+}
+
+// handleDeleteAttachment removes a pending upload.
+func (s *Server) handleDeleteAttachment(w http.ResponseWriter, r *http.Request) {
+	org := r.PathValue("org")
+	repoName := r.PathValue("repo")
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	attID, err := strconv.ParseInt(r.PathValue("attID"), 10, 64)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	a, err := s.queries.GetAttachment(r.Context(), attID)
+	if err != nil || a.PromptRequestID != id {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	if a.MessageID != nil {
+		http.Error(w, "This image was already sent and can't be removed.", http.StatusConflict)
+		return
+	}
+
+	orphaned, err := s.queries.DeletePendingAttachment(r.Context(), attID)
+	if err != nil {
+		log.Printf("deleting attachment %d: %v", attID, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if orphaned {
+		if err := os.Remove(a.Path); err != nil {
+			log.Printf("removing attachment file %s: %v", a.Path, err)
+		}
+	}
+
+	data, err := s.newAttachmentsFragmentData(r.Context(), org, repoName, id)
+	if err != nil {
+		log.Printf("listing attachments for prompt request %d: %v", id, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	s.renderFragment(w, "attachments_fragment.html", data)
+}
+
+// handleServeAttachment serves an uploaded image.
+func (s *Server) handleServeAttachment(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	a, err := s.queries.GetAttachment(r.Context(), id)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	f, err := os.Open(a.Path)
+	if err != nil {
+		log.Printf("opening attachment %d: %v", id, err)
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", a.ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	http.ServeContent(w, r, "", a.CreatedAt, f)
+}
+
+// activeAttachments lists the images sent with the conversation's active
+// (not superseded) messages.
+func (s *Server) activeAttachments(ctx context.Context, prID int64) ([]models.Attachment, error) {
+	msgs, err := s.queries.ListMessages(ctx, prID)
+	if err != nil {
+		return nil, err
+	}
+	active := make(map[int64]bool, len(msgs))
+	for _, m := range msgs {
+		active[m.ID] = true
+	}
+	all, err := s.queries.ListMessageAttachments(ctx, prID)
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(all, func(a models.Attachment) bool { return !active[*a.MessageID] }), nil
+}
+
+// attachmentsSection is the Markdown section embedding images in the issue
+// body, or "" when there are none.
+func attachmentsSection(atts []models.Attachment, url func(models.Attachment) string) string {
+	if len(atts) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("\n\n## Attachments\n")
+	for _, a := range atts {
+		fmt.Fprintf(&b, "\n![%s](%s)\n", strings.NewReplacer("[", "", "]", "").Replace(a.Filename), url(a))
+	}
+	return b.String()
+}
+
+// publishAttachments uploads the active attachments that aren't hosted yet
+// and returns the issue body section embedding all of them.
+func (s *Server) publishAttachments(ctx context.Context, prID int64) (string, error) {
+	atts, err := s.activeAttachments(ctx, prID)
+	if err != nil {
+		return "", err
+	}
+
+	var upload []models.Attachment
+	for _, a := range atts {
+		if a.RemoteURL == nil {
+			upload = append(upload, a)
+		}
+	}
+	urls, err := github.UploadImages(ctx, attachmentPaths(upload))
+	if err != nil {
+		return "", err
+	}
+	remote := make(map[int64]string, len(upload))
+	for i, a := range upload {
+		remote[a.ID] = urls[i]
+		if err := s.queries.SetAttachmentRemoteURL(context.WithoutCancel(ctx), a.ID, urls[i]); err != nil {
+			log.Printf("recording attachment URL: %v", err)
+		}
+	}
+
+	return attachmentsSection(atts, func(a models.Attachment) string {
+		if a.RemoteURL != nil {
+			return *a.RemoteURL
+		}
+		return remote[a.ID]
+	}), nil
+}
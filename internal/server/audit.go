@@ -0,0 +1,69 @@
+package server
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/esnunes/prompter/internal/models"
+)
+
+type historyData struct {
+	basePageData
+	Events []models.AuditEvent
+	// PromptRequestID narrows the page to one prompt request when set.
+	PromptRequestID int64
+}
+
+// audit appends an event for a prompt request to the audit log. A failure to
+// record it is logged and doesn't fail the action.
+func (s *Server) audit(promptRequestID int64, kind, detail string, duration time.Duration) {
+	pr, err := s.queries.GetPromptRequest(context.Background(), promptRequestID)
+	if err != nil {
+		log.Printf("audit: loading prompt request %d: %v", promptRequestID, err)
+		return
+	}
+	s.auditPR(pr, kind, detail, duration)
+}
+
+// auditPR is audit for a prompt request already loaded, or about to be purged.
+func (s *Server) auditPR(pr *models.PromptRequest, kind, detail string, duration time.Duration) {
+	err := s.queries.RecordEvent(context.Background(), models.AuditEvent{
+		PromptRequestID: pr.ID,
+		RepoURL:         pr.RepoURL,
+		Title:           pr.Title,
+		Kind:            kind,
+		Detail:          detail,
+		Duration:        duration,
+	})
+	if err != nil {
+		log.Printf("audit: %v", err)
+	}
+}
+
+// handleHistoryPage lists the audit log, newest first, optionally for a
+// single prompt request (?prompt_request=ID).
+func (s *Server) handleHistoryPage(w http.ResponseWriter, r *http.Request) {
+	var prID int64
+	if v := r.URL.Query().Get("prompt_request"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			http.Error(w, "Not Found", http.StatusNotFound)
+			return
+		}
+		prID = id
+	}
+	events, err := s.queries.ListEvents(r.Context(), prID, 500)
+	if err != nil {
+		log.Printf("listing audit events: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	s.renderPage(w, "history.html", historyData{
+		basePageData:    basePageData{Sidebar: s.buildAllSidebar(r.Context(), sidebarPageSize)},
+		Events:          events,
+		PromptRequestID: prID,
+	})
+}
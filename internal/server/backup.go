@@ -0,0 +1,37 @@
+package server
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/esnunes/prompter/internal/db"
+)
+
+// runBackups snapshots the database every BackupInterval, keeping the newest
+// BackupKeep copies in the backup directory. The first backup is taken one
+// interval after start.
+func (s *Server) runBackups(ctx context.Context) {
+	if s.cfg.BackupInterval <= 0 || s.cfg.BackupKeep <= 0 {
+		return
+	}
+	dir, err := db.BackupDir()
+	if err != nil {
+		log.Printf("backup: %v", err)
+		return
+	}
+	ticker := time.NewTicker(s.cfg.BackupInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if path, err := s.queries.CreateBackup(ctx, dir, s.cfg.BackupKeep); err != nil {
+			log.Printf("backup: %v", err)
+		} else {
+			log.Printf("backup: saved %s", path)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/esnunes/prompter/internal/models"
+)
+
+// boardColumn is a status column on the kanban board.
+type boardColumn struct {
+	Key   string // "draft", "ready", "published", "answered"
+	Title string
+	Cards []boardCard
+}
+
+type boardCard struct {
+	models.PromptRequest
+	Org  string
+	Repo string
+	// DropTargets lists the column keys the card can be dragged to.
+	DropTargets string
+}
+
+type boardData struct {
+	basePageData
+	Columns []boardColumn
+}
+
+// handleBoardPage shows active prompt requests as a kanban board. Drafts with a
+// generated prompt are "ready to publish" and can be dragged onto Published,
+// which publishes them to GitHub. Published prompt requests whose issue saw
+// activity from someone else, as recorded by the issue watch, move on to
+// "Answered by maintainer".
+func (s *Server) handleBoardPage(w http.ResponseWriter, r *http.Request) {
+	prs, _, err := s.queries.ListPromptRequests(r.Context(), false, 0, 0)
+	if err != nil {
+		log.Printf("listing prompt requests for board: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	columns := []boardColumn{
+		{Key: "draft", Title: "Draft"},
+		{Key: "ready", Title: "Ready to publish"},
+		{Key: "published", Title: "Published"},
+		{Key: "answered", Title: "Answered by maintainer"},
+	}
+	for _, pr := range prs {
+		card := boardCard{PromptRequest: pr}
+		if parts := strings.SplitN(pr.RepoURL, "/", 3); len(parts) == 3 {
+			card.Org, card.Repo = parts[1], parts[2]
+		}
+		switch {
+		case pr.Status == "published" && pr.IssueActivityAt != nil:
+			columns[3].Cards = append(columns[3].Cards, card)
+		case pr.Status == "published":
+			columns[2].Cards = append(columns[2].Cards, card)
+		case pr.HasGeneratedPrompt:
+			card.DropTargets = "published"
+			columns[1].Cards = append(columns[1].Cards, card)
+		default:
+			columns[0].Cards = append(columns[0].Cards, card)
+		}
+	}
+
+	s.renderPage(w, "board.html", boardData{
+		basePageData: basePageData{Sidebar: s.buildAllSidebar(r.Context(), sidebarPageSize)},
+		Columns:      columns,
+	})
+}
+
+func (s *Server) hasGeneratedPrompt(ctx context.Context, prID int64) bool {
+	gc, err := s.queries.GetLatestGeneratedContent(ctx, prID)
+	return err == nil && gc != nil
+}
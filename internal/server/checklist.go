@@ -0,0 +1,145 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/esnunes/prompter/internal/claude"
+	"github.com/esnunes/prompter/internal/db"
+	"github.com/esnunes/prompter/internal/models"
+)
+
+// promptCheckRow is a checklist item with its verdict, as the publish
+// preview shows it.
+type promptCheckRow struct {
+	Label  string
+	Passed bool
+	Fix    string
+}
+
+// promptChecks returns the verdicts of the last quality check of the prompt
+// generated by messageID, in checklist order, and whether every item passed.
+// No rows means the prompt wasn't checked yet.
+func (s *Server) promptChecks(ctx context.Context, messageID int64) (rows []promptCheckRow, passed bool, err error) {
+	checks, err := s.queries.ListPromptChecks(ctx, messageID)
+	if err != nil || len(checks) == 0 {
+		return nil, false, err
+	}
+	passed = true
+	for _, item := range claude.Checklist {
+		for _, c := range checks {
+			if c.Item == item.ID {
+				rows = append(rows, promptCheckRow{Label: item.Label, Passed: c.Passed, Fix: c.Fix})
+				passed = passed && c.Passed
+			}
+		}
+	}
+	return rows, passed, nil
+}
+
+// promptChecked reports whether the quality check ran on the prompt
+// generated by messageID, or the latest one when messageID is 0. Prompts are
+// published only once it has, whatever its verdicts. Missing prompts are
+// left for publishing to report.
+func (s *Server) promptChecked(ctx context.Context, prID, messageID int64) bool {
+	gc, err := s.generatedContent(ctx, prID, messageID)
+	if err != nil {
+		return true
+	}
+	checks, _, err := s.promptChecks(ctx, gc.MessageID)
+	if err != nil {
+		log.Printf("listing quality checks of prompt request %d: %v", prID, err)
+	}
+	return len(checks) > 0
+}
+
+// generatedIssueText is the generated prompt as the quality check reviews it.
+func generatedIssueText(gc *db.GeneratedContent) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Title: %s\n\nMotivation:\n%s\n\nPrompt:\n%s\n", gc.Title, gc.Motivation, gc.Prompt)
+	if len(gc.Glossary) > 0 {
+		b.WriteString("\nDefinitions:\n")
+		for _, t := range gc.Glossary {
+			fmt.Fprintf(&b, "- %s: %s\n", t.Term, t.Definition)
+		}
+	}
+	if len(gc.NonGoals) > 0 {
+		b.WriteString("\nOut of scope:\n- " + strings.Join(gc.NonGoals, "\n- ") + "\n")
+	}
+	if len(gc.Alternatives) > 0 {
+		b.WriteString("\nAlternatives considered:\n- " + strings.Join(gc.Alternatives, "\n- ") + "\n")
+	}
+	return b.String()
+}
+
+// handleCheckPrompt has Claude validate a generated prompt against the
+// quality checklist and re-renders the publish preview with the verdicts.
+// ?message_id= picks the prompt version; it defaults to the latest.
+func (s *Server) handleCheckPrompt(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	pr, err := s.queries.GetPromptRequest(r.Context(), id)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	messageID, _ := strconv.ParseInt(r.URL.Query().Get("message_id"), 10, 64)
+	gc, err := s.generatedContent(r.Context(), id, messageID)
+	if err != nil {
+		s.publishPreview(w, r, "")
+		return
+	}
+	s.publishPreview(w, r, s.checkPrompt(r.Context(), pr, gc))
+}
+
+// checkPrompt runs the quality check of gc and records its verdicts. It
+// returns why the check couldn't run, or "".
+func (s *Server) checkPrompt(ctx context.Context, pr *models.PromptRequest, gc *db.GeneratedContent) string {
+	msgs, err := s.queries.ListMessages(ctx, pr.ID)
+	if err != nil {
+		log.Printf("listing messages of prompt request %d: %v", pr.ID, err)
+		return "Couldn't load the conversation."
+	}
+	var upTo []models.Message
+	for _, m := range msgs {
+		if m.ID <= gc.MessageID {
+			upTo = append(upTo, m)
+		}
+	}
+	var transcript strings.Builder
+	writeTranscript(&transcript, upTo)
+
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.JobTimeout)
+	defer cancel()
+	release, err := s.acquireClaude(ctx, pr.ID)
+	if err != nil {
+		return "The quality check was cancelled."
+	}
+	defer release()
+
+	started := time.Now()
+	results, err := claude.CheckPrompt(ctx, pr.RepoLocalPath, transcript.String(), generatedIssueText(gc))
+	if err != nil {
+		s.auditPR(pr, "claude", fmt.Sprintf("quality check failed: %v", err), time.Since(started))
+		return "The quality check failed. Try again."
+	}
+	s.auditPR(pr, "claude", "checked the prompt's quality", time.Since(started))
+
+	checks := make([]models.PromptCheck, len(results))
+	for i, res := range results {
+		checks[i] = models.PromptCheck{Item: res.ID, Passed: res.Passed, Fix: strings.TrimSpace(res.Fix)}
+	}
+	if err := s.queries.ReplacePromptChecks(context.WithoutCancel(ctx), gc.MessageID, checks); err != nil {
+		log.Printf("saving quality check of prompt request %d: %v", pr.ID, err)
+		return "Couldn't save the quality check."
+	}
+	return ""
+}
@@ -0,0 +1,148 @@
+package server
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+var gzipPool = sync.Pool{
+	New: func() any { return gzip.NewWriter(io.Discard) },
+}
+
+// gzipResponseWriter compresses the body once the handler starts writing,
+// unless the response already has an encoding or carries no body.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	gz          *gzip.Writer
+	wroteHeader bool
+}
+
+func (g *gzipResponseWriter) WriteHeader(code int) {
+	if g.wroteHeader {
+		return
+	}
+	g.wroteHeader = true
+	h := g.Header()
+	if code != http.StatusNoContent && code != http.StatusNotModified && h.Get("Content-Encoding") == "" {
+		h.Set("Content-Encoding", "gzip")
+		h.Del("Content-Length")
+		g.gz = gzipPool.Get().(*gzip.Writer)
+		g.gz.Reset(g.ResponseWriter)
+	}
+	g.ResponseWriter.WriteHeader(code)
+}
+
+func (g *gzipResponseWriter) Write(b []byte) (int, error) {
+	if !g.wroteHeader {
+		if g.Header().Get("Content-Type") == "" {
+			g.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		g.WriteHeader(http.StatusOK)
+	}
+	if g.gz == nil {
+		return g.ResponseWriter.Write(b)
+	}
+	return g.gz.Write(b)
+}
+
+func (g *gzipResponseWriter) Flush() {
+	if g.gz != nil {
+		g.gz.Flush()
+	}
+	if f, ok := g.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (g *gzipResponseWriter) close() {
+	if g.gz == nil {
+		return
+	}
+	g.gz.Close()
+	gzipPool.Put(g.gz)
+	g.gz = nil
+}
+
+// withGzip compresses responses for clients that accept gzip. WebSocket
+// upgrades are passed through untouched since they hijack the connection,
+// and range requests since byte offsets refer to the uncompressed body.
+func withGzip(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") ||
+			strings.EqualFold(r.Header.Get("Upgrade"), "websocket") ||
+			r.Header.Get("Range") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		gw := &gzipResponseWriter{ResponseWriter: w}
+		defer gw.close()
+		next.ServeHTTP(gw, r)
+	})
+}
+
+// hashStatic computes a short content hash for every file in the static FS.
+// Hashes are used both as ETags and as cache-busting query strings.
+func hashStatic(fsys fs.FS) (map[string]string, error) {
+	hashes := map[string]string{}
+	err := fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(fsys, path)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(data)
+		hashes[path] = hex.EncodeToString(sum[:6])
+		return nil
+	})
+	return hashes, err
+}
+
+// staticHandler serves static assets with validators. Requests carrying the
+// current content hash in ?v= are cached for a year; anything else must
+// revalidate. In dev mode (hashes == nil) nothing is cached.
+func staticHandler(fsys fs.FS, hashes map[string]string) http.Handler {
+	files := http.StripPrefix("/static/", http.FileServer(http.FS(fsys)))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hashes == nil {
+			w.Header().Set("Cache-Control", "no-store")
+			files.ServeHTTP(w, r)
+			return
+		}
+		hash, ok := hashes[strings.TrimPrefix(r.URL.Path, "/static/")]
+		if ok {
+			w.Header().Set("ETag", `"`+hash+`"`)
+		}
+		if ok && r.URL.Query().Get("v") == hash {
+			w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		} else {
+			w.Header().Set("Cache-Control", "no-cache")
+		}
+		files.ServeHTTP(w, r)
+	})
+}
+
+// serviceWorkerHandler serves static/sw.js from the site root: a service
+// worker only controls the pages under the path it was served from.
+func serviceWorkerHandler(fsys fs.FS) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-cache")
+		http.ServeFileFS(w, r, fsys, "sw.js")
+	})
+}
+
+// staticURL returns the URL for a static asset, versioned by content hash when known.
+func staticURL(hashes map[string]string, name string) string {
+	if hash, ok := hashes[name]; ok {
+		return "/static/" + name + "?v=" + hash
+	}
+	return "/static/" + name
+}
@@ -0,0 +1,220 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/esnunes/prompter/internal/github"
+	"github.com/esnunes/prompter/internal/models"
+)
+
+// errUpstreamEdited is returned when publishing would overwrite edits made to
+// the issue on GitHub since the last revision.
+var errUpstreamEdited = errors.New("the issue was edited on GitHub since it was last published")
+
+// normalizeIssueBody ignores the line-ending and trailing-space differences
+// GitHub introduces when an issue is saved from its web editor.
+func normalizeIssueBody(body string) string {
+	return strings.TrimSpace(strings.ReplaceAll(body, "\r\n", "\n"))
+}
+
+// checkUpstreamEdits compares the issue's current body with the last
+// published revision and returns errUpstreamEdited when they differ.
+func (s *Server) checkUpstreamEdits(ctx context.Context, pr *models.PromptRequest) error {
+	revisions, err := s.queries.ListRevisions(ctx, pr.ID)
+	if err != nil {
+		return err
+	}
+	if len(revisions) == 0 {
+		return nil
+	}
+	issue, err := github.ViewIssue(ctx, pr.RepoURL, *pr.IssueNumber)
+	if err != nil {
+		return fmt.Errorf("fetching GitHub issue: %w", err)
+	}
+	if normalizeIssueBody(issue.Body) != normalizeIssueBody(revisions[len(revisions)-1].Content) {
+		return errUpstreamEdited
+	}
+	return nil
+}
+
+func conflictURL(org, repoName string, id, messageID int64) string {
+	return fmt.Sprintf("/github.com/%s/%s/prompt-requests/%d/publish/conflict?message_id=%d", org, repoName, id, messageID)
+}
+
+// upstreamEditedNotice is pushed to the conversation when a publish stops
+// because of upstream edits.
+func upstreamEditedNotice(org, repoName string, id, messageID int64) string {
+	return fmt.Sprintf(
+		`<div class="publish-conflict-notice">The issue was edited on GitHub since it was last published, so nothing was overwritten. `+
+			`<a href="%s">Review the changes</a> to overwrite, keep or merge them.</div>`,
+		template.HTMLEscapeString(conflictURL(org, repoName, id, messageID)))
+}
+
+func (s *Server) redirectToConflict(w http.ResponseWriter, r *http.Request, id, messageID int64) {
+	redirectURL := conflictURL(r.PathValue("org"), r.PathValue("repo"), id, messageID)
+	if r.Header.Get("HX-Request") == "true" {
+		w.Header().Set("HX-Redirect", redirectURL)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+}
+
+type publishConflictData struct {
+	basePageData
+	PromptRequest *models.PromptRequest
+	Org           string
+	Repo          string
+	MessageID     int64
+	TheirChanges  []diffLine // last published revision → current issue body
+	OurChanges    []diffLine // current issue body → the version being published
+	Upstream      string
+	Error         string
+}
+
+// conflictBodies returns the last published revision, the issue's current
+// body on GitHub, and the body the selected generated prompt would publish.
+func (s *Server) conflictBodies(ctx context.Context, pr *models.PromptRequest, messageID int64) (published, upstream, ours string, err error) {
+	revisions, err := s.queries.ListRevisions(ctx, pr.ID)
+	if err != nil {
+		return "", "", "", err
+	}
+	if len(revisions) > 0 {
+		published = revisions[len(revisions)-1].Content
+	}
+	issue, err := github.ViewIssue(ctx, pr.RepoURL, *pr.IssueNumber)
+	if err != nil {
+		return "", "", "", fmt.Errorf("fetching GitHub issue: %w", err)
+	}
+	gc, err := s.generatedContent(ctx, pr.ID, messageID)
+	if err != nil {
+		return "", "", "", err
+	}
+	ours, err = s.previewIssueBody(pr, gc)
+	if err != nil {
+		return "", "", "", err
+	}
+	return published, normalizeIssueBody(issue.Body), ours, nil
+}
+
+// handlePublishConflict shows what changed on GitHub since the last publish
+// next to what publishing would change, and offers to overwrite, keep the
+// GitHub version, or merge by hand.
+func (s *Server) handlePublishConflict(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	pr, err := s.queries.GetPromptRequest(r.Context(), id)
+	if err != nil || pr.IssueNumber == nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	messageID, _ := strconv.ParseInt(r.URL.Query().Get("message_id"), 10, 64)
+
+	data := publishConflictData{
+		basePageData:  basePageData{Sidebar: s.buildAllSidebar(r.Context(), sidebarPageSize)},
+		PromptRequest: pr,
+		Org:           r.PathValue("org"),
+		Repo:          r.PathValue("repo"),
+		MessageID:     messageID,
+	}
+	published, upstream, ours, err := s.conflictBodies(r.Context(), pr, messageID)
+	if err != nil {
+		log.Printf("comparing prompt request %d with its issue: %v", id, err)
+		data.Error = "Couldn't load the issue from GitHub. Check your connection and reload."
+	} else {
+		data.TheirChanges = lineDiff(normalizeIssueBody(published), upstream)
+		data.OurChanges = lineDiff(upstream, ours)
+		data.Upstream = upstream
+	}
+	s.renderPage(w, "publish_conflict.html", data)
+}
+
+// handleKeepUpstream accepts the issue as edited on GitHub: it is recorded as
+// a new revision so later publishes compare against it, and nothing is sent.
+func (s *Server) handleKeepUpstream(w http.ResponseWriter, r *http.Request) {
+	s.resolveConflict(w, r, func(ctx context.Context, pr *models.PromptRequest) (string, error) {
+		issue, err := github.ViewIssue(ctx, pr.RepoURL, *pr.IssueNumber)
+		if err != nil {
+			return "", fmt.Errorf("fetching GitHub issue: %w", err)
+		}
+		return normalizeIssueBody(issue.Body), nil
+	})
+}
+
+// handleMergeUpstream publishes a hand-merged body and records it as a revision.
+func (s *Server) handleMergeUpstream(w http.ResponseWriter, r *http.Request) {
+	body := strings.TrimSpace(strings.ReplaceAll(r.FormValue("body"), "\r\n", "\n"))
+	s.resolveConflict(w, r, func(ctx context.Context, pr *models.PromptRequest) (string, error) {
+		if body == "" {
+			return "", errEmptyMergedBody
+		}
+		if err := github.EditIssue(ctx, pr.RepoURL, *pr.IssueNumber, body); err != nil {
+			return "", fmt.Errorf("updating GitHub issue: %w", err)
+		}
+		return body, nil
+	})
+}
+
+var errEmptyMergedBody = errors.New("the merged issue body is empty")
+
+// resolveConflict runs resolve, which settles the issue body, then records
+// that body as the latest revision.
+func (s *Server) resolveConflict(w http.ResponseWriter, r *http.Request, resolve func(context.Context, *models.PromptRequest) (string, error)) {
+	org := r.PathValue("org")
+	repoName := r.PathValue("repo")
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	pr, err := s.queries.GetPromptRequest(r.Context(), id)
+	if err != nil || pr.IssueNumber == nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	body, err := resolve(r.Context(), pr)
+	if errors.Is(err, errEmptyMergedBody) {
+		http.Error(w, "The merged issue body can't be empty.", http.StatusBadRequest)
+		return
+	} else if err != nil {
+		log.Printf("resolving publish conflict for prompt request %d: %v", id, err)
+		http.Error(w, "Couldn't reach the GitHub issue. Check your connection and try again.", http.StatusBadRequest)
+		return
+	}
+
+	var afterMsgID, sourceMsgID *int64
+	if lastMsg, err := s.queries.GetLastMessage(r.Context(), id); err == nil {
+		afterMsgID = &lastMsg.ID
+	}
+	if messageID, _ := strconv.ParseInt(r.FormValue("message_id"), 10, 64); messageID != 0 {
+		if gc, err := s.generatedContent(r.Context(), id, messageID); err == nil {
+			sourceMsgID = &gc.MessageID
+		}
+	}
+	if _, err := s.queries.CreateRevision(r.Context(), id, body, afterMsgID, sourceMsgID); err != nil {
+		log.Printf("creating revision: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	s.auditPR(pr, "issue-edited", fmt.Sprintf("issue #%d conflict resolved", *pr.IssueNumber), 0)
+
+	redirectURL := fmt.Sprintf("/github.com/%s/%s/prompt-requests/%d", org, repoName, id)
+	if r.Header.Get("HX-Request") == "true" {
+		w.Header().Set("HX-Redirect", redirectURL)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+}
@@ -0,0 +1,191 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/esnunes/prompter/internal/models"
+)
+
+// diffContext is how many unchanged lines are kept around each change;
+// longer unchanged runs are collapsed.
+const diffContext = 3
+
+// diffLine is one line of a unified diff. Op is "+", "-", " ", or "…" for a
+// collapsed run of unchanged lines (Text then says how many).
+type diffLine struct {
+	Op   string
+	Text string
+}
+
+// lineDiff computes a line-based unified diff from a to b using the longest
+// common subsequence. Revisions are short enough for the quadratic table.
+func lineDiff(a, b string) []diffLine {
+	x := strings.Split(a, "\n")
+	y := strings.Split(b, "\n")
+
+	// lcs[i][j] is the LCS length of x[i:] and y[j:].
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var lines []diffLine
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			lines = append(lines, diffLine{Op: " ", Text: x[i]})
+			i++
+			j++
+		case i < len(x) && (j == len(y) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, diffLine{Op: "-", Text: x[i]})
+			i++
+		default:
+			lines = append(lines, diffLine{Op: "+", Text: y[j]})
+			j++
+		}
+	}
+	return collapseUnchanged(lines)
+}
+
+// collapseUnchanged replaces unchanged lines farther than diffContext from
+// any change with a single "…" marker.
+func collapseUnchanged(lines []diffLine) []diffLine {
+	keep := make([]bool, len(lines))
+	for i, l := range lines {
+		if l.Op == " " {
+			continue
+		}
+		for k := max(0, i-diffContext); k <= min(len(lines)-1, i+diffContext); k++ {
+			keep[k] = true
+		}
+	}
+
+	var out []diffLine
+	for i := 0; i < len(lines); {
+		if keep[i] {
+			out = append(out, lines[i])
+			i++
+			continue
+		}
+		n := 0
+		for i < len(lines) && !keep[i] {
+			n++
+			i++
+		}
+		out = append(out, diffLine{Op: "…", Text: fmt.Sprintf("%d unchanged lines", n)})
+	}
+	return out
+}
+
+// diffSide is one selectable side of a comparison: a revision or the current draft.
+type diffSide struct {
+	Value string // revision ID, or "current"
+	Label string
+	Body  string
+}
+
+type revisionDiffData struct {
+	basePageData
+	PromptRequest *models.PromptRequest
+	Org           string
+	Repo          string
+	Sides         []diffSide
+	From          string
+	To            string
+	Lines         []diffLine
+	Changed       bool
+}
+
+// handleRevisionDiff compares two published revisions, or a revision with the
+// body the current generated prompt would publish.
+func (s *Server) handleRevisionDiff(w http.ResponseWriter, r *http.Request) {
+	org := r.PathValue("org")
+	repoName := r.PathValue("repo")
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	pr, err := s.queries.GetPromptRequest(r.Context(), id)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	revisions, err := s.queries.ListRevisions(r.Context(), id)
+	if err != nil {
+		log.Printf("listing revisions: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	var sides []diffSide
+	for _, rev := range revisions {
+		sides = append(sides, diffSide{
+			Value: strconv.FormatInt(rev.ID, 10),
+			Label: fmt.Sprintf("Revision %d (%s)", rev.ID, timeAgo(rev.PublishedAt)),
+			Body:  rev.Content,
+		})
+	}
+	if gc, err := s.queries.GetLatestGeneratedContent(r.Context(), id); err == nil {
+		body, err := s.previewIssueBody(pr, gc)
+		if err != nil {
+			log.Printf("composing current draft: %v", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		sides = append(sides, diffSide{Value: "current", Label: "Current draft (unpublished)", Body: body})
+	}
+	if len(sides) < 2 {
+		http.Error(w, "There is nothing to compare yet: publish a revision and keep refining the prompt first.", http.StatusBadRequest)
+		return
+	}
+
+	data := revisionDiffData{
+		basePageData:  basePageData{Sidebar: s.buildAllSidebar(r.Context(), sidebarPageSize)},
+		PromptRequest: pr,
+		Org:           org,
+		Repo:          repoName,
+		Sides:         sides,
+		From:          r.URL.Query().Get("from"),
+		To:            r.URL.Query().Get("to"),
+	}
+	// Default to the last two entries: the latest revision against the draft.
+	from := findDiffSide(sides, data.From)
+	if from == nil {
+		from = &sides[len(sides)-2]
+		data.From = from.Value
+	}
+	to := findDiffSide(sides, data.To)
+	if to == nil {
+		to = &sides[len(sides)-1]
+		data.To = to.Value
+	}
+	data.Lines = lineDiff(from.Body, to.Body)
+	data.Changed = from.Body != to.Body
+
+	s.renderPage(w, "revision_diff.html", data)
+}
+
+func findDiffSide(sides []diffSide, value string) *diffSide {
+	for i := range sides {
+		if sides[i].Value == value {
+			return &sides[i]
+		}
+	}
+	return nil
+}
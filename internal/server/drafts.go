@@ -0,0 +1,125 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/esnunes/prompter/internal/db"
+)
+
+// maxDraftLength bounds a saved draft, message or answers.
+const maxDraftLength = 100_000
+
+// handleSaveDraft autosaves the unsent input of a conversation: the message
+// being typed, or the answers picked so far to the pending questions along
+// with the assistant message asking them. Only the part posted is replaced.
+func (s *Server) handleSaveDraft(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	if _, err := s.queries.GetPromptRequest(r.Context(), id); err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	if r.PostForm.Has("message") {
+		message := r.PostForm.Get("message")
+		if strings.TrimSpace(message) == "" {
+			message = ""
+		}
+		if len(message) > maxDraftLength {
+			http.Error(w, fmt.Sprintf("Drafts must be at most %d characters.", maxDraftLength), http.StatusBadRequest)
+			return
+		}
+		if err := s.queries.SaveDraftMessage(r.Context(), id, message); err != nil {
+			log.Printf("saving draft of prompt request %d: %v", id, err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if v := r.PostForm.Get("answers_for"); v != "" {
+		messageID, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+		answers := draftAnswers(r.PostForm).Encode()
+		if len(answers) > maxDraftLength {
+			http.Error(w, fmt.Sprintf("Drafts must be at most %d characters.", maxDraftLength), http.StatusBadRequest)
+			return
+		}
+		if err := s.queries.SaveDraftAnswers(r.Context(), id, messageID, answers); err != nil {
+			log.Printf("saving draft answers of prompt request %d: %v", id, err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// draftAnswers keeps the question fields of a form that hold answers: the
+// options picked and the "Other" texts and details typed.
+func draftAnswers(form url.Values) url.Values {
+	answers := url.Values{}
+	for key, values := range form {
+		if !strings.HasPrefix(key, "q_") || strings.HasSuffix(key, "_header") {
+			continue
+		}
+		for _, v := range values {
+			if v != "" {
+				answers.Add(key, v)
+			}
+		}
+	}
+	return answers
+}
+
+// restoreDraftAnswers picks the options and fills in the "Other" texts and details saved
+// in a draft.
+func restoreDraftAnswers(questions []questionData, encoded string) {
+	answers, err := url.ParseQuery(encoded)
+	if err != nil || len(answers) == 0 {
+		return
+	}
+	for i := range questions {
+		q := &questions[i]
+		picked := answers[fmt.Sprintf("q_%d", q.Index)]
+		for j := range q.Options {
+			q.Options[j].Checked = slices.Contains(picked, q.Options[j].Label)
+		}
+		for j := range q.Fixed {
+			q.Fixed[j].Checked = slices.Contains(picked, q.Fixed[j].Value)
+		}
+		q.OtherChecked = slices.Contains(picked, "__other__")
+		q.Other = answers.Get(fmt.Sprintf("q_%d_other", q.Index))
+		q.Note = answers.Get(fmt.Sprintf("q_%d_note", q.Index))
+	}
+}
+
+// clearDraft drops the draft a sent message came from: the answers picked
+// when it answered questions, the typed message otherwise.
+func clearDraft(ctx context.Context, q *db.Queries, id int64, answered bool) error {
+	var err error
+	if answered {
+		err = q.SaveDraftAnswers(ctx, id, 0, "")
+	} else {
+		err = q.SaveDraftMessage(ctx, id, "")
+	}
+	if err != nil {
+		return fmt.Errorf("clearing draft: %w", err)
+	}
+	return nil
+}
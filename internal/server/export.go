@@ -0,0 +1,223 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/esnunes/prompter/internal/db"
+	"github.com/esnunes/prompter/internal/models"
+)
+
+// exportData is a read-only copy of a prompt request: a downloaded HTML file
+// or the page behind a share link. It needs nothing from the server once
+// rendered, so images are inlined.
+type exportData struct {
+	PromptRequest *models.PromptRequest
+	Generated     *db.GeneratedContent // nil until the AI generated a prompt
+	Messages      []exportMessage
+	GeneratedAt   time.Time
+	Link          *models.ShareLink // set on a shared page
+}
+
+type exportMessage struct {
+	Role      string
+	Content   string
+	CreatedAt time.Time
+	Questions []questionData
+	Images    []template.URL // data: URLs of the attached images
+}
+
+func (s *Server) newExportData(ctx context.Context, pr *models.PromptRequest) (*exportData, error) {
+	msgs, err := s.queries.ListMessages(ctx, pr.ID)
+	if err != nil {
+		return nil, err
+	}
+	attachments, err := s.messageAttachments(ctx, pr.ID)
+	if err != nil {
+		return nil, err
+	}
+	data := &exportData{PromptRequest: pr, GeneratedAt: time.Now().UTC()}
+	if gc, err := s.queries.GetLatestGeneratedContent(ctx, pr.ID); err == nil {
+		data.Generated = gc
+	}
+	for _, m := range msgs {
+		em := exportMessage{Role: m.Role, Content: m.Content, CreatedAt: m.CreatedAt}
+		em.Questions, _ = s.pendingQuestions(ctx, &m)
+		for _, a := range attachments[m.ID] {
+			b, err := os.ReadFile(a.Path)
+			if err != nil {
+				log.Printf("exporting attachment %d: %v", a.ID, err)
+				continue
+			}
+			em.Images = append(em.Images, template.URL("data:"+a.ContentType+";base64,"+base64.StdEncoding.EncodeToString(b)))
+		}
+		data.Messages = append(data.Messages, em)
+	}
+	return data, nil
+}
+
+// handleExportHTML downloads the prompt request as a single HTML file that
+// opens without Prompter, to show a draft to someone before publishing.
+func (s *Server) handleExportHTML(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	pr, err := s.queries.GetPromptRequest(r.Context(), id)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	data, err := s.newExportData(r.Context(), pr)
+	if err != nil {
+		log.Printf("exporting prompt request %d: %v", id, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="prompt-request-%d.html"`, id))
+	s.renderFragment(w, "export.html", data)
+}
+
+// handleExportTranscript downloads the conversation as a Markdown transcript,
+// the same one "prompter export ID" writes.
+func (s *Server) handleExportTranscript(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	md, err := s.queries.ExportMarkdown(r.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("exporting prompt request %d as Markdown: %v", id, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="prompt-request-%d.md"`, id))
+	io.WriteString(w, md)
+}
+
+type shareLinksData struct {
+	Org             string
+	Repo            string
+	PromptRequestID int64
+	BaseURL         string // where others reach this server
+	TTL             string // how long a new link works, e.g. "7 days"
+	Links           []models.ShareLink
+}
+
+func (s *Server) newShareLinksData(ctx context.Context, org, repoName string, id int64) (shareLinksData, error) {
+	links, err := s.queries.ListShareLinks(ctx, id)
+	return shareLinksData{
+		Org:             org,
+		Repo:            repoName,
+		PromptRequestID: id,
+		BaseURL:         strings.TrimSuffix(s.cfg.PublicURL, "/"),
+		TTL:             shareLinkTTL(s.cfg.ShareLinkTTL),
+		Links:           links,
+	}, err
+}
+
+func shareLinkTTL(d time.Duration) string {
+	switch days := d / (24 * time.Hour); {
+	case d%(24*time.Hour) != 0:
+		return formatDuration(d)
+	case days == 1:
+		return "1 day"
+	default:
+		return fmt.Sprintf("%d days", days)
+	}
+}
+
+// handleCreateShareLink creates a link to a read-only copy of the prompt
+// request that expires after ShareLinkTTL. It needs PublicURL, as links to
+// an address only this computer can reach are no use to anyone else.
+func (s *Server) handleCreateShareLink(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || s.cfg.PublicURL == "" {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	if _, err := s.queries.GetPromptRequest(r.Context(), id); err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	if _, err := s.queries.CreateShareLink(r.Context(), id, time.Now().Add(s.cfg.ShareLinkTTL)); err != nil {
+		log.Printf("sharing prompt request %d: %v", id, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	s.renderShareLinks(w, r, id)
+}
+
+// handleRevokeShareLinks stops every link to the prompt request working.
+func (s *Server) handleRevokeShareLinks(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || s.cfg.PublicURL == "" {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	if err := s.queries.RevokeShareLinks(r.Context(), id); err != nil {
+		log.Printf("revoking share links of prompt request %d: %v", id, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	s.renderShareLinks(w, r, id)
+}
+
+func (s *Server) renderShareLinks(w http.ResponseWriter, r *http.Request, id int64) {
+	data, err := s.newShareLinksData(r.Context(), r.PathValue("org"), r.PathValue("repo"), id)
+	if err != nil {
+		log.Printf("listing share links of prompt request %d: %v", id, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	s.renderFragment(w, "share_links_fragment.html", data)
+}
+
+// handleSharedPage shows the read-only copy behind a share link.
+func (s *Server) handleSharedPage(w http.ResponseWriter, r *http.Request) {
+	if s.cfg.PublicURL == "" {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	link, err := s.queries.GetShareLink(r.Context(), r.PathValue("token"))
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "This link doesn't exist or has expired.", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("reading share link: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	pr, err := s.queries.GetPromptRequest(r.Context(), link.PromptRequestID)
+	if err != nil || pr.Status == "deleted" {
+		http.Error(w, "This link doesn't exist or has expired.", http.StatusNotFound)
+		return
+	}
+	data, err := s.newExportData(r.Context(), pr)
+	if err != nil {
+		log.Printf("sharing prompt request %d: %v", pr.ID, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	data.Link = link
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Header().Set("Cache-Control", "private, no-store")
+	s.renderFragment(w, "export.html", data)
+}
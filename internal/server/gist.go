@@ -0,0 +1,101 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/esnunes/prompter/internal/github"
+)
+
+func gistPublishURL(org, repoName string, id, messageID int64) string {
+	return fmt.Sprintf("/github.com/%s/%s/prompt-requests/%d/publish/gist?message_id=%d", org, repoName, id, messageID)
+}
+
+// gistNotice is pushed to the conversation when the repository refuses new
+// issues, offering to publish the issue body as a gist instead.
+func gistNotice(org, repoName string, id, messageID int64) string {
+	return fmt.Sprintf(
+		`<div class="publish-conflict-notice">This repository doesn't accept new issues from you: they are disabled, or you lack permission. `+
+			`Publish the issue as a secret gist to still get a link you can share with the maintainers. `+
+			`<form method="post" action="%s" style="display:inline;"><button type="submit" class="btn btn-sm btn-secondary">Publish as a Gist</button></form></div>`,
+		template.HTMLEscapeString(gistPublishURL(org, repoName, id, messageID)))
+}
+
+// publishGist publishes the issue composed from the generated content of
+// assistant message messageID (0 for the latest) as a gist, and records its
+// URL on the prompt request.
+func (s *Server) publishGist(ctx context.Context, id, messageID int64) (string, error) {
+	pr, err := s.queries.GetPromptRequest(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	gc, err := s.generatedContent(ctx, id, messageID)
+	if err != nil {
+		return "", err
+	}
+	images, err := s.publishAttachments(ctx, id)
+	if err != nil {
+		return "", fmt.Errorf("uploading attachments: %w", err)
+	}
+	body, err := s.composeIssueBody(ctx, pr, gc, images)
+	if err != nil {
+		return "", err
+	}
+	title := s.issueTitle(pr, publishTitle(pr, gc))
+
+	gistURL, err := github.CreateGist(ctx, fmt.Sprintf("prompt-request-%d.md", id), title, "# "+title+"\n\n"+body)
+	if err != nil {
+		return "", err
+	}
+	// The gist exists now, so record it even if ctx is done.
+	ctx = context.WithoutCancel(ctx)
+	if err := s.queries.SetPromptRequestGistURL(ctx, id, gistURL); err != nil {
+		log.Printf("recording gist of prompt request %d: %v", id, err)
+	}
+	s.audit(id, "gist-published", gistURL, 0)
+	return gistURL, nil
+}
+
+func (s *Server) handlePublishGist(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	if _, err := s.queries.GetPromptRequest(r.Context(), id); err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	messageID, _ := strconv.ParseInt(r.FormValue("message_id"), 10, 64)
+	if !s.promptChecked(r.Context(), id, messageID) {
+		http.Error(w, "Run the quality check before publishing.", http.StatusBadRequest)
+		return
+	}
+	if _, err := s.publishGist(r.Context(), id, messageID); err != nil {
+		log.Printf("publishing prompt request %d as a gist: %v", id, err)
+		if errors.Is(err, errNoGeneratedPrompt) {
+			http.Error(w, "No generated prompt found. Continue the conversation until the AI generates a prompt.", http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, errUnknownGeneratedPrompt) {
+			http.Error(w, "The selected prompt version no longer exists. Pick another one.", http.StatusBadRequest)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to create the gist: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	redirectURL := fmt.Sprintf("/github.com/%s/%s/prompt-requests/%d", r.PathValue("org"), r.PathValue("repo"), id)
+	if r.Header.Get("HX-Request") == "true" {
+		w.Header().Set("HX-Redirect", redirectURL)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+}
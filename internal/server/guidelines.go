@@ -0,0 +1,65 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/esnunes/prompter/internal/models"
+	"github.com/esnunes/prompter/internal/repo"
+)
+
+// guidelinesPrompt hands Claude the project's own description of its scope
+// and contribution rules at the start of a session, so its questions and the
+// generated prompt stay within them.
+func guidelinesPrompt(docs []repo.Doc) string {
+	if len(docs) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("The repository states its scope and contribution rules in these files. Follow them: ")
+	b.WriteString("if the request seems out of scope or conflicts with them, raise it with the user, ")
+	b.WriteString("and make the generated prompt respect them.\n\n")
+	for _, d := range docs {
+		fmt.Fprintf(&b, "<document path=%q>\n%s\n", d.Path, d.Content)
+		if d.Truncated {
+			b.WriteString("[truncated; read the file for the rest]\n")
+		}
+		b.WriteString("</document>\n\n")
+	}
+	return b.String()
+}
+
+// systemPromptExtras is appended to the system prompt of every Claude turn.
+// The repository's agent instructions are reread each time, as the system
+// prompt isn't part of the resumed session.
+func systemPromptExtras(pr *models.PromptRequest) string {
+	var instructions string
+	if docs, err := repo.AgentInstructions(pr.RepoLocalPath); err != nil {
+		log.Printf("reading agent instructions of %s: %v", pr.RepoURL, err)
+	} else {
+		instructions = agentInstructionsPrompt(docs)
+	}
+	return instructions + languagePrompt(pr) + questionModePrompt(pr) + outputFormatPrompt(pr) + testPlanPrompt(pr)
+}
+
+// agentInstructionsPrompt extends the system prompt with the instructions the
+// maintainers wrote for their own AI agents, so the questions and the prompt
+// use the project's terminology and conventions.
+func agentInstructionsPrompt(docs []repo.Doc) string {
+	if len(docs) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("The maintainers of this repository wrote the following instructions for AI agents working on it. ")
+	b.WriteString("Use their terminology and conventions in your questions and in the generated prompt. ")
+	b.WriteString("Your role stays the same: you help shape a request and never change the code.\n\n")
+	for _, d := range docs {
+		fmt.Fprintf(&b, "<document path=%q>\n%s\n", d.Path, d.Content)
+		if d.Truncated {
+			b.WriteString("[truncated; read the file for the rest]\n")
+		}
+		b.WriteString("</document>\n\n")
+	}
+	return b.String()
+}
@@ -1,18 +1,25 @@
 package server
 
 import (
+	"bytes"
+	"cmp"
 	"context"
-	"encoding/json"
+	"database/sql"
+	"errors"
 	"fmt"
 	"html/template"
+	"io"
 	"log"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/esnunes/prompter/gotk"
 	"github.com/esnunes/prompter/internal/claude"
+	"github.com/esnunes/prompter/internal/db"
 	"github.com/esnunes/prompter/internal/github"
 	"github.com/esnunes/prompter/internal/models"
 	"github.com/esnunes/prompter/internal/repo"
@@ -26,8 +33,8 @@ type sidebarItem struct {
 	ID         int64
 	Title      string
 	Status     string // "draft", "published"
-	Processing bool   // true if repoStatus shows cloning/pulling/processing
-	Unread     bool   // true if new assistant response since last_viewed_at
+	Processing bool   // true if the job shows cloning/pulling/processing
+	Unread     bool   // true if new assistant response or issue activity since last_viewed_at
 	RepoURL    string // shown only on dashboard
 	UpdatedAt  time.Time
 	Org        string // for URL construction
@@ -39,8 +46,12 @@ type sidebarData struct {
 	Scope     string // "all" (dashboard) or "repo"
 	CurrentID int64  // highlighted item (0 if not on conversation page)
 	PollURL   string // URL for HTMX polling
+	MoreURL   string // URL that loads the next page ("" when everything is shown)
 }
 
+// sidebarPageSize is how many prompt requests the "all" sidebar shows per page.
+const sidebarPageSize = 50
+
 // Base page data embedded in all page data structs
 type basePageData struct {
 	Sidebar sidebarData
@@ -48,24 +59,148 @@ type basePageData struct {
 
 type dashboardData struct {
 	basePageData
-	Repositories []models.RepositorySummary
+	Repositories  []models.RepositorySummary
+	Query         string
+	SearchResults []models.SearchResult
+	View          string // "repos" (repository cards) or "grouped" (prompt requests nested per repository)
+	Groups        []repoGroup
+	Pinned        []models.PromptRequest
+	AutoArchived  []models.PromptRequest // stale drafts archived by the retention policy, pending acknowledgement
+	RetentionDays int
+	Tags          []models.TagCount
+	ActiveTag     string
+	Tagged        []models.PromptRequest // prompt requests carrying ActiveTag
+	// UnreadNotifications counts issue notifications not yet seen.
+	UnreadNotifications int
+}
+
+// repoGroup is a repository section in the grouped dashboard view.
+type repoGroup struct {
+	models.RepositorySummary
+	Org            string
+	Repo           string
+	PromptRequests []models.PromptRequest
+	Drafts         int
+	Published      int
 }
 
+// searchResultLimit caps how many conversations a dashboard search returns.
+const searchResultLimit = 50
+
 func (s *Server) handleDashboard(w http.ResponseWriter, r *http.Request) {
-	repos, err := s.queries.ListRepositorySummaries()
+	repos, err := s.queries.ListRepositorySummaries(r.Context())
 	if err != nil {
 		log.Printf("listing repository summaries: %v", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
-	sidebarPRs, _ := s.queries.ListPromptRequests(false)
-	sidebar := s.buildSidebar(sidebarPRs, "all", 0)
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	var results []models.SearchResult
+	if query != "" {
+		results, err = s.queries.SearchPromptRequests(r.Context(), query, searchResultLimit)
+		if err != nil {
+			log.Printf("searching prompt requests: %v", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+	}
+	view := "repos"
+	var groups []repoGroup
+	if r.URL.Query().Get("view") == "grouped" {
+		view = "grouped"
+		groups, err = s.groupPromptRequestsByRepo(r.Context(), repos)
+		if err != nil {
+			log.Printf("grouping prompt requests: %v", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+	}
+	pinned, err := s.queries.ListPinnedPromptRequests(r.Context())
+	if err != nil {
+		log.Printf("listing pinned prompt requests: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	autoArchived, err := s.queries.ListAutoArchivedPromptRequests(r.Context())
+	if err != nil {
+		log.Printf("listing auto-archived prompt requests: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	tags, err := s.queries.ListTagCounts(r.Context())
+	if err != nil {
+		log.Printf("listing tags: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	activeTag := normalizeTag(r.URL.Query().Get("tag"))
+	var tagged []models.PromptRequest
+	if activeTag != "" {
+		tagged, err = s.queries.ListPromptRequestsByTag(r.Context(), activeTag)
+		if err != nil {
+			log.Printf("listing prompt requests by tag: %v", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		s.attachTags(r.Context(), tagged)
+	}
+	unreadNotifications, err := s.queries.CountUnreadIssueNotifications(r.Context())
+	if err != nil {
+		log.Printf("counting issue notifications: %v", err)
+	}
+	sidebar := s.buildAllSidebar(r.Context(), sidebarPageSize)
 	s.renderPage(w, "dashboard.html", dashboardData{
-		basePageData: basePageData{Sidebar: sidebar},
-		Repositories: repos,
+		basePageData:  basePageData{Sidebar: sidebar},
+		Repositories:  repos,
+		Query:         query,
+		SearchResults: results,
+		View:          view,
+		Groups:        groups,
+		Pinned:        pinned,
+		AutoArchived:  autoArchived,
+		RetentionDays: int(s.cfg.DraftRetention / (24 * time.Hour)),
+		Tags:          tags,
+		ActiveTag:     activeTag,
+		Tagged:        tagged,
+
+		UnreadNotifications: unreadNotifications,
 	})
 }
 
+// groupPromptRequestsByRepo nests active prompt requests under their
+// repository, keeping repos in most-recent-activity order.
+func (s *Server) groupPromptRequestsByRepo(ctx context.Context, repos []models.RepositorySummary) ([]repoGroup, error) {
+	prs, _, err := s.queries.ListPromptRequests(ctx, false, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	s.attachTags(ctx, prs)
+	byRepo := map[string][]models.PromptRequest{}
+	for _, pr := range prs {
+		byRepo[pr.RepoURL] = append(byRepo[pr.RepoURL], pr)
+	}
+
+	var groups []repoGroup
+	for _, rs := range repos {
+		g := repoGroup{RepositorySummary: rs, PromptRequests: byRepo[rs.URL]}
+		if len(g.PromptRequests) == 0 {
+			continue
+		}
+		if parts := strings.SplitN(rs.URL, "/", 3); len(parts) == 3 {
+			g.Org, g.Repo = parts[1], parts[2]
+		}
+		for _, pr := range g.PromptRequests {
+			if pr.Status == "published" {
+				g.Published++
+			} else {
+				g.Drafts++
+			}
+		}
+		groups = append(groups, g)
+	}
+	return groups, nil
+}
+
 type repoData struct {
 	basePageData
 	RepoURL        string
@@ -74,6 +209,8 @@ type repoData struct {
 	Error          string
 	PromptRequests []models.PromptRequest
 	ShowArchived   bool
+	IssueFormat    issueFormatData
+	Metadata       *models.RepoMetadata // description and statistics from GitHub
 }
 
 func (s *Server) handleRepoPage(w http.ResponseWriter, r *http.Request) {
@@ -92,8 +229,9 @@ func (s *Server) handleRepoPage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Verify repo exists on GitHub
-	if err := github.VerifyRepo(r.Context(), org, repoName); err != nil {
+	// Fetching the metadata also verifies the repo exists on GitHub.
+	gr, err := github.ViewRepo(r.Context(), repoURL)
+	if err != nil {
 		s.renderPage(w, "repo.html", repoData{
 			basePageData: basePageData{Sidebar: s.buildSidebar(nil, "repo", 0)},
 			RepoURL:      repoURL,
@@ -103,9 +241,11 @@ func (s *Server) handleRepoPage(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
+	metadata := repoMetadata(gr)
+	s.cacheRepoMetadata(repoURL, metadata)
 
 	showArchived := r.URL.Query().Get("archived") == "1"
-	prs, err := s.queries.ListPromptRequestsByRepoURL(repoURL, showArchived)
+	prs, err := s.queries.ListPromptRequestsByRepoURL(r.Context(), repoURL, showArchived)
 	if err != nil {
 		log.Printf("listing prompt requests for repo: %v", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
@@ -115,7 +255,7 @@ func (s *Server) handleRepoPage(w http.ResponseWriter, r *http.Request) {
 	// Sidebar always gets active prompts
 	sidebarPRs := prs
 	if showArchived {
-		sidebarPRs, _ = s.queries.ListPromptRequestsByRepoURL(repoURL, false)
+		sidebarPRs, _ = s.queries.ListPromptRequestsByRepoURL(r.Context(), repoURL, false)
 	}
 	sidebar := s.buildSidebar(sidebarPRs, "repo", 0)
 	s.renderPage(w, "repo.html", repoData{
@@ -125,6 +265,8 @@ func (s *Server) handleRepoPage(w http.ResponseWriter, r *http.Request) {
 		Repo:           repoName,
 		PromptRequests: prs,
 		ShowArchived:   showArchived,
+		IssueFormat:    s.newIssueFormatData(r.Context(), repoURL),
+		Metadata:       metadata,
 	})
 }
 
@@ -141,46 +283,485 @@ func (s *Server) handleCreate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	repoRecord, err := s.queries.UpsertRepository(repoURL, localPath)
+	repoRecord, err := s.queries.UpsertRepository(r.Context(), repoURL, localPath)
 	if err != nil {
 		log.Printf("upserting repository: %v", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 
+	// An uploaded notes file becomes the first message.
+	seed, invalid := readSeedFile(r)
+	if invalid != "" {
+		http.Error(w, invalid, http.StatusBadRequest)
+		return
+	}
+	kind := r.FormValue("kind")
+	if _, ok := lookupPromptKind(kind); !ok {
+		http.Error(w, "Unknown kind of prompt request.", http.StatusBadRequest)
+		return
+	}
+
 	sessionID := uuid.New().String()
-	pr, err := s.queries.CreatePromptRequest(repoRecord.ID, sessionID)
+	pr, err := s.queries.CreatePromptRequest(r.Context(), repoRecord.ID, sessionID)
 	if err != nil {
 		log.Printf("creating prompt request: %v", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
-
-	// Determine initial status based on whether the repo is already cloned
-	cloned, _ := repo.IsCloned(repoURL)
-	if cloned {
-		s.setRepoStatus(pr.ID, "pulling", "")
+	if kind != "" {
+		if err := s.queries.SetPromptRequestKind(r.Context(), pr.ID, kind); err != nil {
+			log.Printf("setting prompt request kind: %v", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+	}
+	if seed != "" {
+		if _, err := s.queries.CreateMessage(r.Context(), pr.ID, "user", seed, nil); err != nil {
+			log.Printf("seeding prompt request from file: %v", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		s.auditPR(pr, "created", "from a notes file", 0)
 	} else {
-		s.setRepoStatus(pr.ID, "cloning", "")
+		s.auditPR(pr, "created", "", 0)
 	}
+	s.applyDefaultPersona(r.Context(), pr.ID)
 
-	// Launch async clone/pull
-	go s.asyncEnsureCloned(pr.ID, repoURL)
+	// Queue async clone/pull; a seed message is sent once the clone is ready.
+	s.queueEnsureCloned(pr.ID, repoURL)
 
 	http.Redirect(w, r, fmt.Sprintf("/github.com/%s/%s/prompt-requests/%d", org, repoName, pr.ID), http.StatusSeeOther)
 }
 
+// maxSeedFileSize bounds a notes file used to start a prompt request.
+const maxSeedFileSize = 100 << 10
+
+// readSeedFile returns the text of the optional "seed" file upload, or a
+// user-facing message if it isn't a usable text file.
+func readSeedFile(r *http.Request) (seed, invalid string) {
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		return "", ""
+	}
+	file, _, err := r.FormFile("seed")
+	if errors.Is(err, http.ErrMissingFile) {
+		return "", ""
+	}
+	if err != nil {
+		return "", "Couldn't read the uploaded file."
+	}
+	defer file.Close()
+
+	b, err := io.ReadAll(io.LimitReader(file, maxSeedFileSize+1))
+	if err != nil {
+		return "", "Couldn't read the uploaded file."
+	}
+	if len(b) > maxSeedFileSize {
+		return "", fmt.Sprintf("Notes files must be at most %d KB.", maxSeedFileSize>>10)
+	}
+	if !utf8.Valid(b) || bytes.IndexByte(b, 0) >= 0 {
+		return "", "Only Markdown or plain text files can be used."
+	}
+	return strings.TrimSpace(strings.ReplaceAll(string(b), "\r\n", "\n")), ""
+}
+
+// handleFork duplicates a prompt request into a new draft with a fresh Claude session.
+func (s *Server) handleFork(w http.ResponseWriter, r *http.Request) {
+	org := r.PathValue("org")
+	repoName := r.PathValue("repo")
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	src, err := s.queries.GetPromptRequest(r.Context(), id)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	title := "Copy of Untitled"
+	if src.Title != "" {
+		title = "Copy of " + src.Title
+	}
+	fork, err := s.queries.ForkPromptRequest(r.Context(), id, uuid.New().String(), title)
+	if err != nil {
+		log.Printf("forking prompt request %d: %v", id, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	s.auditPR(fork, "created", fmt.Sprintf("duplicated from #%d", id), 0)
+	s.queueEnsureCloned(fork.ID, fork.RepoURL)
+
+	http.Redirect(w, r, fmt.Sprintf("/github.com/%s/%s/prompt-requests/%d", org, repoName, fork.ID), http.StatusSeeOther)
+}
+
+// handleCopyToRepo starts a new prompt request in another repository, seeded
+// with this one's generated motivation and prompt, and asks Claude to
+// re-validate it against the target codebase.
+func (s *Server) handleCopyToRepo(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	src, err := s.queries.GetPromptRequest(r.Context(), id)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	gc, err := s.queries.GetLatestGeneratedContent(r.Context(), id)
+	if err != nil {
+		http.Error(w, "No generated prompt found. Continue the conversation until the AI generates a prompt.", http.StatusBadRequest)
+		return
+	}
+
+	targetURL := strings.TrimSpace(r.FormValue("repo_url"))
+	targetURL = strings.TrimPrefix(strings.TrimPrefix(targetURL, "https://"), "http://")
+	targetURL = strings.TrimSuffix(strings.TrimSuffix(targetURL, "/"), ".git")
+	if err := repo.ValidateURL(targetURL); err != nil {
+		http.Error(w, "Enter the target repository as github.com/owner/repo.", http.StatusBadRequest)
+		return
+	}
+	if targetURL == src.RepoURL {
+		http.Error(w, "Choose a different repository, or use Duplicate to copy within the same one.", http.StatusBadRequest)
+		return
+	}
+	parts := strings.SplitN(targetURL, "/", 3)
+	org, repoName := parts[1], parts[2]
+	if err := github.VerifyRepo(r.Context(), org, repoName); err != nil {
+		http.Error(w, "The target repository doesn't exist on GitHub or is not accessible.", http.StatusBadRequest)
+		return
+	}
+
+	localPath, err := repo.LocalPath(targetURL)
+	if err != nil {
+		log.Printf("computing local path: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	repoRecord, err := s.queries.UpsertRepository(r.Context(), targetURL, localPath)
+	if err != nil {
+		log.Printf("upserting repository: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	pr, err := s.queries.CreatePromptRequest(r.Context(), repoRecord.ID, uuid.New().String())
+	if err != nil {
+		log.Printf("creating prompt request: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if src.Title != "" {
+		s.queries.UpdatePromptRequestTitle(r.Context(), pr.ID, src.Title)
+	}
+	if err := s.queries.SetPromptRequestPersona(r.Context(), pr.ID, src.PersonaID); err != nil {
+		log.Printf("setting prompt request persona: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if err := s.queries.SetPromptRequestKind(r.Context(), pr.ID, src.Kind); err != nil {
+		log.Printf("setting prompt request kind: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if _, err := s.queries.CreateMessage(r.Context(), pr.ID, "user", copyToRepoMessage(src.RepoURL, gc), nil); err != nil {
+		log.Printf("seeding copied prompt request: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	s.audit(pr.ID, "created", fmt.Sprintf("copied from %s #%d", src.RepoURL, src.ID), 0)
+
+	// The seed message is sent automatically once the clone is ready.
+	s.queueEnsureCloned(pr.ID, targetURL)
+
+	redirectURL := fmt.Sprintf("/github.com/%s/%s/prompt-requests/%d", org, repoName, pr.ID)
+	if r.Header.Get("HX-Request") == "true" {
+		w.Header().Set("HX-Redirect", redirectURL)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+}
+
+// copyToRepoMessage is the first user message of a prompt request copied from another repository.
+func copyToRepoMessage(srcRepoURL string, gc *db.GeneratedContent) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "I wrote this feature request for %s and want to propose it for this repository instead.\n\n", srcRepoURL)
+	if gc.Motivation != "" {
+		b.WriteString("## Why\n\n" + gc.Motivation + "\n\n")
+	}
+	b.WriteString("## Prompt\n\n" + gc.Prompt + "\n\n")
+	if len(gc.Glossary) > 0 {
+		b.WriteString("## Definitions\n\n")
+		for _, t := range gc.Glossary {
+			b.WriteString("- **" + t.Term + "**: " + t.Definition + "\n")
+		}
+		b.WriteString("\n")
+	}
+	if len(gc.TestPlan) > 0 {
+		b.WriteString("## How to verify\n\n")
+		for _, step := range gc.TestPlan {
+			b.WriteString("- " + step + "\n")
+		}
+		b.WriteString("\n")
+	}
+	if len(gc.NonGoals) > 0 {
+		b.WriteString("## Out of scope\n\n")
+		for _, g := range gc.NonGoals {
+			b.WriteString("- " + g + "\n")
+		}
+		b.WriteString("\n")
+	}
+	if len(gc.Alternatives) > 0 {
+		b.WriteString("## Alternatives considered\n\n")
+		for _, a := range gc.Alternatives {
+			b.WriteString("- " + a + "\n")
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("Please re-validate it against this codebase: check which parts already exist, " +
+		"what works differently here, and ask me about anything that needs to change before we regenerate the prompt.")
+	return b.String()
+}
+
+// handleMerge merges another draft from the same repository into this one. The
+// target keeps its Claude session; the source is archived.
+func (s *Server) handleMerge(w http.ResponseWriter, r *http.Request) {
+	org := r.PathValue("org")
+	repoName := r.PathValue("repo")
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	sourceID, err := strconv.ParseInt(r.FormValue("source_id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Choose a draft to merge.", http.StatusBadRequest)
+		return
+	}
+
+	target, err := s.queries.GetPromptRequest(r.Context(), id)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	source, err := s.queries.GetPromptRequest(r.Context(), sourceID)
+	if err != nil || source.ID == target.ID || source.RepoURL != target.RepoURL {
+		http.Error(w, "Choose another draft from this repository.", http.StatusBadRequest)
+		return
+	}
+	if target.Status != "draft" || source.Status != "draft" {
+		http.Error(w, "Only drafts can be merged.", http.StatusBadRequest)
+		return
+	}
+	for _, prID := range []int64{target.ID, source.ID} {
+		switch s.getRepoStatus(r.Context(), prID).Status {
+		case stateProcessing:
+			http.Error(w, "Wait for the AI to finish responding before merging.", http.StatusConflict)
+			return
+		}
+	}
+
+	if err := s.queries.MergePromptRequests(r.Context(), target.ID, source.ID); err != nil {
+		log.Printf("merging prompt request %d into %d: %v", source.ID, target.ID, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	title := source.Title
+	if title == "" {
+		title = "Untitled"
+	}
+	msg := fmt.Sprintf("I merged the draft “%s” (#%d) into this conversation because it describes the same feature. "+
+		"Please combine both into a single feature request, ask me about any conflicts between them, "+
+		"and regenerate the prompt with the full combined context.", title, source.ID)
+	if _, err := s.queries.CreateMessage(r.Context(), target.ID, "user", msg, nil); err != nil {
+		log.Printf("saving merge message: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	// As with a regular message: send now if the repo is ready, otherwise the
+	// status poll sends it once the clone/pull finishes.
+	if status := s.getRepoStatus(r.Context(), target.ID).Status; status == stateNone || status == stateReady {
+		s.queueSendMessage(target.ID)
+	}
+
+	redirectURL := fmt.Sprintf("/github.com/%s/%s/prompt-requests/%d", org, repoName, target.ID)
+	if r.Header.Get("HX-Request") == "true" {
+		w.Header().Set("HX-Redirect", redirectURL)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+}
+
+// handleEditMessage replaces an earlier user message and regenerates the
+// conversation from there. Later messages are kept but marked superseded, and
+// a fresh Claude session is replayed the history before the edited message.
+func (s *Server) handleEditMessage(w http.ResponseWriter, r *http.Request) {
+	org := r.PathValue("org")
+	repoName := r.PathValue("repo")
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	msgID, err := strconv.ParseInt(r.PathValue("msgID"), 10, 64)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	if _, err := s.queries.GetPromptRequest(r.Context(), id); err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	msg, err := s.queries.GetMessage(r.Context(), msgID)
+	if err != nil || msg.PromptRequestID != id {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	if msg.Role != "user" || msg.Superseded {
+		http.Error(w, "Only current user messages can be edited.", http.StatusBadRequest)
+		return
+	}
+
+	content := strings.TrimSpace(r.FormValue("message"))
+	if content == "" {
+		http.Error(w, "Message cannot be empty.", http.StatusBadRequest)
+		return
+	}
+
+	switch s.getRepoStatus(r.Context(), id).Status {
+	case stateProcessing:
+		http.Error(w, "Wait for the AI to finish responding before editing.", http.StatusConflict)
+		return
+	}
+
+	if _, err := s.queries.BranchFromMessage(r.Context(), id, msgID, uuid.New().String(), content); err != nil {
+		log.Printf("editing message %d of prompt request %d: %v", msgID, id, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	s.audit(id, "message", "edited an earlier message", 0)
+
+	// Send now if the repo is ready (a cancelled request left it ready too);
+	// otherwise the status poll sends it once the clone/pull finishes.
+	if status := s.getRepoStatus(r.Context(), id).Status; status == stateNone || status == stateReady || status == stateCancelled {
+		s.queueSendMessage(id)
+	}
+
+	redirectURL := fmt.Sprintf("/github.com/%s/%s/prompt-requests/%d", org, repoName, id)
+	if r.Header.Get("HX-Request") == "true" {
+		w.Header().Set("HX-Redirect", redirectURL)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+}
+
+// handleUndoExchange removes the latest user message and its replies so a
+// mis-sent message doesn't stay in the transcript the prompt is built from.
+func (s *Server) handleUndoExchange(w http.ResponseWriter, r *http.Request) {
+	org := r.PathValue("org")
+	repoName := r.PathValue("repo")
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	if _, err := s.queries.GetPromptRequest(r.Context(), id); err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	switch s.getRepoStatus(r.Context(), id).Status {
+	case stateProcessing:
+		http.Error(w, "Wait for the AI to finish responding before undoing.", http.StatusConflict)
+		return
+	}
+
+	err = s.queries.UndoLastExchange(r.Context(), id, uuid.New().String())
+	switch {
+	case errors.Is(err, db.ErrExchangePublished):
+		http.Error(w, "The last exchange has been published and can't be undone.", http.StatusConflict)
+		return
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "There is nothing to undo.", http.StatusBadRequest)
+		return
+	case err != nil:
+		log.Printf("undoing last exchange of prompt request %d: %v", id, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	redirectURL := fmt.Sprintf("/github.com/%s/%s/prompt-requests/%d", org, repoName, id)
+	if r.Header.Get("HX-Request") == "true" {
+		w.Header().Set("HX-Redirect", redirectURL)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+}
+
 type conversationData struct {
 	basePageData
 	PromptRequest  *models.PromptRequest
 	Org            string
 	Repo           string
 	RepoStatus     string // "cloning", "pulling", "ready", "processing", "cancelled", "error", or "" (no active operation)
-	RepoStartedAt int64  // Unix timestamp for processing timer
-	Timeline       []timelineItem
-	LastQuestions   []questionData
+	RepoStartedAt  int64  // Unix timestamp for processing timer
+	RepoPollDelay  string // interval of the status poll
+	Timeline       timelineData
+	LastQuestions  []questionData
 	PromptReady    bool
 	Revisions      []models.Revision
+	IssueImported  bool // the published issue is the imported one, so retracting only unlinks it
+	TitleEdit      titleFragmentData
+	Tags           tagsFragmentData
+	Notes          notesFragmentData
+	Attachments    attachmentsFragmentData
+	References     referencesFragmentData
+	CanCopy        bool                   // a generated prompt exists that can be copied to another repo
+	CanUndo        bool                   // the conversation has a user message whose exchange can be undone
+	Answers        []answeredQuestion     // questions answered so far, which can be changed
+	MergeSources   []models.PromptRequest // other drafts in the repo that can be merged into this one
+	IssueTemplates []repo.IssueTemplate   // the repository's issue templates and forms
+	RelatedIssues  []models.RelatedIssue  // open issues Claude found related to this one
+	Languages      []string               // suggestions for the language inputs
+	Personas       []models.Persona       // personas the conversation can follow
+	PersonaID      int64                  // the persona it follows; 0 for none
+	QuestionsID    int64                  // the assistant message asking LastQuestions
+	Draft          *models.Draft          // unsent input saved as it was typed
+	ShareLinks     *shareLinksData        // nil unless PublicURL is set
+	Usage          string                 // Claude's total time and cost so far
+}
+
+// conversationPageSize is how many messages the conversation page shows at
+// first and loads with each "Load earlier messages".
+const conversationPageSize = 50
+
+// timelineData is a page of the conversation timeline.
+type timelineData struct {
+	Org              string
+	Repo             string
+	PromptRequestID  int64
+	Items            []timelineItem
+	LatestRevisionID int64  // the revision currently on GitHub; older ones can be restored
+	MoreURL          string // URL that loads the earlier messages ("" when everything is shown)
+
+	MessageAttachments    map[int64][]models.Attachment    // images sent with each user message
+	MessageFileReferences map[int64][]models.FileReference // files pointed out with each user message
 }
 
 type timelineItem struct {
@@ -194,12 +775,25 @@ type questionData struct {
 	Text        string
 	MultiSelect bool
 	Options     []optionData
+	Fixed       []optionData // the fixedAnswers options, after Claude's
 	Index       int
+
+	// Number counts the question among all asked in the conversation, out
+	// of Budget; zero Budget means no limit.
+	Number int
+	Budget int
+
+	// Restored from a draft.
+	OtherChecked bool
+	Other        string
+	Note         string
 }
 
 type optionData struct {
+	Value       string // form value when it isn't the label
 	Label       string
 	Description string
+	Checked     bool // picked in a draft
 }
 
 func (s *Server) handleShow(w http.ResponseWriter, r *http.Request) {
@@ -213,23 +807,37 @@ func (s *Server) handleShow(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	pr, err := s.queries.GetPromptRequest(id)
+	pr, err := s.queries.GetPromptRequest(r.Context(), id)
 	if err != nil {
 		http.Error(w, "Not Found", http.StatusNotFound)
 		return
 	}
 
 	// Update last_viewed_at for unread tracking
-	s.queries.UpdateLastViewedAt(id)
+	s.queries.UpdateLastViewedAt(r.Context(), id)
 
-	messages, err := s.queries.ListMessages(id)
+	// The timeline shows the latest messages, superseded ones included, and
+	// loads earlier ones on demand.
+	history, more, err := s.queries.ListMessagePage(r.Context(), id, 0, conversationPageSize)
+	if err != nil {
+		log.Printf("listing messages: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	last, err := s.queries.GetLastMessage(r.Context(), id)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("getting last message: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	canUndo, err := s.queries.HasUserMessages(r.Context(), id)
 	if err != nil {
 		log.Printf("listing messages: %v", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 
-	revisions, err := s.queries.ListRevisions(id)
+	revisions, err := s.queries.ListRevisions(r.Context(), id)
 	if err != nil {
 		log.Printf("listing revisions: %v", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
@@ -237,22 +845,22 @@ func (s *Server) handleShow(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Check repo status for polling div
-	statusEntry := s.getRepoStatus(id)
+	statusEntry := s.getRepoStatus(r.Context(), id)
 	repoStatus := statusEntry.Status
-	if repoStatus == "" {
-		// Server restart recovery: check filesystem
+	if repoStatus == stateNone {
+		// No job recorded (prompt request predates job tracking): check filesystem
 		cloned, _ := repo.IsCloned(repoURL)
 		if cloned {
-			repoStatus = "ready"
+			repoStatus = stateReady
 		}
 	}
 	// When status is "responded", the assistant message is already in the DB
-	// and will be rendered by the template. Clear the map entry so that
-	// subsequent actions (e.g., sending a new message) see "ready" state
-	// and can trigger a new Claude call.
-	if repoStatus == "responded" {
-		s.repoStatus.Delete(id)
-		repoStatus = "ready"
+	// and will be rendered by the template. Move the job to "ready" so that
+	// subsequent actions (e.g., sending a new message) can trigger a new
+	// Claude call.
+	if repoStatus == stateResponded {
+		s.setRepoStatus(id, stateReady, "")
+		repoStatus = stateReady
 	}
 
 	var repoStartedAt int64
@@ -260,28 +868,115 @@ func (s *Server) handleShow(w http.ResponseWriter, r *http.Request) {
 		repoStartedAt = statusEntry.StartedAt.Unix()
 	}
 
+	tags, err := s.newTagsFragmentData(r.Context(), org, repoName, id)
+	if err != nil {
+		log.Printf("listing tags: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	attachments, err := s.newAttachmentsFragmentData(r.Context(), org, repoName, id)
+	if err != nil {
+		log.Printf("listing attachments: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	messageAttachments, err := s.messageAttachments(r.Context(), id)
+	if err != nil {
+		log.Printf("listing attachments: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	references, err := s.newReferencesFragmentData(r.Context(), org, repoName, id)
+	if err != nil {
+		log.Printf("listing file references: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	messageFileReferences, err := s.messageFileReferences(r.Context(), id)
+	if err != nil {
+		log.Printf("listing file references: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
 	// Build sidebar with repo-scoped active prompt requests (never archived)
-	sidebarPRs, _ := s.queries.ListPromptRequestsByRepoURL(repoURL, false)
+	sidebarPRs, _ := s.queries.ListPromptRequestsByRepoURL(r.Context(), repoURL, false)
 	sidebar := s.buildSidebar(sidebarPRs, "repo", id)
 
 	data := conversationData{
-		basePageData:   basePageData{Sidebar: sidebar},
-		PromptRequest:  pr,
-		Org:            org,
-		Repo:           repoName,
-		RepoStatus:     repoStatus,
+		basePageData:  basePageData{Sidebar: sidebar},
+		PromptRequest: pr,
+		Org:           org,
+		Repo:          repoName,
+		RepoStatus:    repoStatus,
 		RepoStartedAt: repoStartedAt,
-		Timeline:       buildTimeline(messages, revisions),
-		Revisions:      revisions,
+		RepoPollDelay: pollDelay(statusEntry.Since),
+		Timeline: timelineData{
+			Org:                   org,
+			Repo:                  repoName,
+			PromptRequestID:       id,
+			Items:                 buildTimeline(history, revisions),
+			MessageAttachments:    messageAttachments,
+			MessageFileReferences: messageFileReferences,
+		},
+		Revisions:   revisions,
+		TitleEdit:   newTitleFragmentData(org, repoName, pr),
+		Tags:        tags,
+		Notes:       newNotesFragmentData(org, repoName, pr),
+		Attachments: attachments,
+		References:  references,
+		CanCopy:     s.hasGeneratedPrompt(r.Context(), pr.ID),
+		CanUndo:     canUndo,
+		Answers:     s.answerHistory(r.Context(), id),
+		Languages:   commonLanguages,
+		Personas:    s.personas(r.Context()),
+		Usage:       s.conversationUsage(r.Context(), id),
+	}
+	if pr.PersonaID != nil {
+		data.PersonaID = *pr.PersonaID
+	}
+	if more {
+		data.Timeline.MoreURL = timelineMoreURL(org, repoName, id, history[0].ID)
+	}
+	if len(revisions) > 0 {
+		data.Timeline.LatestRevisionID = revisions[len(revisions)-1].ID
+	}
+	// An uncloned repo has no templates to offer yet.
+	if data.IssueTemplates, err = repo.IssueTemplates(pr.RepoLocalPath); err != nil {
+		log.Printf("listing issue templates of %s: %v", repoURL, err)
+	}
+	if data.RelatedIssues, err = s.relatedIssues(r.Context(), pr); err != nil {
+		log.Printf("listing related issues of prompt request %d: %v", id, err)
+	}
+	data.IssueImported = pr.IssueNumber != nil && pr.SourceIssueNumber != nil && *pr.IssueNumber == *pr.SourceIssueNumber
+	if pr.Status == "draft" {
+		for _, other := range sidebarPRs {
+			if other.ID != pr.ID && other.Status == "draft" {
+				data.MergeSources = append(data.MergeSources, other)
+			}
+		}
+	}
+
+	if s.cfg.PublicURL != "" {
+		links, err := s.newShareLinksData(r.Context(), org, repoName, id)
+		if err != nil {
+			log.Printf("listing share links of prompt request %d: %v", id, err)
+		}
+		data.ShareLinks = &links
+	}
+
+	if data.Draft, err = s.queries.GetDraft(r.Context(), id); err != nil {
+		log.Printf("reading draft of prompt request %d: %v", id, err)
+		data.Draft = &models.Draft{}
 	}
 
 	// Check the last assistant message for pending questions / prompt ready
-	if len(messages) > 0 {
-		last := messages[len(messages)-1]
-		if last.Role == "assistant" && last.RawResponse != nil {
-			questions, promptReady := extractQuestionsFromRaw(*last.RawResponse)
-			data.LastQuestions = questions
-			data.PromptReady = promptReady
+	if last != nil {
+		data.LastQuestions, data.PromptReady = s.pendingQuestions(r.Context(), last)
+		data.QuestionsID = last.ID
+		if data.Draft.AnswersMessageID == last.ID {
+			restoreDraftAnswers(data.LastQuestions, data.Draft.Answers)
 		}
 
 		// Suppress prompt_ready if the last message was already published
@@ -296,15 +991,129 @@ func (s *Server) handleShow(w http.ResponseWriter, r *http.Request) {
 	s.renderPage(w, "conversation.html", data)
 }
 
+// handleTimeline renders the page of the timeline preceding the message given
+// by ?before=, for the conversation's "Load earlier messages" button.
+func (s *Server) handleTimeline(w http.ResponseWriter, r *http.Request) {
+	org := r.PathValue("org")
+	repoName := r.PathValue("repo")
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	before, err := strconv.ParseInt(r.URL.Query().Get("before"), 10, 64)
+	if err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	if _, err := s.queries.GetPromptRequest(r.Context(), id); err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	history, more, err := s.queries.ListMessagePage(r.Context(), id, before, conversationPageSize)
+	if err != nil {
+		log.Printf("listing messages: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	revisions, err := s.queries.ListRevisions(r.Context(), id)
+	if err != nil {
+		log.Printf("listing revisions: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	messageAttachments, err := s.messageAttachments(r.Context(), id)
+	if err != nil {
+		log.Printf("listing attachments: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	messageFileReferences, err := s.messageFileReferences(r.Context(), id)
+	if err != nil {
+		log.Printf("listing file references: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	data := timelineData{
+		Org:                   org,
+		Repo:                  repoName,
+		PromptRequestID:       id,
+		MessageAttachments:    messageAttachments,
+		MessageFileReferences: messageFileReferences,
+	}
+	if len(revisions) > 0 {
+		data.LatestRevisionID = revisions[len(revisions)-1].ID
+	}
+	// Revisions without a message to follow are already shown at the end of
+	// the timeline.
+	anchored := revisions[:0]
+	for _, rev := range revisions {
+		if rev.AfterMessageID != nil {
+			anchored = append(anchored, rev)
+		}
+	}
+	data.Items = buildTimeline(history, anchored)
+	if more {
+		data.MoreURL = timelineMoreURL(org, repoName, id, history[0].ID)
+	}
+	s.renderFragment(w, "timeline_fragment.html", data)
+}
+
+// timelineMoreURL is the URL of the timeline page preceding message before.
+func timelineMoreURL(org, repo string, id, before int64) string {
+	return fmt.Sprintf("/github.com/%s/%s/prompt-requests/%d/timeline?before=%d", org, repo, id, before)
+}
+
 type messageFragmentData struct {
 	PromptRequestID int64
 	Org             string
 	Repo            string
 	Messages        []models.Message
+	Attachments     []models.Attachment    // sent with the user message
+	FileReferences  []models.FileReference // sent with the user message
 	Questions       []questionData
 	PromptReady     bool
 }
 
+// sentMessage is a user message as saved, with the uploads and file
+// references sent along with it.
+type sentMessage struct {
+	*models.Message
+	Attachments    []models.Attachment
+	FileReferences []models.FileReference
+}
+
+// saveUserMessage saves a message the user sent in one transaction: the
+// message, the answers it gives, its pending uploads and file references,
+// and clearing the draft it came from.
+func (s *Server) saveUserMessage(ctx context.Context, prID int64, content string, answers map[int]string) (*sentMessage, error) {
+	sent := &sentMessage{}
+	err := s.queries.InTx(ctx, func(tx *db.Queries) error {
+		msg, err := tx.CreateMessage(ctx, prID, "user", content, nil)
+		if err != nil {
+			return err
+		}
+		sent.Message = msg
+		if err := tx.SaveAnswers(ctx, prID, msg.ID, answers); err != nil {
+			return err
+		}
+		if sent.Attachments, err = sendPendingAttachments(ctx, tx, prID, msg.ID); err != nil {
+			return err
+		}
+		if sent.FileReferences, err = sendPendingFileReferences(ctx, tx, prID, msg.ID); err != nil {
+			return err
+		}
+		return clearDraft(ctx, tx, prID, answers != nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sent, nil
+}
+
 func (s *Server) handleSendMessage(w http.ResponseWriter, r *http.Request) {
 	org := r.PathValue("org")
 	repoName := r.PathValue("repo")
@@ -322,41 +1131,43 @@ func (s *Server) handleSendMessage(w http.ResponseWriter, r *http.Request) {
 
 	userMessage := strings.TrimSpace(r.FormValue("message"))
 	// If no direct message, try assembling from multi-question form fields
+	var answers map[int]string
 	if userMessage == "" {
-		userMessage = assembleQuestionAnswers(r)
+		userMessage, answers = assembleQuestionAnswers(r)
 	}
 	if userMessage == "" {
 		http.Error(w, "Message is required", http.StatusBadRequest)
 		return
 	}
 
-	// Save user message
-	userMsg, err := s.queries.CreateMessage(id, "user", userMessage, nil)
+	sent, err := s.saveUserMessage(r.Context(), id, userMessage, answers)
 	if err != nil {
 		log.Printf("saving user message: %v", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
+	userMsg, attached, referenced := sent.Message, sent.Attachments, sent.FileReferences
+	s.audit(id, "message", "", 0)
 
 	// If repo is not ready, just save and disable form — auto-send kicks in when ready
-	statusEntry := s.getRepoStatus(id)
-	if statusEntry.Status != "" && statusEntry.Status != "ready" {
+	statusEntry := s.getRepoStatus(r.Context(), id)
+	if statusEntry.Status != stateNone && statusEntry.Status != stateReady {
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
 		fragment := messageFragmentData{
 			PromptRequestID: id,
 			Org:             org,
 			Repo:            repoName,
 			Messages:        []models.Message{*userMsg},
+			Attachments:     attached,
+			FileReferences:  referenced,
 		}
-		s.pages["message_fragment.html"].ExecuteTemplate(w, "message_fragment.html", fragment)
+		s.renderFragment(w, "message_fragment.html", fragment)
 		fmt.Fprint(w, `<script>(function(){var f=document.getElementById('message-form');if(f){f.querySelector('textarea').disabled=true;f.querySelector('button').disabled=true;}})();</script>`)
 		return
 	}
 
-	// Repo is ready — launch async Claude call
-	ctx, cancel := context.WithCancel(context.Background())
-	s.setRepoStatusProcessing(id, cancel)
-	go s.backgroundSendMessage(ctx, id)
+	// Repo is ready — queue async Claude call
+	s.queueSendMessage(id)
 
 	// Return user message bubble + processing status div for polling
 	pollURL := fmt.Sprintf("/github.com/%s/%s/prompt-requests/%d/status", org, repoName, id)
@@ -368,16 +1179,18 @@ func (s *Server) handleSendMessage(w http.ResponseWriter, r *http.Request) {
 		Org:             org,
 		Repo:            repoName,
 		Messages:        []models.Message{*userMsg},
+		Attachments:     attached,
+		FileReferences:  referenced,
 	}
-	s.pages["message_fragment.html"].ExecuteTemplate(w, "message_fragment.html", fragment)
+	s.renderFragment(w, "message_fragment.html", fragment)
 
 	// Remove any stale #repo-status element (e.g. leftover "Repository ready!" div)
 	// before appending the new processing div to avoid duplicate IDs.
 	fmt.Fprint(w, `<script>(function(){var old=document.getElementById('repo-status');if(old)old.remove();})();</script>`)
 
 	// Append processing status div that starts polling
-	entry := s.getRepoStatus(id)
-	fmt.Fprintf(w, `<div id="repo-status" class="repo-status" hx-get="%s" hx-trigger="every 2s" hx-swap="morph:outerHTML" data-started-at="%d">`, pollURL, entry.StartedAt.Unix())
+	entry := s.getRepoStatus(r.Context(), id)
+	fmt.Fprintf(w, `<div id="repo-status" class="repo-status" hx-get="%s" hx-trigger="every %s" hx-swap="morph:outerHTML" data-started-at="%d">`, pollURL, pollDelay(time.Now()), entry.StartedAt.Unix())
 	fmt.Fprint(w, `<div class="processing-indicator"><div class="spinner"></div><span class="processing-text">Thinking...</span><span class="elapsed-timer"></span></div>`)
 	fmt.Fprintf(w, `<form hx-post="%s" hx-target="#repo-status" hx-swap="outerHTML" hx-disabled-elt="find button" style="display:inline;"><button type="submit" class="btn btn-sm btn-secondary">Cancel</button></form>`, cancelURL)
 	fmt.Fprint(w, `</div>`)
@@ -386,89 +1199,230 @@ func (s *Server) handleSendMessage(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprint(w, `<script>setTimeout(function(){var f=document.getElementById('message-form');if(f){f.querySelector('textarea').disabled=true;f.querySelector('button').disabled=true;}if(typeof updateElapsedTimers==='function')updateElapsedTimers();},0);</script>`)
 }
 
-func (s *Server) handlePublish(w http.ResponseWriter, r *http.Request) {
-	org := r.PathValue("org")
-	repoName := r.PathValue("repo")
+// errNoGeneratedPrompt is returned when publishing before the AI has produced a prompt.
+var errNoGeneratedPrompt = errors.New("no generated prompt found; continue the conversation until the AI generates a prompt")
 
-	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+// publishPromptRequest composes the issue body from the generated content of
+// assistant message messageID (0 for the latest), creates or updates the
+// GitHub issue, and records a revision.
+func (s *Server) publishPromptRequest(ctx context.Context, id, messageID int64, overwrite bool) (*models.Revision, error) {
+	pr, err := s.queries.GetPromptRequest(ctx, id)
 	if err != nil {
-		http.Error(w, "Not Found", http.StatusNotFound)
-		return
+		return nil, err
 	}
 
-	pr, err := s.queries.GetPromptRequest(id)
+	gc, err := s.generatedContent(ctx, id, messageID)
 	if err != nil {
-		http.Error(w, "Not Found", http.StatusNotFound)
-		return
+		return nil, err
 	}
 
-	// Get the generated content (motivation + prompt)
-	gc, err := s.queries.GetLatestGeneratedContent(id)
+	images, err := s.publishAttachments(ctx, id)
 	if err != nil {
-		log.Printf("getting generated content: %v", err)
-		http.Error(w, "No generated prompt found. Continue the conversation until the AI generates a prompt.", http.StatusBadRequest)
-		return
+		return nil, fmt.Errorf("uploading attachments: %w", err)
 	}
 
-	// Compose issue body: motivation, prompt, and copyable raw prompt
-	copyBlock := "\n\n<details>\n<summary>Copy prompt</summary>\n\n```\n" + gc.Prompt + "\n```\n\n</details>"
-	var body string
-	if gc.Motivation != "" {
-		body = "## Why\n\n" + gc.Motivation + "\n\n## Prompt\n\n" + gc.Prompt + copyBlock
-	} else {
-		body = gc.Prompt + copyBlock
-	}
-
-	title := pr.Title
-	if gc.Title != "" {
-		title = gc.Title
-		s.queries.UpdatePromptRequestTitle(id, title)
-	} else if title == "" {
-		title = "Prompt Request"
-	}
-
-	issueTitle := "Prompt Request: " + title
-
-	if pr.IssueNumber != nil {
-		// Update existing issue
-		if err := github.EditIssue(r.Context(), pr.RepoURL, *pr.IssueNumber, body); err != nil {
-			log.Printf("editing issue: %v", err)
-			http.Error(w, fmt.Sprintf("Failed to update GitHub issue: %v", err), http.StatusInternalServerError)
-			return
+	body, err := s.composeIssueBody(ctx, pr, gc, images)
+	if err != nil {
+		return nil, err
+	}
+	title := publishTitle(pr, gc)
+
+	var event, detail string
+	var issueNumber int // the issue to link the prompt request to, if any
+	var issueURL string
+	switch {
+	case pr.SourceIssueNumber != nil && pr.PublishTarget == "comment":
+		// Every publish adds a comment to the imported issue.
+		if err := github.CommentOnIssue(ctx, pr.RepoURL, *pr.SourceIssueNumber, body); err != nil {
+			return nil, fmt.Errorf("commenting on GitHub issue: %w", err)
 		}
-	} else {
-		// Ensure "prompter" label exists (best-effort, don't block publish)
+		event, detail = "published", fmt.Sprintf("commented on issue #%d", *pr.SourceIssueNumber)
+		if pr.IssueNumber == nil {
+			issueNumber, issueURL = *pr.SourceIssueNumber, sourceIssueURL(pr)
+		}
+	case pr.IssueNumber != nil:
+		// Update existing issue, unless someone edited it on GitHub since
+		// the last publish and the user hasn't chosen to overwrite that.
+		if !overwrite {
+			if err := s.checkUpstreamEdits(ctx, pr); err != nil {
+				return nil, err
+			}
+		}
+		if err := github.EditIssue(ctx, pr.RepoURL, *pr.IssueNumber, body); err != nil {
+			return nil, fmt.Errorf("updating GitHub issue: %w", err)
+		}
+		event, detail = "issue-edited", fmt.Sprintf("issue #%d", *pr.IssueNumber)
+		if overwrite {
+			detail += ", overwriting edits made on GitHub"
+		}
+	case pr.SourceIssueNumber != nil && pr.PublishTarget == "edit":
+		if err := github.EditIssue(ctx, pr.RepoURL, *pr.SourceIssueNumber, body); err != nil {
+			return nil, fmt.Errorf("updating GitHub issue: %w", err)
+		}
+		issueNumber, issueURL = *pr.SourceIssueNumber, sourceIssueURL(pr)
+		event, detail = "issue-edited", fmt.Sprintf("imported issue #%d", *pr.SourceIssueNumber)
+	default:
+		// Ensure the configured labels and the issue template's labels exist
+		// (best-effort, don't block publish)
 		var labels []string
-		if err := github.EnsureLabel(r.Context(), pr.RepoURL, github.LabelName); err != nil {
-			log.Printf("warning: ensuring label %q: %v", github.LabelName, err)
-		} else {
-			labels = []string{github.LabelName}
+		names := slices.Clone(s.issueLabels(pr))
+		if t := s.issueTemplate(pr); t != nil {
+			names = append(names, t.Labels...)
+		}
+		for _, name := range names {
+			if err := github.EnsureLabel(ctx, pr.RepoURL, name); err != nil {
+				log.Printf("warning: ensuring label %q: %v", name, err)
+			} else {
+				labels = append(labels, name)
+			}
+		}
+
+		// Suggested labels the user kept; they exist already.
+		for _, name := range appliedLabels(s.suggestedLabels(ctx, pr, gc)) {
+			if !slices.Contains(labels, name) {
+				labels = append(labels, name)
+			}
 		}
 
 		// Create new issue
-		issue, err := github.CreateIssue(r.Context(), pr.RepoURL, issueTitle, body, labels)
+		issue, err := github.CreateIssue(ctx, pr.RepoURL, s.issueTitle(pr, title), body, labels)
 		if err != nil {
-			log.Printf("creating issue: %v", err)
-			http.Error(w, fmt.Sprintf("Failed to create GitHub issue: %v", err), http.StatusInternalServerError)
-			return
+			return nil, fmt.Errorf("creating GitHub issue: %w", err)
 		}
-		if err := s.queries.UpdatePromptRequestIssue(id, issue.Number, issue.URL); err != nil {
-			log.Printf("updating issue info: %v", err)
+		issueNumber, issueURL = issue.Number, issue.URL
+		event, detail = "published", fmt.Sprintf("issue #%d", issue.Number)
+		s.addToProject(context.WithoutCancel(ctx), pr, issue.URL)
+	}
+	// GitHub has the new body now, so record it even if ctx is done.
+	ctx = context.WithoutCancel(ctx)
+
+	// Record the publish as a whole: title, linked issue, the new revision
+	// and the published status.
+	var rev *models.Revision
+	err = s.queries.InTx(ctx, func(tx *db.Queries) error {
+		if gc.Title != "" && !pr.TitleEdited {
+			if err := tx.UpdatePromptRequestTitle(ctx, id, title); err != nil {
+				return fmt.Errorf("updating title: %w", err)
+			}
+		}
+		if issueNumber != 0 {
+			if err := tx.UpdatePromptRequestIssue(ctx, id, issueNumber, issueURL); err != nil {
+				return fmt.Errorf("updating issue info: %w", err)
+			}
+		}
+
+		// Link the revision to the last message for inline marker placement
+		var afterMsgID *int64
+		if lastMsg, err := tx.GetLastMessage(ctx, id); err == nil {
+			afterMsgID = &lastMsg.ID
 		}
+		var err error
+		if rev, err = tx.CreateRevision(ctx, id, body, afterMsgID, &gc.MessageID); err != nil {
+			return fmt.Errorf("creating revision: %w", err)
+		}
+		if err := tx.UpdatePromptRequestStatus(ctx, id, "published"); err != nil {
+			return fmt.Errorf("updating status: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		// The issue is on GitHub already: failing would retry and publish it again.
+		log.Printf("recording publish of prompt request %d: %v", id, err)
+		rev = nil
+	}
+	s.audit(id, event, detail, 0)
+	return rev, nil
+}
+
+// publishRetryable reports whether a failed publish is retried in the
+// background: only when gh couldn't reach GitHub, so nothing was published
+// yet. Other failures may have created the issue already, or would fail the
+// same way again.
+func publishRetryable(err error) bool {
+	return errors.Is(err, github.ErrUnreachable)
+}
+
+// errUnknownGeneratedPrompt is returned when publishing a generated prompt
+// that isn't part of the active conversation.
+var errUnknownGeneratedPrompt = errors.New("the selected prompt version no longer exists; pick another one")
+
+// generatedContent returns the prompt generated by assistant message
+// messageID, or the latest one when messageID is 0.
+func (s *Server) generatedContent(ctx context.Context, prID, messageID int64) (*db.GeneratedContent, error) {
+	if messageID == 0 {
+		gc, err := s.queries.GetLatestGeneratedContent(ctx, prID)
+		if err != nil {
+			return nil, errNoGeneratedPrompt
+		}
+		return gc, nil
+	}
+	gc, err := s.queries.GetGeneratedContent(ctx, prID, messageID)
+	if err != nil {
+		return nil, errUnknownGeneratedPrompt
 	}
+	return gc, nil
+}
 
-	// Create revision, linking it to the last message for inline marker placement
-	var afterMsgID *int64
-	if lastMsg, err := s.queries.GetLastMessage(id); err == nil {
-		afterMsgID = &lastMsg.ID
+// publishTitle is the prompt request title used when publishing: the
+// generated one unless the user renamed it.
+func publishTitle(pr *models.PromptRequest, gc *db.GeneratedContent) string {
+	if gc.Title != "" && !pr.TitleEdited {
+		return gc.Title
 	}
-	if _, err := s.queries.CreateRevision(id, body, afterMsgID); err != nil {
-		log.Printf("creating revision: %v", err)
+	if pr.Title == "" {
+		return "Prompt Request"
 	}
+	return pr.Title
+}
+
+func (s *Server) handlePublish(w http.ResponseWriter, r *http.Request) {
+	org := r.PathValue("org")
+	repoName := r.PathValue("repo")
 
-	// Update status to published
-	if err := s.queries.UpdatePromptRequestStatus(id, "published"); err != nil {
-		log.Printf("updating status: %v", err)
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	if _, err := s.queries.GetPromptRequest(r.Context(), id); err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	messageID, _ := strconv.ParseInt(r.FormValue("message_id"), 10, 64)
+	if !s.promptChecked(r.Context(), id, messageID) {
+		http.Error(w, "Run the quality check before publishing.", http.StatusBadRequest)
+		return
+	}
+	overwrite := r.FormValue("overwrite") == "1"
+	if _, err := s.publishPromptRequest(r.Context(), id, messageID, overwrite); err != nil {
+		log.Printf("publishing prompt request %d: %v", id, err)
+		if errors.Is(err, errUpstreamEdited) {
+			s.redirectToConflict(w, r, id, messageID)
+			return
+		}
+		if errors.Is(err, errNoGeneratedPrompt) {
+			http.Error(w, "No generated prompt found. Continue the conversation until the AI generates a prompt.", http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, errUnknownGeneratedPrompt) {
+			http.Error(w, "The selected prompt version no longer exists. Pick another one.", http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, github.ErrIssuesUnavailable) {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.WriteHeader(http.StatusConflict)
+			fmt.Fprint(w, gistNotice(org, repoName, id, messageID))
+			return
+		}
+		if publishRetryable(err) {
+			s.enqueue(jobPublish, jobPayload{PromptRequestID: id, MessageID: messageID, Overwrite: overwrite})
+			http.Error(w, fmt.Sprintf("Failed to publish: %v. Prompter will keep retrying in the background.", err), http.StatusInternalServerError)
+			return
+		}
+		http.Error(w, fmt.Sprintf("Failed to publish: %v", err), http.StatusInternalServerError)
+		return
 	}
 
 	// Use HX-Redirect for HTMX requests to trigger a full page navigation
@@ -492,11 +1446,12 @@ func (s *Server) handleDelete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := s.queries.DeletePromptRequest(id); err != nil {
+	if err := s.queries.DeletePromptRequest(r.Context(), id); err != nil {
 		log.Printf("deleting prompt request: %v", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
+	s.audit(id, "deleted", "moved to the trash", 0)
 
 	http.Redirect(w, r, fmt.Sprintf("/github.com/%s/%s/prompt-requests", org, repoName), http.StatusSeeOther)
 }
@@ -517,7 +1472,7 @@ func (s *Server) handleArchive(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := s.queries.ArchivePromptRequest(id); err != nil {
+	if err := s.queries.ArchivePromptRequest(r.Context(), id); err != nil {
 		log.Printf("archiving prompt request: %v", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
@@ -525,7 +1480,7 @@ func (s *Server) handleArchive(w http.ResponseWriter, r *http.Request) {
 
 	// If HTMX request (from conversation page), return the archived banner fragment
 	if r.Header.Get("HX-Request") == "true" {
-		pr, _ := s.queries.GetPromptRequest(id)
+		pr, _ := s.queries.GetPromptRequest(r.Context(), id)
 		s.renderFragment(w, "archive_banner_fragment.html", archiveBannerData{
 			Org:           org,
 			Repo:          repoName,
@@ -542,6 +1497,38 @@ func (s *Server) handleArchive(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, referer, http.StatusSeeOther)
 }
 
+func (s *Server) handlePin(w http.ResponseWriter, r *http.Request) {
+	s.setPinned(w, r, true)
+}
+
+func (s *Server) handleUnpin(w http.ResponseWriter, r *http.Request) {
+	s.setPinned(w, r, false)
+}
+
+// setPinned updates the pinned flag and redirects back to the referring page.
+func (s *Server) setPinned(w http.ResponseWriter, r *http.Request, pinned bool) {
+	org := r.PathValue("org")
+	repoName := r.PathValue("repo")
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	if err := s.queries.SetPromptRequestPinned(r.Context(), id, pinned); err != nil {
+		log.Printf("pinning prompt request %d: %v", id, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	referer := r.Header.Get("Referer")
+	if referer == "" {
+		referer = fmt.Sprintf("/github.com/%s/%s/prompt-requests/%d", org, repoName, id)
+	}
+	http.Redirect(w, r, referer, http.StatusSeeOther)
+}
+
 func (s *Server) handleUnarchive(w http.ResponseWriter, r *http.Request) {
 	org := r.PathValue("org")
 	repoName := r.PathValue("repo")
@@ -552,7 +1539,7 @@ func (s *Server) handleUnarchive(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := s.queries.UnarchivePromptRequest(id); err != nil {
+	if err := s.queries.UnarchivePromptRequest(r.Context(), id); err != nil {
 		log.Printf("unarchiving prompt request: %v", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
@@ -573,19 +1560,144 @@ func (s *Server) handleUnarchive(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, referer, http.StatusSeeOther)
 }
 
-// asyncEnsureCloned runs clone/pull in the background, updating status in sync.Map.
-func (s *Server) asyncEnsureCloned(prID int64, repoURL string) {
-	// Serialize clone/pull operations per repo to prevent concurrent git corruption
-	mu := s.lockRepo(repoURL)
-	defer mu.Unlock()
+// maxTitleLength caps user-chosen titles; GitHub allows up to 256 characters
+// and the issue title adds a prefix.
+const maxTitleLength = 200
+
+type titleFragmentData struct {
+	Org       string
+	Repo      string
+	ID        int64
+	Title     string
+	Published bool // offer to sync the rename to the GitHub issue
+	Error     string
+}
+
+func newTitleFragmentData(org, repoName string, pr *models.PromptRequest) titleFragmentData {
+	return titleFragmentData{
+		Org:       org,
+		Repo:      repoName,
+		ID:        pr.ID,
+		Title:     pr.Title,
+		Published: pr.IssueNumber != nil,
+	}
+}
+
+// handleRename sets a user-chosen title and optionally updates the published issue's title.
+func (s *Server) handleRename(w http.ResponseWriter, r *http.Request) {
+	org := r.PathValue("org")
+	repoName := r.PathValue("repo")
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	pr, err := s.queries.GetPromptRequest(r.Context(), id)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	title := strings.TrimSpace(r.FormValue("title"))
+	if title == "" || len(title) > maxTitleLength {
+		http.Error(w, fmt.Sprintf("Title must be between 1 and %d characters.", maxTitleLength), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.queries.RenamePromptRequest(r.Context(), id, title); err != nil {
+		log.Printf("renaming prompt request %d: %v", id, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	pr.Title = title
+
+	data := newTitleFragmentData(org, repoName, pr)
+	if r.FormValue("sync_issue") == "1" && pr.IssueNumber != nil {
+		if err := github.EditIssueTitle(r.Context(), pr.RepoURL, *pr.IssueNumber, s.issueTitle(pr, title)); err != nil {
+			log.Printf("syncing title to issue #%d: %v", *pr.IssueNumber, err)
+			data.Error = "Title saved, but updating the GitHub issue failed."
+		} else {
+			s.auditPR(pr, "issue-edited", fmt.Sprintf("issue #%d retitled", *pr.IssueNumber), 0)
+		}
+	}
+
+	if r.Header.Get("HX-Request") == "true" {
+		s.renderFragment(w, "title_fragment.html", data)
+		return
+	}
+	if data.Error != "" {
+		http.Error(w, data.Error, http.StatusBadGateway)
+		return
+	}
+	referer := r.Header.Get("Referer")
+	if referer == "" {
+		referer = fmt.Sprintf("/github.com/%s/%s/prompt-requests/%d", org, repoName, id)
+	}
+	http.Redirect(w, r, referer, http.StatusSeeOther)
+}
+
+// maxNotesLength bounds the private notes stored on a prompt request.
+const maxNotesLength = 20000
+
+type notesFragmentData struct {
+	Org       string
+	Repo      string
+	ID        int64
+	Notes     string
+	MaxLength int
+	Error     string
+}
+
+func newNotesFragmentData(org, repoName string, pr *models.PromptRequest) notesFragmentData {
+	return notesFragmentData{
+		Org:       org,
+		Repo:      repoName,
+		ID:        pr.ID,
+		Notes:     pr.Notes,
+		MaxLength: maxNotesLength,
+	}
+}
+
+// handleSaveNotes stores the private notes. They are never sent to Claude or
+// included in the published issue.
+func (s *Server) handleSaveNotes(w http.ResponseWriter, r *http.Request) {
+	org := r.PathValue("org")
+	repoName := r.PathValue("repo")
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
 
-	_, err := repo.EnsureCloned(context.Background(), repoURL)
+	pr, err := s.queries.GetPromptRequest(r.Context(), id)
 	if err != nil {
-		log.Printf("async clone/pull failed for %s: %v", repoURL, err)
-		s.setRepoStatus(prID, "error", err.Error())
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	notes := strings.TrimSpace(strings.ReplaceAll(r.FormValue("notes"), "\r\n", "\n"))
+	data := newNotesFragmentData(org, repoName, pr)
+	data.Notes = notes
+	if len(notes) > maxNotesLength {
+		data.Error = fmt.Sprintf("Notes must be at most %d characters.", maxNotesLength)
+	} else if err := s.queries.UpdatePromptRequestNotes(r.Context(), id, notes); err != nil {
+		log.Printf("saving notes for prompt request %d: %v", id, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	if r.Header.Get("HX-Request") == "true" {
+		s.renderFragment(w, "notes_fragment.html", data)
+		return
+	}
+	if data.Error != "" {
+		http.Error(w, data.Error, http.StatusBadRequest)
 		return
 	}
-	s.setRepoStatus(prID, "ready", "")
+	http.Redirect(w, r, fmt.Sprintf("/github.com/%s/%s/prompt-requests/%d", org, repoName, id), http.StatusSeeOther)
 }
 
 type statusFragmentData struct {
@@ -596,6 +1708,29 @@ type statusFragmentData struct {
 	CancelURL string
 	ResendURL string
 	StartedAt int64 // Unix timestamp, 0 if not processing
+
+	// Set while a clone/pull waits for a free clone worker, or a message
+	// for a free claude process.
+	Queued      bool
+	QueuedAhead int // clones and pulls that run first
+
+	PollDelay string // htmx interval until the next poll, e.g. "2s"
+}
+
+// pollDelay is how long the status poll waits before asking again: short
+// right after the status changed, when the next change tends to follow
+// quickly, and backing off the longer a clone or Claude turn runs.
+func pollDelay(since time.Time) string {
+	switch d := time.Since(since); {
+	case d < 10*time.Second:
+		return "1s"
+	case d < 30*time.Second:
+		return "2s"
+	case d < 2*time.Minute:
+		return "3s"
+	default:
+		return "5s"
+	}
 }
 
 func (s *Server) handleRepoStatus(w http.ResponseWriter, r *http.Request) {
@@ -612,43 +1747,37 @@ func (s *Server) handleRepoStatus(w http.ResponseWriter, r *http.Request) {
 	pollURL := fmt.Sprintf("/github.com/%s/%s/prompt-requests/%d/status", org, repoName, id)
 	retryURL := fmt.Sprintf("/github.com/%s/%s/prompt-requests/%d/retry", org, repoName, id)
 
-	entry := s.getRepoStatus(id)
+	entry := s.getRepoStatus(r.Context(), id)
 
-	// Server restart recovery: if no status tracked, check filesystem
-	if entry.Status == "" {
+	// No job recorded (prompt request predates job tracking): check filesystem
+	if entry.Status == stateNone {
 		cloned, _ := repo.IsCloned(repoURL)
 		if cloned {
-			s.setRepoStatus(id, "ready", "")
-			entry = repoStatusEntry{Status: "ready"}
+			s.setRepoStatus(id, stateReady, "")
+			entry = repoStatusEntry{Status: stateReady}
 		} else {
 			// Auto-start clone
-			s.setRepoStatus(id, "cloning", "")
-			go s.asyncEnsureCloned(id, repoURL)
-			entry = repoStatusEntry{Status: "cloning"}
+			s.queueEnsureCloned(id, repoURL)
+			entry = repoStatusEntry{Status: stateCloning}
 		}
 	}
 
 	// If ready, check for a pending user message to auto-send
-	if entry.Status == "ready" {
-		lastMsg, err := s.queries.GetLastMessage(id)
+	if entry.Status == stateReady {
+		lastMsg, err := s.queries.GetLastMessage(r.Context(), id)
 		if err == nil && lastMsg.Role == "user" {
-			// Atomically transition to "processing" to prevent duplicate Claude calls
-			old := repoStatusEntry{Status: "ready"}
-			if s.repoStatus.CompareAndSwap(id, old, repoStatusEntry{Status: "processing"}) {
-				ctx, cancel := context.WithCancel(context.Background())
-				s.setRepoStatusProcessing(id, cancel)
-				go s.backgroundSendMessage(ctx, id)
-			}
-			entry = s.getRepoStatus(id)
+			// The state machine lets only one poll start processing it.
+			s.queueSendMessage(id)
+			entry = s.getRepoStatus(r.Context(), id)
 		}
 	}
 
-	// If responded, deliver the assistant message and stop polling.
-	// We replace #repo-status with the response content plus a script that
-	// moves the messages into #conversation at the correct position.
-	if entry.Status == "responded" {
-		s.repoStatus.Delete(id)
-		lastMsg, err := s.queries.GetLastMessage(id)
+	// If responded, deliver the assistant message and stop polling: the
+	// reply is appended to #conversation out of band and #repo-status is
+	// swapped for nothing.
+	if entry.Status == stateResponded {
+		s.setRepoStatus(id, stateReady, "")
+		lastMsg, err := s.queries.GetLastMessage(r.Context(), id)
 		if err == nil && lastMsg.Role == "assistant" {
 			fragment := messageFragmentData{
 				PromptRequestID: id,
@@ -656,20 +1785,14 @@ func (s *Server) handleRepoStatus(w http.ResponseWriter, r *http.Request) {
 				Repo:            repoName,
 				Messages:        []models.Message{*lastMsg},
 			}
-			if lastMsg.RawResponse != nil {
-				questions, promptReady := extractQuestionsFromRaw(*lastMsg.RawResponse)
-				fragment.Questions = questions
-				fragment.PromptReady = promptReady
-			}
+			fragment.Questions, fragment.PromptReady = s.pendingQuestions(r.Context(), lastMsg)
 
-			// Render the message fragment into a wrapper div that replaces #repo-status
-			// and auto-relocates its children to the end of #conversation via inline script.
+			// Nothing replaces the status, so swap it plainly instead of morphing.
+			w.Header().Set("HX-Reswap", "outerHTML")
 			w.Header().Set("Content-Type", "text/html; charset=utf-8")
-			fmt.Fprint(w, `<div id="repo-status" style="display:none">`)
-			s.pages["message_fragment.html"].ExecuteTemplate(w, "message_fragment.html", fragment)
-			fmt.Fprint(w, `</div><script>`)
-			fmt.Fprint(w, `(function(){var s=document.getElementById('repo-status');var c=document.getElementById('conversation');while(s.firstChild){c.appendChild(s.firstChild);}s.remove();htmx.process(c);if(typeof renderMarkdown==='function')renderMarkdown();if(typeof scrollConversation==='function')scrollConversation();else{c.scrollTop=c.scrollHeight;}var f=document.getElementById('message-form');if(f){f.querySelector('textarea').disabled=false;f.querySelector('button').disabled=false;}})();`)
-			fmt.Fprint(w, `</script>`)
+			fmt.Fprint(w, `<div hx-swap-oob="beforeend:#conversation">`)
+			s.renderFragment(w, "message_fragment.html", fragment)
+			fmt.Fprint(w, `</div>`)
 			return
 		}
 	}
@@ -682,103 +1805,366 @@ func (s *Server) handleRepoStatus(w http.ResponseWriter, r *http.Request) {
 		startedAt = entry.StartedAt.Unix()
 	}
 
+	var queued bool
+	var ahead int
+	switch entry.Status {
+	case stateCloning, statePulling:
+		queued, ahead = s.cloneQueuePosition(r.Context(), id)
+	case stateProcessing:
+		queued = s.waitingForClaude(r.Context(), id)
+	}
+
 	s.renderFragment(w, "status_fragment.html", statusFragmentData{
-		Status:    entry.Status,
-		Error:     entry.Error,
-		PollURL:   pollURL,
-		RetryURL:  retryURL,
-		CancelURL: cancelURL,
-		ResendURL: resendURL,
-		StartedAt: startedAt,
+		Status:      entry.Status,
+		Error:       entry.Error,
+		PollURL:     pollURL,
+		RetryURL:    retryURL,
+		CancelURL:   cancelURL,
+		ResendURL:   resendURL,
+		StartedAt:   startedAt,
+		Queued:      queued,
+		QueuedAhead: ahead,
+		PollDelay:   pollDelay(entry.Since),
 	})
 }
 
-// backgroundSendMessage processes a pending user message with Claude in a background goroutine.
+// writeTranscript renders messages as a plain-text transcript for Claude.
+func writeTranscript(b *strings.Builder, msgs []models.Message) {
+	b.WriteString("<transcript>\n")
+	for _, m := range msgs {
+		role := "User"
+		if m.Role == "assistant" {
+			role = "Assistant"
+		}
+		fmt.Fprintf(b, "%s: %s\n\n", role, m.Content)
+	}
+	b.WriteString("</transcript>\n\n")
+}
+
+// forkTranscript replays the conversation up to the fork point so a fresh
+// Claude session starts with the same context. Messages covered by the
+// running summary are replaced by the summary.
+func forkTranscript(pr *models.PromptRequest, msgs []models.Message) string {
+	var summaryMessageID int64
+	if pr.Summary != "" && pr.SummaryMessageID != nil {
+		summaryMessageID = *pr.SummaryMessageID
+	}
+	var copied []models.Message
+	for _, m := range msgs {
+		if m.ID > summaryMessageID && m.ID <= *pr.ForkMessageID {
+			copied = append(copied, m)
+		}
+	}
+	var b strings.Builder
+	if summaryMessageID > 0 {
+		b.WriteString("This conversation continues an earlier one. Here is a summary of the earlier part:\n\n")
+		b.WriteString("<summary>\n" + pr.Summary + "\n</summary>\n\n")
+		b.WriteString("And the most recent messages:\n\n")
+	} else {
+		b.WriteString("This conversation continues an earlier one. Here is the transcript so far:\n\n")
+	}
+	writeTranscript(&b, copied)
+	b.WriteString("Continue from there. The user's next message follows.\n\n")
+	return b.String()
+}
+
+// summaryKeepRecent is how many of the latest messages are replayed verbatim,
+// rather than summarized, when a long session is replaced.
+const summaryKeepRecent = 6
+
+// summarizeSession replaces a Claude session whose transcript exceeds the
+// configured threshold: older messages are folded into the running summary
+// and the prompt request moves to a fresh session, which forkTranscript seeds
+// with the summary and the recent messages. It reports whether it did so.
+func (s *Server) summarizeSession(ctx context.Context, pr *models.PromptRequest, msgs []models.Message, pendingID int64) (bool, error) {
+	var summaryMessageID int64
+	if pr.Summary != "" && pr.SummaryMessageID != nil {
+		summaryMessageID = *pr.SummaryMessageID
+	}
+
+	// Messages already part of the conversation, ordered by ID so that the
+	// summarized part is exactly those at or below the new summary point.
+	var seen []models.Message
+	size := len(pr.Summary)
+	sinceFork := 0 // messages exchanged in the current session
+	for _, m := range msgs {
+		if m.ID == pendingID {
+			continue
+		}
+		seen = append(seen, m)
+		if m.ID > summaryMessageID {
+			size += len(m.Content)
+		}
+		if pr.ForkMessageID == nil || m.ID > *pr.ForkMessageID {
+			sinceFork++
+		}
+	}
+	// Requiring some new messages keeps a large summary from being
+	// regenerated on every turn, and there must be messages older than the
+	// recent ones to summarize.
+	if size <= s.cfg.SummaryThreshold || sinceFork < summaryKeepRecent || len(seen) <= summaryKeepRecent {
+		return false, nil
+	}
+	slices.SortFunc(seen, func(a, b models.Message) int { return cmp.Compare(a.ID, b.ID) })
+	older := seen[:len(seen)-summaryKeepRecent]
+	var newer []models.Message
+	for _, m := range older {
+		if m.ID > summaryMessageID {
+			newer = append(newer, m)
+		}
+	}
+	if len(newer) == 0 {
+		return false, nil
+	}
+
+	var b strings.Builder
+	if summaryMessageID > 0 {
+		b.WriteString("Summary of the conversation so far:\n\n" + pr.Summary + "\n\n")
+		b.WriteString("Update it with these newer messages:\n\n")
+	} else {
+		b.WriteString("Summarize this conversation:\n\n")
+	}
+	writeTranscript(&b, newer)
+
+	started := time.Now()
+	summary, err := claude.Summarize(ctx, pr.RepoLocalPath, b.String())
+	if err != nil {
+		s.auditPR(pr, "claude", fmt.Sprintf("summarizing failed: %v", err), time.Since(started))
+		return false, err
+	}
+	s.auditPR(pr, "claude", "summarized the conversation", time.Since(started))
+	err = s.queries.StartSummarizedSession(context.WithoutCancel(ctx), pr.ID, uuid.New().String(), summary,
+		older[len(older)-1].ID, seen[len(seen)-1].ID)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// mergeTranscript introduces messages merged in from other prompt requests
+// that the Claude session hasn't seen yet.
+func mergeTranscript(merged []models.Message) string {
+	var b strings.Builder
+	b.WriteString("Another draft about the same feature was merged into this conversation. Here is its transcript:\n\n")
+	writeTranscript(&b, merged)
+	b.WriteString("Treat both conversations as one feature request from now on. The user's next message follows.\n\n")
+	return b.String()
+}
+
+// backgroundSendMessage processes a pending user message with Claude. It runs as a claude-send job.
 // It saves the response to DB and updates the repo status to "responded" or "cancelled".
 func (s *Server) backgroundSendMessage(ctx context.Context, prID int64) {
 	defer s.clearCancelFunc(prID)
 
-	pr, err := s.queries.GetPromptRequest(prID)
+	// Cancelling stops Claude; what happened is still recorded.
+	dbCtx := context.WithoutCancel(ctx)
+
+	pr, err := s.queries.GetPromptRequest(dbCtx, prID)
 	if err != nil {
 		log.Printf("auto-send: getting prompt request: %v", err)
-		s.setRepoStatus(prID, "error", fmt.Sprintf("Failed to load prompt request: %v", err))
+		s.setRepoStatus(prID, stateError, fmt.Sprintf("Failed to load prompt request: %v", err))
 		return
 	}
 
-	lastMsg, err := s.queries.GetLastMessage(prID)
+	lastMsg, err := s.queries.GetLastMessage(dbCtx, prID)
 	if err != nil || lastMsg.Role != "user" {
 		log.Printf("auto-send: no pending user message for PR %d", prID)
-		s.setRepoStatus(prID, "ready", "")
+		s.setRepoStatus(prID, stateReady, "")
 		return
 	}
 
 	// Acquire session lock to prevent concurrent Claude calls
-	mu := s.lockSession(pr.SessionID)
-	defer mu.Unlock()
+	unlock := s.lockSession(pr.SessionID)
+	defer unlock()
 
 	// Re-check: ensure last message is still from user (not already processed)
-	lastMsg, err = s.queries.GetLastMessage(prID)
+	lastMsg, err = s.queries.GetLastMessage(dbCtx, prID)
 	if err != nil || lastMsg.Role != "user" {
-		s.setRepoStatus(prID, "ready", "")
+		s.setRepoStatus(prID, stateReady, "")
+		return
+	}
+	// A prewarm holding the lock may have moved it to a new session.
+	if pr, err = s.queries.GetPromptRequest(dbCtx, prID); err != nil {
+		log.Printf("auto-send: reloading prompt request: %v", err)
+		s.setRepoStatus(prID, stateError, fmt.Sprintf("Failed to load prompt request: %v", err))
+		return
+	}
+
+	// Wait for a free claude process; summarizing and replying share it.
+	release, err := s.acquireClaude(ctx, prID)
+	if err != nil {
+		if err == context.Canceled {
+			log.Printf("auto-send: cancelled for PR %d while queued", prID)
+			s.queries.CreateMessage(dbCtx, prID, "assistant", "Request cancelled by user.", nil)
+			s.setRepoStatus(prID, stateCancelled, "")
+			s.pushAll(s.buildResponsePush(prID, "Request cancelled by user.", nil))
+			return
+		}
+		s.setRepoStatus(prID, stateError, "Timed out waiting for a free Claude process")
 		return
 	}
+	defer release()
 
 	// Determine resume vs new
-	existingMsgs, err := s.queries.ListMessages(prID)
+	existingMsgs, err := s.queries.ListMessages(dbCtx, prID)
 	if err != nil {
 		log.Printf("auto-send: listing messages: %v", err)
-		s.setRepoStatus(prID, "error", fmt.Sprintf("Failed to list messages: %v", err))
+		s.setRepoStatus(prID, stateError, fmt.Sprintf("Failed to list messages: %v", err))
 		return
 	}
-	resume := false
+	// Messages copied into a fork or merged from another prompt request
+	// weren't produced by this session and don't count.
+	resume := pr.Prewarmed
+	var lastReplyID int64
 	for _, m := range existingMsgs {
-		if m.ID < lastMsg.ID && m.Role == "assistant" {
+		if m.ID < lastMsg.ID && m.Role == "assistant" && m.MergedFromID == nil &&
+			(pr.ForkMessageID == nil || m.ID > *pr.ForkMessageID) {
 			resume = true
-			break
+			lastReplyID = max(lastReplyID, m.ID)
+		}
+	}
+
+	// A very long session is replaced by a fresh one seeded with a summary.
+	if resume && s.cfg.SummaryThreshold > 0 {
+		if replaced, err := s.summarizeSession(ctx, pr, existingMsgs, lastMsg.ID); err != nil {
+			log.Printf("auto-send: summarizing PR %d, resuming the full session instead: %v", prID, err)
+		} else if replaced {
+			if pr, err = s.queries.GetPromptRequest(dbCtx, prID); err != nil {
+				log.Printf("auto-send: reloading prompt request: %v", err)
+				s.setRepoStatus(prID, stateError, fmt.Sprintf("Failed to load prompt request: %v", err))
+				return
+			}
+			resume = false
+		}
+	}
+
+	userMessage := lastMsg.Content
+	if atts, err := s.queries.ListMessageAttachments(dbCtx, prID); err != nil {
+		log.Printf("auto-send: listing attachments: %v", err)
+	} else {
+		var files []models.Attachment
+		for _, a := range atts {
+			if *a.MessageID == lastMsg.ID {
+				files = append(files, a)
+			}
+		}
+		if len(files) > 0 {
+			userMessage += "\n\nAttached images (use the Read tool to view them):\n" +
+				strings.Join(attachmentPaths(files), "\n")
+		}
+	}
+	if refs, err := s.queries.ListMessageFileReferences(dbCtx, prID); err != nil {
+		log.Printf("auto-send: listing file references: %v", err)
+	} else if refs = slices.DeleteFunc(refs, func(f models.FileReference) bool {
+		return *f.MessageID != lastMsg.ID
+	}); len(refs) > 0 {
+		userMessage += fileReferencesPrompt(refs)
+	}
+	// Merged messages are inserted after the replies the session has seen, so
+	// anything merged since the last reply is new to Claude.
+	var merged []models.Message
+	for _, m := range existingMsgs {
+		if m.MergedFromID != nil && m.ID > lastReplyID &&
+			(pr.ForkMessageID == nil || m.ID > *pr.ForkMessageID) {
+			merged = append(merged, m)
 		}
 	}
+	if len(merged) > 0 {
+		userMessage = mergeTranscript(merged) + userMessage
+	}
+	if !resume && pr.ForkMessageID != nil {
+		userMessage = forkTranscript(pr, existingMsgs) + userMessage
+	}
+	// Pass on a change of issue template; a fresh session hasn't seen any.
+	templateSent := pr.IssueTemplateSent
+	if !resume {
+		templateSent = ""
+	}
+	if pr.IssueTemplate != templateSent {
+		userMessage = issueTemplatePrompt(s.issueTemplate(pr)) + userMessage
+	}
+	if !resume {
+		userMessage = s.sessionContext(ctx, pr) + userMessage
+	}
 
-	resp, rawJSON, err := claude.SendMessage(ctx, pr.SessionID, pr.RepoLocalPath, lastMsg.Content, resume)
+	started := time.Now()
+	extras := systemPromptExtras(pr) + s.personaPrompt(dbCtx, pr) + s.questionBudgetPrompt(dbCtx, prID)
+	resp, rawJSON, err := claude.SendMessage(ctx, pr.SessionID, pr.RepoLocalPath, userMessage, extras, claude.Kind(pr.Kind), resume)
 	if err != nil {
 		if ctx.Err() == context.Canceled {
+			s.auditPR(pr, "claude", "cancelled", time.Since(started))
 			log.Printf("auto-send: cancelled for PR %d", prID)
-			s.queries.CreateMessage(prID, "assistant", "Request cancelled by user.", nil)
-			s.setRepoStatus(prID, "cancelled", "")
+			s.queries.CreateMessage(dbCtx, prID, "assistant", "Request cancelled by user.", nil)
+			s.setRepoStatus(prID, stateCancelled, "")
 			s.pushAll(s.buildResponsePush(prID, "Request cancelled by user.", nil))
 			return
 		}
+		s.auditPR(pr, "claude", fmt.Sprintf("failed: %v", err), time.Since(started))
 		log.Printf("auto-send: claude error: %v", err)
 		errMsg := fmt.Sprintf("Sorry, I encountered an error: %v", err)
-		s.queries.CreateMessage(prID, "assistant", errMsg, nil)
-		s.setRepoStatus(prID, "responded", "")
+		s.queries.CreateMessage(dbCtx, prID, "assistant", errMsg, nil)
+		s.setRepoStatus(prID, stateResponded, "")
 		s.pushAll(s.buildResponsePush(prID, errMsg, nil))
+		s.pushResponseNotification(pr, errMsg, true)
 		return
 	}
 
-	if _, err := s.queries.CreateMessage(prID, "assistant", resp.Message, &rawJSON); err != nil {
-		log.Printf("auto-send: saving assistant message: %v", err)
-		s.setRepoStatus(prID, "error", "Failed to save response")
-		s.pushAll(s.buildResponsePush(prID, "Failed to save response", nil))
-		return
-	}
+	detail := "replied"
+	if resp.PromptReady {
+		detail = "generated a prompt"
+	} else if len(resp.Questions) > 0 {
+		detail = fmt.Sprintf("asked %d questions", len(resp.Questions))
+	}
+	took := time.Since(started)
+	s.auditPR(pr, "claude", detail, took)
+	// The reply, its usage, the title it sets and the issue template it saw
+	// are saved together.
+	var saved *models.Message
+	err = s.queries.InTx(dbCtx, func(tx *db.Queries) error {
+		if pr.IssueTemplate != pr.IssueTemplateSent {
+			if err := tx.SetPromptRequestIssueTemplateSent(dbCtx, prID, pr.IssueTemplate); err != nil {
+				return fmt.Errorf("recording issue template: %w", err)
+			}
+		}
 
-	// Set title from response
-	if pr.Title == "" {
-		if resp.GeneratedTitle != "" {
-			s.queries.UpdatePromptRequestTitle(prID, resp.GeneratedTitle)
-		} else if resp.Message != "" {
-			title := resp.Message
+		var err error
+		if saved, err = tx.CreateMessage(dbCtx, prID, "assistant", resp.Message, &rawJSON); err != nil {
+			return fmt.Errorf("saving assistant message: %w", err)
+		}
+		saved.Duration, saved.CostUSD = took, claude.Cost(rawJSON)
+		if err := tx.SetMessageUsage(dbCtx, saved.ID, saved.Duration, saved.CostUSD); err != nil {
+			return err
+		}
+
+		// Set title from response
+		title := resp.GeneratedTitle
+		if title == "" && pr.Title == "" && resp.Message != "" {
+			title = resp.Message
 			if len(title) > 60 {
 				title = title[:60] + "..."
 			}
-			s.queries.UpdatePromptRequestTitle(prID, title)
 		}
-	} else if resp.GeneratedTitle != "" {
-		s.queries.UpdatePromptRequestTitle(prID, resp.GeneratedTitle)
+		if title != "" {
+			if err := tx.UpdatePromptRequestTitle(dbCtx, prID, title); err != nil {
+				return fmt.Errorf("setting title: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("auto-send: %v", err)
+		s.setRepoStatus(prID, stateError, "Failed to save response")
+		s.pushAll(s.buildResponsePush(prID, "Failed to save response", nil))
+		s.pushResponseNotification(pr, "Failed to save response", true)
+		return
 	}
 
-	s.setRepoStatus(prID, "responded", "")
-	s.pushAll(s.buildResponsePush(prID, resp.Message, &rawJSON))
+	s.setRepoStatus(prID, stateResponded, "")
+	s.pushAll(s.buildResponsePush(prID, resp.Message, saved))
+	if updated, err := s.queries.GetPromptRequest(dbCtx, prID); err == nil {
+		pr = updated // with the title the reply set
+	}
+	s.pushResponseNotification(pr, resp.Message, false)
 }
 
 func (s *Server) handleRetry(w http.ResponseWriter, r *http.Request) {
@@ -792,22 +2178,16 @@ func (s *Server) handleRetry(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	cloned, _ := repo.IsCloned(repoURL)
-	if cloned {
-		s.setRepoStatus(id, "pulling", "")
-	} else {
-		s.setRepoStatus(id, "cloning", "")
-	}
-
-	go s.asyncEnsureCloned(id, repoURL)
+	s.queueEnsureCloned(id, repoURL)
 
 	pollURL := fmt.Sprintf("/github.com/%s/%s/prompt-requests/%d/status", org, repoName, id)
 	retryURL := fmt.Sprintf("/github.com/%s/%s/prompt-requests/%d/retry", org, repoName, id)
 
 	s.renderFragment(w, "status_fragment.html", statusFragmentData{
-		Status:   s.getRepoStatus(id).Status,
-		PollURL:  pollURL,
-		RetryURL: retryURL,
+		Status:    s.getRepoStatus(r.Context(), id).Status,
+		PollURL:   pollURL,
+		RetryURL:  retryURL,
+		PollDelay: pollDelay(time.Now()),
 	})
 }
 
@@ -822,8 +2202,8 @@ func (s *Server) handleCancel(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Call the cancel function if processing
-	entry := s.getRepoStatus(id)
-	if entry.Status == "processing" {
+	entry := s.getRepoStatus(r.Context(), id)
+	if entry.Status == stateProcessing {
 		if v, ok := s.cancelFuncs.Load(id); ok {
 			if cancel, ok := v.(context.CancelFunc); ok {
 				cancel()
@@ -836,10 +2216,11 @@ func (s *Server) handleCancel(w http.ResponseWriter, r *http.Request) {
 	cancelURL := fmt.Sprintf("/github.com/%s/%s/prompt-requests/%d/cancel", org, repoName, id)
 
 	s.renderFragment(w, "status_fragment.html", statusFragmentData{
-		Status:    "processing",
+		Status:    stateProcessing,
 		PollURL:   pollURL,
 		CancelURL: cancelURL,
 		StartedAt: entry.StartedAt.Unix(),
+		PollDelay: pollDelay(time.Now()),
 	})
 }
 
@@ -854,26 +2235,25 @@ func (s *Server) handleResend(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Delete the synthetic cancelled assistant message
-	lastMsg, err := s.queries.GetLastMessage(id)
+	lastMsg, err := s.queries.GetLastMessage(r.Context(), id)
 	if err == nil && lastMsg.Role == "assistant" && lastMsg.Content == "Request cancelled by user." {
-		s.queries.DeleteMessage(lastMsg.ID)
+		s.queries.DeleteMessage(r.Context(), lastMsg.ID)
 	}
 
-	// Launch async Claude call
-	ctx, cancel := context.WithCancel(context.Background())
-	s.setRepoStatusProcessing(id, cancel)
-	go s.backgroundSendMessage(ctx, id)
+	// Queue async Claude call
+	s.queueSendMessage(id)
 
 	// Return processing status fragment
 	pollURL := fmt.Sprintf("/github.com/%s/%s/prompt-requests/%d/status", org, repoName, id)
 	cancelURL := fmt.Sprintf("/github.com/%s/%s/prompt-requests/%d/cancel", org, repoName, id)
-	entry := s.getRepoStatus(id)
+	entry := s.getRepoStatus(r.Context(), id)
 
 	s.renderFragment(w, "status_fragment.html", statusFragmentData{
-		Status:    "processing",
+		Status:    stateProcessing,
 		PollURL:   pollURL,
 		CancelURL: cancelURL,
 		StartedAt: entry.StartedAt.Unix(),
+		PollDelay: pollDelay(time.Now()),
 	})
 }
 
@@ -906,71 +2286,81 @@ func buildTimeline(messages []models.Message, revisions []models.Revision) []tim
 	return items
 }
 
-// extractQuestionsFromRaw parses the raw Claude response to find pending questions.
-// It supports both the new "questions" array and the old singular "question" field
-// for backward compatibility with existing sessions.
-func extractQuestionsFromRaw(rawJSON string) ([]questionData, bool) {
-	resp := parseRawResponse(rawJSON)
-	if resp == nil {
+// pendingQuestions returns the questions an assistant message asked and
+// whether it marked the prompt ready.
+func (s *Server) pendingQuestions(ctx context.Context, msg *models.Message) ([]questionData, bool) {
+	if msg.Role != "assistant" {
 		return nil, false
 	}
-
-	if len(resp.Questions) == 0 {
-		// Try the old singular "question" field for backward compat
-		questions := extractLegacyQuestion(rawJSON)
-		return questions, resp.PromptReady
+	questions, err := s.queries.ListQuestions(ctx, msg.ID)
+	if err != nil {
+		log.Printf("listing questions of message %d: %v", msg.ID, err)
 	}
-
-	var questions []questionData
-	for i, q := range resp.Questions {
-		qd := questionData{
-			Header:      q.Header,
-			Text:        q.Text,
-			MultiSelect: q.MultiSelect,
-			Index:       i,
+	var asked, budget int
+	if len(questions) > 0 {
+		if asked, err = s.queries.CountQuestions(ctx, msg.PromptRequestID, msg.ID); err != nil {
+			log.Printf("%v", err)
 		}
+		budget = s.questionBudget(ctx)
+	}
+	var result []questionData
+	for i, q := range questions {
+		qd := questionData{Header: q.Header, Text: q.Text, MultiSelect: q.MultiSelect, Index: q.Position,
+			Number: asked + i + 1, Budget: budget}
 		for _, opt := range q.Options {
 			qd.Options = append(qd.Options, optionData{Label: opt.Label, Description: opt.Description})
 		}
-		questions = append(questions, qd)
+		for _, f := range fixedAnswers {
+			qd.Fixed = append(qd.Fixed, optionData{Value: f.value, Label: f.label, Description: f.description})
+		}
+		result = append(result, qd)
 	}
-	return questions, resp.PromptReady
+	return result, msg.PromptReady
 }
 
-// extractLegacyQuestion handles old raw_response JSON that used the singular "question" field.
-func extractLegacyQuestion(rawJSON string) []questionData {
-	// Parse looking for the old schema shape: {"question": {"text": "...", "options": [...]}}
-	var legacy struct {
-		StructuredOutput *struct {
-			Question *struct {
-				Text    string `json:"text"`
-				Options []struct {
-					Label       string `json:"label"`
-					Description string `json:"description"`
-				} `json:"options"`
-			} `json:"question"`
-		} `json:"structured_output"`
-	}
-	if err := json.Unmarshal([]byte(rawJSON), &legacy); err != nil {
-		return nil
-	}
-	if legacy.StructuredOutput == nil || legacy.StructuredOutput.Question == nil {
-		return nil
+// fixedAnswers are offered with every question besides Claude's options, for
+// contributors who can't or don't want to answer. Picking one sends its
+// answer, which tells Claude how to go on, in place of any other choice.
+var fixedAnswers = []struct {
+	value, label, description, answer string
+}{
+	{"__unknown__", "I don't know", "The AI picks a sensible default and notes the assumption",
+		"I don't know — choose a sensible default and state it as an assumption"},
+	{"__skip__", "Skip", "Leave this topic out of the request",
+		"Skip — leave this topic out of the request and don't ask about it again"},
+}
+
+// withNote appends the optional elaboration typed under a question to the
+// options picked; the elaboration alone answers when nothing was picked.
+func withNote(parts []string, note string) string {
+	answer := strings.Join(parts, ", ")
+	switch {
+	case note == "":
+		return answer
+	case answer == "":
+		return note
+	default:
+		return answer + " (" + note + ")"
 	}
+}
 
-	q := legacy.StructuredOutput.Question
-	qd := questionData{Text: q.Text, Index: 0}
-	for _, opt := range q.Options {
-		qd.Options = append(qd.Options, optionData{Label: opt.Label, Description: opt.Description})
+// fixedAnswer returns the answer sent for a fixed option's form value.
+func fixedAnswer(value string) (string, bool) {
+	for _, f := range fixedAnswers {
+		if f.value == value {
+			return f.answer, true
+		}
 	}
-	return []questionData{qd}
+	return "", false
 }
 
-// assembleQuestionAnswers reads multi-question form fields (q_0, q_0_other, q_1, etc.)
-// and assembles them into a single answer string to send to Claude.
-func assembleQuestionAnswers(r *http.Request) string {
+// assembleQuestionAnswers reads multi-question form fields (q_0, q_0_other, q_0_note, q_1, etc.)
+// and assembles them into a single answer string to send to Claude, along with
+// each question's answer keyed by its position.
+func assembleQuestionAnswers(r *http.Request) (string, map[int]string) {
 	var answers []string
 	var headers []string
+	chosen := make(map[int]string)
 
 	for i := 0; ; i++ {
 		key := fmt.Sprintf("q_%d", i)
@@ -983,10 +2373,15 @@ func assembleQuestionAnswers(r *http.Request) string {
 		}
 
 		otherText := strings.TrimSpace(r.FormValue(fmt.Sprintf("q_%d_other", i)))
+		note := strings.TrimSpace(r.FormValue(fmt.Sprintf("q_%d_note", i)))
 
 		// Build the answer for this question
 		var parts []string
 		for _, v := range values {
+			if answer, ok := fixedAnswer(v); ok {
+				parts = []string{answer}
+				break
+			}
 			if v == "__other__" {
 				if otherText != "" {
 					parts = append(parts, "Other: "+otherText)
@@ -996,19 +2391,20 @@ func assembleQuestionAnswers(r *http.Request) string {
 			}
 		}
 
-		if len(parts) > 0 {
-			answers = append(answers, strings.Join(parts, ", "))
+		if answer := withNote(parts, note); answer != "" {
+			answers = append(answers, answer)
 			headers = append(headers, header)
+			chosen[i] = answer
 		}
 	}
 
 	if len(answers) == 0 {
-		return ""
+		return "", nil
 	}
 
 	// Single question: just the answer, no prefix
 	if len(answers) == 1 {
-		return answers[0]
+		return answers[0], chosen
 	}
 
 	// Multiple questions: prefix each with header or question index
@@ -1020,15 +2416,16 @@ func assembleQuestionAnswers(r *http.Request) string {
 			lines = append(lines, fmt.Sprintf("Q%d: %s", i+1, answer))
 		}
 	}
-	return strings.Join(lines, "\n")
+	return strings.Join(lines, "\n"), chosen
 }
 
 // assembleQuestionAnswersFromPayload assembles question answers from a gotk payload.
 // Mirrors assembleQuestionAnswers but works with gotk.Payload instead of *http.Request.
-func assembleQuestionAnswersFromPayload(p gotk.Payload) string {
+func assembleQuestionAnswersFromPayload(p gotk.Payload) (string, map[int]string) {
 	data := p.Map()
 	var answers []string
 	var headers []string
+	chosen := make(map[int]string)
 
 	for i := 0; ; i++ {
 		key := fmt.Sprintf("q_%d", i)
@@ -1040,6 +2437,7 @@ func assembleQuestionAnswersFromPayload(p gotk.Payload) string {
 		}
 
 		otherText := strings.TrimSpace(p.String(fmt.Sprintf("q_%d_other", i)))
+		note := strings.TrimSpace(p.String(fmt.Sprintf("q_%d_note", i)))
 
 		// Collect values: may be a string (radio) or []any (checkboxes)
 		var values []string
@@ -1058,6 +2456,10 @@ func assembleQuestionAnswersFromPayload(p gotk.Payload) string {
 
 		var parts []string
 		for _, v := range values {
+			if answer, ok := fixedAnswer(v); ok {
+				parts = []string{answer}
+				break
+			}
 			if v == "__other__" {
 				if otherText != "" {
 					parts = append(parts, "Other: "+otherText)
@@ -1067,18 +2469,19 @@ func assembleQuestionAnswersFromPayload(p gotk.Payload) string {
 			}
 		}
 
-		if len(parts) > 0 {
-			answers = append(answers, strings.Join(parts, ", "))
+		if answer := withNote(parts, note); answer != "" {
+			answers = append(answers, answer)
 			headers = append(headers, header)
+			chosen[i] = answer
 		}
 	}
 
 	if len(answers) == 0 {
-		return ""
+		return "", nil
 	}
 
 	if len(answers) == 1 {
-		return answers[0]
+		return answers[0], chosen
 	}
 
 	var lines []string
@@ -1089,11 +2492,22 @@ func assembleQuestionAnswersFromPayload(p gotk.Payload) string {
 			lines = append(lines, fmt.Sprintf("Q%d: %s", i+1, answer))
 		}
 	}
-	return strings.Join(lines, "\n")
+	return strings.Join(lines, "\n"), chosen
+}
+
+// unread reports whether pr has an assistant response or activity on its
+// published issue newer than the last time it was viewed.
+func unread(pr models.PromptRequest) bool {
+	for _, at := range []*time.Time{pr.LatestAssistantAt, pr.IssueActivityAt} {
+		if at != nil && (pr.LastViewedAt == nil || at.After(*pr.LastViewedAt)) {
+			return true
+		}
+	}
+	return false
 }
 
 // buildSidebar creates sidebar data from a list of prompt requests, merging in
-// processing state from the in-memory repoStatus map and computing unread flags.
+// processing state from the jobs table and computing unread flags.
 func (s *Server) buildSidebar(prs []models.PromptRequest, scope string, currentID int64) sidebarData {
 	var items []sidebarItem
 	for _, pr := range prs {
@@ -1105,29 +2519,19 @@ func (s *Server) buildSidebar(prs []models.PromptRequest, scope string, currentI
 			repoName = parts[2]
 		}
 
-		// Check processing state from in-memory status
+		// Check processing state from the job status
 		processing := false
-		entry := s.getRepoStatus(pr.ID)
-		if entry.Status == "cloning" || entry.Status == "pulling" || entry.Status == "processing" {
+		entry := s.getRepoStatus(context.Background(), pr.ID)
+		if entry.Status == stateCloning || entry.Status == statePulling || entry.Status == stateProcessing {
 			processing = true
 		}
 
-		// Compute unread: has assistant response newer than last_viewed_at
-		unread := false
-		if pr.LatestAssistantAt != nil && pr.ID != currentID {
-			if pr.LastViewedAt == nil {
-				unread = true
-			} else if pr.LatestAssistantAt.After(*pr.LastViewedAt) {
-				unread = true
-			}
-		}
-
 		items = append(items, sidebarItem{
 			ID:         pr.ID,
 			Title:      pr.Title,
 			Status:     pr.Status,
 			Processing: processing,
-			Unread:     unread,
+			Unread:     pr.ID != currentID && unread(pr),
 			RepoURL:    pr.RepoURL,
 			UpdatedAt:  pr.UpdatedAt,
 			Org:        org,
@@ -1193,13 +2597,16 @@ func (s *Server) handleSidebarFragment(w http.ResponseWriter, r *http.Request) {
 	repoURL := r.URL.Query().Get("repo_url")
 	currentID, _ := strconv.ParseInt(r.URL.Query().Get("current_id"), 10, 64)
 
-	var prs []models.PromptRequest
-	var err error
-	if scope == "repo" && repoURL != "" {
-		prs, err = s.queries.ListPromptRequestsByRepoURL(repoURL, false)
-	} else {
-		prs, err = s.queries.ListPromptRequests(false)
+	if scope != "repo" || repoURL == "" {
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		if limit <= 0 {
+			limit = sidebarPageSize
+		}
+		s.renderFragment(w, "sidebar.html", s.buildAllSidebar(r.Context(), limit))
+		return
 	}
+
+	prs, err := s.queries.ListPromptRequestsByRepoURL(r.Context(), repoURL, false)
 	if err != nil {
 		log.Printf("sidebar query error: %v", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
@@ -1210,36 +2617,27 @@ func (s *Server) handleSidebarFragment(w http.ResponseWriter, r *http.Request) {
 	s.renderFragment(w, "sidebar.html", sidebar)
 }
 
-// parseRawResponse extracts a claude.Response from the raw JSON stored in the DB.
-func parseRawResponse(rawJSON string) *claude.Response {
-	// The raw JSON is the full claude CLI output: {"type":"result","structured_output":{...},...}
-	var wrapper struct {
-		StructuredOutput *claude.Response `json:"structured_output"`
-		Result           string           `json:"result"`
+// buildAllSidebar builds the cross-repository sidebar showing the first limit
+// prompt requests. "Load more" re-requests the sidebar with a larger limit, and
+// the poll URL keeps that limit so polling doesn't collapse the list.
+func (s *Server) buildAllSidebar(ctx context.Context, limit int) sidebarData {
+	prs, hasMore, err := s.queries.ListPromptRequests(ctx, false, limit, 0)
+	if err != nil {
+		log.Printf("sidebar query error: %v", err)
 	}
-	if err := json.Unmarshal([]byte(rawJSON), &wrapper); err == nil {
-		if wrapper.StructuredOutput != nil {
-			return wrapper.StructuredOutput
-		}
-		if wrapper.Result != "" {
-			var resp claude.Response
-			if json.Unmarshal([]byte(wrapper.Result), &resp) == nil {
-				return &resp
-			}
-		}
+	sidebar := s.buildSidebar(prs, "all", 0)
+	if limit != sidebarPageSize {
+		sidebar.PollURL += "&limit=" + strconv.Itoa(limit)
 	}
-
-	// Try direct parse
-	var resp claude.Response
-	if json.Unmarshal([]byte(rawJSON), &resp) == nil && resp.Message != "" {
-		return &resp
+	if hasMore {
+		sidebar.MoreURL = "/api/sidebar?scope=all&limit=" + strconv.Itoa(limit+sidebarPageSize)
 	}
-	return nil
+	return sidebar
 }
 
 // orgRepoForPR returns the org and repo name for a prompt request.
-func (s *Server) orgRepoForPR(prID int64) (string, string) {
-	pr, err := s.queries.GetPromptRequest(prID)
+func (s *Server) orgRepoForPR(ctx context.Context, prID int64) (string, string) {
+	pr, err := s.queries.GetPromptRequest(ctx, prID)
 	if err != nil {
 		return "", ""
 	}
@@ -1254,7 +2652,7 @@ func (s *Server) orgRepoForPR(prID int64) (string, string) {
 // buildResponsePush builds gotk instructions to push a Claude response to the client.
 // It removes the spinner, appends the assistant message, re-enables the form, and triggers
 // markdown rendering and scroll.
-func (s *Server) buildResponsePush(prID int64, message string, rawJSON *string) []gotk.Instruction {
+func (s *Server) buildResponsePush(prID int64, message string, saved *models.Message) []gotk.Instruction {
 	var ins []gotk.Instruction
 
 	// Remove spinner
@@ -1262,17 +2660,22 @@ func (s *Server) buildResponsePush(prID int64, message string, rawJSON *string)
 
 	// Append assistant message
 	msgHTML := `<div class="message message-assistant"><div class="message-bubble">` +
-		template.HTMLEscapeString(message) + `</div></div>`
+		string(renderMarkdown(message)) + `</div>`
+	if usage := turnUsage(saved); usage != "" {
+		msgHTML += `<div class="message-usage text-sm text-secondary">` + template.HTMLEscapeString(usage) + `</div>`
+		ins = append(ins, gotk.Instruction{Op: "html", Target: "#usage-total", HTML: template.HTMLEscapeString(s.conversationUsage(context.Background(), prID))})
+	}
+	msgHTML += `</div>`
 	ins = append(ins, gotk.Instruction{Op: "html", Target: "#conversation", HTML: msgHTML, Mode: gotk.Append})
 
-	// Handle questions / prompt-ready from raw response
+	// Handle questions / prompt-ready from the saved response
 	hasQuestions := false
-	if rawJSON != nil {
-		questions, promptReady := extractQuestionsFromRaw(*rawJSON)
+	if saved != nil {
+		questions, promptReady := s.pendingQuestions(context.Background(), saved)
 		// Get org/repo for form URLs
-		org, repoName := s.orgRepoForPR(prID)
+		org, repoName := s.orgRepoForPR(context.Background(), prID)
 		if len(questions) > 0 && org != "" {
-			ins = append(ins, s.buildQuestionPush(prID, org, repoName, questions)...)
+			ins = append(ins, s.buildQuestionPush(prID, saved.ID, org, repoName, questions)...)
 			hasQuestions = true
 		}
 		if promptReady && org != "" {
@@ -1287,26 +2690,33 @@ func (s *Server) buildResponsePush(prID int64, message string, rawJSON *string)
 		ins = append(ins, gotk.Instruction{Op: "attr-set", Target: "#message-form", Attr: "style", Value: "display:none"})
 	}
 
-	// Render markdown and scroll
-	ins = append(ins, gotk.Instruction{Op: "exec", Name: "renderMarkdown"})
 	ins = append(ins, gotk.Instruction{Op: "exec", Name: "scrollConversation"})
 
 	return ins
 }
 
 // buildQuestionPush builds gotk instructions to display Claude's questions.
-func (s *Server) buildQuestionPush(prID int64, org, repoName string, questions []questionData) []gotk.Instruction {
+func (s *Server) buildQuestionPush(prID, messageID int64, org, repoName string, questions []questionData) []gotk.Instruction {
 	var html strings.Builder
-	html.WriteString(`<div class="question-block" id="question-form">`)
-	html.WriteString(`<div id="question-form-fields">`)
+	html.WriteString(`<div class="question-block" id="question-form" role="region" aria-label="Questions from the AI">`)
+	html.WriteString(fmt.Sprintf(`<div id="question-form-fields" hx-post="/github.com/%s/%s/prompt-requests/%d/draft" `+
+		`hx-trigger="change, input delay:1s" hx-include="this" hx-vals='{"answers_for": "%d"}' hx-swap="none">`,
+		org, repoName, prID, messageID))
 	html.WriteString(fmt.Sprintf(`<input type="hidden" name="prompt_request_id" value="%d">`, prID))
 
 	for _, q := range questions {
-		html.WriteString(`<div class="question-group">`)
+		role := "radiogroup"
+		if q.MultiSelect {
+			role = "group"
+		}
+		html.WriteString(fmt.Sprintf(`<div class="question-group" role="%s" aria-labelledby="q-%d-header q-%d-text">`, role, q.Index, q.Index))
+		if q.Budget > 0 {
+			html.WriteString(fmt.Sprintf(`<span class="question-count">Question %d of %d</span>`, q.Number, q.Budget))
+		}
 		if q.Header != "" {
-			html.WriteString(fmt.Sprintf(`<span class="question-header">%s</span>`, template.HTMLEscapeString(q.Header)))
+			html.WriteString(fmt.Sprintf(`<span class="question-header" id="q-%d-header">%s</span>`, q.Index, template.HTMLEscapeString(q.Header)))
 		}
-		html.WriteString(fmt.Sprintf(`<h4>%s</h4>`, template.HTMLEscapeString(q.Text)))
+		html.WriteString(fmt.Sprintf(`<h4 id="q-%d-text">%s</h4>`, q.Index, template.HTMLEscapeString(q.Text)))
 		html.WriteString(fmt.Sprintf(`<input type="hidden" name="q_%d_header" value="%s">`, q.Index, template.HTMLEscapeString(q.Header)))
 		html.WriteString(`<div class="options-list">`)
 		for _, opt := range q.Options {
@@ -1321,9 +2731,14 @@ func (s *Server) buildQuestionPush(prID int64, org, repoName string, questions [
 		if q.MultiSelect {
 			inputType = "checkbox"
 		}
+		for _, opt := range q.Fixed {
+			html.WriteString(fmt.Sprintf(`<label class="option-item fixed-option"><input type="%s" name="q_%d" value="%s"><div><div class="option-label">%s</div><div class="option-description">%s</div></div></label>`,
+				inputType, q.Index, opt.Value, template.HTMLEscapeString(opt.Label), template.HTMLEscapeString(opt.Description)))
+		}
 		html.WriteString(fmt.Sprintf(`<label class="option-item other-option"><input type="%s" name="q_%d" value="__other__"><div><div class="option-label">Other</div></div></label>`, inputType, q.Index))
 		html.WriteString(`</div>`)
-		html.WriteString(fmt.Sprintf(`<input type="text" name="q_%d_other" class="other-input" placeholder="Type your answer..." maxlength="500">`, q.Index))
+		html.WriteString(fmt.Sprintf(`<input type="text" name="q_%d_other" class="other-input" placeholder="Type your answer..." maxlength="500" aria-label="Other answer">`, q.Index))
+		html.WriteString(fmt.Sprintf(`<textarea name="q_%d_note" class="note-input" rows="1" placeholder="Add details (optional)" maxlength="1000" aria-label="Details"></textarea>`, q.Index))
 		html.WriteString(`</div>`)
 	}
 	html.WriteString(`</div>`) // close #question-form-fields
@@ -1334,6 +2749,8 @@ func (s *Server) buildQuestionPush(prID int64, org, repoName string, questions [
 
 	return []gotk.Instruction{
 		{Op: "html", Target: "#conversation", HTML: html.String(), Mode: gotk.Append},
+		{Op: "exec", Name: "htmxProcess", Args: map[string]any{"selector": "#question-form-fields"}},
+		{Op: "exec", Name: "focusQuestions"},
 	}
 }
 
@@ -1341,18 +2758,20 @@ func (s *Server) buildQuestionPush(prID int64, org, repoName string, questions [
 func (s *Server) buildPromptReadyPush(prID int64, org, repoName string) []gotk.Instruction {
 	publishHTML := fmt.Sprintf(`<div class="prompt-ready" id="publish-form">`+
 		`<p>Prompt is ready to publish!</p>`+
-		`<button gotk-click="publish" gotk-val-prompt_request_id="%d" `+
-		`gotk-loading="Publishing..." class="btn btn-primary">Publish to GitHub</button>`+
-		`</div>`, prID)
+		`<button hx-get="/github.com/%s/%s/prompt-requests/%d/publish/preview" hx-target="#publish-preview" `+
+		`hx-disabled-elt="this" aria-keyshortcuts="p" class="btn btn-primary">Preview issue</button>`+
+		`<div id="publish-preview"></div>`+
+		`</div>`, org, repoName, prID)
 
 	return []gotk.Instruction{
 		{Op: "html", Target: "#conversation", HTML: publishHTML, Mode: gotk.Append},
+		{Op: "exec", Name: "htmxProcess", Args: map[string]any{"selector": "#publish-form"}},
 	}
 }
 
 // registerGotkCommands registers gotk command handlers on the mux.
 func (s *Server) registerGotkCommands() {
-	s.gotkMux.Handle("send-message", func(ctx *gotk.Context) error {
+	s.gotkMux.Handle("send-message", s.sendLimiter.limitGotk("#conversation", func(ctx *gotk.Context) error {
 		idStr := ctx.Payload.String("prompt_request_id")
 		id, err := strconv.ParseInt(idStr, 10, 64)
 		if err != nil {
@@ -1365,36 +2784,39 @@ func (s *Server) registerGotkCommands() {
 			return nil
 		}
 
-		// Save user message
-		userMsg, err := s.queries.CreateMessage(id, "user", message, nil)
+		sent, err := s.saveUserMessage(context.Background(), id, message, nil)
 		if err != nil {
+			log.Printf("saving user message: %v", err)
 			ctx.Error("#conversation", "Failed to save message")
 			return nil
 		}
+		userMsg, attached, referenced := sent.Message, sent.Attachments, sent.FileReferences
+		s.audit(id, "message", "", 0)
 
 		// Render user message bubble and append to conversation
 		userHTML := `<div class="message message-user"><div class="message-bubble">` +
-			template.HTMLEscapeString(userMsg.Content) + `</div></div>`
+			template.HTMLEscapeString(userMsg.Content) + `</div>` +
+			fileReferenceChipsHTML(referenced) + attachmentThumbsHTML(attached) + `</div>`
 		ctx.HTML("#conversation", userHTML, gotk.Append)
+		ctx.HTML("#pending-attachments", "")
+		ctx.HTML("#pending-references", "")
 
 		// Clear the textarea
 		ctx.SetValue("#message-input", "")
 
 		// Check repo status — if not ready, just save and disable form
-		statusEntry := s.getRepoStatus(id)
-		if statusEntry.Status != "" && statusEntry.Status != "ready" {
+		statusEntry := s.getRepoStatus(context.Background(), id)
+		if statusEntry.Status != stateNone && statusEntry.Status != stateReady {
 			ctx.AttrSet("#message-input", "disabled", "true")
 			ctx.AttrSet("#send-btn", "disabled", "true")
 			return nil
 		}
 
-		// Repo is ready — launch async Claude call
-		bgCtx, cancel := context.WithCancel(context.Background())
-		s.setRepoStatusProcessing(id, cancel)
-		go s.backgroundSendMessage(bgCtx, id)
+		// Repo is ready — queue async Claude call
+		s.queueSendMessage(id)
 
 		// Show processing indicator with gotk-based cancel
-		entry := s.getRepoStatus(id)
+		entry := s.getRepoStatus(context.Background(), id)
 		processingHTML := fmt.Sprintf(
 			`<div id="repo-status" class="repo-status" data-started-at="%d">`+
 				`<div class="processing-indicator"><div class="spinner"></div>`+
@@ -1416,7 +2838,7 @@ func (s *Server) registerGotkCommands() {
 		ctx.AttrSet("#send-btn", "disabled", "true")
 
 		return nil
-	})
+	}))
 
 	s.gotkMux.Handle("cancel-message", func(ctx *gotk.Context) error {
 		idStr := ctx.Payload.String("prompt_request_id")
@@ -1425,8 +2847,8 @@ func (s *Server) registerGotkCommands() {
 			return nil
 		}
 
-		entry := s.getRepoStatus(id)
-		if entry.Status == "processing" {
+		entry := s.getRepoStatus(context.Background(), id)
+		if entry.Status == stateProcessing {
 			if v, ok := s.cancelFuncs.Load(id); ok {
 				if cancel, ok := v.(context.CancelFunc); ok {
 					cancel()
@@ -1438,7 +2860,7 @@ func (s *Server) registerGotkCommands() {
 		return nil
 	})
 
-	s.gotkMux.Handle("answer-question", func(ctx *gotk.Context) error {
+	s.gotkMux.Handle("answer-question", s.sendLimiter.limitGotk("#conversation", func(ctx *gotk.Context) error {
 		idStr := ctx.Payload.String("prompt_request_id")
 		id, err := strconv.ParseInt(idStr, 10, 64)
 		if err != nil {
@@ -1446,17 +2868,19 @@ func (s *Server) registerGotkCommands() {
 			return nil
 		}
 
-		message := assembleQuestionAnswersFromPayload(ctx.Payload)
+		message, answers := assembleQuestionAnswersFromPayload(ctx.Payload)
 		if message == "" {
 			return nil
 		}
 
-		// Save user message
-		userMsg, err := s.queries.CreateMessage(id, "user", message, nil)
+		sent, err := s.saveUserMessage(context.Background(), id, message, answers)
 		if err != nil {
+			log.Printf("saving user message: %v", err)
 			ctx.Error("#conversation", "Failed to save message")
 			return nil
 		}
+		userMsg, attached, referenced := sent.Message, sent.Attachments, sent.FileReferences
+		s.audit(id, "message", "answered questions", 0)
 
 		// Remove question form, show message form again
 		ctx.Remove("#question-form")
@@ -1464,16 +2888,17 @@ func (s *Server) registerGotkCommands() {
 
 		// Append user message bubble
 		userHTML := `<div class="message message-user"><div class="message-bubble">` +
-			template.HTMLEscapeString(userMsg.Content) + `</div></div>`
+			template.HTMLEscapeString(userMsg.Content) + `</div>` +
+			fileReferenceChipsHTML(referenced) + attachmentThumbsHTML(attached) + `</div>`
 		ctx.HTML("#conversation", userHTML, gotk.Append)
+		ctx.HTML("#pending-attachments", "")
+		ctx.HTML("#pending-references", "")
 
-		// Launch async Claude call
-		bgCtx, cancel := context.WithCancel(context.Background())
-		s.setRepoStatusProcessing(id, cancel)
-		go s.backgroundSendMessage(bgCtx, id)
+		// Queue async Claude call
+		s.queueSendMessage(id)
 
 		// Show processing indicator
-		entry := s.getRepoStatus(id)
+		entry := s.getRepoStatus(context.Background(), id)
 		processingHTML := fmt.Sprintf(
 			`<div id="repo-status" class="repo-status" data-started-at="%d">`+
 				`<div class="processing-indicator"><div class="spinner"></div>`+
@@ -1494,9 +2919,9 @@ func (s *Server) registerGotkCommands() {
 		ctx.AttrSet("#send-btn", "disabled", "true")
 
 		return nil
-	})
+	}))
 
-	s.gotkMux.Handle("publish", func(ctx *gotk.Context) error {
+	s.gotkMux.Handle("publish", s.publishLimiter.limitGotk("#conversation", func(ctx *gotk.Context) error {
 		idStr := ctx.Payload.String("prompt_request_id")
 		id, err := strconv.ParseInt(idStr, 10, 64)
 		if err != nil {
@@ -1504,80 +2929,52 @@ func (s *Server) registerGotkCommands() {
 			return nil
 		}
 
-		pr, err := s.queries.GetPromptRequest(id)
-		if err != nil {
+		if _, err := s.queries.GetPromptRequest(context.Background(), id); err != nil {
 			ctx.Error("#conversation", "Prompt request not found")
 			return nil
 		}
 
-		gc, err := s.queries.GetLatestGeneratedContent(id)
-		if err != nil {
-			ctx.Error("#conversation", "No generated prompt found. Continue the conversation until the AI generates a prompt.")
+		messageID, _ := strconv.ParseInt(ctx.Payload.String("message_id"), 10, 64)
+		if !s.promptChecked(context.Background(), id, messageID) {
+			ctx.Error("#conversation", "Run the quality check before publishing.")
 			return nil
 		}
-
-		// Compose issue body
-		copyBlock := "\n\n<details>\n<summary>Copy prompt</summary>\n\n```\n" + gc.Prompt + "\n```\n\n</details>"
-		var body string
-		if gc.Motivation != "" {
-			body = "## Why\n\n" + gc.Motivation + "\n\n## Prompt\n\n" + gc.Prompt + copyBlock
-		} else {
-			body = gc.Prompt + copyBlock
-		}
-
-		title := pr.Title
-		if gc.Title != "" {
-			title = gc.Title
-			s.queries.UpdatePromptRequestTitle(id, title)
-		} else if title == "" {
-			title = "Prompt Request"
-		}
-
-		issueTitle := "Prompt Request: " + title
-		org, repoName := s.orgRepoForPR(id)
-
-		bgCtx := context.Background()
-		if pr.IssueNumber != nil {
-			if err := github.EditIssue(bgCtx, pr.RepoURL, *pr.IssueNumber, body); err != nil {
-				log.Printf("editing issue: %v", err)
-				ctx.Error("#conversation", fmt.Sprintf("Failed to update GitHub issue: %v", err))
+		rev, err := s.publishPromptRequest(context.Background(), id, messageID, false)
+		if err != nil {
+			log.Printf("publishing prompt request %d: %v", id, err)
+			if errors.Is(err, errUpstreamEdited) {
+				org, repoName := s.orgRepoForPR(context.Background(), id)
+				ctx.HTML("#conversation", upstreamEditedNotice(org, repoName, id, messageID), gotk.Append)
+				ctx.Exec("scrollConversation")
 				return nil
 			}
-		} else {
-			var labels []string
-			if err := github.EnsureLabel(bgCtx, pr.RepoURL, github.LabelName); err != nil {
-				log.Printf("warning: ensuring label %q: %v", github.LabelName, err)
-			} else {
-				labels = []string{github.LabelName}
+			if errors.Is(err, errNoGeneratedPrompt) {
+				ctx.Error("#conversation", "No generated prompt found. Continue the conversation until the AI generates a prompt.")
+				return nil
 			}
-
-			issue, err := github.CreateIssue(bgCtx, pr.RepoURL, issueTitle, body, labels)
-			if err != nil {
-				log.Printf("creating issue: %v", err)
-				ctx.Error("#conversation", fmt.Sprintf("Failed to create GitHub issue: %v", err))
+			if errors.Is(err, errUnknownGeneratedPrompt) {
+				ctx.Error("#conversation", "The selected prompt version no longer exists. Pick another one.")
 				return nil
 			}
-			if err := s.queries.UpdatePromptRequestIssue(id, issue.Number, issue.URL); err != nil {
-				log.Printf("updating issue info: %v", err)
+			if errors.Is(err, github.ErrIssuesUnavailable) {
+				// Retrying won't help; offer a gist instead.
+				org, repoName := s.orgRepoForPR(context.Background(), id)
+				ctx.HTML("#conversation", gistNotice(org, repoName, id, messageID), gotk.Append)
+				ctx.Exec("scrollConversation")
+				return nil
 			}
+			if publishRetryable(err) {
+				s.enqueue(jobPublish, jobPayload{PromptRequestID: id, MessageID: messageID})
+				ctx.Error("#conversation", fmt.Sprintf("Failed to publish: %v. Prompter will keep retrying in the background.", err))
+				return nil
+			}
+			ctx.Error("#conversation", fmt.Sprintf("Failed to publish: %v", err))
+			return nil
 		}
-
-		// Create revision
-		var afterMsgID *int64
-		if lastMsg, err := s.queries.GetLastMessage(id); err == nil {
-			afterMsgID = &lastMsg.ID
-		}
-		rev, err := s.queries.CreateRevision(id, body, afterMsgID)
-		if err != nil {
-			log.Printf("creating revision: %v", err)
-		}
-
-		if err := s.queries.UpdatePromptRequestStatus(id, "published"); err != nil {
-			log.Printf("updating status: %v", err)
-		}
+		org, repoName := s.orgRepoForPR(context.Background(), id)
 
 		// Re-fetch PR to get updated issue URL
-		pr, _ = s.queries.GetPromptRequest(id)
+		pr, _ := s.queries.GetPromptRequest(context.Background(), id)
 
 		// --- Push UI updates ---
 
@@ -1598,16 +2995,16 @@ func (s *Server) registerGotkCommands() {
 		// Update revision sidebar content
 		var sidebarHTML strings.Builder
 		sidebarHTML.WriteString(`<h3 class="sidebar-heading">Revisions</h3>`)
-		revisions, _ := s.queries.ListRevisions(id)
+		revisions, _ := s.queries.ListRevisions(context.Background(), id)
 		if len(revisions) > 0 {
 			sidebarHTML.WriteString(`<ul class="revision-list">`)
 			for _, r := range revisions {
 				sidebarHTML.WriteString(fmt.Sprintf(
 					`<li class="revision-list-item"><a href="#revision-%d" class="revision-link">`+
 						`<span class="revision-number">Revision %d</span>`+
-						`<time class="revision-time text-sm text-secondary">%s</time>`+
+						`<time class="revision-time text-sm text-secondary" datetime="%s" title="%s">%s</time>`+
 						`</a></li>`,
-					r.ID, r.ID, r.PublishedAt.Format("Jan 2, 2006 3:04 PM")))
+					r.ID, r.ID, isoTime(r.PublishedAt), fullTime(r.PublishedAt), timeAgo(r.PublishedAt)))
 			}
 			sidebarHTML.WriteString(`</ul>`)
 			if pr.IssueURL != nil {
@@ -1615,6 +3012,9 @@ func (s *Server) registerGotkCommands() {
 					`<a href="%s" target="_blank" class="sidebar-issue-link">View GitHub Issue</a>`,
 					template.HTMLEscapeString(*pr.IssueURL)))
 			}
+			sidebarHTML.WriteString(fmt.Sprintf(
+				`<a href="/github.com/%s/%s/prompt-requests/%d/diff" class="sidebar-issue-link">Compare revisions</a>`,
+				org, repoName, id))
 		}
 		// Include archive button
 		sidebarHTML.WriteString(`<div class="sidebar-archive-action">`)
@@ -1644,19 +3044,18 @@ func (s *Server) registerGotkCommands() {
 					`<details class="submission-marker-details">`+
 					`<summary class="submission-marker-text">`+
 					`Published to GitHub — Revision %d `+
-					`<time>%s</time>`+
+					`<time datetime="%s" title="%s">%s</time>`+
 					`</summary>`+
 					`<div class="revision-content">%s</div>`+
 					`</details></div>`,
 				rev.ID, rev.ID,
-				rev.PublishedAt.Format("Jan 2, 2006 3:04 PM"),
-				template.HTMLEscapeString(rev.Content))
+				isoTime(rev.PublishedAt), fullTime(rev.PublishedAt), timeAgo(rev.PublishedAt),
+				renderMarkdown(rev.Content))
 			ctx.HTML("#conversation", markerHTML, gotk.Append)
 		}
 
-		ctx.Exec("renderMarkdown")
 		ctx.Exec("scrollConversation")
 
 		return nil
-	})
+	}))
 }
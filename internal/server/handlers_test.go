@@ -0,0 +1,44 @@
+package server
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/esnunes/prompter/internal/models"
+)
+
+func TestSummarizeSession_NothingOlderToSummarize(t *testing.T) {
+	s := &Server{cfg: Config{SummaryThreshold: 100}}
+	long := strings.Repeat("x", 1000)
+	messages := func(n int) []models.Message {
+		var msgs []models.Message
+		for i := 1; i <= n; i++ {
+			role := "user"
+			if i%2 == 0 {
+				role = "assistant"
+			}
+			msgs = append(msgs, models.Message{ID: int64(i), Role: role, Content: long})
+		}
+		return msgs
+	}
+	two := int64(2)
+
+	for _, tt := range []struct {
+		name string
+		pr   *models.PromptRequest
+		msgs []models.Message
+	}{
+		{"exactly the recent messages", &models.PromptRequest{ID: 1}, messages(summaryKeepRecent)},
+		{"older messages already summarized", &models.PromptRequest{ID: 1, Summary: "Earlier", SummaryMessageID: &two, ForkMessageID: &two},
+			messages(summaryKeepRecent + 2)},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			// Summarizing would run claude; returning early must not.
+			summarized, err := s.summarizeSession(context.Background(), tt.pr, tt.msgs, 0)
+			if summarized || err != nil {
+				t.Errorf("summarizeSession = %v, %v; want false, nil", summarized, err)
+			}
+		})
+	}
+}
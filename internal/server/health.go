@@ -0,0 +1,91 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/esnunes/prompter/internal/paths"
+)
+
+// healthCheck is the result of a single readiness probe.
+type healthCheck struct {
+	Name  string `json:"name"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+type healthResponse struct {
+	Status string        `json:"status"` // "ok" or "unavailable"
+	Checks []healthCheck `json:"checks,omitempty"`
+}
+
+// handleHealthz reports that the process is up and serving requests.
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, healthResponse{Status: "ok"})
+}
+
+// handleReadyz reports whether the server can do useful work: the database
+// answers queries, the cache directory is writable, and the external CLIs
+// that prompter shells out to are installed.
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	checks := []healthCheck{
+		runCheck("database", func() error { return s.queries.Ping(ctx) }),
+		runCheck("cache_dir", checkCacheDirWritable),
+	}
+	for _, bin := range []string{"claude", "gh", "git"} {
+		checks = append(checks, runCheck(bin, func() error {
+			_, err := exec.LookPath(bin)
+			return err
+		}))
+	}
+
+	resp := healthResponse{Status: "ok", Checks: checks}
+	for _, c := range checks {
+		if !c.OK {
+			resp.Status = "unavailable"
+			break
+		}
+	}
+	writeHealth(w, resp)
+}
+
+func runCheck(name string, fn func() error) healthCheck {
+	if err := fn(); err != nil {
+		return healthCheck{Name: name, Error: err.Error()}
+	}
+	return healthCheck{Name: name, OK: true}
+}
+
+// checkCacheDirWritable creates and removes a temp file in the cache directory.
+func checkCacheDirWritable() error {
+	dir, err := paths.CacheDir()
+	if err != nil {
+		return fmt.Errorf("getting cache directory: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("creating cache directory: %w", err)
+	}
+	f, err := os.CreateTemp(dir, ".readyz-*")
+	if err != nil {
+		return fmt.Errorf("writing to cache directory: %w", err)
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+func writeHealth(w http.ResponseWriter, resp healthResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if resp.Status != "ok" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(resp)
+}
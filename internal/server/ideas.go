@@ -0,0 +1,233 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+
+	"github.com/esnunes/prompter/internal/claude"
+	"github.com/esnunes/prompter/internal/models"
+	"github.com/esnunes/prompter/internal/repo"
+)
+
+// ideaRuns tracks which repositories Claude is proposing ideas for, and
+// why the last attempt failed for the others.
+type ideaRuns struct {
+	mu      sync.Mutex
+	running map[string]bool   // repo URL → proposing now
+	errs    map[string]string // repo URL → error of the last failed attempt
+}
+
+// start marks ideas of the repository as being proposed, unless they
+// already are.
+func (r *ideaRuns) start(repoURL string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.running == nil {
+		r.running = map[string]bool{}
+		r.errs = map[string]string{}
+	}
+	if r.running[repoURL] {
+		return false
+	}
+	r.running[repoURL] = true
+	delete(r.errs, repoURL)
+	return true
+}
+
+func (r *ideaRuns) finish(repoURL string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.running, repoURL)
+	if err != nil {
+		r.errs[repoURL] = err.Error()
+	}
+}
+
+// status reports whether ideas of the repository are being proposed, and
+// the error of the last attempt if it failed.
+func (r *ideaRuns) status(repoURL string) (running bool, errMsg string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.running[repoURL], r.errs[repoURL]
+}
+
+type ideasData struct {
+	basePageData
+	RepoURL    string
+	Org        string
+	Repo       string
+	Ideas      []models.RepoIdea
+	Generating bool
+	Error      string // why the last attempt failed
+}
+
+// handleIdeasPage lists the feature ideas Claude proposed for a repository.
+func (s *Server) handleIdeasPage(w http.ResponseWriter, r *http.Request) {
+	org := r.PathValue("org")
+	repoName := r.PathValue("repo")
+	repoURL := fmt.Sprintf("github.com/%s/%s", org, repoName)
+	if err := repo.ValidateURL(repoURL); err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	var ideas []models.RepoIdea
+	if rec, err := s.queries.GetRepositoryByURL(r.Context(), repoURL); err == nil {
+		ideas, err = s.queries.ListRepoIdeas(r.Context(), rec.ID)
+		if err != nil {
+			log.Printf("listing ideas of %s: %v", repoURL, err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+	}
+	prs, _ := s.queries.ListPromptRequestsByRepoURL(r.Context(), repoURL, false)
+	generating, errMsg := s.ideaRuns.status(repoURL)
+	s.renderPage(w, "ideas.html", ideasData{
+		basePageData: basePageData{Sidebar: s.buildSidebar(prs, "repo", 0)},
+		RepoURL:      repoURL,
+		Org:          org,
+		Repo:         repoName,
+		Ideas:        ideas,
+		Generating:   generating,
+		Error:        errMsg,
+	})
+}
+
+// handleGenerateIdeas starts proposing new ideas for a repository in the
+// background, replacing the current ones when done.
+func (s *Server) handleGenerateIdeas(w http.ResponseWriter, r *http.Request) {
+	org := r.PathValue("org")
+	repoName := r.PathValue("repo")
+	repoURL := fmt.Sprintf("github.com/%s/%s", org, repoName)
+	if err := repo.ValidateURL(repoURL); err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	localPath, err := repo.LocalPath(repoURL)
+	if err != nil {
+		log.Printf("computing local path: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	rec, err := s.queries.UpsertRepository(r.Context(), repoURL, localPath)
+	if err != nil {
+		log.Printf("upserting repository: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if s.ideaRuns.start(repoURL) {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), s.cfg.JobTimeout)
+			defer cancel()
+			err := s.generateIdeas(ctx, rec)
+			if err != nil {
+				log.Printf("proposing ideas for %s: %v", repoURL, err)
+			}
+			s.ideaRuns.finish(repoURL, err)
+		}()
+	}
+	http.Redirect(w, r, fmt.Sprintf("/github.com/%s/%s/ideas", org, repoName), http.StatusSeeOther)
+}
+
+// generateIdeas brings the repository's local copy up to date and has
+// Claude propose ideas from it, knowing the recent work and open issues.
+func (s *Server) generateIdeas(ctx context.Context, rec *models.Repository) error {
+	unlock := s.lockRepo(rec.URL)
+	if !s.repoFresh(ctx, rec.URL) {
+		if _, err := repo.EnsureCloned(ctx, rec.URL); err != nil {
+			unlock()
+			return err
+		}
+		if err := s.queries.SetRepositoryPulled(ctx, rec.URL); err != nil {
+			log.Printf("recording pull of %s: %v", rec.URL, err)
+		}
+	}
+	unlock()
+
+	release, err := s.acquireClaude(ctx, 0)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	pr := &models.PromptRequest{RepoURL: rec.URL, RepoLocalPath: rec.LocalPath}
+	proposed, err := claude.SuggestIdeas(ctx, rec.LocalPath, s.recentWorkPrompt(ctx, pr)+s.openIssuesPrompt(ctx, pr))
+	if err != nil {
+		return err
+	}
+	ideas := make([]models.RepoIdea, 0, len(proposed))
+	for _, i := range proposed {
+		if title := strings.TrimSpace(i.Title); title != "" {
+			ideas = append(ideas, models.RepoIdea{Title: title, Description: strings.TrimSpace(i.Description)})
+		}
+	}
+	if len(ideas) == 0 {
+		return errors.New("no ideas proposed")
+	}
+	return s.queries.ReplaceRepoIdeas(context.WithoutCancel(ctx), rec.ID, ideas)
+}
+
+// handleStartIdea creates a prompt request seeded with an idea and opens
+// its conversation.
+func (s *Server) handleStartIdea(w http.ResponseWriter, r *http.Request) {
+	org := r.PathValue("org")
+	repoName := r.PathValue("repo")
+	repoURL := fmt.Sprintf("github.com/%s/%s", org, repoName)
+
+	ideaID, err := strconv.ParseInt(r.PathValue("idea"), 10, 64)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	rec, err := s.queries.GetRepositoryByURL(r.Context(), repoURL)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	idea, err := s.queries.GetRepoIdea(r.Context(), rec.ID, ideaID)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	pr, err := s.queries.CreatePromptRequest(r.Context(), rec.ID, uuid.New().String())
+	if err != nil {
+		log.Printf("creating prompt request: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	s.queries.UpdatePromptRequestTitle(r.Context(), pr.ID, idea.Title)
+	if _, err := s.queries.CreateMessage(r.Context(), pr.ID, "user", ideaMessage(idea), nil); err != nil {
+		log.Printf("seeding prompt request from idea: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	s.audit(pr.ID, "created", "from a proposed idea", 0)
+	s.applyDefaultPersona(r.Context(), pr.ID)
+
+	// The seed message is sent automatically once the clone is ready.
+	s.queueEnsureCloned(pr.ID, repoURL)
+
+	http.Redirect(w, r, fmt.Sprintf("/github.com/%s/%s/prompt-requests/%d", org, repoName, pr.ID), http.StatusSeeOther)
+}
+
+// ideaMessage is the first message of a prompt request started from an idea.
+func ideaMessage(idea *models.RepoIdea) string {
+	var b strings.Builder
+	b.WriteString("I'd like to propose this feature, which was suggested for the repository:\n\n")
+	b.WriteString("## " + idea.Title + "\n\n")
+	if idea.Description != "" {
+		b.WriteString(idea.Description + "\n\n")
+	}
+	b.WriteString("Help me turn it into a feature request: check it against the codebase and ask me what needs deciding.")
+	return b.String()
+}
@@ -0,0 +1,129 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/esnunes/prompter/internal/github"
+	"github.com/esnunes/prompter/internal/models"
+	"github.com/esnunes/prompter/internal/repo"
+	"github.com/google/uuid"
+)
+
+// parseIssueRef accepts "42", "#42" or an issue URL in repoURL and returns
+// the issue number, or 0 if raw doesn't refer to an issue in repoURL.
+func parseIssueRef(repoURL, raw string) int {
+	raw = strings.TrimSpace(raw)
+	raw = strings.TrimPrefix(strings.TrimPrefix(raw, "https://"), "http://")
+	if rest, ok := strings.CutPrefix(raw, repoURL+"/issues/"); ok {
+		raw = strings.TrimRight(rest, "/")
+		if i := strings.IndexAny(raw, "#?/"); i >= 0 {
+			raw = raw[:i]
+		}
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(raw, "#"))
+	if err != nil || n < 1 {
+		return 0
+	}
+	return n
+}
+
+// importIssueMessage is the first user message of a prompt request imported
+// from an existing issue.
+func importIssueMessage(issue *github.IssueDetails) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "I'd like to turn issue #%d into a proper prompt request. Here it is:\n\n", issue.Number)
+	b.WriteString("# " + issue.Title + "\n\n")
+	if body := strings.TrimSpace(issue.Body); body != "" {
+		b.WriteString(body + "\n\n")
+	} else {
+		b.WriteString("(The issue has no description.)\n\n")
+	}
+	if len(issue.Comments) > 0 {
+		b.WriteString("## Comments\n\n")
+		for _, c := range issue.Comments {
+			fmt.Fprintf(&b, "**@%s:**\n\n%s\n\n", c.Author.Login, strings.TrimSpace(c.Body))
+		}
+	}
+	b.WriteString("Please check it against this codebase and ask me about anything that is vague or missing.")
+	return b.String()
+}
+
+// handleImportIssue starts a prompt request seeded with an existing issue's
+// title, body and comments.
+func (s *Server) handleImportIssue(w http.ResponseWriter, r *http.Request) {
+	org := r.PathValue("org")
+	repoName := r.PathValue("repo")
+	repoURL := fmt.Sprintf("github.com/%s/%s", org, repoName)
+
+	number := parseIssueRef(repoURL, r.FormValue("issue"))
+	if number == 0 {
+		http.Error(w, fmt.Sprintf("Enter an issue number or a %s issue URL.", repoURL), http.StatusBadRequest)
+		return
+	}
+	target := r.FormValue("publish_target")
+	switch target {
+	case "", "edit", "comment":
+	default:
+		http.Error(w, "Unknown publish option.", http.StatusBadRequest)
+		return
+	}
+
+	issue, err := github.ViewIssue(r.Context(), repoURL, number)
+	if err != nil {
+		log.Printf("importing issue #%d of %s: %v", number, repoURL, err)
+		http.Error(w, fmt.Sprintf("Couldn't load issue #%d. Check that it exists and you have access.", number), http.StatusBadRequest)
+		return
+	}
+
+	localPath, err := repo.LocalPath(repoURL)
+	if err != nil {
+		log.Printf("computing local path: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	repoRecord, err := s.queries.UpsertRepository(r.Context(), repoURL, localPath)
+	if err != nil {
+		log.Printf("upserting repository: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	pr, err := s.queries.CreatePromptRequest(r.Context(), repoRecord.ID, uuid.New().String())
+	if err != nil {
+		log.Printf("creating prompt request: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	s.queries.UpdatePromptRequestTitle(r.Context(), pr.ID, issue.Title)
+	if err := s.queries.SetPromptRequestSourceIssue(r.Context(), pr.ID, issue.Number, target); err != nil {
+		log.Printf("linking imported issue: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if _, err := s.queries.CreateMessage(r.Context(), pr.ID, "user", importIssueMessage(issue), nil); err != nil {
+		log.Printf("seeding imported prompt request: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	s.audit(pr.ID, "created", fmt.Sprintf("imported from issue #%d", issue.Number), 0)
+	s.applyDefaultPersona(r.Context(), pr.ID)
+
+	// The seed message is sent automatically once the clone is ready.
+	s.queueEnsureCloned(pr.ID, repoURL)
+
+	redirectURL := fmt.Sprintf("/github.com/%s/%s/prompt-requests/%d", org, repoName, pr.ID)
+	if r.Header.Get("HX-Request") == "true" {
+		w.Header().Set("HX-Redirect", redirectURL)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+}
+
+// sourceIssueURL is the URL of the issue the prompt request was imported from.
+func sourceIssueURL(pr *models.PromptRequest) string {
+	return fmt.Sprintf("https://%s/issues/%d", pr.RepoURL, *pr.SourceIssueNumber)
+}
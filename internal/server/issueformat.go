@@ -0,0 +1,308 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/esnunes/prompter/internal/db"
+	"github.com/esnunes/prompter/internal/models"
+	"github.com/esnunes/prompter/internal/repo"
+)
+
+// defaultIssueTitlePrefix is prepended to issue titles unless configured otherwise.
+const defaultIssueTitlePrefix = "Prompt Request: "
+
+// defaultIssueLabel marks issues created by Prompter unless configured otherwise.
+const defaultIssueLabel = "prompter"
+
+// ParseLabels splits a comma-separated list of labels, dropping blanks.
+func ParseLabels(s string) []string {
+	var labels []string
+	for _, l := range strings.Split(s, ",") {
+		if l = strings.TrimSpace(l); l != "" && !slices.Contains(labels, l) {
+			labels = append(labels, l)
+		}
+	}
+	return labels
+}
+
+// issueLabels are the labels applied to every issue created for pr: the
+// repository's own, or the configured ones.
+func (s *Server) issueLabels(pr *models.PromptRequest) []string {
+	if pr.RepoIssueLabels != nil {
+		return ParseLabels(*pr.RepoIssueLabels)
+	}
+	return s.cfg.IssueLabels
+}
+
+// defaultIssueBodyTemplate lays out the issue body: motivation, prompt
+// (under a heading that follows the repository's output format),
+// attached images, the terms it defines, how to verify it, what is out of
+// scope, the alternatives considered, the estimated size, and a copyable raw
+// prompt.
+const defaultIssueBodyTemplate = "{{if .Motivation}}## Why\n\n{{.Motivation}}\n\n## {{.PromptHeading}}\n\n{{end}}" +
+	"{{.Prompt}}{{.Images}}\n\n" +
+	"{{with .Glossary}}## Definitions\n\n{{range .}}- **{{.Term}}**: {{.Definition}}\n{{end}}\n{{end}}" +
+	"{{with .TestPlan}}## How to verify\n\n{{range .}}- {{.}}\n{{end}}\n{{end}}" +
+	"{{with .NonGoals}}## Out of scope\n\n{{range .}}- {{.}}\n{{end}}\n{{end}}" +
+	"{{with .Alternatives}}## Alternatives considered\n\n{{range .}}- {{.}}\n{{end}}\n{{end}}" +
+	"{{if .Size}}## Estimated size\n\n**{{.Size}}**{{with .SizeRationale}}: {{.}}{{end}}\n\n{{end}}" +
+	"<details>\n<summary>Copy prompt</summary>\n\n```\n{{.Prompt}}\n```\n\n</details>"
+
+// kindIssueBodyTemplate lays out the issue body of kinds other than feature
+// requests, whose prompt already has the kind's sections: the motivation
+// under heading, the prompt, images, definitions, how to verify it, what is
+// out of scope, alternatives and estimated size, and a copyable raw prompt.
+func kindIssueBodyTemplate(heading, copyLabel string) string {
+	return "{{if .Motivation}}## " + heading + "\n\n{{.Motivation}}\n\n{{end}}" +
+		"{{.Prompt}}{{.Images}}\n\n" +
+		"{{with .Glossary}}## Definitions\n\n{{range .}}- **{{.Term}}**: {{.Definition}}\n{{end}}\n{{end}}" +
+		"{{with .TestPlan}}## How to verify\n\n{{range .}}- {{.}}\n{{end}}\n{{end}}" +
+		"{{with .NonGoals}}## Out of scope\n\n{{range .}}- {{.}}\n{{end}}\n{{end}}" +
+		"{{with .Alternatives}}## Alternatives considered\n\n{{range .}}- {{.}}\n{{end}}\n{{end}}" +
+		"{{if .Size}}## Estimated size\n\n**{{.Size}}**{{with .SizeRationale}}: {{.}}{{end}}\n\n{{end}}" +
+		"<details>\n<summary>" + copyLabel + "</summary>\n\n```\n{{.Prompt}}\n```\n\n</details>"
+}
+
+// maxIssueBodyTemplateSize bounds a per-repository body template.
+const maxIssueBodyTemplateSize = 16 << 10
+
+// issueBodyFields are the values available to issue body templates.
+type issueBodyFields struct {
+	Title      string
+	Motivation string
+	Prompt     string
+	Images     string // Markdown section with the attached images, or ""
+	Kind       string // "" for a feature request, or "bug", "refactor", "docs" or "performance"
+	Format     string // output format of feature requests: "", "stories" or "gherkin"
+
+	Glossary     []models.GlossaryTerm // terms the conversation defined, each with .Term and .Definition
+	TestPlan     []string              // steps to confirm the request works once built
+	NonGoals     []string              // what the request deliberately leaves out
+	Alternatives []string              // approaches the contributor rejected, with why
+
+	Size          string // estimated effort, "S" to "XL", or "" if not estimated
+	SizeRationale string
+}
+
+// PromptHeading is the heading of the prompt in the default issue body.
+func (f issueBodyFields) PromptHeading() string {
+	format, ok := lookupOutputFormat(f.Format)
+	if !ok {
+		format = outputFormats[0]
+	}
+	return format.Heading
+}
+
+// parseIssueBodyTemplate parses an issue body template and checks that it
+// renders, so mistakes surface when it is saved rather than on publish.
+func parseIssueBodyTemplate(text string) (*texttemplate.Template, error) {
+	tmpl, err := texttemplate.New("issue").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	sample := issueBodyFields{Title: "Title", Format: "stories", Motivation: "Motivation", Prompt: "Prompt", Size: "M", SizeRationale: "Rationale", NonGoals: []string{"Non-goal"}, Alternatives: []string{"Alternative"},
+		Glossary: []models.GlossaryTerm{{Term: "Term", Definition: "Definition"}}, TestPlan: []string{"Step"}}
+	if err := tmpl.Execute(io.Discard, sample); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// issueBodyTemplate returns the body template for pr: the repository's, the
+// configured global one, or the default for its kind.
+func (s *Server) issueBodyTemplate(pr *models.PromptRequest) string {
+	switch {
+	case pr.RepoBodyTemplate != "":
+		return pr.RepoBodyTemplate
+	case s.cfg.IssueBodyTemplate != "":
+		return s.cfg.IssueBodyTemplate
+	}
+	if k, ok := lookupPromptKind(pr.Kind); ok && k.BodyTemplate != "" {
+		return k.BodyTemplate
+	}
+	return defaultIssueBodyTemplate
+}
+
+// composeIssueBody renders the issue body for gc with pr's body template,
+// followed by the areas likely affected, the transcript, the related
+// issues and the maintainers to mention when pr opted in. When pr follows a repository
+// issue template, the generated prompt already is the body, laid out as the
+// template asks.
+func (s *Server) composeIssueBody(ctx context.Context, pr *models.PromptRequest, gc *db.GeneratedContent, images string) (string, error) {
+	var b strings.Builder
+	if s.issueTemplate(pr) != nil {
+		b.WriteString(gc.Prompt + images)
+	} else {
+		tmpl, err := parseIssueBodyTemplate(s.issueBodyTemplate(pr))
+		if err != nil {
+			return "", fmt.Errorf("parsing issue body template: %w", err)
+		}
+		fields := issueBodyFields{
+			Title: gc.Title, Motivation: gc.Motivation, Prompt: gc.Prompt, Images: images, Kind: pr.Kind, Format: pr.RepoOutputFormat,
+			Glossary: gc.Glossary, TestPlan: gc.TestPlan, NonGoals: gc.NonGoals, Alternatives: gc.Alternatives, Size: gc.Size, SizeRationale: gc.SizeReason,
+		}
+		if err := tmpl.Execute(&b, fields); err != nil {
+			return "", fmt.Errorf("rendering issue body: %w", err)
+		}
+	}
+	if pr.IncludeAffectedAreas && len(gc.Areas) > 0 {
+		b.WriteString(areasAppendix(gc.Areas))
+	}
+	if pr.IncludeTranscript {
+		msgs, err := s.queries.ListMessages(ctx, pr.ID)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(s.issueTranscript(msgs, gc.MessageID))
+	}
+	if pr.LinkRelatedIssues {
+		related, err := s.relatedIssues(ctx, pr)
+		if err != nil {
+			return "", err
+		}
+		if len(related) > 0 {
+			b.WriteString("\n\n" + relatedLine(related))
+		}
+	}
+	if len(pr.Mentions) > 0 {
+		b.WriteString("\n\n" + mentionsLine(pr.Mentions))
+	}
+	if pr.SourceIssueNumber != nil && pr.PublishTarget == "" {
+		fmt.Fprintf(&b, "\n\nBased on #%d.", *pr.SourceIssueNumber)
+	}
+	return b.String(), nil
+}
+
+// issueTitle is the GitHub issue title for a prompt request title. The
+// default title of the issue template pr follows, such as "[Bug]: ", takes
+// the place of the configured prefix.
+func (s *Server) issueTitle(pr *models.PromptRequest, title string) string {
+	if t := s.issueTemplate(pr); t != nil && t.Title != "" {
+		return t.Title + title
+	}
+	if pr.RepoTitlePrefix != nil {
+		return *pr.RepoTitlePrefix + title
+	}
+	return s.cfg.IssueTitlePrefix + title
+}
+
+type issueFormatData struct {
+	InheritPrefix   bool
+	TitlePrefix     string
+	InheritLabels   bool
+	Labels          string
+	DefaultLabels   string
+	BodyTemplate    string
+	DefaultPrefix   string
+	DefaultTemplate string
+	IncludeAreas    bool
+	OutputFormat    string
+	OutputFormats   []outputFormat
+	TestPlan        bool
+	Project         string
+}
+
+func (s *Server) newIssueFormatData(ctx context.Context, repoURL string) issueFormatData {
+	data := issueFormatData{InheritPrefix: true, DefaultPrefix: s.cfg.IssueTitlePrefix, DefaultTemplate: s.cfg.IssueBodyTemplate,
+		InheritLabels: true, DefaultLabels: strings.Join(s.cfg.IssueLabels, ", "), OutputFormats: outputFormats}
+	if data.DefaultTemplate == "" {
+		data.DefaultTemplate = defaultIssueBodyTemplate
+	}
+	// Repositories without prompt requests have no record, hence no overrides.
+	if rec, err := s.queries.GetRepositoryByURL(ctx, repoURL); err == nil {
+		data.InheritPrefix = rec.IssueTitlePrefix == nil
+		if rec.IssueTitlePrefix != nil {
+			data.TitlePrefix = *rec.IssueTitlePrefix
+		}
+		data.InheritLabels = rec.IssueLabels == nil
+		if rec.IssueLabels != nil {
+			data.Labels = *rec.IssueLabels
+		}
+		data.BodyTemplate = rec.IssueBodyTemplate
+		data.IncludeAreas = rec.IncludeAffectedAreas
+		data.OutputFormat = rec.OutputFormat
+		data.TestPlan = rec.ProposeTestPlan
+		data.Project = rec.Project
+	}
+	return data
+}
+
+// handleIssueFormat saves a repository's issue title prefix and body
+// template and its issue options.
+func (s *Server) handleIssueFormat(w http.ResponseWriter, r *http.Request) {
+	org := r.PathValue("org")
+	repoName := r.PathValue("repo")
+	repoURL := fmt.Sprintf("github.com/%s/%s", org, repoName)
+
+	var prefix *string
+	if r.FormValue("inherit_prefix") != "1" {
+		p := r.FormValue("title_prefix")
+		prefix = &p
+	}
+	var labels *string
+	if r.FormValue("inherit_labels") != "1" {
+		l := strings.Join(ParseLabels(r.FormValue("labels")), ", ")
+		labels = &l
+	}
+	body := strings.TrimSpace(strings.ReplaceAll(r.FormValue("body_template"), "\r\n", "\n"))
+	if len(body) > maxIssueBodyTemplateSize {
+		http.Error(w, fmt.Sprintf("The body template must be at most %d KB.", maxIssueBodyTemplateSize>>10), http.StatusBadRequest)
+		return
+	}
+	if body != "" {
+		if _, err := parseIssueBodyTemplate(body); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid body template: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	format := r.FormValue("output_format")
+	if _, ok := lookupOutputFormat(format); !ok {
+		http.Error(w, "Unknown output format.", http.StatusBadRequest)
+		return
+	}
+	project, ok := projectRef(r.FormValue("project"))
+	if !ok {
+		http.Error(w, "The project must be a project's URL, OWNER/NUMBER or, for a repository's classic project, OWNER/REPO/NUMBER.", http.StatusBadRequest)
+		return
+	}
+
+	localPath, err := repo.LocalPath(repoURL)
+	if err != nil {
+		log.Printf("computing local path: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	repoRecord, err := s.queries.UpsertRepository(r.Context(), repoURL, localPath)
+	if err != nil {
+		log.Printf("upserting repository: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if err := s.queries.SetRepositoryIssueFormat(r.Context(), repoRecord.ID, prefix, body, db.IssueOptions{
+		IncludeAffectedAreas: r.FormValue("include_areas") == "1",
+		OutputFormat:         format,
+		ProposeTestPlan:      r.FormValue("test_plan") == "1",
+		Project:              project,
+		Labels:               labels,
+	}); err != nil {
+		log.Printf("saving issue format for %s: %v", repoURL, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	redirectURL := fmt.Sprintf("/github.com/%s/%s/prompt-requests", org, repoName)
+	if r.Header.Get("HX-Request") == "true" {
+		w.Header().Set("HX-Redirect", redirectURL)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/esnunes/prompter/internal/models"
+	"github.com/esnunes/prompter/internal/repo"
+)
+
+// issueTemplate returns the repository issue template pr follows, or nil if
+// none was picked or the repository no longer has it.
+func (s *Server) issueTemplate(pr *models.PromptRequest) *repo.IssueTemplate {
+	if pr.IssueTemplate == "" {
+		return nil
+	}
+	t, err := repo.FindIssueTemplate(pr.RepoLocalPath, pr.IssueTemplate)
+	if err != nil {
+		log.Printf("reading issue template %s of %s: %v", pr.IssueTemplate, pr.RepoURL, err)
+	}
+	return t
+}
+
+// issueTemplatePrompt tells Claude about a change of issue template: t is the
+// template to follow from now on, or nil to go back to the usual format.
+func issueTemplatePrompt(t *repo.IssueTemplate) string {
+	if t == nil {
+		return "The user no longer wants the issue to follow the repository's issue template. " +
+			"Write \"generated_prompt\" in the usual format again.\n\n"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "The issue must follow the repository's %q issue ", t.Name)
+	if t.Form {
+		b.WriteString("form, defined below. Make sure the conversation covers what its required fields ask for. ")
+		b.WriteString("When the prompt is ready, \"generated_prompt\" is the complete issue body as GitHub renders a submitted form: ")
+		b.WriteString("for each textarea, input, dropdown and checkboxes field in order, a \"### \" heading with its label followed by the answer, ")
+		b.WriteString("or \"_No response_\" when there is nothing to say. Leave out markdown fields.\n\n")
+		fmt.Fprintf(&b, "<issue-form path=%q>\n%s\n</issue-form>\n\n", t.Path, t.Body)
+	} else {
+		b.WriteString("template, shown below. Make sure the conversation covers what its sections ask for. ")
+		b.WriteString("When the prompt is ready, \"generated_prompt\" is the complete issue body: the template's headings in order, ")
+		b.WriteString("each filled in, with the motivation in whichever section asks for the problem or use case. ")
+		b.WriteString("Drop the template's instructions and HTML comments.\n\n")
+		fmt.Fprintf(&b, "<issue-template path=%q>\n%s\n</issue-template>\n\n", t.Path, t.Body)
+	}
+	return b.String()
+}
+
+// handleIssueTemplate picks the repository issue template a prompt request's
+// issue follows. Claude is told with the next message.
+func (s *Server) handleIssueTemplate(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	pr, err := s.queries.GetPromptRequest(r.Context(), id)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	path := r.FormValue("path")
+	if path != "" {
+		t, err := repo.FindIssueTemplate(pr.RepoLocalPath, path)
+		if err != nil {
+			log.Printf("listing issue templates of %s: %v", pr.RepoURL, err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if t == nil {
+			http.Error(w, "The repository has no such issue template.", http.StatusBadRequest)
+			return
+		}
+	}
+	if err := s.queries.SetPromptRequestIssueTemplate(r.Context(), id, path); err != nil {
+		log.Printf("setting issue template of prompt request %d: %v", id, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	// Clear any earlier error.
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,161 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/esnunes/prompter/internal/github"
+	"github.com/esnunes/prompter/internal/models"
+)
+
+const (
+	// notificationsPageSize is how many notifications the page lists.
+	notificationsPageSize = 100
+	// maxCommentExcerpt bounds the comment text kept in a notification.
+	maxCommentExcerpt = 200
+)
+
+// runIssueWatch checks published issues for maintainer replies at startup
+// and then every IssueWatchInterval, until ctx is cancelled.
+func (s *Server) runIssueWatch(ctx context.Context) {
+	if s.cfg.IssueWatchInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(s.cfg.IssueWatchInterval)
+	defer ticker.Stop()
+	for {
+		s.checkIssues(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkIssues records what changed on each watched issue since the last
+// check. The first check of an issue only takes note of its current state.
+func (s *Server) checkIssues(ctx context.Context) {
+	prs, err := s.queries.ListWatchedPromptRequests(ctx)
+	if err != nil {
+		log.Printf("issue watch: %v", err)
+		return
+	}
+	if len(prs) == 0 {
+		return
+	}
+	// The user's own comments aren't news to them.
+	self, err := github.CurrentUser(ctx)
+	if err != nil {
+		log.Printf("issue watch: %v", err)
+	}
+	for _, pr := range prs {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := s.checkIssue(ctx, &pr, self); err != nil {
+			log.Printf("issue watch: issue #%d of %s: %v", *pr.IssueNumber, pr.RepoURL, err)
+		}
+	}
+}
+
+func (s *Server) checkIssue(ctx context.Context, pr *models.PromptRequest, self string) error {
+	activity, err := github.ViewIssueActivity(ctx, pr.RepoURL, *pr.IssueNumber)
+	if err != nil {
+		return err
+	}
+	last, err := s.queries.GetIssueWatch(ctx, pr.ID)
+	if err != nil {
+		return err
+	}
+	seen := models.IssueWatch{PromptRequestID: pr.ID, CommentCount: len(activity.Comments), State: activity.State}
+	for _, l := range activity.Labels {
+		seen.Labels = append(seen.Labels, l.Name)
+	}
+	var notifications []models.IssueNotification
+	if last != nil {
+		notifications = issueChanges(last, &seen, activity, self, issueURL(pr))
+	}
+	if err := s.queries.SaveIssueWatch(ctx, seen, notifications); err != nil {
+		return err
+	}
+	if len(notifications) > 0 {
+		if err := s.queries.RecordIssueActivity(ctx, pr.ID, time.Now()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// issueChanges lists what others changed on an issue between two checks.
+func issueChanges(last, seen *models.IssueWatch, activity *github.IssueActivity, self, url string) []models.IssueNotification {
+	var changes []models.IssueNotification
+	// Comments are only ever appended, unless deleted; then a count below the
+	// last one hides new comments until it catches up, which is rare enough.
+	if last.CommentCount < len(activity.Comments) {
+		for _, c := range activity.Comments[last.CommentCount:] {
+			if c.Author.Login == self {
+				continue
+			}
+			excerpt := []rune(strings.TrimSpace(c.Body))
+			if len(excerpt) > maxCommentExcerpt {
+				excerpt = append(excerpt[:maxCommentExcerpt-1], '…')
+			}
+			changes = append(changes, models.IssueNotification{Kind: "comment", Author: c.Author.Login, Detail: string(excerpt), URL: c.URL})
+		}
+	}
+	var added []string
+	for _, l := range seen.Labels {
+		if !slices.Contains(last.Labels, l) {
+			added = append(added, l)
+		}
+	}
+	if len(added) > 0 {
+		changes = append(changes, models.IssueNotification{Kind: "labeled", Detail: strings.Join(added, ", "), URL: url})
+	}
+	switch {
+	case last.State == "OPEN" && seen.State == "CLOSED":
+		changes = append(changes, models.IssueNotification{Kind: "closed", URL: url})
+	case last.State == "CLOSED" && seen.State == "OPEN":
+		changes = append(changes, models.IssueNotification{Kind: "reopened", URL: url})
+	}
+	return changes
+}
+
+// issueURL links to a prompt request's published issue.
+func issueURL(pr *models.PromptRequest) string {
+	if pr.IssueURL != nil {
+		return *pr.IssueURL
+	}
+	return fmt.Sprintf("https://%s/issues/%d", pr.RepoURL, *pr.IssueNumber)
+}
+
+type notificationsData struct {
+	basePageData
+	Notifications []models.IssueNotification
+	Watching      bool // the issue watch is enabled
+}
+
+// handleNotificationsPage lists the changes found on published issues,
+// newest first, and marks them read.
+func (s *Server) handleNotificationsPage(w http.ResponseWriter, r *http.Request) {
+	notifications, err := s.queries.ListIssueNotifications(r.Context(), notificationsPageSize)
+	if err != nil {
+		log.Printf("listing issue notifications: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if err := s.queries.MarkIssueNotificationsRead(r.Context()); err != nil {
+		log.Printf("marking issue notifications read: %v", err)
+	}
+	s.renderPage(w, "notifications.html", notificationsData{
+		basePageData:  basePageData{Sidebar: s.buildAllSidebar(r.Context(), sidebarPageSize)},
+		Notifications: notifications,
+		Watching:      s.cfg.IssueWatchInterval > 0,
+	})
+}
@@ -0,0 +1,44 @@
+package server
+
+import (
+	"github.com/esnunes/prompter/internal/claude"
+)
+
+// promptKind is a kind of prompt request offered when creating one. Each
+// has its own system prompt and response schema, and a default issue body.
+type promptKind struct {
+	Value        string
+	Label        string
+	Badge        string // shown next to prompt requests of this kind; "" for none
+	BodyTemplate string // default issue body; "" for defaultIssueBodyTemplate
+}
+
+// promptKinds are the kinds of prompt request, in the order offered. The
+// first is the default.
+var promptKinds = []promptKind{
+	{Value: string(claude.KindFeature), Label: "Feature request"},
+	{Value: string(claude.KindBug), Label: "Bug report", Badge: "bug",
+		BodyTemplate: kindIssueBodyTemplate("Summary", "Copy report")},
+	{Value: string(claude.KindRefactor), Label: "Refactoring proposal", Badge: "refactor",
+		BodyTemplate: kindIssueBodyTemplate("Why", "Copy proposal")},
+	{Value: string(claude.KindDocs), Label: "Documentation request", Badge: "docs",
+		BodyTemplate: kindIssueBodyTemplate("Why", "Copy request")},
+	{Value: string(claude.KindPerformance), Label: "Performance problem", Badge: "performance",
+		BodyTemplate: kindIssueBodyTemplate("Impact", "Copy report")},
+}
+
+// lookupPromptKind returns the kind with the given value.
+func lookupPromptKind(value string) (promptKind, bool) {
+	for _, k := range promptKinds {
+		if k.Value == value {
+			return k, true
+		}
+	}
+	return promptKind{}, false
+}
+
+// kindBadge is the badge of prompt requests of kind value, or "".
+func kindBadge(value string) string {
+	k, _ := lookupPromptKind(value)
+	return k.Badge
+}
@@ -0,0 +1,145 @@
+package server
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/esnunes/prompter/internal/db"
+	"github.com/esnunes/prompter/internal/github"
+	"github.com/esnunes/prompter/internal/models"
+)
+
+// labelsMaxAge is how long a repository's labels are reused before being
+// listed again.
+const labelsMaxAge = 5 * time.Minute
+
+// labelsCache holds the labels of each repository, which every render of
+// the publish preview and every new session reads.
+type labelsCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedLabels // by repository URL
+}
+
+type cachedLabels struct {
+	labels    []github.Label
+	fetchedAt time.Time
+}
+
+// repoLabels returns the repository's labels, listing them again when the
+// cached copy is older than labelsMaxAge. Failures aren't cached.
+func (s *Server) repoLabels(ctx context.Context, repoURL string) ([]github.Label, error) {
+	c := &s.labelsCache
+	c.mu.Lock()
+	entry, ok := c.entries[repoURL]
+	c.mu.Unlock()
+	if ok && time.Since(entry.fetchedAt) < labelsMaxAge {
+		return entry.labels, nil
+	}
+	labels, err := github.ListLabels(ctx, repoURL)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	if c.entries == nil {
+		c.entries = make(map[string]cachedLabels)
+	}
+	c.entries[repoURL] = cachedLabels{labels: labels, fetchedAt: time.Now()}
+	c.mu.Unlock()
+	return labels, nil
+}
+
+// labelChoice is a label Claude suggested, shown as a chip in the publish
+// preview.
+type labelChoice struct {
+	Name    string
+	Applied bool // not dismissed by the user
+}
+
+// suggestedLabels returns the labels Claude suggested with gc that exist in
+// the repository. Without access to the repository's labels there are none.
+func (s *Server) suggestedLabels(ctx context.Context, pr *models.PromptRequest, gc *db.GeneratedContent) []labelChoice {
+	if len(gc.Labels) == 0 {
+		return nil
+	}
+	existing, err := s.repoLabels(ctx, pr.RepoURL)
+	if err != nil {
+		log.Printf("listing labels of %s: %v", pr.RepoURL, err)
+		return nil
+	}
+	var choices []labelChoice
+	for _, name := range gc.Labels {
+		// Claude may get the case wrong; GitHub's label names win.
+		i := slices.IndexFunc(existing, func(l github.Label) bool { return strings.EqualFold(l.Name, name) })
+		if i < 0 || slices.ContainsFunc(choices, func(c labelChoice) bool { return c.Name == existing[i].Name }) {
+			continue
+		}
+		choices = append(choices, labelChoice{Name: existing[i].Name, Applied: !slices.Contains(pr.DismissedLabels, existing[i].Name)})
+	}
+	return choices
+}
+
+// labelsPrompt lists the repository's labels to a new Claude session, so it
+// can suggest some for the issue.
+func labelsPrompt(labels []github.Label) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("The repository's issue labels, for \"suggested_labels\" once the prompt is ready:\n\n<labels>\n")
+	for _, l := range labels {
+		b.WriteString(l.Name)
+		if l.Description != "" {
+			b.WriteString(": " + l.Description)
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("</labels>\n\n")
+	return b.String()
+}
+
+// handleIssueLabel applies or dismisses one suggested label, then re-renders
+// the publish preview.
+func (s *Server) handleIssueLabel(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	pr, err := s.queries.GetPromptRequest(r.Context(), id)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	name := r.FormValue("label")
+	if name == "" {
+		http.Error(w, "Missing label.", http.StatusBadRequest)
+		return
+	}
+	dismissed := slices.DeleteFunc(pr.DismissedLabels, func(l string) bool { return l == name })
+	if r.FormValue("apply") != "1" {
+		dismissed = append(dismissed, name)
+	}
+	if err := s.queries.SetPromptRequestDismissedLabels(r.Context(), id, dismissed); err != nil {
+		log.Printf("updating labels of prompt request %d: %v", id, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	s.handlePublishPreview(w, r)
+}
+
+// appliedLabels names the suggested labels to put on a new issue.
+func appliedLabels(choices []labelChoice) []string {
+	var names []string
+	for _, c := range choices {
+		if c.Applied {
+			names = append(names, c.Name)
+		}
+	}
+	return names
+}
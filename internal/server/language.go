@@ -0,0 +1,70 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/esnunes/prompter/internal/models"
+)
+
+// maxLanguageLength bounds a language name, which goes into the system prompt.
+const maxLanguageLength = 40
+
+// commonLanguages are suggested in the language inputs; any name is accepted.
+var commonLanguages = []string{
+	"English", "Español", "Português", "Français", "Deutsch", "Italiano",
+	"Nederlands", "Polski", "Русский", "Українська", "Türkçe",
+	"日本語", "한국어", "简体中文", "繁體中文", "हिन्दी", "العربية",
+}
+
+// languagePrompt extends the system prompt with the languages chosen for a
+// prompt request. Like the agent instructions it is sent on every turn, so a
+// change applies from the next message.
+func languagePrompt(pr *models.PromptRequest) string {
+	var b strings.Builder
+	if pr.ConversationLanguage != "" {
+		fmt.Fprintf(&b, "Talk with the contributor in %s: write \"message\", the questions, their options and \"ambiguities\" in %s, whatever language they write in.\n", pr.ConversationLanguage, pr.ConversationLanguage)
+	}
+	if pr.OutputLanguage != "" {
+		fmt.Fprintf(&b, "Write \"generated_title\", \"generated_motivation\", \"generated_prompt\", the \"glossary\" definitions, \"test_plan\", \"non_goals\", \"alternatives_considered\" and \"size_rationale\" in %s, the maintainers' language, whatever language the conversation is in.\n", pr.OutputLanguage)
+	}
+	return b.String()
+}
+
+// handleLanguages sets the languages of the conversation and of the
+// generated issue.
+func (s *Server) handleLanguages(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	if _, err := s.queries.GetPromptRequest(r.Context(), id); err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	conversation := strings.TrimSpace(r.FormValue("conversation_language"))
+	output := strings.TrimSpace(r.FormValue("output_language"))
+	for _, name := range []string{conversation, output} {
+		if len([]rune(name)) > maxLanguageLength {
+			http.Error(w, fmt.Sprintf("Language names must be at most %d characters.", maxLanguageLength), http.StatusBadRequest)
+			return
+		}
+		if strings.ContainsFunc(name, unicode.IsControl) {
+			http.Error(w, "Language names must be a single line.", http.StatusBadRequest)
+			return
+		}
+	}
+	if err := s.queries.SetPromptRequestLanguages(r.Context(), id, conversation, output); err != nil {
+		log.Printf("setting languages of prompt request %d: %v", id, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	// Clear any earlier error.
+	w.WriteHeader(http.StatusOK)
+}
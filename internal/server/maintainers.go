@@ -0,0 +1,176 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"maps"
+	"net/http"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/esnunes/prompter/internal/db"
+	"github.com/esnunes/prompter/internal/github"
+	"github.com/esnunes/prompter/internal/models"
+	"github.com/esnunes/prompter/internal/repo"
+)
+
+const (
+	maxMaintainerPaths = 5  // of the paths Claude named, how many are looked up
+	maxMaintainers     = 4  // suggestions shown, besides those already mentioned
+	pathAuthorCommits  = 20 // latest commits per path whose authors count
+
+	// maintainersMaxAge is how long the maintainers suggested for a generated
+	// prompt are reused before being looked up again.
+	maintainersMaxAge = time.Hour
+)
+
+// mentionPattern matches a GitHub user ("@login") or team ("@org/team").
+var mentionPattern = regexp.MustCompile(`^@[A-Za-z0-9](?:[A-Za-z0-9-]*[A-Za-z0-9])?(?:/[A-Za-z0-9._-]+)?$`)
+
+// maintainerChoice is a maintainer suggested for an @-mention, shown as a
+// chip in the publish preview. Nobody is mentioned until the user says so.
+type maintainerChoice struct {
+	Login     string // "@user" or "@org/team"
+	Reason    string // why they were suggested
+	Mentioned bool
+}
+
+// maintainersCache holds the maintainers looked up for each generated
+// prompt, so re-rendering the publish preview doesn't call GitHub again.
+type maintainersCache struct {
+	mu      sync.Mutex
+	entries map[int64]cachedMaintainers // by generated content message ID
+}
+
+type cachedMaintainers struct {
+	choices   []maintainerChoice
+	fetchedAt time.Time
+}
+
+// suggestedMaintainers returns who to mention in the issue: the CODEOWNERS
+// of the paths Claude believes the request touches, then those who
+// committed to them most lately. The user themselves is left out, and
+// maintainers already mentioned are always listed so they can be removed.
+func (s *Server) suggestedMaintainers(ctx context.Context, pr *models.PromptRequest, gc *db.GeneratedContent) []maintainerChoice {
+	c := &s.maintainersCache
+	c.mu.Lock()
+	entry, ok := c.entries[gc.MessageID]
+	c.mu.Unlock()
+	if !ok || time.Since(entry.fetchedAt) >= maintainersMaxAge {
+		entry = cachedMaintainers{choices: lookUpMaintainers(ctx, pr, gc), fetchedAt: time.Now()}
+		c.mu.Lock()
+		if c.entries == nil {
+			c.entries = make(map[int64]cachedMaintainers)
+		}
+		maps.DeleteFunc(c.entries, func(_ int64, e cachedMaintainers) bool { return time.Since(e.fetchedAt) >= maintainersMaxAge })
+		c.entries[gc.MessageID] = entry
+		c.mu.Unlock()
+	}
+
+	choices := slices.Clone(entry.choices)
+	for _, login := range pr.Mentions {
+		if !slices.ContainsFunc(choices, func(c maintainerChoice) bool { return strings.EqualFold(c.Login, login) }) {
+			choices = append(choices, maintainerChoice{Login: login, Reason: "mentioned"})
+		}
+	}
+	for i := range choices {
+		choices[i].Mentioned = slices.Contains(pr.Mentions, choices[i].Login)
+	}
+	return choices
+}
+
+// lookUpMaintainers finds the maintainers to suggest for gc's paths.
+func lookUpMaintainers(ctx context.Context, pr *models.PromptRequest, gc *db.GeneratedContent) []maintainerChoice {
+	var choices []maintainerChoice
+	add := func(login, reason string) {
+		if !slices.ContainsFunc(choices, func(c maintainerChoice) bool { return strings.EqualFold(c.Login, login) }) {
+			choices = append(choices, maintainerChoice{Login: login, Reason: reason})
+		}
+	}
+
+	paths := gc.Paths[:min(len(gc.Paths), maxMaintainerPaths)]
+	if len(paths) > 0 {
+		me, err := github.CurrentUser(ctx)
+		if err != nil {
+			log.Printf("suggesting maintainers: %v", err)
+		}
+		isMe := func(login string) bool { return me != "" && strings.EqualFold(login, "@"+me) }
+
+		rules, err := repo.Codeowners(pr.RepoLocalPath)
+		if err != nil {
+			log.Printf("reading CODEOWNERS of %s: %v", pr.RepoURL, err)
+		}
+		for _, p := range paths {
+			for _, owner := range repo.Owners(rules, p) {
+				if !isMe(owner) {
+					add(owner, "code owner of "+p)
+				}
+			}
+		}
+
+		commits := map[string]int{}
+		var authors []string
+		for _, p := range paths {
+			logins, err := github.PathAuthors(ctx, pr.RepoURL, p, pathAuthorCommits)
+			if err != nil {
+				log.Printf("suggesting maintainers: %v", err)
+				continue
+			}
+			for _, l := range logins {
+				if commits[l] == 0 {
+					authors = append(authors, l)
+				}
+				commits[l]++
+			}
+		}
+		sort.SliceStable(authors, func(i, j int) bool { return commits[authors[i]] > commits[authors[j]] })
+		for _, l := range authors {
+			if !isMe("@" + l) {
+				add("@"+l, fmt.Sprintf("%d of the latest commits to this code", commits[l]))
+			}
+		}
+		choices = choices[:min(len(choices), maxMaintainers)]
+	}
+	return choices
+}
+
+// mentionsLine asks the mentioned maintainers to take a look.
+func mentionsLine(logins []string) string {
+	return "cc " + strings.Join(logins, " ")
+}
+
+// handleMention adds or removes one maintainer from the issue's mentions,
+// then re-renders the publish preview.
+func (s *Server) handleMention(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	pr, err := s.queries.GetPromptRequest(r.Context(), id)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	login := r.FormValue("login")
+	if !mentionPattern.MatchString(login) {
+		http.Error(w, "Invalid user or team.", http.StatusBadRequest)
+		return
+	}
+	mentions := slices.DeleteFunc(pr.Mentions, func(l string) bool { return l == login })
+	if r.FormValue("mention") == "1" {
+		mentions = append(mentions, login)
+	}
+	if err := s.queries.SetPromptRequestMentions(r.Context(), id, mentions); err != nil {
+		log.Printf("updating mentions of prompt request %d: %v", id, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	s.handlePublishPreview(w, r)
+}
@@ -0,0 +1,36 @@
+package server
+
+import (
+	"bytes"
+	"html/template"
+	"log"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+// markdownParser renders GitHub-flavored Markdown, as replies and generated
+// prompts are written for GitHub issues.
+var markdownParser = goldmark.New(goldmark.WithExtensions(extension.GFM))
+
+// markdownPolicy keeps the formatting Markdown produces and drops anything
+// that could run script, since replies echo text from issues and users.
+var markdownPolicy = func() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.AllowAttrs("class").Matching(bluemonday.SpaceSeparatedTokens).OnElements("code")
+	p.AllowAttrs("type", "checked", "disabled").OnElements("input")
+	p.AddTargetBlankToFullyQualifiedLinks(true)
+	return p
+}()
+
+// renderMarkdown renders Markdown to sanitized HTML, falling back to the
+// escaped text if it can't be rendered.
+func renderMarkdown(text string) template.HTML {
+	var buf bytes.Buffer
+	if err := markdownParser.Convert([]byte(text), &buf); err != nil {
+		log.Printf("rendering markdown: %v", err)
+		return template.HTML(template.HTMLEscapeString(text))
+	}
+	return template.HTML(markdownPolicy.SanitizeBytes(buf.Bytes()))
+}
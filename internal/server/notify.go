@@ -0,0 +1,34 @@
+package server
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/esnunes/prompter/gotk"
+	"github.com/esnunes/prompter/internal/models"
+)
+
+// maxNotificationBody bounds the reply excerpt shown in a notification.
+const maxNotificationBody = 140
+
+// pushResponseNotification tells every open page that Claude finished
+// responding for pr, so pages the user tabbed away from can raise a browser
+// notification linking back to the conversation.
+func (s *Server) pushResponseNotification(pr *models.PromptRequest, message string, failed bool) {
+	title := pr.Title
+	if title == "" {
+		title = "Untitled prompt request"
+	}
+	body := []rune(message)
+	if len(body) > maxNotificationBody {
+		body = append(body[:maxNotificationBody-1], '…')
+	}
+	s.pushAll([]gotk.Instruction{{Op: "exec", Name: "notifyResponse", Args: map[string]any{
+		"id":    pr.ID,
+		"key":   fmt.Sprintf("%d-%d", pr.ID, time.Now().UnixNano()), // the same in every tab that receives the event
+		"title": title,
+		"body":  string(body),
+		"url":   fmt.Sprintf("/%s/prompt-requests/%d", pr.RepoURL, pr.ID),
+		"error": failed,
+	}}})
+}
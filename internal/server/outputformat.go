@@ -0,0 +1,56 @@
+package server
+
+import "github.com/esnunes/prompter/internal/models"
+
+// outputFormat is a way of writing the generated prompt of feature requests
+// that a repository can ask for.
+type outputFormat struct {
+	Value   string
+	Label   string
+	Heading string // of the prompt in the default issue body
+	prompt  string // tells Claude how to write the prompt
+}
+
+var outputFormats = []outputFormat{
+	{Value: "", Label: "Plain prompt", Heading: "Prompt"},
+	{Value: "stories", Label: "User stories", Heading: "User stories",
+		prompt: "This repository triages feature requests as user stories. Write \"generated_prompt\" as a short overview followed by user stories, " +
+			"each \"As a <kind of user>, I want <capability>, so that <benefit>.\" with a bulleted list of its acceptance criteria. " +
+			"Every story and criterion must come from the conversation.\n"},
+	{Value: "gherkin", Label: "Given/When/Then scenarios", Heading: "Scenarios",
+		prompt: "This repository triages feature requests as acceptance scenarios. Write \"generated_prompt\" as a short overview followed by a ```gherkin code block " +
+			"with a Feature and its Scenarios in Given/When/Then steps, covering the main flow and the edge cases the contributor confirmed. " +
+			"Every scenario must come from the conversation.\n"},
+}
+
+func lookupOutputFormat(value string) (outputFormat, bool) {
+	for _, f := range outputFormats {
+		if f.Value == value {
+			return f, true
+		}
+	}
+	return outputFormat{}, false
+}
+
+// outputFormatPrompt extends the system prompt of feature requests with the
+// repository's output format; the other kinds have layouts of their own. Like
+// the languages it is sent on every turn, so a change applies from the next
+// message.
+func outputFormatPrompt(pr *models.PromptRequest) string {
+	if pr.Kind != "" {
+		return ""
+	}
+	f, _ := lookupOutputFormat(pr.RepoOutputFormat)
+	return f.prompt
+}
+
+// testPlanPrompt asks Claude for a test plan when the repository wants one.
+// Like the output format it is sent on every turn.
+func testPlanPrompt(pr *models.PromptRequest) string {
+	if !pr.RepoTestPlan {
+		return ""
+	}
+	return "The maintainers of this repository want a verification plan with each request. With the generated fields, include \"test_plan\": " +
+		"a few short steps a maintainer can follow to confirm the request works once built, covering the behavior and edge cases the contributor confirmed, " +
+		"written from a user's point of view so their coding agents can turn them into tests.\n"
+}
@@ -0,0 +1,227 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/esnunes/prompter/internal/models"
+)
+
+// Bounds of a user-defined persona.
+const (
+	maxPersonaName         = 60
+	maxPersonaInstructions = 4000
+)
+
+// personaPrompt extends the system prompt with the instructions of the
+// prompt request's persona. Like the question mode it is sent on every
+// turn, so a change applies from the next message.
+func (s *Server) personaPrompt(ctx context.Context, pr *models.PromptRequest) string {
+	if pr.PersonaID == nil {
+		return ""
+	}
+	p, err := s.queries.GetPersona(ctx, *pr.PersonaID)
+	if err != nil {
+		log.Printf("%v", err)
+		return ""
+	}
+	return fmt.Sprintf("The contributor chose the %q persona for this conversation. Follow its instructions in how you ask "+
+		"questions and phrase the generated fields, as long as they don't conflict with the response format:\n"+
+		"<persona>\n%s\n</persona>\n", p.Name, p.Instructions)
+}
+
+// personas lists the personas a conversation can follow; a failed listing
+// offers none.
+func (s *Server) personas(ctx context.Context) []models.Persona {
+	personas, err := s.queries.ListPersonas(ctx)
+	if err != nil {
+		log.Printf("%v", err)
+	}
+	return personas
+}
+
+// defaultPersona is the persona new prompt requests start with, or 0 for
+// none.
+func (s *Server) defaultPersona(ctx context.Context) int64 {
+	v, err := s.queries.Preference(ctx, prefDefaultPersona)
+	if err != nil {
+		log.Printf("%v", err)
+	}
+	id, _ := strconv.ParseInt(v, 10, 64)
+	return id
+}
+
+// applyDefaultPersona gives a new prompt request the default persona, if
+// one is set and still exists.
+func (s *Server) applyDefaultPersona(ctx context.Context, prID int64) {
+	id := s.defaultPersona(ctx)
+	if id == 0 {
+		return
+	}
+	if _, err := s.queries.GetPersona(ctx, id); err != nil {
+		return
+	}
+	if err := s.queries.SetPromptRequestPersona(ctx, prID, &id); err != nil {
+		log.Printf("setting default persona of prompt request %d: %v", prID, err)
+	}
+}
+
+// parsePersonaID reads an optional persona ID form value; "" is none.
+func (s *Server) parsePersonaID(ctx context.Context, value string) (id *int64, ok bool) {
+	if value == "" {
+		return nil, true
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return nil, false
+	}
+	if _, err := s.queries.GetPersona(ctx, n); err != nil {
+		return nil, false
+	}
+	return &n, true
+}
+
+// handlePersona sets the persona a prompt request's conversation follows.
+func (s *Server) handlePersona(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	if _, err := s.queries.GetPromptRequest(r.Context(), id); err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	personaID, ok := s.parsePersonaID(r.Context(), r.FormValue("persona"))
+	if !ok {
+		http.Error(w, "Unknown persona.", http.StatusBadRequest)
+		return
+	}
+	if err := s.queries.SetPromptRequestPersona(r.Context(), id, personaID); err != nil {
+		log.Printf("setting persona of prompt request %d: %v", id, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	// Clear any earlier error.
+	w.WriteHeader(http.StatusOK)
+}
+
+// readPersonaForm validates the name and instructions of a persona being
+// created or edited, returning a user-facing message if they're unusable.
+func (s *Server) readPersonaForm(ctx context.Context, r *http.Request, editing int64) (name, instructions, invalid string) {
+	name = strings.TrimSpace(r.FormValue("name"))
+	instructions = strings.TrimSpace(strings.ReplaceAll(r.FormValue("instructions"), "\r\n", "\n"))
+	switch {
+	case name == "" || instructions == "":
+		return "", "", "A persona needs a name and instructions."
+	case utf8.RuneCountInString(name) > maxPersonaName:
+		return "", "", fmt.Sprintf("Persona names must be at most %d characters.", maxPersonaName)
+	case utf8.RuneCountInString(instructions) > maxPersonaInstructions:
+		return "", "", fmt.Sprintf("Persona instructions must be at most %d characters.", maxPersonaInstructions)
+	}
+	personas, err := s.queries.ListPersonas(ctx)
+	if err != nil {
+		log.Printf("%v", err)
+	}
+	for _, p := range personas {
+		if p.ID != editing && strings.EqualFold(p.Name, name) {
+			return "", "", fmt.Sprintf("There already is a persona named %q.", p.Name)
+		}
+	}
+	return name, instructions, ""
+}
+
+// handleCreatePersona adds a user-defined persona.
+func (s *Server) handleCreatePersona(w http.ResponseWriter, r *http.Request) {
+	name, instructions, invalid := s.readPersonaForm(r.Context(), r, 0)
+	if invalid != "" {
+		http.Error(w, invalid, http.StatusBadRequest)
+		return
+	}
+	if _, err := s.queries.CreatePersona(r.Context(), name, instructions); err != nil {
+		log.Printf("%v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	settingsRedirect(w, r)
+}
+
+// handleUpdatePersona edits a user-defined persona. Conversations using it
+// follow the new instructions from their next message.
+func (s *Server) handleUpdatePersona(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	name, instructions, invalid := s.readPersonaForm(r.Context(), r, id)
+	if invalid != "" {
+		http.Error(w, invalid, http.StatusBadRequest)
+		return
+	}
+	if err := s.queries.UpdatePersona(r.Context(), id, name, instructions); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Not Found", http.StatusNotFound)
+			return
+		}
+		log.Printf("%v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	settingsRedirect(w, r)
+}
+
+// handleDeletePersona deletes a user-defined persona. Prompt requests using
+// it go back to none.
+func (s *Server) handleDeletePersona(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	if err := s.queries.DeletePersona(r.Context(), id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Not Found", http.StatusNotFound)
+			return
+		}
+		log.Printf("%v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	settingsRedirect(w, r)
+}
+
+// handleDefaultPersona saves the persona new prompt requests start with.
+func (s *Server) handleDefaultPersona(w http.ResponseWriter, r *http.Request) {
+	value := r.FormValue("persona")
+	if _, ok := s.parsePersonaID(r.Context(), value); !ok {
+		http.Error(w, "Unknown persona.", http.StatusBadRequest)
+		return
+	}
+	if err := s.queries.SetPreference(r.Context(), prefDefaultPersona, value); err != nil {
+		log.Printf("%v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	// Clear any earlier error.
+	w.WriteHeader(http.StatusOK)
+}
+
+// settingsRedirect reloads the settings page after a change to the list of
+// personas.
+func settingsRedirect(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("HX-Request") == "true" {
+		w.Header().Set("HX-Redirect", "/settings")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	http.Redirect(w, r, "/settings", http.StatusSeeOther)
+}
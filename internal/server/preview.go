@@ -0,0 +1,203 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/esnunes/prompter/internal/db"
+	"github.com/esnunes/prompter/internal/models"
+)
+
+type publishPreviewData struct {
+	Org             string
+	Repo            string
+	PromptRequestID int64
+	MessageID       int64           // assistant message whose generated prompt is previewed
+	Versions        []promptVersion // every generated prompt, oldest first
+	Title           string          // issue title, or "" when publishing a comment
+	Body            string
+	Action          string // what publishing will do on GitHub
+
+	IncludeTranscript bool
+	IncludeAreas      bool
+	HasAreas          bool // the previewed prompt came with areas likely affected
+	LinkRelated       bool
+	Related           []models.RelatedIssue // issues the "Related:" line would link
+	Labels            []labelChoice         // suggested labels, when publishing opens a new issue
+	Maintainers       []maintainerChoice    // who could be @-mentioned
+
+	Clarity     int      // the AI's 1 to 5 rating of the prompt, or 0 if not rated
+	LowClarity  bool     // the rating is low enough to warn before publishing
+	Ambiguities []string // points the AI considers still open
+
+	Checks       []promptCheckRow // quality checklist verdicts; none until checked
+	ChecksPassed bool
+	CheckError   string // why the quality check couldn't run
+}
+
+// lowClarityScore is the highest clarity rating the publish preview warns
+// about.
+const lowClarityScore = 3
+
+type promptVersion struct {
+	Number    int
+	MessageID int64
+	Title     string
+	CreatedAt time.Time
+}
+
+// handlePublishPreview renders the exact issue that publishing would create
+// or update, so it can be checked before the irreversible step. When the AI
+// generated several prompts, ?message_id= picks the one to publish; it
+// defaults to the latest. Images not yet uploaded are shown from their local
+// copies.
+func (s *Server) handlePublishPreview(w http.ResponseWriter, r *http.Request) {
+	s.publishPreview(w, r, "")
+}
+
+// publishPreview renders the publish preview, with checkError explaining
+// why the quality check couldn't run, if it didn't.
+func (s *Server) publishPreview(w http.ResponseWriter, r *http.Request, checkError string) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	pr, err := s.queries.GetPromptRequest(r.Context(), id)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	versions, err := s.queries.ListGeneratedContents(r.Context(), id)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if len(versions) == 0 {
+		http.Error(w, "No generated prompt found. Continue the conversation until the AI generates a prompt.", http.StatusBadRequest)
+		return
+	}
+	gc := &versions[len(versions)-1]
+	if messageID, _ := strconv.ParseInt(r.URL.Query().Get("message_id"), 10, 64); messageID != 0 {
+		i := slices.IndexFunc(versions, func(v db.GeneratedContent) bool { return v.MessageID == messageID })
+		if i < 0 {
+			http.Error(w, "The selected prompt version no longer exists. Pick another one.", http.StatusBadRequest)
+			return
+		}
+		gc = &versions[i]
+	}
+	body, err := s.previewIssueBody(pr, gc)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	data := publishPreviewData{
+		Org:             r.PathValue("org"),
+		Repo:            r.PathValue("repo"),
+		PromptRequestID: id,
+		MessageID:       gc.MessageID,
+		Title:           s.issueTitle(pr, publishTitle(pr, gc)),
+		Body:            body,
+
+		IncludeTranscript: pr.IncludeTranscript,
+		IncludeAreas:      pr.IncludeAffectedAreas,
+		HasAreas:          len(gc.Areas) > 0,
+		LinkRelated:       pr.LinkRelatedIssues,
+
+		Clarity:     gc.Clarity,
+		LowClarity:  gc.Clarity != 0 && gc.Clarity <= lowClarityScore,
+		Ambiguities: gc.Ambiguities,
+
+		CheckError: checkError,
+	}
+	if data.Checks, data.ChecksPassed, err = s.promptChecks(r.Context(), gc.MessageID); err != nil {
+		log.Printf("listing quality checks of prompt request %d: %v", id, err)
+	}
+	if data.Related, err = s.relatedIssues(r.Context(), pr); err != nil {
+		log.Printf("listing related issues of prompt request %d: %v", id, err)
+	}
+	data.Maintainers = s.suggestedMaintainers(r.Context(), pr, gc)
+	for i, v := range versions {
+		data.Versions = append(data.Versions, promptVersion{Number: i + 1, MessageID: v.MessageID, Title: v.Title, CreatedAt: v.CreatedAt})
+	}
+	switch {
+	case pr.SourceIssueNumber != nil && pr.PublishTarget == "comment":
+		data.Title = ""
+		data.Action = fmt.Sprintf("Publishing adds this as a comment on issue #%d.", *pr.SourceIssueNumber)
+	case pr.IssueNumber != nil:
+		data.Action = fmt.Sprintf("Publishing replaces the description of issue #%d; its title is kept.", *pr.IssueNumber)
+	case pr.SourceIssueNumber != nil && pr.PublishTarget == "edit":
+		data.Action = fmt.Sprintf("Publishing replaces the description of issue #%d; its title is kept.", *pr.SourceIssueNumber)
+	default:
+		data.Action = fmt.Sprintf("Publishing opens a new issue in %s.", pr.RepoURL)
+		data.Labels = s.suggestedLabels(r.Context(), pr, gc)
+	}
+	s.renderFragment(w, "publish_preview_fragment.html", data)
+}
+
+// handleExportMarkdown returns the exact issue body as Markdown, for pasting
+// somewhere other than GitHub Issues, and marks the prompt request exported.
+// Images are uploaded first so the copied links work anywhere.
+func (s *Server) handleExportMarkdown(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	pr, err := s.queries.GetPromptRequest(r.Context(), id)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	messageID, _ := strconv.ParseInt(r.FormValue("message_id"), 10, 64)
+	gc, err := s.generatedContent(r.Context(), id, messageID)
+	if errors.Is(err, errNoGeneratedPrompt) {
+		http.Error(w, "No generated prompt found. Continue the conversation until the AI generates a prompt.", http.StatusBadRequest)
+		return
+	} else if err != nil {
+		http.Error(w, "The selected prompt version no longer exists. Pick another one.", http.StatusBadRequest)
+		return
+	}
+	images, err := s.publishAttachments(r.Context(), id)
+	if err != nil {
+		log.Printf("uploading attachments for prompt request %d: %v", id, err)
+		http.Error(w, "Couldn't upload the attached images to GitHub. Try again.", http.StatusBadRequest)
+		return
+	}
+	body, err := s.composeIssueBody(r.Context(), pr, gc, images)
+	if err != nil {
+		log.Printf("composing issue body for prompt request %d: %v", id, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if err := s.queries.MarkPromptRequestExported(r.Context(), id); err != nil {
+		log.Printf("marking prompt request %d exported: %v", id, err)
+	}
+
+	w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+	io.WriteString(w, body)
+}
+
+// previewIssueBody composes the issue body for gc without uploading anything:
+// images not yet hosted on GitHub point at their local copies.
+func (s *Server) previewIssueBody(pr *models.PromptRequest, gc *db.GeneratedContent) (string, error) {
+	atts, err := s.activeAttachments(context.Background(), pr.ID)
+	if err != nil {
+		return "", err
+	}
+	images := attachmentsSection(atts, func(a models.Attachment) string {
+		if a.RemoteURL != nil {
+			return *a.RemoteURL
+		}
+		return fmt.Sprintf("/attachments/%d", a.ID)
+	})
+	return s.composeIssueBody(context.Background(), pr, gc, images)
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/esnunes/prompter/internal/claude"
+	"github.com/esnunes/prompter/internal/models"
+)
+
+// prewarmPrompt has a new session explore the repository before the user's
+// first message, which then resumes it.
+const prewarmPrompt = `The user hasn't written yet. Get familiar with this repository in the meantime, so you can answer their first message quickly: read the README, look at the layout, the main packages or modules and their conventions.
+
+Don't ask questions or generate a prompt yet. Reply with a single sentence saying you're ready.`
+
+// queuePrewarm queues starting the Claude session of a prompt request that
+// has no messages yet, if prewarming is enabled.
+func (s *Server) queuePrewarm(ctx context.Context, prID int64) {
+	if !s.cfg.Prewarm {
+		return
+	}
+	pr, err := s.queries.GetPromptRequest(ctx, prID)
+	if err != nil || pr.Prewarmed {
+		return
+	}
+	if _, err := s.queries.GetLastMessage(ctx, prID); err == nil {
+		return // the first message starts the session
+	}
+	s.enqueue(jobPrewarm, jobPayload{PromptRequestID: prID})
+}
+
+// runPrewarmJob starts a Claude session exploring the repository and moves
+// the prompt request to it, unless the conversation started meanwhile. It
+// holds the session lock, so a first message sent while it runs waits and
+// then resumes the prewarmed session.
+func (s *Server) runPrewarmJob(ctx context.Context, job *models.QueuedJob, p jobPayload) error {
+	pr, err := s.queries.GetPromptRequest(ctx, p.PromptRequestID)
+	if err != nil {
+		return err
+	}
+	unlock := s.lockSession(pr.SessionID)
+	defer unlock()
+	if _, err := s.queries.GetLastMessage(ctx, pr.ID); err == nil || pr.Prewarmed {
+		return nil
+	}
+
+	release, err := s.acquireClaude(ctx, pr.ID)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	sessionID := uuid.New().String()
+	started := time.Now()
+	_, _, err = claude.SendMessage(ctx, sessionID, pr.RepoLocalPath, s.sessionContext(ctx, pr)+prewarmPrompt,
+		systemPromptExtras(pr)+s.personaPrompt(ctx, pr), claude.Kind(pr.Kind), false)
+	if err != nil {
+		s.auditPR(pr, "claude", fmt.Sprintf("prewarming failed: %v", err), time.Since(started))
+		return err
+	}
+	s.auditPR(pr, "claude", "prewarmed the session", time.Since(started))
+
+	if ok, err := s.queries.StartPrewarmedSession(context.WithoutCancel(ctx), pr.ID, pr.SessionID, sessionID); err != nil {
+		return err
+	} else if !ok {
+		log.Printf("prewarm: PR %d changed meanwhile, dropping session %s", pr.ID, sessionID)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/esnunes/prompter/internal/github"
+	"github.com/esnunes/prompter/internal/models"
+)
+
+// project is where a repository's new issues are added: a GitHub Project or
+// classic project of owner, or the classic project of the repository
+// owner/repo when repo is set.
+type project struct {
+	owner, repo string
+	number      int
+}
+
+// parseProject parses a project reference: "OWNER/NUMBER" as in a GitHub
+// Project's URL (github.com/orgs/OWNER/projects/NUMBER), or
+// "OWNER/REPO/NUMBER" for a repository's classic project.
+func parseProject(ref string) (project, bool) {
+	parts := strings.Split(ref, "/")
+	if len(parts) < 2 || len(parts) > 3 {
+		return project{}, false
+	}
+	for _, p := range parts[:len(parts)-1] {
+		if p == "" || strings.Contains(p, " ") {
+			return project{}, false
+		}
+	}
+	number, err := strconv.Atoi(parts[len(parts)-1])
+	if err != nil || number <= 0 {
+		return project{}, false
+	}
+	p := project{owner: parts[0], number: number}
+	if len(parts) == 3 {
+		p.repo = parts[1]
+	}
+	return p, true
+}
+
+// projectRef accepts a project's URL or reference and returns the latter; an
+// empty input clears the project. Organization and user projects, GitHub
+// Projects or classic, share their URLs; repository projects are classic.
+func projectRef(input string) (string, bool) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return "", true
+	}
+	if _, path, ok := strings.Cut(input, "github.com/"); ok {
+		parts := strings.Split(path, "/")
+		switch {
+		// https://github.com/orgs/OWNER/projects/NUMBER/views/1 → OWNER/NUMBER
+		case len(parts) >= 4 && (parts[0] == "orgs" || parts[0] == "users") && parts[2] == "projects":
+			input = parts[1] + "/" + parts[3]
+		// https://github.com/OWNER/REPO/projects/NUMBER → OWNER/REPO/NUMBER
+		case len(parts) >= 4 && parts[2] == "projects":
+			input = parts[0] + "/" + parts[1] + "/" + parts[3]
+		default:
+			return "", false
+		}
+	}
+	if _, ok := parseProject(input); !ok {
+		return "", false
+	}
+	return input, true
+}
+
+// addToProject adds a newly created issue to the repository's project, if it
+// has one. Like labels it is best-effort: the issue exists already, and a
+// missing scope or project shouldn't fail the publish.
+func (s *Server) addToProject(ctx context.Context, pr *models.PromptRequest, issueURL string) {
+	p, ok := parseProject(pr.RepoProject)
+	if !ok {
+		return
+	}
+	var err error
+	if p.repo != "" {
+		err = github.AddToClassicProject(ctx, p.owner, p.repo, p.number, issueURL)
+	} else if err = github.AddToProject(ctx, p.owner, p.number, issueURL); errors.Is(err, github.ErrProjectNotFound) {
+		err = github.AddToClassicProject(ctx, p.owner, "", p.number, issueURL)
+	}
+	if err != nil {
+		log.Printf("warning: %v", err)
+		return
+	}
+	s.audit(pr.ID, "added-to-project", pr.RepoProject, 0)
+}
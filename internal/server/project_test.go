@@ -0,0 +1,31 @@
+package server
+
+import "testing"
+
+func TestProjectRef(t *testing.T) {
+	for _, tt := range []struct {
+		input, want string
+		ok          bool
+	}{
+		{"", "", true},
+		{"acme/5", "acme/5", true},
+		{"https://github.com/orgs/acme/projects/5/views/1", "acme/5", true},
+		{"https://github.com/users/jane/projects/2", "jane/2", true},
+		{"https://github.com/acme/widgets/projects/3", "acme/widgets/3", true},
+		{"acme/widgets/3", "acme/widgets/3", true},
+		{"https://github.com/acme/widgets/issues/3", "", false},
+		{"acme/widgets", "", false},
+		{"acme//3", "", false},
+		{"acme/0", "", false},
+	} {
+		got, ok := projectRef(tt.input)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("projectRef(%q) = %q, %v; want %q, %v", tt.input, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	p, ok := parseProject("acme/widgets/3")
+	if !ok || p != (project{owner: "acme", repo: "widgets", number: 3}) {
+		t.Errorf("parseProject = %+v, %v", p, ok)
+	}
+}
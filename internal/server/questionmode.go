@@ -0,0 +1,80 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/esnunes/prompter/internal/models"
+)
+
+// Question modes of a prompt request.
+const (
+	questionModeThorough = ""
+	questionModeQuick    = "quick"
+)
+
+// questionModePrompt extends the system prompt in quick mode, relaxing the
+// thorough questioning it asks for by default. Like the languages it is sent
+// on every turn, so a change applies from the next message.
+func questionModePrompt(pr *models.PromptRequest) string {
+	if pr.QuestionMode != questionModeQuick {
+		return ""
+	}
+	return "The contributor chose quick mode, which overrides the guidelines about being thorough. " +
+		"Ask at most 3 questions over the whole conversation, only ones whose answers change what gets built. " +
+		"For everything else, state the assumption you make in \"message\" instead of asking; " +
+		"an assumption the contributor doesn't object to counts as confirmed and may go in the prompt. " +
+		"Set \"prompt_ready\" as soon as the essentials are covered.\n"
+}
+
+// handleQuestionMode switches a prompt request between quick and thorough
+// questioning.
+func (s *Server) handleQuestionMode(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	if _, err := s.queries.GetPromptRequest(r.Context(), id); err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	mode := r.FormValue("mode")
+	if mode != questionModeThorough && mode != questionModeQuick {
+		http.Error(w, "Unknown question mode.", http.StatusBadRequest)
+		return
+	}
+	if err := s.queries.SetPromptRequestQuestionMode(r.Context(), id, mode); err != nil {
+		log.Printf("setting question mode of prompt request %d: %v", id, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	// Clear any earlier error.
+	w.WriteHeader(http.StatusOK)
+}
+
+// questionBudgetPrompt extends the system prompt with how many questions the
+// AI may still ask, when the question budget is set. Prompter counts the
+// questions, so the AI doesn't have to keep track across a long session.
+func (s *Server) questionBudgetPrompt(ctx context.Context, prID int64) string {
+	budget := s.questionBudget(ctx)
+	if budget == 0 {
+		return ""
+	}
+	asked, err := s.queries.CountQuestions(ctx, prID, 0)
+	if err != nil {
+		log.Printf("%v", err)
+		return ""
+	}
+	if left := budget - asked; left > 0 {
+		return fmt.Sprintf("You have asked %d of at most %d questions in this conversation; ask no more than %d more in total. "+
+			"Spend them on what matters most; what stays unclear goes in an \"Assumptions\" section at the end of the generated prompt.\n", asked, budget, left)
+	}
+	return fmt.Sprintf("You have asked all %d questions allowed in this conversation. Don't ask any more: "+
+		"set \"prompt_ready\" and generate the prompt now, ending it with an \"Assumptions\" section listing "+
+		"every point you had to decide without the contributor's answer.\n", budget)
+}
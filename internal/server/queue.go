@@ -0,0 +1,322 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/esnunes/prompter/internal/models"
+	"github.com/esnunes/prompter/internal/repo"
+)
+
+// Job kinds handled by the worker pool.
+const (
+	jobClone      = "clone"       // clone or pull the repository for a prompt request
+	jobClaudeSend = "claude-send" // send the pending user message to Claude
+	jobPublish    = "publish"     // retry a publish while GitHub is unreachable
+	jobPrewarm    = "prewarm"     // start the Claude session before the first message
+)
+
+// jobPayload is the JSON payload shared by all job kinds.
+type jobPayload struct {
+	PromptRequestID int64  `json:"prompt_request_id"`
+	RepoURL         string `json:"repo_url,omitempty"`
+	MessageID       int64  `json:"message_id,omitempty"` // generated prompt to publish; 0 for the latest
+	Overwrite       bool   `json:"overwrite,omitempty"`  // publish even if the issue was edited on GitHub
+}
+
+// jobHandler runs a single job. A returned error counts as a failed attempt.
+type jobHandler func(ctx context.Context, job *models.QueuedJob, p jobPayload) error
+
+// finalError wraps a job failure that retrying can't fix, so the job fails
+// without using its remaining attempts.
+type finalError struct{ error }
+
+func (e finalError) Unwrap() error { return e.error }
+
+// jobSpec configures how a job kind is retried.
+type jobSpec struct {
+	handler     jobHandler
+	maxAttempts int
+}
+
+func (s *Server) jobSpecs() map[string]jobSpec {
+	return map[string]jobSpec{
+		jobClone:      {handler: s.runCloneJob, maxAttempts: 3},
+		jobClaudeSend: {handler: s.runClaudeSendJob, maxAttempts: 1},
+		jobPublish:    {handler: s.runPublishJob, maxAttempts: 5},
+		jobPrewarm:    {handler: s.runPrewarmJob, maxAttempts: 1},
+	}
+}
+
+// enqueue adds a job for a prompt request and wakes an idle worker. Duplicate
+// jobs of the same kind for the same prompt request are ignored.
+func (s *Server) enqueue(kind string, p jobPayload) {
+	spec, ok := s.jobs[kind]
+	if !ok {
+		log.Printf("queue: unknown job kind %q", kind)
+		return
+	}
+	data, _ := json.Marshal(p)
+	ref := strconv.FormatInt(p.PromptRequestID, 10)
+	if _, err := s.queries.EnqueueJob(context.Background(), kind, ref, string(data), spec.maxAttempts); err != nil {
+		log.Printf("queue: enqueueing %s for PR %d: %v", kind, p.PromptRequestID, err)
+		return
+	}
+	select {
+	case s.queueWake <- struct{}{}:
+	default:
+	}
+}
+
+// queueEnsureCloned marks the prompt request as cloning/pulling and queues the work.
+func (s *Server) queueEnsureCloned(prID int64, repoURL string) {
+	status := stateCloning
+	if cloned, _ := repo.IsCloned(repoURL); cloned {
+		status = statePulling
+	}
+	if !s.setRepoStatus(prID, status, "") {
+		return
+	}
+	s.enqueue(jobClone, jobPayload{PromptRequestID: prID, RepoURL: repoURL})
+}
+
+// cloneQueuePosition reports whether the prompt request's clone/pull waits
+// for a free clone worker, and how many others are ahead of it.
+func (s *Server) cloneQueuePosition(ctx context.Context, prID int64) (waiting bool, ahead int) {
+	waiting, ahead, err := s.queries.QueuedJobsAhead(ctx, jobClone, strconv.FormatInt(prID, 10))
+	if err != nil {
+		log.Printf("queue: %v", err)
+	}
+	return waiting, ahead
+}
+
+// acquireClaude waits for a free claude process slot, showing the prompt
+// request as queued meanwhile. The returned func releases the slot.
+func (s *Server) acquireClaude(ctx context.Context, prID int64) (release func(), err error) {
+	select {
+	case s.claudeSlots <- struct{}{}:
+	default:
+		s.claudeWaiting.Store(prID, struct{}{})
+		defer s.claudeWaiting.Delete(prID)
+		select {
+		case s.claudeSlots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return func() { <-s.claudeSlots }, nil
+}
+
+// waitingForClaude reports whether the prompt request's message is queued
+// rather than being answered: its job hasn't been claimed yet, or is waiting
+// for a claude slot.
+func (s *Server) waitingForClaude(ctx context.Context, prID int64) bool {
+	if _, ok := s.claudeWaiting.Load(prID); ok {
+		return true
+	}
+	waiting, _, err := s.queries.QueuedJobsAhead(ctx, jobClaudeSend, strconv.FormatInt(prID, 10))
+	if err != nil {
+		log.Printf("queue: %v", err)
+	}
+	return waiting
+}
+
+// queueSendMessage marks the prompt request as processing and queues the
+// Claude call, unless it's already processing a message.
+func (s *Server) queueSendMessage(prID int64) {
+	if !s.setRepoStatusProcessing(prID) {
+		return
+	}
+	s.enqueue(jobClaudeSend, jobPayload{PromptRequestID: prID})
+}
+
+// runWorkers starts the worker pool and blocks until ctx is cancelled and all
+// in-flight jobs have returned.
+func (s *Server) runWorkers(ctx context.Context) {
+	if n, err := s.queries.RequeueRunningJobs(ctx); err != nil {
+		log.Printf("queue: %v", err)
+	} else if n > 0 {
+		log.Printf("queue: requeued %d interrupted jobs", n)
+	}
+
+	var wg sync.WaitGroup
+	for range s.cfg.Workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.worker(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+func (s *Server) worker(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		job, err := s.queries.ClaimQueuedJob(ctx, s.cfg.JobTimeout, map[string]int{
+			jobClone:      s.cfg.CloneWorkers,
+			jobClaudeSend: s.cfg.ClaudeProcesses,
+		})
+		if err != nil {
+			log.Printf("queue: %v", err)
+		}
+		if job != nil {
+			s.runJob(ctx, job)
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.queueWake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// runJob executes a claimed job with a deadline equal to the visibility
+// timeout, so a job is abandoned before another worker can reclaim it.
+// Shutdown does not cancel a running job: if the process exits first, the
+// job stays "running" and is re-queued on the next start.
+func (s *Server) runJob(ctx context.Context, job *models.QueuedJob) {
+	// A claimed job runs and records its outcome even during shutdown.
+	ctx = context.WithoutCancel(ctx)
+
+	spec, ok := s.jobs[job.Kind]
+	if !ok {
+		s.queries.FailQueuedJob(ctx, job.ID, fmt.Sprintf("unknown job kind %q", job.Kind), 0)
+		return
+	}
+	var p jobPayload
+	if err := json.Unmarshal([]byte(job.Payload), &p); err != nil {
+		s.queries.FailQueuedJob(ctx, job.ID, fmt.Sprintf("decoding payload: %v", err), 0)
+		return
+	}
+
+	jobCtx, cancel := context.WithTimeout(ctx, s.cfg.JobTimeout)
+	defer cancel()
+
+	if err := spec.handler(jobCtx, job, p); err != nil {
+		log.Printf("queue: %s job %d (attempt %d/%d) failed: %v", job.Kind, job.ID, job.Attempts, job.MaxAttempts, err)
+		if errors.As(err, new(finalError)) {
+			if err := s.queries.AbandonQueuedJob(ctx, job.ID, err.Error()); err != nil {
+				log.Printf("queue: recording failure for job %d: %v", job.ID, err)
+			}
+			return
+		}
+		// Quadratic backoff: 5s, 20s, 45s, ...
+		backoff := time.Duration(job.Attempts*job.Attempts) * 5 * time.Second
+		if err := s.queries.FailQueuedJob(ctx, job.ID, err.Error(), backoff); err != nil {
+			log.Printf("queue: recording failure for job %d: %v", job.ID, err)
+		}
+		return
+	}
+	if err := s.queries.CompleteQueuedJob(ctx, job.ID); err != nil {
+		log.Printf("queue: completing job %d: %v", job.ID, err)
+	}
+}
+
+func (s *Server) runCloneJob(ctx context.Context, job *models.QueuedJob, p jobPayload) error {
+	// Serialize clone/pull operations per repo to prevent concurrent git corruption
+	unlock := s.lockRepo(p.RepoURL)
+	defer unlock()
+
+	if s.repoFresh(ctx, p.RepoURL) {
+		s.setRepoStatus(p.PromptRequestID, stateReady, "")
+		s.queuePrewarm(ctx, p.PromptRequestID)
+		return nil
+	}
+	if _, err := repo.EnsureCloned(ctx, p.RepoURL); err != nil {
+		log.Printf("clone/pull failed for %s: %v", p.RepoURL, err)
+		if job.Attempts >= job.MaxAttempts {
+			s.setRepoStatus(p.PromptRequestID, stateError, err.Error())
+		}
+		return err
+	}
+	if err := s.queries.SetRepositoryPulled(ctx, p.RepoURL); err != nil {
+		log.Printf("recording pull of %s: %v", p.RepoURL, err)
+	}
+	s.setRepoStatus(p.PromptRequestID, stateReady, "")
+	s.refreshRepoMetadata(ctx, p.RepoURL)
+	s.queuePrewarm(ctx, p.PromptRequestID)
+	return nil
+}
+
+// repoFresh reports whether the repository's local copy was cloned or pulled
+// within PullFreshness, so pulling it again can be skipped.
+func (s *Server) repoFresh(ctx context.Context, repoURL string) bool {
+	if s.cfg.PullFreshness <= 0 {
+		return false
+	}
+	if cloned, _ := repo.IsCloned(repoURL); !cloned {
+		return false
+	}
+	rec, err := s.queries.GetRepositoryByURL(ctx, repoURL)
+	if err != nil || rec.PulledAt == nil {
+		return false
+	}
+	return time.Since(*rec.PulledAt) < s.cfg.PullFreshness
+}
+
+func (s *Server) runClaudeSendJob(ctx context.Context, job *models.QueuedJob, p jobPayload) error {
+	// A job re-queued after a restart finds the status reset to "ready".
+	s.setRepoStatusProcessing(p.PromptRequestID)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	s.cancelFuncs.Store(p.PromptRequestID, cancel)
+	s.backgroundSendMessage(ctx, p.PromptRequestID)
+	return nil
+}
+
+func (s *Server) runPublishJob(ctx context.Context, job *models.QueuedJob, p jobPayload) error {
+	_, err := s.publishPromptRequest(ctx, p.PromptRequestID, p.MessageID, p.Overwrite)
+	if err != nil && !publishRetryable(err) {
+		return finalError{err}
+	}
+	return err
+}
+
+type jobsData struct {
+	basePageData
+	Jobs []models.QueuedJob
+}
+
+// handleJobsPage lists recent background jobs for inspection.
+func (s *Server) handleJobsPage(w http.ResponseWriter, r *http.Request) {
+	jobs, err := s.queries.ListQueuedJobs(r.Context(), 200)
+	if err != nil {
+		log.Printf("listing jobs: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	s.renderPage(w, "jobs.html", jobsData{
+		basePageData: basePageData{Sidebar: s.buildAllSidebar(r.Context(), sidebarPageSize)},
+		Jobs:         jobs,
+	})
+}
+
+// handleJobRetry re-queues a failed job.
+func (s *Server) handleJobRetry(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	if err := s.queries.RetryQueuedJob(r.Context(), id); err != nil {
+		log.Printf("retrying job %d: %v", id, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	select {
+	case s.queueWake <- struct{}{}:
+	default:
+	}
+	http.Redirect(w, r, "/jobs", http.StatusSeeOther)
+}
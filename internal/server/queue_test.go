@@ -0,0 +1,43 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/esnunes/prompter/internal/db"
+	"github.com/esnunes/prompter/internal/github"
+)
+
+func TestPublishRetryable(t *testing.T) {
+	for _, tt := range []struct {
+		err  error
+		want bool
+	}{
+		{fmt.Errorf("creating GitHub issue: %w", fmt.Errorf("creating issue: %w: dial", github.ErrUnreachable)), true},
+		{fmt.Errorf("creating GitHub issue: creating issue: HTTP 502"), false},
+		{fmt.Errorf("creating GitHub issue: %w", github.ErrIssuesUnavailable), false},
+		{errNoGeneratedPrompt, false},
+	} {
+		if got := publishRetryable(tt.err); got != tt.want {
+			t.Errorf("publishRetryable(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestRunPublishJob_FailsFinally(t *testing.T) {
+	database, err := db.Open(filepath.Join(t.TempDir(), "prompter.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	s := &Server{queries: db.NewQueries(database)}
+
+	// Publishing a prompt request that doesn't exist fails the same way every time.
+	err = s.runPublishJob(context.Background(), nil, jobPayload{PromptRequestID: 42})
+	if err == nil || !errors.As(err, new(finalError)) {
+		t.Errorf("runPublishJob = %v, want a final error", err)
+	}
+}
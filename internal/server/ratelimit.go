@@ -0,0 +1,142 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/esnunes/prompter/gotk"
+)
+
+// RateLimit allows Requests per Interval for a single client. A zero value disables limiting.
+type RateLimit struct {
+	Requests int
+	Interval time.Duration
+}
+
+// ParseRateLimit parses a limit of the form "<requests>/<interval>" (e.g. "10/1m").
+// "0" and "off" disable limiting.
+func ParseRateLimit(s string) (RateLimit, error) {
+	s = strings.TrimSpace(s)
+	if s == "0" || s == "off" {
+		return RateLimit{}, nil
+	}
+	n, d, ok := strings.Cut(s, "/")
+	if !ok {
+		return RateLimit{}, fmt.Errorf("invalid rate limit %q: expected format <requests>/<interval>", s)
+	}
+	requests, err := strconv.Atoi(n)
+	if err != nil || requests < 0 {
+		return RateLimit{}, fmt.Errorf("invalid rate limit %q: bad request count", s)
+	}
+	interval, err := time.ParseDuration(d)
+	if err != nil || interval <= 0 {
+		return RateLimit{}, fmt.Errorf("invalid rate limit %q: bad interval", s)
+	}
+	return RateLimit{Requests: requests, Interval: interval}, nil
+}
+
+func (l RateLimit) enabled() bool {
+	return l.Requests > 0 && l.Interval > 0
+}
+
+// rateLimiter is a per-client token bucket. Each client starts with a full
+// bucket of Requests tokens that refills evenly over Interval.
+type rateLimiter struct {
+	limit   RateLimit
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	sweep   time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(limit RateLimit) *rateLimiter {
+	return &rateLimiter{limit: limit, buckets: map[string]*bucket{}}
+}
+
+// allow consumes a token for key. When the bucket is empty it returns false and
+// how long the client should wait before retrying.
+func (rl *rateLimiter) allow(key string) (bool, time.Duration) {
+	if rl == nil || !rl.limit.enabled() {
+		return true, 0
+	}
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := time.Now()
+	capacity := float64(rl.limit.Requests)
+	perToken := rl.limit.Interval / time.Duration(rl.limit.Requests)
+
+	// Drop buckets that have fully refilled so idle clients don't accumulate.
+	if now.Sub(rl.sweep) > rl.limit.Interval {
+		for k, b := range rl.buckets {
+			if now.Sub(b.last) > rl.limit.Interval {
+				delete(rl.buckets, k)
+			}
+		}
+		rl.sweep = now
+	}
+
+	b, ok := rl.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, last: now}
+		rl.buckets[key] = b
+	}
+	b.tokens = math.Min(capacity, b.tokens+float64(now.Sub(b.last))/float64(perToken))
+	b.last = now
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) * float64(perToken))
+		return false, wait
+	}
+	b.tokens--
+	return true, 0
+}
+
+// limitHTTP wraps a handler so requests beyond the limit get 429 Too Many Requests.
+func (rl *rateLimiter) limitHTTP(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if ok, wait := rl.allow(clientIP(r.RemoteAddr)); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			http.Error(w, "Too many requests, please slow down.", http.StatusTooManyRequests)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// limitGotk wraps a gotk command handler so commands beyond the limit render an
+// error into target instead of running.
+func (rl *rateLimiter) limitGotk(target string, next gotk.HandlerFunc) gotk.HandlerFunc {
+	return func(ctx *gotk.Context) error {
+		key := ""
+		if conn := ctx.Conn(); conn != nil {
+			key = clientIP(conn.RemoteAddr())
+		}
+		if ok, _ := rl.allow(key); !ok {
+			ctx.Error(target, "Too many requests, please slow down.")
+			return nil
+		}
+		return next(ctx)
+	}
+}
+
+// clientIP strips the port from a remote address so all connections from the
+// same host share a bucket.
+func clientIP(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return remoteAddr
+	}
+	return host
+}
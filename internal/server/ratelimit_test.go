@@ -0,0 +1,108 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	for _, tt := range []struct {
+		in      string
+		want    RateLimit
+		wantErr bool
+	}{
+		{"10/1m", RateLimit{Requests: 10, Interval: time.Minute}, false},
+		{" 5/30s ", RateLimit{Requests: 5, Interval: 30 * time.Second}, false},
+		{"0", RateLimit{}, false},
+		{"off", RateLimit{}, false},
+		{"10", RateLimit{}, true},
+		{"x/1m", RateLimit{}, true},
+		{"-1/1m", RateLimit{}, true},
+		{"10/soon", RateLimit{}, true},
+		{"10/0s", RateLimit{}, true},
+	} {
+		got, err := ParseRateLimit(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseRateLimit(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseRateLimit(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRateLimiter_Allow(t *testing.T) {
+	rl := newRateLimiter(RateLimit{Requests: 2, Interval: time.Hour})
+	for i := range 2 {
+		if ok, _ := rl.allow("a"); !ok {
+			t.Fatalf("request %d was limited", i+1)
+		}
+	}
+	ok, wait := rl.allow("a")
+	if ok {
+		t.Fatal("third request was allowed")
+	}
+	if wait <= 0 || wait > 30*time.Minute {
+		t.Errorf("wait = %v, want up to 30m", wait)
+	}
+	if ok, _ := rl.allow("b"); !ok {
+		t.Error("another client shares the first client's bucket")
+	}
+}
+
+func TestRateLimiter_Refills(t *testing.T) {
+	rl := newRateLimiter(RateLimit{Requests: 1, Interval: 20 * time.Millisecond})
+	if ok, _ := rl.allow("a"); !ok {
+		t.Fatal("first request was limited")
+	}
+	if ok, _ := rl.allow("a"); ok {
+		t.Fatal("second request was allowed before the bucket refilled")
+	}
+	time.Sleep(30 * time.Millisecond)
+	if ok, _ := rl.allow("a"); !ok {
+		t.Error("request was limited after the bucket refilled")
+	}
+}
+
+func TestRateLimiter_Disabled(t *testing.T) {
+	for _, rl := range []*rateLimiter{nil, newRateLimiter(RateLimit{})} {
+		for range 100 {
+			if ok, _ := rl.allow("a"); !ok {
+				t.Fatal("disabled limiter limited a request")
+			}
+		}
+	}
+}
+
+func TestRateLimiter_LimitHTTP(t *testing.T) {
+	rl := newRateLimiter(RateLimit{Requests: 1, Interval: time.Minute})
+	h := rl.limitHTTP(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	serve := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/", nil)
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		h(w, req)
+		return w
+	}
+
+	if w := serve("10.0.0.1:1234"); w.Code != http.StatusNoContent {
+		t.Fatalf("first request: status %d", w.Code)
+	}
+	// A new connection from the same host shares the bucket.
+	w := serve("10.0.0.1:5678")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("second request: status %d, want %d", w.Code, http.StatusTooManyRequests)
+	}
+	if got := w.Header().Get("Retry-After"); got != "60" {
+		t.Errorf("Retry-After = %q, want 60", got)
+	}
+	if w := serve("10.0.0.2:1234"); w.Code != http.StatusNoContent {
+		t.Errorf("other host: status %d", w.Code)
+	}
+}
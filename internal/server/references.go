@@ -0,0 +1,242 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/esnunes/prompter/internal/db"
+	"github.com/esnunes/prompter/internal/models"
+	"github.com/esnunes/prompter/internal/repo"
+)
+
+// maxFileSuggestions bounds the file picker's suggestion list.
+const maxFileSuggestions = 50
+
+type referencesFragmentData struct {
+	Org     string
+	Repo    string
+	ID      int64
+	Pending []models.FileReference
+	Error   string
+}
+
+func (s *Server) newReferencesFragmentData(ctx context.Context, org, repoName string, prID int64) (referencesFragmentData, error) {
+	pending, err := s.queries.ListPendingFileReferences(ctx, prID)
+	if err != nil {
+		return referencesFragmentData{}, err
+	}
+	return referencesFragmentData{Org: org, Repo: repoName, ID: prID, Pending: pending}, nil
+}
+
+// fileReferenceLabel formats a reference as path, path:line or path:start-end.
+func fileReferenceLabel(f models.FileReference) string {
+	switch {
+	case f.StartLine == 0:
+		return f.Path
+	case f.EndLine == f.StartLine:
+		return fmt.Sprintf("%s:%d", f.Path, f.StartLine)
+	default:
+		return fmt.Sprintf("%s:%d-%d", f.Path, f.StartLine, f.EndLine)
+	}
+}
+
+// parseLineRange parses "", "12" or "12-40" into 1-based inclusive lines.
+func parseLineRange(raw string) (start, end int, ok bool) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return 0, 0, true
+	}
+	from, to, found := strings.Cut(raw, "-")
+	start, err := strconv.Atoi(strings.TrimSpace(from))
+	if err != nil || start < 1 {
+		return 0, 0, false
+	}
+	end = start
+	if found {
+		end, err = strconv.Atoi(strings.TrimSpace(to))
+		if err != nil || end < start {
+			return 0, 0, false
+		}
+	}
+	return start, end, true
+}
+
+// messageFileReferences groups a prompt request's sent references by message.
+func (s *Server) messageFileReferences(ctx context.Context, prID int64) (map[int64][]models.FileReference, error) {
+	refs, err := s.queries.ListMessageFileReferences(ctx, prID)
+	if err != nil {
+		return nil, err
+	}
+	byMessage := make(map[int64][]models.FileReference)
+	for _, f := range refs {
+		byMessage[*f.MessageID] = append(byMessage[*f.MessageID], f)
+	}
+	return byMessage, nil
+}
+
+// sendPendingFileReferences links the pending references to a newly sent
+// user message and returns them.
+func sendPendingFileReferences(ctx context.Context, q *db.Queries, prID, messageID int64) ([]models.FileReference, error) {
+	pending, err := q.ListPendingFileReferences(ctx, prID)
+	if err != nil {
+		return nil, fmt.Errorf("listing pending file references: %w", err)
+	}
+	if len(pending) == 0 {
+		return nil, nil
+	}
+	if err := q.AttachPendingFileReferences(ctx, prID, messageID); err != nil {
+		return nil, fmt.Errorf("attaching file references to message %d: %w", messageID, err)
+	}
+	return pending, nil
+}
+
+// fileReferenceChipsHTML renders reference chips for gotk pushes; it mirrors
+// the file_reference_chips.html template.
+func fileReferenceChipsHTML(refs []models.FileReference) string {
+	if len(refs) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString(`<div class="message-references">`)
+	for _, f := range refs {
+		fmt.Fprintf(&b, `<code class="file-reference">%s</code>`, template.HTMLEscapeString(fileReferenceLabel(f)))
+	}
+	b.WriteString(`</div>`)
+	return b.String()
+}
+
+// fileReferencesPrompt tells Claude which files the user wants it to focus on.
+func fileReferencesPrompt(refs []models.FileReference) string {
+	var b strings.Builder
+	b.WriteString("\n\nFocus on these files from the repository (paths relative to its root):")
+	for _, f := range refs {
+		b.WriteString("\n- " + fileReferenceLabel(f))
+	}
+	return b.String()
+}
+
+// handleFileSuggestions lists repository files matching q as datalist options
+// for the file picker.
+func (s *Server) handleFileSuggestions(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	pr, err := s.queries.GetPromptRequest(r.Context(), id)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	// An uncloned repo has nothing to suggest yet.
+	files, _ := repo.ListFiles(r.Context(), pr.RepoLocalPath)
+	q := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("path")))
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	n := 0
+	for _, f := range files {
+		if n == maxFileSuggestions {
+			break
+		}
+		if q != "" && !strings.Contains(strings.ToLower(f), q) {
+			continue
+		}
+		fmt.Fprintf(w, "<option value=\"%s\"></option>\n", template.HTMLEscapeString(f))
+		n++
+	}
+}
+
+// handleAddFileReference adds a pending file reference for the next user message.
+func (s *Server) handleAddFileReference(w http.ResponseWriter, r *http.Request) {
+	org := r.PathValue("org")
+	repoName := r.PathValue("repo")
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	pr, err := s.queries.GetPromptRequest(r.Context(), id)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	var invalid string
+	path := strings.TrimPrefix(strings.TrimSpace(r.FormValue("path")), "/")
+	start, end, ok := parseLineRange(r.FormValue("lines"))
+	if !ok {
+		invalid = "Lines must be a line number or a range like 10-40."
+	} else if files, err := repo.ListFiles(r.Context(), pr.RepoLocalPath); err != nil {
+		invalid = "The repository isn't ready yet."
+	} else if !slices.Contains(files, path) {
+		invalid = fmt.Sprintf("%q is not a file in the repository.", path)
+	} else if err := s.queries.CreateFileReference(r.Context(), id, path, start, end); err != nil {
+		log.Printf("adding file reference for prompt request %d: %v", id, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	if r.Header.Get("HX-Request") != "true" {
+		if invalid != "" {
+			http.Error(w, invalid, http.StatusBadRequest)
+			return
+		}
+		http.Redirect(w, r, fmt.Sprintf("/github.com/%s/%s/prompt-requests/%d", org, repoName, id), http.StatusSeeOther)
+		return
+	}
+	data, err := s.newReferencesFragmentData(r.Context(), org, repoName, id)
+	if err != nil {
+		log.Printf("listing file references for prompt request %d: %v", id, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	data.Error = invalid
+	s.renderFragment(w, "references_fragment.html", data)
+}
+
+// handleDeleteFileReference removes a pending file reference.
+func (s *Server) handleDeleteFileReference(w http.ResponseWriter, r *http.Request) {
+	org := r.PathValue("org")
+	repoName := r.PathValue("repo")
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	refID, err := strconv.ParseInt(r.PathValue("refID"), 10, 64)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	f, err := s.queries.GetFileReference(r.Context(), refID)
+	if err != nil || f.PromptRequestID != id {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	if f.MessageID != nil {
+		http.Error(w, "This file reference was already sent and can't be removed.", http.StatusConflict)
+		return
+	}
+	if err := s.queries.DeletePendingFileReference(r.Context(), refID); err != nil {
+		log.Printf("deleting file reference %d: %v", refID, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	data, err := s.newReferencesFragmentData(r.Context(), org, repoName, id)
+	if err != nil {
+		log.Printf("listing file references for prompt request %d: %v", id, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	s.renderFragment(w, "references_fragment.html", data)
+}
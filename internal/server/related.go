@@ -0,0 +1,58 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/esnunes/prompter/internal/models"
+)
+
+// relatedIssues lists the open issues Claude found related to pr, leaving
+// out the issue pr was imported from, which the body references already.
+func (s *Server) relatedIssues(ctx context.Context, pr *models.PromptRequest) ([]models.RelatedIssue, error) {
+	issues, err := s.queries.ListRelatedIssues(ctx, pr.ID)
+	if err != nil {
+		return nil, err
+	}
+	var related []models.RelatedIssue
+	for _, issue := range issues {
+		if pr.SourceIssueNumber != nil && issue.Number == *pr.SourceIssueNumber {
+			continue
+		}
+		related = append(related, issue)
+	}
+	return related, nil
+}
+
+// relatedLine is the "Related: #12, #34" line appended to the issue body.
+func relatedLine(issues []models.RelatedIssue) string {
+	refs := make([]string, len(issues))
+	for i, issue := range issues {
+		refs[i] = fmt.Sprintf("#%d", issue.Number)
+	}
+	return "Related: " + strings.Join(refs, ", ")
+}
+
+// handleLinkRelatedIssues toggles the "Related:" line and re-renders the
+// publish preview with it.
+func (s *Server) handleLinkRelatedIssues(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	if _, err := s.queries.GetPromptRequest(r.Context(), id); err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	if err := s.queries.SetPromptRequestLinkRelatedIssues(r.Context(), id, r.FormValue("link_related") == "1"); err != nil {
+		log.Printf("updating related issues option for prompt request %d: %v", id, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	s.handlePublishPreview(w, r)
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/esnunes/prompter/internal/github"
+	"github.com/esnunes/prompter/internal/models"
+	"github.com/esnunes/prompter/internal/repo"
+)
+
+// sessionContext is what a new Claude session is told about the repository
+// before the conversation: its guidelines, recent work, open issues and
+// labels.
+// Anything that can't be read is left out.
+func (s *Server) sessionContext(ctx context.Context, pr *models.PromptRequest) string {
+	var b strings.Builder
+	if docs, err := repo.Guidelines(pr.RepoLocalPath); err != nil {
+		log.Printf("reading guidelines of %s: %v", pr.RepoURL, err)
+	} else {
+		b.WriteString(guidelinesPrompt(docs))
+	}
+	b.WriteString(s.recentWorkPrompt(ctx, pr))
+	b.WriteString(s.openIssuesPrompt(ctx, pr))
+	if labels, err := s.repoLabels(ctx, pr.RepoURL); err != nil {
+		log.Printf("listing labels of %s: %v", pr.RepoURL, err)
+	} else {
+		b.WriteString(labelsPrompt(labels))
+	}
+	if b.Len() == 0 {
+		return ""
+	}
+	b.WriteString("The conversation follows.\n\n")
+	return b.String()
+}
+
+// recentWorkPrompt lists the latest commits and merged pull requests, so
+// Claude knows what is in progress and doesn't propose what was just shipped.
+func (s *Server) recentWorkPrompt(ctx context.Context, pr *models.PromptRequest) string {
+	if s.cfg.RecentWork == 0 {
+		return ""
+	}
+	commits, err := repo.RecentCommits(ctx, pr.RepoLocalPath, s.cfg.RecentWork)
+	if err != nil {
+		log.Printf("reading recent commits of %s: %v", pr.RepoURL, err)
+	}
+	merged, err := github.ListMergedPullRequests(ctx, pr.RepoURL, s.cfg.RecentWork)
+	if err != nil {
+		log.Printf("listing merged pull requests of %s: %v", pr.RepoURL, err)
+	}
+	if len(commits) == 0 && len(merged) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("Recent work in the repository, newest first. If what the user asks for was just shipped ")
+	b.WriteString("or is clearly in progress, tell them.\n\n")
+	if len(commits) > 0 {
+		b.WriteString("<recent-commits>\n" + strings.Join(commits, "\n") + "\n</recent-commits>\n\n")
+	}
+	if len(merged) > 0 {
+		b.WriteString("<merged-pull-requests>\n")
+		for _, m := range merged {
+			date, _, _ := strings.Cut(m.MergedAt, "T")
+			fmt.Fprintf(&b, "#%d %s %s\n", m.Number, date, m.Title)
+		}
+		b.WriteString("</merged-pull-requests>\n\n")
+	}
+	return b.String()
+}
+
+// openIssuesPrompt lists the repository's open issues, so Claude can point
+// out that a request duplicates one of them while there is still time to
+// change course.
+func (s *Server) openIssuesPrompt(ctx context.Context, pr *models.PromptRequest) string {
+	if s.cfg.OpenIssues == 0 {
+		return ""
+	}
+	issues, err := github.ListOpenIssues(ctx, pr.RepoURL, s.cfg.OpenIssues)
+	if err != nil {
+		log.Printf("listing open issues of %s: %v", pr.RepoURL, err)
+	}
+	// The issue this prompt request was imported from isn't a duplicate.
+	issues = slices.DeleteFunc(issues, func(i github.IssueSummary) bool {
+		return pr.SourceIssueNumber != nil && i.Number == *pr.SourceIssueNumber
+	})
+	if len(issues) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("Open issues in the repository, newest first. If the request looks like one of them, ")
+	b.WriteString("say so with its number (\"this looks like existing issue #123\") and ask the user whether to continue. ")
+	b.WriteString("Issues that are related without being duplicates go in \"related_issues\".\n\n")
+	b.WriteString("<open-issues>\n")
+	for _, i := range issues {
+		fmt.Fprintf(&b, "#%d %s\n", i.Number, i.Title)
+	}
+	b.WriteString("</open-issues>\n\n")
+	return b.String()
+}
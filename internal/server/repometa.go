@@ -0,0 +1,54 @@
+package server
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/esnunes/prompter/internal/github"
+	"github.com/esnunes/prompter/internal/models"
+)
+
+// repoMetadataMaxAge is how long cached repository metadata is shown before
+// it is fetched again.
+const repoMetadataMaxAge = 24 * time.Hour
+
+func repoMetadata(r *github.Repository) *models.RepoMetadata {
+	return &models.RepoMetadata{
+		Description: r.Description,
+		Stars:       r.Stars,
+		Language:    r.Language,
+		License:     r.License,
+		OpenIssues:  r.OpenIssues,
+		FetchedAt:   time.Now(),
+	}
+}
+
+// cacheRepoMetadata stores m for a registered repository; others have no
+// record to keep it in.
+func (s *Server) cacheRepoMetadata(repoURL string, m *models.RepoMetadata) {
+	rec, err := s.queries.GetRepositoryByURL(context.Background(), repoURL)
+	if err != nil {
+		return
+	}
+	if err := s.queries.SetRepositoryMetadata(context.Background(), rec.ID, *m); err != nil {
+		log.Printf("caching metadata of %s: %v", repoURL, err)
+	}
+}
+
+// refreshRepoMetadata fetches the metadata of a registered repository from
+// GitHub unless the cached copy is recent. Failures only leave it stale.
+func (s *Server) refreshRepoMetadata(ctx context.Context, repoURL string) {
+	rec, err := s.queries.GetRepositoryByURL(ctx, repoURL)
+	if err != nil || (rec.Metadata != nil && time.Since(rec.Metadata.FetchedAt) < repoMetadataMaxAge) {
+		return
+	}
+	r, err := github.ViewRepo(ctx, repoURL)
+	if err != nil {
+		log.Printf("fetching metadata of %s: %v", repoURL, err)
+		return
+	}
+	if err := s.queries.SetRepositoryMetadata(ctx, rec.ID, *repoMetadata(r)); err != nil {
+		log.Printf("caching metadata of %s: %v", repoURL, err)
+	}
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/esnunes/prompter/internal/github"
+)
+
+const (
+	// repoSuggestionLimit caps the repositories suggested for one query.
+	repoSuggestionLimit = 20
+	// myReposMaxAge is how long the user's own and starred repositories are
+	// reused before being listed again.
+	myReposMaxAge = 5 * time.Minute
+	// minRepoSearchLength is the shortest query searched for on all of GitHub;
+	// shorter ones only match the user's repositories.
+	minRepoSearchLength = 3
+)
+
+// repoSuggestion is an option of the repository picker.
+type repoSuggestion struct {
+	URL         string // "github.com/owner/repo"
+	Description string
+}
+
+// myReposCache holds the user's own and starred repositories, which every
+// keystroke in the picker filters.
+type myReposCache struct {
+	mu        sync.Mutex
+	repos     []github.RepoSummary
+	fetchedAt time.Time
+}
+
+// myRepos returns the user's own and starred repositories, listing them again
+// when the cached copy is older than myReposMaxAge. A failed listing leaves
+// out what it would have returned.
+func (s *Server) myRepos(ctx context.Context) []github.RepoSummary {
+	c := &s.myReposCache
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.repos != nil && time.Since(c.fetchedAt) < myReposMaxAge {
+		return c.repos
+	}
+	own, err := github.ListUserRepos(ctx, 100)
+	if err != nil {
+		log.Printf("listing own repositories: %v", err)
+	}
+	starred, err := github.ListStarredRepos(ctx)
+	if err != nil {
+		log.Printf("listing starred repositories: %v", err)
+	}
+	c.repos = append(own, starred...)
+	c.fetchedAt = time.Now()
+	return c.repos
+}
+
+// handleRepoSuggestions renders the options of the dashboard's repository
+// picker for ?repo_url=: repositories already used in Prompter, then the
+// user's own and starred ones, then GitHub search results.
+func (s *Server) handleRepoSuggestions(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.FormValue("repo_url"))
+	query = strings.TrimPrefix(strings.TrimPrefix(query, "https://"), "http://")
+	query = strings.ToLower(strings.TrimPrefix(query, "github.com/"))
+
+	var suggestions []repoSuggestion
+	seen := map[string]bool{}
+	add := func(name, description string) {
+		url := "github.com/" + name
+		if len(suggestions) < repoSuggestionLimit && !seen[strings.ToLower(url)] {
+			seen[strings.ToLower(url)] = true
+			suggestions = append(suggestions, repoSuggestion{URL: url, Description: description})
+		}
+	}
+	matches := func(name string) bool { return strings.Contains(strings.ToLower(name), query) }
+
+	registered, err := s.queries.ListRepositories(r.Context())
+	if err != nil {
+		log.Printf("listing repositories: %v", err)
+	}
+	for _, rec := range registered {
+		if name := strings.TrimPrefix(rec.URL, "github.com/"); matches(name) {
+			description := ""
+			if rec.Metadata != nil {
+				description = rec.Metadata.Description
+			}
+			add(name, description)
+		}
+	}
+	for _, gr := range s.myRepos(r.Context()) {
+		if matches(gr.Name) {
+			add(gr.Name, gr.Description)
+		}
+	}
+	if len(query) >= minRepoSearchLength && len(suggestions) < repoSuggestionLimit {
+		// Search results may match on the description rather than the name.
+		found, err := github.SearchRepos(r.Context(), query, repoSuggestionLimit)
+		if err != nil {
+			log.Printf("searching repositories for %q: %v", query, err)
+		}
+		for _, gr := range found {
+			add(gr.Name, gr.Description)
+		}
+	}
+	s.renderFragment(w, "repo_suggestions_fragment.html", suggestions)
+}
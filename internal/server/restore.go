@@ -0,0 +1,78 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/esnunes/prompter/internal/github"
+	"github.com/esnunes/prompter/internal/models"
+)
+
+// restoreRevision republishes an older revision's body to the prompt
+// request's issue and records it as a new revision.
+func (s *Server) restoreRevision(ctx context.Context, pr *models.PromptRequest, rev *models.Revision) (*models.Revision, error) {
+	if pr.PublishTarget == "comment" {
+		if err := github.CommentOnIssue(ctx, pr.RepoURL, *pr.IssueNumber, rev.Content); err != nil {
+			return nil, fmt.Errorf("commenting on GitHub issue: %w", err)
+		}
+	} else if err := github.EditIssue(ctx, pr.RepoURL, *pr.IssueNumber, rev.Content); err != nil {
+		return nil, fmt.Errorf("updating GitHub issue: %w", err)
+	}
+	ctx = context.WithoutCancel(ctx) // the issue is restored either way
+
+	s.auditPR(pr, "issue-edited", fmt.Sprintf("issue #%d restored to the revision published %s", *pr.IssueNumber, rev.PublishedAt.Format("Jan 2, 2006 3:04 PM")), 0)
+
+	var afterMsgID *int64
+	if lastMsg, err := s.queries.GetLastMessage(ctx, pr.ID); err == nil {
+		afterMsgID = &lastMsg.ID
+	}
+	return s.queries.CreateRevision(ctx, pr.ID, rev.Content, afterMsgID, rev.SourceMessageID)
+}
+
+// handleRestoreRevision rolls the GitHub issue back to a previous revision.
+func (s *Server) handleRestoreRevision(w http.ResponseWriter, r *http.Request) {
+	org := r.PathValue("org")
+	repoName := r.PathValue("repo")
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	revID, err := strconv.ParseInt(r.PathValue("revID"), 10, 64)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	pr, err := s.queries.GetPromptRequest(r.Context(), id)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	rev, err := s.queries.GetRevision(r.Context(), revID)
+	if err != nil || rev.PromptRequestID != id {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	if pr.IssueNumber == nil {
+		http.Error(w, "This prompt request has no GitHub issue to restore into.", http.StatusConflict)
+		return
+	}
+
+	if _, err := s.restoreRevision(r.Context(), pr, rev); err != nil {
+		log.Printf("restoring revision %d of prompt request %d: %v", revID, id, err)
+		http.Error(w, "Couldn't update the GitHub issue. Check your connection and try again.", http.StatusBadRequest)
+		return
+	}
+
+	redirectURL := fmt.Sprintf("/github.com/%s/%s/prompt-requests/%d", org, repoName, id)
+	if r.Header.Get("HX-Request") == "true" {
+		w.Header().Set("HX-Redirect", redirectURL)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+}
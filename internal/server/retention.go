@@ -0,0 +1,53 @@
+package server
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+)
+
+// retentionInterval is how often stale drafts are swept.
+const retentionInterval = time.Hour
+
+// runRetention archives stale drafts at startup and then periodically,
+// until ctx is cancelled. Pinned drafts are never archived.
+func (s *Server) runRetention(ctx context.Context) {
+	if s.cfg.DraftRetention <= 0 {
+		return
+	}
+	ticker := time.NewTicker(retentionInterval)
+	defer ticker.Stop()
+	for {
+		if n, err := s.queries.AutoArchiveStaleDrafts(ctx, s.cfg.DraftRetention); err != nil {
+			log.Printf("retention: %v", err)
+		} else if n > 0 {
+			log.Printf("retention: archived %d stale drafts", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// handleAutoArchiveUndo restores the drafts listed in the dashboard banner.
+func (s *Server) handleAutoArchiveUndo(w http.ResponseWriter, r *http.Request) {
+	if err := s.queries.UndoAutoArchive(r.Context()); err != nil {
+		log.Printf("undoing auto-archive: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// handleAutoArchiveDismiss hides the dashboard banner, keeping the drafts archived.
+func (s *Server) handleAutoArchiveDismiss(w http.ResponseWriter, r *http.Request) {
+	if err := s.queries.DismissAutoArchive(r.Context()); err != nil {
+		log.Printf("dismissing auto-archive: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
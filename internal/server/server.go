@@ -9,11 +9,15 @@ import (
 	"log"
 	"net"
 	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/esnunes/prompter/gotk"
 	"github.com/esnunes/prompter/internal/db"
+	"github.com/esnunes/prompter/internal/models"
 )
 
 //go:embed templates
@@ -23,24 +27,142 @@ var templatesFS embed.FS
 var staticFS embed.FS
 
 // repoStatusEntry tracks the state of an async clone/pull or AI processing operation.
+// It is persisted in the jobs table so it survives restarts.
 type repoStatusEntry struct {
 	Status    string    // "cloning", "pulling", "ready", "processing", "responded", "cancelled", "error"
 	Error     string    // error message if Status == "error"
 	StartedAt time.Time // when processing started (zero for non-processing states)
+	Since     time.Time // when the status last changed
+}
+
+// Config holds tunable server settings.
+type Config struct {
+	// Per-client rate limits for endpoints that spawn claude or gh processes.
+	SendRateLimit    RateLimit
+	PublishRateLimit RateLimit
+	StatusRateLimit  RateLimit
+
+	// Background job queue: number of concurrent workers and how long a job
+	// may run before it is abandoned and becomes eligible for another attempt.
+	Workers    int
+	JobTimeout time.Duration
+
+	// CloneWorkers caps how many of the workers clone or pull repositories at
+	// once, so several large repositories don't saturate disk and network.
+	CloneWorkers int
+
+	// ClaudeProcesses caps how many claude processes run at once; further
+	// requests wait for a free slot and show as queued.
+	ClaudeProcesses int
+
+	// PullFreshness skips pulling a repository that was cloned or pulled
+	// less than this long ago, e.g. for several prompt requests started on
+	// it at once. Zero always pulls.
+	PullFreshness time.Duration
+
+	// Prewarm starts the Claude session of a new prompt request as soon as
+	// its repository is ready, exploring the code before the first message
+	// so the first reply comes faster, at the cost of an extra Claude turn.
+	Prewarm bool
+
+	// DraftRetention archives drafts untouched for longer than this. Zero disables it.
+	DraftRetention time.Duration
+
+	// SummaryThreshold is the transcript size, in characters, above which a
+	// Claude session is summarized and replaced by a fresh one. Zero disables it.
+	SummaryThreshold int
+
+	// RecentWork is how many of the latest commits and merged pull requests
+	// are described to a new Claude session. Zero disables it.
+	RecentWork int
+
+	// OpenIssues is how many of the repository's open issues are listed to a
+	// new Claude session so it can point out duplicates. Zero disables it.
+	OpenIssues int
+
+	// BackupInterval is how often the database is backed up while the server
+	// runs, keeping the newest BackupKeep copies. Zero disables it.
+	BackupInterval time.Duration
+	BackupKeep     int
+
+	// IssueWatchInterval is how often published issues are checked for
+	// comments, labels and closing by others. Zero disables it.
+	IssueWatchInterval time.Duration
+
+	// PublicURL is the address others reach this server at, e.g. behind a
+	// reverse proxy. When set, prompt requests can be shared through links
+	// that stop working after ShareLinkTTL.
+	PublicURL    string
+	ShareLinkTTL time.Duration
+
+	// IssueTitlePrefix is prepended to published issue titles and
+	// IssueBodyTemplate (a text/template over the generated title, motivation,
+	// prompt and images) lays out their bodies. Repositories can override both.
+	IssueTitlePrefix  string
+	IssueBodyTemplate string
+
+	// IssueLabels are applied to every issue Prompter creates, each created
+	// in the repository first if missing. Repositories can override them.
+	IssueLabels []string
+
+	// DevDir, when set, serves templates and static assets from DevDir/templates
+	// and DevDir/static on disk, re-parsing templates on every request.
+	DevDir string
+}
+
+// DefaultConfig returns the settings used when nothing is overridden.
+func DefaultConfig() Config {
+	return Config{
+		SendRateLimit:      RateLimit{Requests: 10, Interval: time.Minute},
+		PublishRateLimit:   RateLimit{Requests: 5, Interval: time.Minute},
+		StatusRateLimit:    RateLimit{Requests: 120, Interval: time.Minute},
+		Workers:            4,
+		CloneWorkers:       2,
+		ClaudeProcesses:    2,
+		PullFreshness:      time.Minute,
+		JobTimeout:         15 * time.Minute,
+		DraftRetention:     90 * 24 * time.Hour,
+		SummaryThreshold:   60000,
+		RecentWork:         30,
+		OpenIssues:         100,
+		BackupInterval:     24 * time.Hour,
+		BackupKeep:         7,
+		IssueWatchInterval: 15 * time.Minute,
+		ShareLinkTTL:       7 * 24 * time.Hour,
+		IssueTitlePrefix:   defaultIssueTitlePrefix,
+		IssueLabels:        []string{defaultIssueLabel},
+	}
 }
 
 type Server struct {
-	queries    *db.Queries
-	pages      map[string]*template.Template
-	gotkMux    *gotk.Mux
-	httpSrv    *http.Server
-	ln         net.Listener
-	addr       string
-	sessionMu   sync.Map // per-session mutex: session ID → *sync.Mutex
-	repoStatus  sync.Map // per-prompt-request status: prompt request ID (int64) → repoStatusEntry
-	cancelFuncs sync.Map // per-prompt-request cancel: prompt request ID (int64) → context.CancelFunc
-	repoMu      sync.Map // per-repo mutex: repo URL (string) → *sync.Mutex
-	gotkConns   sync.Map // active gotk WebSocket connections: conn ID (int64) → *gotk.Conn
+	cfg          Config
+	queries      *db.Queries
+	pages        map[string]*template.Template
+	tmplFS       fs.FS
+	gotkMux      *gotk.Mux
+	httpSrv      *http.Server
+	ln           net.Listener
+	addr         string
+	states       *stateMachine // per-prompt-request conversation state
+	sessionLocks keyedMutex    // per-session lock, keyed by session ID
+	repoLocks    keyedMutex    // per-repo lock, keyed by repo URL
+	cancelFuncs  sync.Map      // per-prompt-request cancel for running Claude jobs: prompt request ID (int64) → context.CancelFunc
+	gotkConns    sync.Map      // active gotk WebSocket connections: conn ID (int64) → *gotk.Conn
+
+	myReposCache     myReposCache     // the user's own and starred repositories, for the repository picker
+	maintainersCache maintainersCache // maintainers suggested for each generated prompt
+	labelsCache      labelsCache      // the labels of each repository
+	ideaRuns         ideaRuns         // repositories Claude is proposing feature ideas for
+
+	sendLimiter    *rateLimiter
+	publishLimiter *rateLimiter
+	statusLimiter  *rateLimiter
+
+	jobs      map[string]jobSpec // job kind → handler and retry policy
+	queueWake chan struct{}      // signals idle workers that a job was enqueued
+
+	claudeSlots   chan struct{} // one token per running claude process
+	claudeWaiting sync.Map      // prompt requests waiting for a claude slot: prompt request ID (int64) → struct{}
 }
 
 var funcMap = template.FuncMap{
@@ -50,24 +172,80 @@ var funcMap = template.FuncMap{
 		}
 		return *s
 	},
+	"highlight": highlightSnippet,
+	"fileRef":   fileReferenceLabel,
+	"timeAgo":   timeAgo,
+	"isoTime":   isoTime,
+	"fullTime":  fullTime,
+	"unread":    unread,
+	"turnUsage": turnUsage,
+	"markdown":  renderMarkdown,
+	"kinds":     func() []promptKind { return promptKinds },
+	"kindBadge": kindBadge,
+}
+
+// highlightSnippet escapes a search snippet and wraps its matched terms in <mark>.
+func highlightSnippet(snippet string) template.HTML {
+	escaped := template.HTMLEscapeString(snippet)
+	escaped = strings.ReplaceAll(escaped, models.SnippetMarkStart, "<mark>")
+	escaped = strings.ReplaceAll(escaped, models.SnippetMarkEnd, "</mark>")
+	return template.HTML(escaped)
 }
 
-func New(queries *db.Queries) (*Server, error) {
-	pages, err := parsePages()
+func New(queries *db.Queries, cfg Config) (*Server, error) {
+	tmplFS, staticSub, err := assetFS(cfg.DevDir)
+	if err != nil {
+		return nil, err
+	}
+	// Content hashes version static URLs; skipped in dev mode where files change on disk.
+	var staticHashes map[string]string
+	if cfg.DevDir == "" {
+		if staticHashes, err = hashStatic(staticSub); err != nil {
+			return nil, fmt.Errorf("hashing static assets: %w", err)
+		}
+	}
+	pages, err := parsePages(tmplFS, staticHashes)
 	if err != nil {
 		return nil, err
 	}
 
+	if cfg.IssueBodyTemplate != "" {
+		if _, err := parseIssueBodyTemplate(cfg.IssueBodyTemplate); err != nil {
+			return nil, fmt.Errorf("parsing issue body template: %w", err)
+		}
+	}
+
+	if cfg.Workers < 1 {
+		cfg.Workers = 1
+	}
+	cfg.CloneWorkers = min(max(cfg.CloneWorkers, 1), cfg.Workers)
+	cfg.ClaudeProcesses = max(cfg.ClaudeProcesses, 1)
+
 	s := &Server{
+		cfg:     cfg,
 		queries: queries,
 		pages:   pages,
+		tmplFS:  tmplFS,
 		gotkMux: gotk.NewMux(),
+		states:  newStateMachine(queries),
+
+		sendLimiter:    newRateLimiter(cfg.SendRateLimit),
+		publishLimiter: newRateLimiter(cfg.PublishRateLimit),
+		statusLimiter:  newRateLimiter(cfg.StatusRateLimit),
+
+		queueWake:   make(chan struct{}, 1),
+		claudeSlots: make(chan struct{}, cfg.ClaudeProcesses),
 	}
+	s.jobs = s.jobSpecs()
 
+	s.reconcileJobs()
 	s.registerGotkCommands()
 
 	s.gotkMux.HandleConnect(func(conn *gotk.Conn) {
 		s.gotkConns.Store(conn.ID(), conn)
+		if err := conn.Push(alertPreferencesPush(s.alertPreferences(context.Background()))); err != nil {
+			log.Printf("gotk: push error (conn %d): %v", conn.ID(), err)
+		}
 	})
 	s.gotkMux.HandleDisconnect(func(conn *gotk.Conn) {
 		s.gotkConns.Delete(conn.ID())
@@ -75,40 +253,130 @@ func New(queries *db.Queries) (*Server, error) {
 
 	mux := http.NewServeMux()
 
-	staticSub, err := fs.Sub(staticFS, "static")
-	if err != nil {
-		return nil, fmt.Errorf("getting static subfs: %w", err)
-	}
-	mux.Handle("GET /static/", http.StripPrefix("/static/", http.FileServer(http.FS(staticSub))))
+	mux.Handle("GET /static/", staticHandler(staticSub, staticHashes))
+	mux.Handle("GET /sw.js", serviceWorkerHandler(staticSub))
 
 	// gotk: WebSocket endpoint and thin client JS
 	mux.HandleFunc("GET /ws", s.gotkMux.ServeWebSocket)
 	mux.HandleFunc("GET /gotk/client.js", gotk.ClientJSHandler())
 
+	mux.HandleFunc("GET /healthz", s.handleHealthz)
+	mux.HandleFunc("GET /readyz", s.handleReadyz)
+
 	mux.HandleFunc("GET /{$}", s.handleDashboard)
+	mux.HandleFunc("GET /repos/suggest", s.handleRepoSuggestions)
 	mux.HandleFunc("GET /github.com/{org}/{repo}/prompt-requests", s.handleRepoPage)
 	mux.HandleFunc("POST /github.com/{org}/{repo}/prompt-requests", s.handleCreate)
+	mux.HandleFunc("POST /github.com/{org}/{repo}/prompt-requests/import", s.handleImportIssue)
+	mux.HandleFunc("POST /github.com/{org}/{repo}/issue-format", s.handleIssueFormat)
+	mux.HandleFunc("GET /github.com/{org}/{repo}/ideas", s.handleIdeasPage)
+	mux.HandleFunc("POST /github.com/{org}/{repo}/ideas", s.handleGenerateIdeas)
+	mux.HandleFunc("POST /github.com/{org}/{repo}/ideas/{idea}/start", s.handleStartIdea)
 	mux.HandleFunc("GET /github.com/{org}/{repo}/prompt-requests/{id}", s.handleShow)
-	mux.HandleFunc("POST /github.com/{org}/{repo}/prompt-requests/{id}/messages", s.handleSendMessage)
-	mux.HandleFunc("POST /github.com/{org}/{repo}/prompt-requests/{id}/publish", s.handlePublish)
-	mux.HandleFunc("GET /github.com/{org}/{repo}/prompt-requests/{id}/status", s.handleRepoStatus)
+	mux.HandleFunc("GET /github.com/{org}/{repo}/prompt-requests/{id}/timeline", s.handleTimeline)
+	mux.HandleFunc("POST /github.com/{org}/{repo}/prompt-requests/{id}/messages", s.sendLimiter.limitHTTP(s.handleSendMessage))
+	mux.HandleFunc("POST /github.com/{org}/{repo}/prompt-requests/{id}/messages/{msgID}/edit", s.sendLimiter.limitHTTP(s.handleEditMessage))
+	mux.HandleFunc("POST /github.com/{org}/{repo}/prompt-requests/{id}/undo", s.handleUndoExchange)
+	mux.HandleFunc("POST /github.com/{org}/{repo}/prompt-requests/{id}/answers/{question}", s.sendLimiter.limitHTTP(s.handleChangeAnswer))
+	mux.HandleFunc("POST /github.com/{org}/{repo}/prompt-requests/{id}/publish", s.publishLimiter.limitHTTP(s.handlePublish))
+	mux.HandleFunc("GET /github.com/{org}/{repo}/prompt-requests/{id}/publish/preview", s.handlePublishPreview)
+	mux.HandleFunc("POST /github.com/{org}/{repo}/prompt-requests/{id}/publish/transcript", s.handleIncludeTranscript)
+	mux.HandleFunc("POST /github.com/{org}/{repo}/prompt-requests/{id}/publish/labels", s.handleIssueLabel)
+	mux.HandleFunc("POST /github.com/{org}/{repo}/prompt-requests/{id}/publish/mentions", s.handleMention)
+	mux.HandleFunc("POST /github.com/{org}/{repo}/prompt-requests/{id}/publish/related", s.handleLinkRelatedIssues)
+	mux.HandleFunc("POST /github.com/{org}/{repo}/prompt-requests/{id}/publish/areas", s.handleIncludeAffectedAreas)
+	mux.HandleFunc("POST /github.com/{org}/{repo}/prompt-requests/{id}/publish/check", s.publishLimiter.limitHTTP(s.handleCheckPrompt))
+	mux.HandleFunc("POST /github.com/{org}/{repo}/prompt-requests/{id}/publish/gist", s.publishLimiter.limitHTTP(s.handlePublishGist))
+	mux.HandleFunc("POST /github.com/{org}/{repo}/prompt-requests/{id}/issue-template", s.handleIssueTemplate)
+	mux.HandleFunc("POST /github.com/{org}/{repo}/prompt-requests/{id}/languages", s.handleLanguages)
+	mux.HandleFunc("POST /github.com/{org}/{repo}/prompt-requests/{id}/question-mode", s.handleQuestionMode)
+	mux.HandleFunc("POST /github.com/{org}/{repo}/prompt-requests/{id}/persona", s.handlePersona)
+	mux.HandleFunc("GET /github.com/{org}/{repo}/prompt-requests/{id}/publish/conflict", s.handlePublishConflict)
+	mux.HandleFunc("POST /github.com/{org}/{repo}/prompt-requests/{id}/publish/conflict/keep", s.handleKeepUpstream)
+	mux.HandleFunc("POST /github.com/{org}/{repo}/prompt-requests/{id}/publish/conflict/merge", s.handleMergeUpstream)
+	mux.HandleFunc("POST /github.com/{org}/{repo}/prompt-requests/{id}/export", s.handleExportMarkdown)
+	mux.HandleFunc("GET /github.com/{org}/{repo}/prompt-requests/{id}/export.html", s.handleExportHTML)
+	mux.HandleFunc("GET /github.com/{org}/{repo}/prompt-requests/{id}/export.md", s.handleExportTranscript)
+	mux.HandleFunc("POST /github.com/{org}/{repo}/prompt-requests/{id}/share", s.handleCreateShareLink)
+	mux.HandleFunc("POST /github.com/{org}/{repo}/prompt-requests/{id}/share/revoke", s.handleRevokeShareLinks)
+	mux.HandleFunc("GET /share/{token}", s.handleSharedPage)
+	mux.HandleFunc("GET /github.com/{org}/{repo}/prompt-requests/{id}/diff", s.handleRevisionDiff)
+	mux.HandleFunc("POST /github.com/{org}/{repo}/prompt-requests/{id}/revisions/{revID}/restore", s.handleRestoreRevision)
+	mux.HandleFunc("POST /github.com/{org}/{repo}/prompt-requests/{id}/unpublish", s.handleUnpublish)
+	mux.HandleFunc("GET /github.com/{org}/{repo}/prompt-requests/{id}/status", s.statusLimiter.limitHTTP(s.handleRepoStatus))
 	mux.HandleFunc("POST /github.com/{org}/{repo}/prompt-requests/{id}/retry", s.handleRetry)
 	mux.HandleFunc("POST /github.com/{org}/{repo}/prompt-requests/{id}/cancel", s.handleCancel)
-	mux.HandleFunc("POST /github.com/{org}/{repo}/prompt-requests/{id}/resend", s.handleResend)
+	mux.HandleFunc("POST /github.com/{org}/{repo}/prompt-requests/{id}/resend", s.sendLimiter.limitHTTP(s.handleResend))
 	mux.HandleFunc("DELETE /github.com/{org}/{repo}/prompt-requests/{id}", s.handleDelete)
 	mux.HandleFunc("POST /github.com/{org}/{repo}/prompt-requests/{id}/archive", s.handleArchive)
 	mux.HandleFunc("POST /github.com/{org}/{repo}/prompt-requests/{id}/unarchive", s.handleUnarchive)
+	mux.HandleFunc("POST /github.com/{org}/{repo}/prompt-requests/{id}/notes", s.handleSaveNotes)
+	mux.HandleFunc("POST /github.com/{org}/{repo}/prompt-requests/{id}/draft", s.handleSaveDraft)
+	mux.HandleFunc("POST /github.com/{org}/{repo}/prompt-requests/{id}/pin", s.handlePin)
+	mux.HandleFunc("POST /github.com/{org}/{repo}/prompt-requests/{id}/unpin", s.handleUnpin)
+	mux.HandleFunc("POST /github.com/{org}/{repo}/prompt-requests/{id}/title", s.handleRename)
+	mux.HandleFunc("POST /github.com/{org}/{repo}/prompt-requests/{id}/fork", s.handleFork)
+	mux.HandleFunc("POST /github.com/{org}/{repo}/prompt-requests/{id}/copy", s.handleCopyToRepo)
+	mux.HandleFunc("POST /github.com/{org}/{repo}/prompt-requests/{id}/merge", s.handleMerge)
+	mux.HandleFunc("POST /github.com/{org}/{repo}/prompt-requests/{id}/tags", s.handleAddTag)
+	mux.HandleFunc("DELETE /github.com/{org}/{repo}/prompt-requests/{id}/tags/{tag}", s.handleRemoveTag)
+	mux.HandleFunc("POST /github.com/{org}/{repo}/prompt-requests/{id}/attachments", s.handleUploadAttachment)
+	mux.HandleFunc("DELETE /github.com/{org}/{repo}/prompt-requests/{id}/attachments/{attID}", s.handleDeleteAttachment)
+	mux.HandleFunc("GET /attachments/{id}", s.handleServeAttachment)
+	mux.HandleFunc("GET /github.com/{org}/{repo}/prompt-requests/{id}/files", s.handleFileSuggestions)
+	mux.HandleFunc("POST /github.com/{org}/{repo}/prompt-requests/{id}/references", s.handleAddFileReference)
+	mux.HandleFunc("DELETE /github.com/{org}/{repo}/prompt-requests/{id}/references/{refID}", s.handleDeleteFileReference)
 	mux.HandleFunc("GET /api/sidebar", s.handleSidebarFragment)
-
-	s.httpSrv = &http.Server{Handler: mux}
+	mux.HandleFunc("POST /auto-archive/undo", s.handleAutoArchiveUndo)
+	mux.HandleFunc("POST /auto-archive/dismiss", s.handleAutoArchiveDismiss)
+	mux.HandleFunc("GET /jobs", s.handleJobsPage)
+	mux.HandleFunc("POST /jobs/{id}/retry", s.handleJobRetry)
+	mux.HandleFunc("GET /stats", s.handleStatsPage)
+	mux.HandleFunc("GET /settings", s.handleSettingsPage)
+	mux.HandleFunc("GET /notifications", s.handleNotificationsPage)
+	mux.HandleFunc("POST /settings/alerts", s.handleAlertSettings)
+	mux.HandleFunc("POST /settings/questions", s.handleQuestionSettings)
+	mux.HandleFunc("POST /settings/personas", s.handleCreatePersona)
+	mux.HandleFunc("POST /settings/personas/default", s.handleDefaultPersona)
+	mux.HandleFunc("POST /settings/personas/{id}", s.handleUpdatePersona)
+	mux.HandleFunc("DELETE /settings/personas/{id}", s.handleDeletePersona)
+	mux.HandleFunc("GET /board", s.handleBoardPage)
+	mux.HandleFunc("GET /history", s.handleHistoryPage)
+	mux.HandleFunc("GET /trash", s.handleTrashPage)
+	mux.HandleFunc("POST /trash/{id}/restore", s.handleTrashRestore)
+	mux.HandleFunc("POST /trash/{id}/purge", s.handleTrashPurge)
+
+	s.httpSrv = &http.Server{Handler: withGzip(mux)}
 	return s, nil
 }
 
-// parsePages builds a template for each page by combining layout.html, shared partials, and the page template.
-func parsePages() (map[string]*template.Template, error) {
-	tmplFS, err := fs.Sub(templatesFS, "templates")
+// assetFS returns the filesystems holding templates and static assets: the
+// embedded copies, or the directories under devDir when running in dev mode.
+func assetFS(devDir string) (tmplFS, staticSub fs.FS, err error) {
+	if devDir != "" {
+		for _, dir := range []string{"templates", "static"} {
+			if _, err := os.Stat(filepath.Join(devDir, dir)); err != nil {
+				return nil, nil, fmt.Errorf("dev mode: %w", err)
+			}
+		}
+		return os.DirFS(filepath.Join(devDir, "templates")), os.DirFS(filepath.Join(devDir, "static")), nil
+	}
+	tmplFS, err = fs.Sub(templatesFS, "templates")
+	if err != nil {
+		return nil, nil, fmt.Errorf("getting templates subfs: %w", err)
+	}
+	staticSub, err = fs.Sub(staticFS, "static")
 	if err != nil {
-		return nil, fmt.Errorf("getting templates subfs: %w", err)
+		return nil, nil, fmt.Errorf("getting static subfs: %w", err)
+	}
+	return tmplFS, staticSub, nil
+}
+
+// parsePages builds a template for each page by combining layout.html, shared partials, and the page template.
+// staticHashes versions the URLs produced by the "static" template func.
+func parsePages(tmplFS fs.FS, staticHashes map[string]string) (map[string]*template.Template, error) {
+	funcs := template.FuncMap{
+		"static": func(name string) string { return staticURL(staticHashes, name) },
 	}
 
 	layoutBytes, err := fs.ReadFile(tmplFS, "layout.html")
@@ -117,9 +385,14 @@ func parsePages() (map[string]*template.Template, error) {
 	}
 
 	// Shared partials included in every page template
-	sidebarBytes, err := fs.ReadFile(tmplFS, "sidebar.html")
-	if err != nil {
-		return nil, fmt.Errorf("reading sidebar: %w", err)
+	partialNames := []string{"sidebar.html", "title_fragment.html", "tags_fragment.html", "notes_fragment.html", "attachments_fragment.html", "attachment_thumbs.html", "references_fragment.html", "file_reference_chips.html", "diff_lines.html", "timeline_fragment.html", "repo_meta.html", "share_links_fragment.html"}
+	partials := make(map[string][]byte, len(partialNames))
+	for _, name := range partialNames {
+		b, err := fs.ReadFile(tmplFS, name)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", name, err)
+		}
+		partials[name] = b
 	}
 
 	pageNames := []string{
@@ -127,9 +400,29 @@ func parsePages() (map[string]*template.Template, error) {
 		"repo.html",
 		"conversation.html",
 		"message_fragment.html",
+		"timeline_fragment.html",
 		"status_fragment.html",
 		"sidebar.html",
 		"archive_banner_fragment.html",
+		"jobs.html",
+		"stats.html",
+		"settings.html",
+		"notifications.html",
+		"ideas.html",
+		"board.html",
+		"trash.html",
+		"history.html",
+		"title_fragment.html",
+		"tags_fragment.html",
+		"notes_fragment.html",
+		"attachments_fragment.html",
+		"references_fragment.html",
+		"publish_preview_fragment.html",
+		"revision_diff.html",
+		"publish_conflict.html",
+		"repo_suggestions_fragment.html",
+		"export.html",
+		"share_links_fragment.html",
 	}
 
 	pages := make(map[string]*template.Template, len(pageNames))
@@ -139,13 +432,15 @@ func parsePages() (map[string]*template.Template, error) {
 			return nil, fmt.Errorf("reading %s: %w", name, err)
 		}
 
-		tmpl, err := template.New("layout.html").Funcs(funcMap).Parse(string(layoutBytes))
+		tmpl, err := template.New("layout.html").Funcs(funcMap).Funcs(funcs).Parse(string(layoutBytes))
 		if err != nil {
 			return nil, fmt.Errorf("parsing layout for %s: %w", name, err)
 		}
 
-		if _, err := tmpl.New("sidebar.html").Parse(string(sidebarBytes)); err != nil {
-			return nil, fmt.Errorf("parsing sidebar for %s: %w", name, err)
+		for _, partial := range partialNames {
+			if _, err := tmpl.New(partial).Parse(string(partials[partial])); err != nil {
+				return nil, fmt.Errorf("parsing %s for %s: %w", partial, name, err)
+			}
 		}
 
 		if _, err := tmpl.New(name).Parse(string(pageBytes)); err != nil {
@@ -168,12 +463,16 @@ func (s *Server) Listen(addr string) error {
 	return nil
 }
 
-// Serve starts handling HTTP requests. Blocks until ctx is cancelled.
+// Serve starts handling HTTP requests and background jobs. Blocks until ctx is cancelled.
 func (s *Server) Serve(ctx context.Context) error {
 	go func() {
 		<-ctx.Done()
 		s.httpSrv.Shutdown(context.Background())
 	}()
+	go s.runWorkers(ctx)
+	go s.runRetention(ctx)
+	go s.runBackups(ctx)
+	go s.runIssueWatch(ctx)
 
 	fmt.Printf("Listening on http://%s\n", s.addr)
 	fmt.Println("Press Ctrl+C to stop.")
@@ -189,8 +488,24 @@ func (s *Server) Addr() string {
 	return s.addr
 }
 
+// page returns the parsed template for name. In dev mode templates are
+// re-parsed from disk on every call so edits show up without a rebuild.
+func (s *Server) page(name string) (*template.Template, bool) {
+	if s.cfg.DevDir == "" {
+		tmpl, ok := s.pages[name]
+		return tmpl, ok
+	}
+	pages, err := parsePages(s.tmplFS, nil)
+	if err != nil {
+		log.Printf("dev mode: %v", err)
+		return nil, false
+	}
+	tmpl, ok := pages[name]
+	return tmpl, ok
+}
+
 func (s *Server) renderPage(w http.ResponseWriter, name string, data any) {
-	tmpl, ok := s.pages[name]
+	tmpl, ok := s.page(name)
 	if !ok {
 		log.Printf("template not found: %s", name)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
@@ -203,42 +518,66 @@ func (s *Server) renderPage(w http.ResponseWriter, name string, data any) {
 	}
 }
 
-// lockSession returns the mutex for a given session ID. Callers must
-// call Unlock when done to allow subsequent requests for the same session.
-func (s *Server) lockSession(sessionID string) *sync.Mutex {
-	v, _ := s.sessionMu.LoadOrStore(sessionID, &sync.Mutex{})
-	mu := v.(*sync.Mutex)
-	mu.Lock()
-	return mu
+// lockSession locks the given session ID and returns the func that unlocks
+// it, so requests for the same session run one at a time.
+func (s *Server) lockSession(sessionID string) (unlock func()) {
+	return s.sessionLocks.Lock(sessionID)
 }
 
-func (s *Server) setRepoStatus(prID int64, status, errMsg string) {
-	s.repoStatus.Store(prID, repoStatusEntry{Status: status, Error: errMsg})
+// setRepoStatus moves the prompt request to status and reports whether it
+// did; the state machine rejects, and logs, transitions it doesn't allow.
+func (s *Server) setRepoStatus(prID int64, status, errMsg string) bool {
+	return s.states.transition(prID, nil, status, errMsg, nil)
 }
 
-func (s *Server) setRepoStatusProcessing(prID int64, cancelFunc context.CancelFunc) {
-	s.repoStatus.Store(prID, repoStatusEntry{Status: "processing", StartedAt: time.Now()})
-	s.cancelFuncs.Store(prID, cancelFunc)
+// setRepoStatusProcessing starts processing a message unless the prompt
+// request is already processing one, or its repository isn't ready.
+func (s *Server) setRepoStatusProcessing(prID int64) bool {
+	now := time.Now()
+	from := []string{stateNone, stateReady, stateResponded, stateCancelled, stateError}
+	return s.states.transition(prID, from, stateProcessing, "", &now)
 }
 
 func (s *Server) clearCancelFunc(prID int64) {
 	s.cancelFuncs.Delete(prID)
 }
 
-func (s *Server) getRepoStatus(prID int64) repoStatusEntry {
-	v, ok := s.repoStatus.Load(prID)
-	if !ok {
+func (s *Server) getRepoStatus(ctx context.Context, prID int64) repoStatusEntry {
+	job, err := s.queries.GetJob(ctx, prID)
+	if err != nil {
 		return repoStatusEntry{}
 	}
-	return v.(repoStatusEntry)
+	entry := repoStatusEntry{Status: job.Status, Error: job.Error, Since: job.UpdatedAt}
+	if job.StartedAt != nil {
+		entry.StartedAt = *job.StartedAt
+	}
+	return entry
+}
+
+// reconcileJobs repairs jobs left in flight by a previous process. Interrupted
+// clones/pulls are re-queued; interrupted Claude calls go back to "ready" so the
+// pending user message is re-sent on the next status poll.
+func (s *Server) reconcileJobs() {
+	jobs, err := s.queries.ListInFlightJobs(context.Background())
+	if err != nil {
+		log.Printf("reconciling jobs: %v", err)
+		return
+	}
+	for _, j := range jobs {
+		switch j.Status {
+		case stateCloning, statePulling:
+			log.Printf("reconcile: restarting %s for PR %d", j.Status, j.PromptRequestID)
+			s.enqueue(jobClone, jobPayload{PromptRequestID: j.PromptRequestID, RepoURL: j.RepoURL})
+		case stateProcessing:
+			log.Printf("reconcile: re-queuing interrupted Claude call for PR %d", j.PromptRequestID)
+			s.setRepoStatus(j.PromptRequestID, stateReady, "")
+		}
+	}
 }
 
-// lockRepo returns the mutex for a given repo URL. Callers must call Unlock when done.
-func (s *Server) lockRepo(repoURL string) *sync.Mutex {
-	v, _ := s.repoMu.LoadOrStore(repoURL, &sync.Mutex{})
-	mu := v.(*sync.Mutex)
-	mu.Lock()
-	return mu
+// lockRepo locks the given repo URL and returns the func that unlocks it.
+func (s *Server) lockRepo(repoURL string) (unlock func()) {
+	return s.repoLocks.Lock(repoURL)
 }
 
 // pushAll sends gotk instructions to all connected WebSocket clients.
@@ -253,7 +592,7 @@ func (s *Server) pushAll(ins []gotk.Instruction) {
 }
 
 func (s *Server) renderFragment(w http.ResponseWriter, name string, data any) {
-	tmpl, ok := s.pages[name]
+	tmpl, ok := s.page(name)
 	if !ok {
 		log.Printf("fragment template not found: %s", name)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
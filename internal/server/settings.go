@@ -0,0 +1,138 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/esnunes/prompter/gotk"
+	"github.com/esnunes/prompter/internal/models"
+)
+
+// Preference names.
+const (
+	prefAlertSound     = "alert_sound"     // "1" plays a sound on a response while the tab is in the background
+	prefAlertTabTitle  = "alert_tab_title" // "1" counts unseen responses in the tab title
+	prefQuestionBudget = "question_budget" // most questions the AI asks per conversation; "" for no limit
+	prefDefaultPersona = "default_persona" // ID of the persona new prompt requests start with; "" for none
+)
+
+// maxQuestionBudget bounds the question budget setting.
+const maxQuestionBudget = 100
+
+// alertPreferences say how a page in the background signals that the AI
+// finished responding, besides browser notifications.
+type alertPreferences struct {
+	Sound    bool
+	TabTitle bool
+}
+
+func (s *Server) alertPreferences(ctx context.Context) alertPreferences {
+	var prefs alertPreferences
+	for _, p := range []struct {
+		name  string
+		value *bool
+	}{
+		{prefAlertSound, &prefs.Sound},
+		{prefAlertTabTitle, &prefs.TabTitle},
+	} {
+		v, err := s.queries.Preference(ctx, p.name)
+		if err != nil {
+			log.Printf("%v", err)
+		}
+		*p.value = v == "1"
+	}
+	return prefs
+}
+
+// alertPreferencesPush hands the alert preferences to app.js, which pages
+// receive when they connect and again whenever they change.
+func alertPreferencesPush(prefs alertPreferences) []gotk.Instruction {
+	return []gotk.Instruction{{Op: "exec", Name: "setAlertPreferences", Args: map[string]any{
+		"sound":    prefs.Sound,
+		"tabTitle": prefs.TabTitle,
+	}}}
+}
+
+// questionBudget is the most questions the AI may ask in a conversation
+// before it must generate the prompt; zero means no limit.
+func (s *Server) questionBudget(ctx context.Context) int {
+	v, err := s.queries.Preference(ctx, prefQuestionBudget)
+	if err != nil {
+		log.Printf("%v", err)
+	}
+	n, _ := strconv.Atoi(v)
+	return n
+}
+
+type settingsData struct {
+	basePageData
+	Alerts         alertPreferences
+	QuestionBudget int
+	Personas       []models.Persona
+	DefaultPersona int64
+}
+
+// handleSettingsPage shows the user's preferences.
+func (s *Server) handleSettingsPage(w http.ResponseWriter, r *http.Request) {
+	personas, err := s.queries.ListPersonas(r.Context())
+	if err != nil {
+		log.Printf("%v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	s.renderPage(w, "settings.html", settingsData{
+		basePageData:   basePageData{Sidebar: s.buildAllSidebar(r.Context(), sidebarPageSize)},
+		Alerts:         s.alertPreferences(r.Context()),
+		QuestionBudget: s.questionBudget(r.Context()),
+		Personas:       personas,
+		DefaultPersona: s.defaultPersona(r.Context()),
+	})
+}
+
+// handleQuestionSettings saves the question budget, which applies from the
+// next message of every conversation.
+func (s *Server) handleQuestionSettings(w http.ResponseWriter, r *http.Request) {
+	value := strings.TrimSpace(r.FormValue("budget"))
+	if value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxQuestionBudget {
+			http.Error(w, fmt.Sprintf("The question budget must be a number from 1 to %d, or empty for no limit.", maxQuestionBudget), http.StatusBadRequest)
+			return
+		}
+		value = strconv.Itoa(n)
+	}
+	if err := s.queries.SetPreference(r.Context(), prefQuestionBudget, value); err != nil {
+		log.Printf("%v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	// Clear any earlier error.
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleAlertSettings saves the alert preferences and applies them to every
+// open page.
+func (s *Server) handleAlertSettings(w http.ResponseWriter, r *http.Request) {
+	prefs := alertPreferences{
+		Sound:    r.FormValue("sound") == "1",
+		TabTitle: r.FormValue("tab_title") == "1",
+	}
+	for name, on := range map[string]bool{prefAlertSound: prefs.Sound, prefAlertTabTitle: prefs.TabTitle} {
+		value := ""
+		if on {
+			value = "1"
+		}
+		if err := s.queries.SetPreference(r.Context(), name, value); err != nil {
+			log.Printf("%v", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+	}
+	s.pushAll(alertPreferencesPush(prefs))
+	// Clear any earlier error.
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,149 @@
+package server
+
+import (
+	"context"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/esnunes/prompter/internal/db"
+)
+
+// Conversation states, persisted per prompt request in the jobs table. The
+// repository is cloned or pulled until it's ready; each user message then
+// moves the conversation to processing until Claude responds, the request is
+// cancelled or it fails.
+const (
+	stateNone       = "" // nothing recorded yet
+	stateCloning    = "cloning"
+	statePulling    = "pulling"
+	stateReady      = "ready"
+	stateProcessing = "processing"
+	stateResponded  = "responded"
+	stateCancelled  = "cancelled"
+	stateError      = "error"
+)
+
+// stateTransitions lists the states each state may move to. Anything else is
+// rejected, so e.g. a second message can't start Claude while it's already
+// answering one, and a pull can't start underneath a running Claude call.
+var stateTransitions = map[string][]string{
+	stateNone:       {stateCloning, statePulling, stateReady, stateProcessing},
+	stateCloning:    {stateCloning, statePulling, stateReady, stateError},
+	statePulling:    {stateCloning, statePulling, stateReady, stateError},
+	stateReady:      {stateCloning, statePulling, stateReady, stateProcessing},
+	stateProcessing: {stateReady, stateResponded, stateCancelled, stateError},
+	stateResponded:  {stateCloning, statePulling, stateReady, stateProcessing},
+	stateCancelled:  {stateCloning, statePulling, stateReady, stateProcessing},
+	stateError:      {stateCloning, statePulling, stateReady, stateProcessing},
+}
+
+// stateGCInterval is how often the state machine forgets the state of
+// prompt requests that are archived or in the trash.
+const stateGCInterval = time.Hour
+
+type stateChange struct {
+	prID      int64
+	from      []string // if set, only change from one of these states
+	to        string
+	errMsg    string
+	startedAt *time.Time
+	done      chan bool
+}
+
+// stateMachine serializes every state change through a single coordinator
+// goroutine, so checking the current state and moving on from it can't race
+// another change.
+type stateMachine struct {
+	queries *db.Queries
+	changes chan stateChange
+}
+
+func newStateMachine(queries *db.Queries) *stateMachine {
+	m := &stateMachine{queries: queries, changes: make(chan stateChange)}
+	go m.run()
+	return m
+}
+
+func (m *stateMachine) run() {
+	gc := time.NewTicker(stateGCInterval)
+	defer gc.Stop()
+	for {
+		select {
+		case c := <-m.changes:
+			c.done <- m.apply(c)
+		case <-gc.C:
+			if n, err := m.queries.DeleteIdleJobs(context.Background()); err != nil {
+				log.Printf("state: collecting: %v", err)
+			} else if n > 0 {
+				log.Printf("state: forgot %d idle prompt requests", n)
+			}
+		}
+	}
+}
+
+func (m *stateMachine) apply(c stateChange) bool {
+	current := stateNone
+	if job, err := m.queries.GetJob(context.Background(), c.prID); err == nil {
+		current = job.Status
+	}
+	if c.from != nil && !slices.Contains(c.from, current) {
+		return false
+	}
+	if !slices.Contains(stateTransitions[current], c.to) {
+		log.Printf("state: PR %d: rejected %q → %q", c.prID, current, c.to)
+		return false
+	}
+	if err := m.queries.SetJobStatus(context.Background(), c.prID, c.to, c.errMsg, c.startedAt); err != nil {
+		log.Printf("state: PR %d: %v", c.prID, err)
+		return false
+	}
+	return true
+}
+
+// transition moves the prompt request to a new state and reports whether it
+// did. A non-nil from restricts the states it moves from; other states are
+// left alone silently.
+func (m *stateMachine) transition(prID int64, from []string, to, errMsg string, startedAt *time.Time) bool {
+	done := make(chan bool, 1)
+	m.changes <- stateChange{prID: prID, from: from, to: to, errMsg: errMsg, startedAt: startedAt, done: done}
+	return <-done
+}
+
+// keyedMutex hands out a mutex per key. Keys nobody holds or waits for are
+// forgotten, so it doesn't grow with every session and repository seen.
+type keyedMutex struct {
+	mu    sync.Mutex
+	locks map[string]*refMutex
+}
+
+type refMutex struct {
+	sync.Mutex
+	refs int // holders plus waiters, guarded by keyedMutex.mu
+}
+
+// Lock locks key and returns the func that unlocks it.
+func (k *keyedMutex) Lock(key string) (unlock func()) {
+	k.mu.Lock()
+	if k.locks == nil {
+		k.locks = make(map[string]*refMutex)
+	}
+	l, ok := k.locks[key]
+	if !ok {
+		l = &refMutex{}
+		k.locks[key] = l
+	}
+	l.refs++
+	k.mu.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		k.mu.Lock()
+		if l.refs--; l.refs == 0 {
+			delete(k.locks, key)
+		}
+		k.mu.Unlock()
+	}
+}
@@ -12,6 +12,15 @@ function scrollConversation() {
   }
 }
 
+// Move focus to the AI's questions when they appear, so keyboard and
+// screen-reader users land on the first one instead of the page's start.
+function focusQuestions() {
+  var q = document.getElementById("question-form");
+  if (!q || q.contains(document.activeElement)) return;
+  var first = q.querySelector('input:not([type="hidden"])');
+  if (first) first.focus({ preventScroll: true });
+}
+
 // Update elapsed timers for processing indicators
 function updateElapsedTimers() {
   var els = document.querySelectorAll("[data-started-at]");
@@ -31,17 +40,48 @@ function updateElapsedTimers() {
 
 setInterval(updateElapsedTimers, 1000);
 
-(function () {
-  function renderMarkdown(root) {
-    var bubbles = (root || document).querySelectorAll(
-      ".message-assistant .message-bubble:not([data-md-rendered]), .revision-content:not([data-md-rendered])"
-    );
-    bubbles.forEach(function (el) {
-      el.innerHTML = DOMPurify.sanitize(marked.parse(el.textContent));
-      el.setAttribute("data-md-rendered", "");
-    });
+// Show <time datetime> elements in the viewer's time zone: a relative label
+// like the server's timeAgo, with the local date and time on hover.
+function relativeTime(date) {
+  var secs = (Date.now() - date.getTime()) / 1000;
+  function plural(n, unit) {
+    n = Math.floor(n);
+    return n === 1 ? "1 " + unit + " ago" : n + " " + unit + "s ago";
   }
+  if (secs < 60) return "just now";
+  if (secs < 3600) return plural(secs / 60, "minute");
+  if (secs < 86400) return plural(secs / 3600, "hour");
+  if (secs < 2 * 86400) return "yesterday";
+  if (secs < 30 * 86400) return plural(secs / 86400, "day");
+  return date.toLocaleDateString(undefined, { dateStyle: "medium" });
+}
+
+function localizeTimes(root) {
+  (root || document).querySelectorAll("time[datetime]").forEach(function (el) {
+    var date = new Date(el.getAttribute("datetime"));
+    if (isNaN(date)) return;
+    el.textContent = relativeTime(date);
+    el.title = date.toLocaleString(undefined, { dateStyle: "medium", timeStyle: "medium" });
+  });
+}
+
+document.addEventListener("DOMContentLoaded", function () {
+  localizeTimes();
+  // Content swapped in by HTMX or pushed by the server arrives with UTC times.
+  new MutationObserver(function (mutations) {
+    mutations.forEach(function (m) {
+      m.addedNodes.forEach(function (node) {
+        if (node.nodeType !== Node.ELEMENT_NODE) return;
+        if (node.matches("time[datetime]")) localizeTimes(node.parentNode);
+        else localizeTimes(node);
+      });
+    });
+  }).observe(document.body, { childList: true, subtree: true });
+});
+
+setInterval(localizeTimes, 60000);
 
+(function () {
   // Validate question form before submission
   function validateQuestionForm(form) {
     var groups = form.querySelectorAll(".question-group");
@@ -52,6 +92,7 @@ setInterval(updateElapsedTimers, 1000);
       var anyChecked = false;
       var otherChecked = false;
       var otherInput = groups[i].querySelector(".other-input");
+      var noteInput = groups[i].querySelector(".note-input");
 
       for (var j = 0; j < inputs.length; j++) {
         if (inputs[j].checked) {
@@ -62,7 +103,9 @@ setInterval(updateElapsedTimers, 1000);
         }
       }
 
-      if (!anyChecked) {
+      // Details alone answer the question too.
+      if (!anyChecked && !(noteInput && noteInput.value.trim() !== "")) {
+        if (inputs.length) inputs[0].focus();
         alert("Please select an option for each question.");
         return false;
       }
@@ -86,8 +129,6 @@ setInterval(updateElapsedTimers, 1000);
   }
 
   document.addEventListener("DOMContentLoaded", function () {
-    renderMarkdown();
-
     // Auto-scroll and focus on initial conversation page load.
     // Skip if URL has a hash fragment (e.g., #revision-3) to preserve
     // native anchor scroll from revision sidebar links.
@@ -104,7 +145,9 @@ setInterval(updateElapsedTimers, 1000);
 
       // Focus textarea unless questionnaire is showing (textarea hidden)
       // or on mobile where keyboard would disrupt scroll position.
-      if (!q && window.innerWidth >= 769) {
+      if (q) {
+        focusQuestions();
+      } else if (window.innerWidth >= 769) {
         var textarea = document.querySelector(".chat-form textarea");
         if (textarea && !textarea.disabled) {
           textarea.focus();
@@ -114,7 +157,6 @@ setInterval(updateElapsedTimers, 1000);
   });
 
   document.addEventListener("htmx:afterSwap", function (e) {
-    renderMarkdown(e.detail.target);
     updateMessageFormVisibility();
     updateElapsedTimers();
 
@@ -123,6 +165,31 @@ setInterval(updateElapsedTimers, 1000);
     var target = e.detail.target;
     if (target && target.id === "conversation") {
       scrollConversation();
+      focusQuestions();
+    }
+  });
+
+  // The status poll delivers a reply by appending it to #conversation out of
+  // band; the conversation can go on from there.
+  document.addEventListener("htmx:oobAfterSwap", function (e) {
+    if (e.detail.target.id !== "conversation") return;
+    var f = document.getElementById("message-form");
+    if (f) {
+      f.querySelector("textarea").disabled = false;
+      f.querySelector("button").disabled = false;
+    }
+    updateMessageFormVisibility();
+    scrollConversation();
+    focusQuestions();
+  });
+
+  // Elements marked data-swap-errors render 4xx response text into their
+  // target instead of silently ignoring it.
+  document.addEventListener("htmx:beforeSwap", function (e) {
+    var status = e.detail.xhr.status;
+    if (status >= 400 && status < 500 && e.detail.elt.closest("[data-swap-errors]")) {
+      e.detail.shouldSwap = true;
+      e.detail.isError = false;
     }
   });
 
@@ -136,6 +203,17 @@ setInterval(updateElapsedTimers, 1000);
     }
   });
 
+  // The Answer button sends whatever is selected, so check in the capture
+  // phase, before gotk sees the click, that every question has an answer.
+  document.addEventListener("click", function (e) {
+    var btn = e.target.closest && e.target.closest('[gotk-click="answer-question"]');
+    var fields = document.getElementById("question-form-fields");
+    if (btn && fields && !validateQuestionForm(fields)) {
+      e.preventDefault();
+      e.stopPropagation();
+    }
+  }, true);
+
   // Enter-to-send: submit chat form on Enter, newline on Shift+Enter
   document.addEventListener("keydown", function (e) {
     if (e.key !== "Enter") return;
@@ -171,6 +249,210 @@ setInterval(updateElapsedTimers, 1000);
   });
 })();
 
+// Rename a prompt request from a list card. The card action carries the
+// rename URL, current title, and whether an issue exists to sync.
+function renamePromptRequest(el) {
+  var current = el.getAttribute("data-title") || "";
+  var title = prompt("Rename prompt request", current);
+  if (title === null || title.trim() === "" || title.trim() === current) return;
+  var body = new URLSearchParams({ title: title.trim() });
+  if (
+    el.hasAttribute("data-published") &&
+    confirm("Also update the title of the published GitHub issue?")
+  ) {
+    body.append("sync_issue", "1");
+  }
+  fetch(el.getAttribute("data-rename-url"), { method: "POST", body: body }).then(
+    function (resp) {
+      if (resp.ok) {
+        window.location.reload();
+        return;
+      }
+      var html = (resp.headers.get("Content-Type") || "").indexOf("text/html") === 0;
+      resp.text().then(function (text) {
+        // The server may answer with a notice offering another way to publish.
+        if (html) card.insertAdjacentHTML("beforeend", text);
+        else alert(text);
+      });
+    }
+  );
+}
+
+// Kanban board: drag a card onto a column listed in its data-drop-targets.
+// Dropping on "published" publishes the prompt request to GitHub.
+(function () {
+  var dragged = null;
+
+  function canDrop(column) {
+    if (!dragged || !column) return false;
+    var targets = (dragged.getAttribute("data-drop-targets") || "").split(" ");
+    return targets.indexOf(column.getAttribute("data-column")) !== -1;
+  }
+
+  document.addEventListener("dragstart", function (e) {
+    var card = e.target.closest && e.target.closest(".board-card[draggable]");
+    if (!card) return;
+    dragged = card;
+    card.classList.add("board-card-dragging");
+    e.dataTransfer.effectAllowed = "move";
+    document.querySelectorAll(".board-column").forEach(function (col) {
+      if (canDrop(col)) col.classList.add("board-column-droppable");
+    });
+  });
+
+  document.addEventListener("dragend", function () {
+    if (dragged) dragged.classList.remove("board-card-dragging");
+    dragged = null;
+    document.querySelectorAll(".board-column-droppable").forEach(function (col) {
+      col.classList.remove("board-column-droppable");
+    });
+  });
+
+  document.addEventListener("dragover", function (e) {
+    var column = e.target.closest && e.target.closest(".board-column");
+    if (canDrop(column)) e.preventDefault();
+  });
+
+  document.addEventListener("drop", function (e) {
+    var column = e.target.closest && e.target.closest(".board-column");
+    if (!canDrop(column)) return;
+    e.preventDefault();
+    var card = dragged;
+    if (column.getAttribute("data-column") !== "published") return;
+    if (!confirm("Publish this prompt request as a GitHub issue?")) return;
+    fetch(card.getAttribute("data-publish-url"), { method: "POST" }).then(function (resp) {
+      if (resp.ok) {
+        window.location.reload();
+        return;
+      }
+      var html = (resp.headers.get("Content-Type") || "").indexOf("text/html") === 0;
+      resp.text().then(function (text) {
+        // The server may answer with a notice offering another way to publish.
+        if (html) card.insertAdjacentHTML("beforeend", text);
+        else alert(text);
+      });
+    });
+  });
+})();
+
+// Start a prompt request from a notes file dropped on a [data-seed-drop] form.
+(function () {
+  function dropZone(e) {
+    return e.target.closest && e.target.closest("[data-seed-drop]");
+  }
+
+  document.addEventListener("dragover", function (e) {
+    var zone = dropZone(e);
+    if (!zone || !e.dataTransfer.types.includes("Files")) return;
+    e.preventDefault();
+    zone.classList.add("seed-drop-active");
+  });
+
+  document.addEventListener("dragleave", function (e) {
+    var zone = dropZone(e);
+    if (zone && !zone.contains(e.relatedTarget)) zone.classList.remove("seed-drop-active");
+  });
+
+  document.addEventListener("drop", function (e) {
+    var zone = dropZone(e);
+    if (!zone || !e.dataTransfer.files.length) return;
+    e.preventDefault();
+    zone.classList.remove("seed-drop-active");
+    var input = zone.querySelector('input[type="file"]');
+    input.files = e.dataTransfer.files;
+    zone.submit();
+  });
+})();
+
+// Copy the composed issue body from a [data-copy-markdown] button's URL. The
+// fetch promise goes straight into the ClipboardItem so browsers that require
+// a user gesture still accept the write.
+(function () {
+  document.addEventListener("click", function (e) {
+    var btn = e.target.closest && e.target.closest("[data-copy-markdown]");
+    if (!btn) return;
+    var label = btn.textContent;
+    var body = fetch(btn.getAttribute("data-copy-markdown"), { method: "POST" }).then(function (res) {
+      return res.text().then(function (text) {
+        if (!res.ok) throw new Error(text);
+        return text;
+      });
+    });
+    var copied = typeof ClipboardItem !== "undefined"
+      ? navigator.clipboard.write([new ClipboardItem({
+          "text/plain": body.then(function (text) { return new Blob([text], { type: "text/plain" }); }),
+        })])
+      : body.then(function (text) { return navigator.clipboard.writeText(text); });
+    btn.disabled = true;
+    copied.then(function () {
+      btn.textContent = "Copied!";
+      var badge = document.getElementById("exported-badge");
+      if (badge) badge.hidden = false;
+    }, function (err) {
+      btn.textContent = "Copy failed";
+      btn.title = err.message;
+    }).finally(function () {
+      btn.disabled = false;
+      setTimeout(function () { btn.textContent = label; }, 2000);
+    });
+  });
+})();
+
+// Keyboard shortcuts. Cmd/Ctrl+Enter submits the form being typed in; outside
+// text fields, j/k move between [data-nav-item] rows and a key clicks the
+// element declaring it in aria-keyshortcuts (e.g. "p" to publish).
+(function () {
+  function isTyping(el) {
+    return el.isContentEditable || el.matches("input, textarea, select");
+  }
+
+  function visible(el) {
+    return !el.disabled && el.getClientRects().length > 0;
+  }
+
+  function submit(el) {
+    var questions = el.closest("#question-form");
+    var btn = questions
+      ? questions.querySelector('[gotk-click="answer-question"]')
+      : el.closest("form") && el.closest("form").querySelector('button[type="submit"], button:not([type])');
+    if (btn && !btn.disabled) btn.click();
+  }
+
+  function moveFocus(step) {
+    var items = Array.prototype.filter.call(document.querySelectorAll("[data-nav-item]"), visible);
+    if (!items.length) return;
+    var i = items.indexOf(document.activeElement);
+    i = i === -1 ? (step > 0 ? 0 : items.length - 1) : Math.min(Math.max(i + step, 0), items.length - 1);
+    items[i].focus();
+    items[i].scrollIntoView({ block: "nearest" });
+  }
+
+  document.addEventListener("keydown", function (e) {
+    if (e.key === "Enter" && (e.metaKey || e.ctrlKey)) {
+      // The message textarea already sends on Enter.
+      if (e.target.id === "message-input" || !isTyping(e.target)) return;
+      e.preventDefault();
+      submit(e.target);
+      return;
+    }
+    if (e.metaKey || e.ctrlKey || e.altKey || e.isComposing || isTyping(e.target)) return;
+
+    if (e.key === "j" || e.key === "k") {
+      e.preventDefault();
+      moveFocus(e.key === "j" ? 1 : -1);
+      return;
+    }
+    // The last match wins, so an open preview's Publish beats Preview.
+    var targets = Array.prototype.filter.call(document.querySelectorAll("[aria-keyshortcuts]"), function (el) {
+      return el.getAttribute("aria-keyshortcuts") === e.key && visible(el);
+    });
+    if (targets.length) {
+      e.preventDefault();
+      targets[targets.length - 1].click();
+    }
+  });
+})();
+
 // Register gotk exec functions for use by server commands
 document.addEventListener("DOMContentLoaded", function () {
   if (window.gotk) {
@@ -185,21 +467,102 @@ document.addEventListener("DOMContentLoaded", function () {
       }
     });
 
-    gotk.register("renderMarkdown", function () {
-      // renderMarkdown is defined inside an IIFE, expose it via a closure
-      var bubbles = document.querySelectorAll(
-        ".message-assistant .message-bubble:not([data-md-rendered]), .revision-content:not([data-md-rendered])"
-      );
-      bubbles.forEach(function (el) {
-        if (typeof DOMPurify !== "undefined" && typeof marked !== "undefined") {
-          el.innerHTML = DOMPurify.sanitize(marked.parse(el.textContent));
-          el.setAttribute("data-md-rendered", "");
-        }
-      });
-    });
+    gotk.register("focusQuestions", focusQuestions);
 
     gotk.register("updateElapsedTimers", function () {
       if (typeof updateElapsedTimers === "function") updateElapsedTimers();
     });
+
+    gotk.register("notifyResponse", notifyResponse);
+    gotk.register("setAlertPreferences", function (args) {
+      alertPreferences = args;
+    });
+  }
+});
+
+// Browser notifications when the AI finishes responding. Every open page
+// receives the event; the tag collapses the copies from several tabs into one
+// notification. Nothing is shown for the conversation being looked at.
+function notifyResponse(args) {
+  if (document.hidden) backgroundAlert(args);
+  if (!("Notification" in window) || Notification.permission !== "granted") return;
+  if (!document.hidden && location.pathname === args.url) return;
+  var n = new Notification(args.error ? "The AI hit an error: " + args.title : args.title, {
+    body: args.body,
+    tag: "prompt-request-" + args.id,
+  });
+  n.onclick = function () {
+    window.focus();
+    if (location.pathname !== args.url) location.href = args.url;
+    n.close();
+  };
+}
+
+// Sound and tab-title alerts for a page in the background, as chosen in
+// Settings; the server sends the preferences when the page connects.
+var alertPreferences = {};
+var unseenResponses = 0;
+var pageTitle = document.title;
+
+function backgroundAlert(args) {
+  if (alertPreferences.tabTitle) {
+    unseenResponses++;
+    document.title = "(" + unseenResponses + ") " + pageTitle;
+  }
+  // Every open tab hears about the response; only the first to claim it beeps.
+  if (alertPreferences.sound && localStorage.getItem("prompter-alert") !== args.key) {
+    localStorage.setItem("prompter-alert", args.key);
+    playAlertSound(args.error);
+  }
+}
+
+// playAlertSound plays a soft two-note chime, descending for errors.
+function playAlertSound(error) {
+  var AudioContext = window.AudioContext || window.webkitAudioContext;
+  if (!AudioContext) return;
+  var ctx = new AudioContext();
+  var notes = error ? [660, 440] : [660, 880];
+  notes.forEach(function (freq, i) {
+    var osc = ctx.createOscillator();
+    var gain = ctx.createGain();
+    var start = ctx.currentTime + i * 0.15;
+    osc.frequency.value = freq;
+    gain.gain.setValueAtTime(0.08, start);
+    gain.gain.exponentialRampToValueAtTime(0.001, start + 0.3);
+    osc.connect(gain).connect(ctx.destination);
+    osc.start(start);
+    osc.stop(start + 0.3);
+  });
+  setTimeout(function () { ctx.close(); }, 1000);
+}
+
+document.addEventListener("visibilitychange", function () {
+  if (document.hidden) {
+    pageTitle = document.title;
+  } else if (unseenResponses) {
+    unseenResponses = 0;
+    document.title = pageTitle;
   }
 });
+
+document.addEventListener("DOMContentLoaded", function () {
+  var btn = document.getElementById("notifications-enable");
+  if (!btn || !("Notification" in window) || Notification.permission !== "default") return;
+  btn.hidden = false;
+  btn.addEventListener("click", function () {
+    Notification.requestPermission().then(function () {
+      btn.hidden = true;
+    });
+  });
+});
+
+// Register the service worker that makes Prompter installable. Browsers only
+// allow it on https:// and localhost, so it's skipped when the server is
+// reached over plain http from another device.
+if ("serviceWorker" in navigator && window.isSecureContext) {
+  window.addEventListener("load", function () {
+    navigator.serviceWorker.register("/sw.js").catch(function (err) {
+      console.warn("Registering the service worker failed:", err);
+    });
+  });
+}
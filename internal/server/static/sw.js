@@ -0,0 +1,64 @@
+// Service worker that lets Prompter be installed to the home screen. Pages
+// and API calls always go to the server; versioned static assets are cached
+// so the app shell loads fast, and a short notice replaces pages while the
+// server can't be reached.
+var CACHE = "prompter-static";
+
+var offlinePage =
+  '<!DOCTYPE html><html lang="en"><head><meta charset="UTF-8">' +
+  '<meta name="viewport" content="width=device-width, initial-scale=1.0">' +
+  "<title>Prompter is offline</title></head>" +
+  '<body style="font-family: system-ui, sans-serif; padding: 2rem; color: #4c4f69; background: #eff1f5;">' +
+  "<h1>Can't reach Prompter</h1>" +
+  "<p>The computer running Prompter is asleep, offline or not on this network. Try again once it's back.</p>" +
+  '<p><a href="">Retry</a></p></body></html>';
+
+self.addEventListener("install", function () {
+  self.skipWaiting();
+});
+
+self.addEventListener("activate", function (e) {
+  e.waitUntil(self.clients.claim());
+});
+
+self.addEventListener("fetch", function (e) {
+  var url = new URL(e.request.url);
+  if (e.request.method !== "GET" || url.origin !== location.origin) return;
+
+  if (e.request.mode === "navigate") {
+    e.respondWith(
+      fetch(e.request).catch(function () {
+        return new Response(offlinePage, { headers: { "Content-Type": "text/html; charset=utf-8" } });
+      })
+    );
+    return;
+  }
+
+  // ?v= carries the content hash, so a cached copy never goes stale.
+  if (url.pathname.indexOf("/static/") === 0 && url.searchParams.has("v")) {
+    e.respondWith(
+      caches.open(CACHE).then(function (cache) {
+        return cache.match(e.request).then(function (hit) {
+          return hit || fetch(e.request).then(function (resp) {
+            if (resp.ok) cacheLatest(cache, e.request, resp.clone());
+            return resp;
+          });
+        });
+      })
+    );
+  }
+});
+
+// cacheLatest stores an asset, dropping the copies of its older versions.
+function cacheLatest(cache, request, resp) {
+  var path = new URL(request.url).pathname;
+  return cache.keys().then(function (keys) {
+    return Promise.all(keys.filter(function (k) {
+      return new URL(k.url).pathname === path;
+    }).map(function (k) {
+      return cache.delete(k);
+    }));
+  }).then(function () {
+    return cache.put(request, resp);
+  });
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/esnunes/prompter/internal/models"
+)
+
+// statsMonths is how many months the publishes-over-time chart covers.
+const statsMonths = 12
+
+type statsData struct {
+	basePageData
+	Stats        *models.Stats
+	AvgDuration  string
+	PublishChart []publishBar
+}
+
+// publishBar is one month in the publishes-over-time chart.
+type publishBar struct {
+	Label   string
+	Count   int
+	Percent int // bar height relative to the busiest month
+}
+
+// handleStatsPage shows aggregate statistics about prompt requests.
+func (s *Server) handleStatsPage(w http.ResponseWriter, r *http.Request) {
+	st, err := s.queries.GetStats(r.Context(), statsMonths)
+	if err != nil {
+		log.Printf("computing stats: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	maxCount := 0
+	for _, pc := range st.PublishesByMonth {
+		maxCount = max(maxCount, pc.Count)
+	}
+	var chart []publishBar
+	for _, pc := range st.PublishesByMonth {
+		bar := publishBar{Label: pc.Period, Count: pc.Count}
+		if t, err := time.Parse("2006-01", pc.Period); err == nil {
+			bar.Label = t.Format("Jan 2006")
+		}
+		if maxCount > 0 {
+			bar.Percent = pc.Count * 100 / maxCount
+		}
+		chart = append(chart, bar)
+	}
+
+	s.renderPage(w, "stats.html", statsData{
+		basePageData: basePageData{Sidebar: s.buildAllSidebar(r.Context(), sidebarPageSize)},
+		Stats:        st,
+		AvgDuration:  formatDuration(st.AvgConversationDuration),
+		PublishChart: chart,
+	})
+}
+
+// formatDuration renders a duration with its two most significant units, e.g. "2h 15m".
+func formatDuration(d time.Duration) string {
+	switch {
+	case d <= 0:
+		return "—"
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm %ds", int(d.Minutes()), int(d.Seconds())%60)
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh %dm", int(d.Hours()), int(d.Minutes())%60)
+	default:
+		return fmt.Sprintf("%dd %dh", int(d.Hours())/24, int(d.Hours())%24)
+	}
+}
@@ -0,0 +1,135 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/esnunes/prompter/internal/models"
+)
+
+// maxTagLength bounds a tag name after normalization.
+const maxTagLength = 32
+
+type tagsFragmentData struct {
+	Org   string
+	Repo  string
+	ID    int64
+	Tags  []string
+	Known []string // existing tag names offered as suggestions
+	Error string
+}
+
+// normalizeTag lowercases a tag and replaces whitespace with dashes. It
+// returns "" when the result is empty, too long, or has unsupported characters.
+func normalizeTag(raw string) string {
+	tag := strings.Join(strings.Fields(strings.ToLower(raw)), "-")
+	if tag == "" || len(tag) > maxTagLength {
+		return ""
+	}
+	for _, r := range tag {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune("-_:.", r) {
+			return ""
+		}
+	}
+	return tag
+}
+
+func (s *Server) newTagsFragmentData(ctx context.Context, org, repoName string, prID int64) (tagsFragmentData, error) {
+	tags, err := s.queries.ListTagsForPromptRequest(ctx, prID)
+	if err != nil {
+		return tagsFragmentData{}, err
+	}
+	data := tagsFragmentData{Org: org, Repo: repoName, ID: prID, Tags: tags}
+
+	counts, err := s.queries.ListTagCounts(ctx)
+	if err != nil {
+		return tagsFragmentData{}, err
+	}
+	for _, tc := range counts {
+		data.Known = append(data.Known, tc.Name)
+	}
+	return data, nil
+}
+
+// attachTags fills in the Tags field of each prompt request.
+func (s *Server) attachTags(ctx context.Context, prs []models.PromptRequest) {
+	if len(prs) == 0 {
+		return
+	}
+	tags, err := s.queries.ListTagsByPromptRequest(ctx)
+	if err != nil {
+		log.Printf("listing tags: %v", err)
+		return
+	}
+	for i := range prs {
+		prs[i].Tags = tags[prs[i].ID]
+	}
+}
+
+func (s *Server) handleAddTag(w http.ResponseWriter, r *http.Request) {
+	s.updateTags(w, r, func(id int64) (string, error) {
+		tag := normalizeTag(r.FormValue("tag"))
+		if tag == "" {
+			return fmt.Sprintf("Tags must be 1 to %d letters, digits, or - _ : . characters.", maxTagLength), nil
+		}
+		return "", s.queries.AddTag(r.Context(), id, tag)
+	})
+}
+
+func (s *Server) handleRemoveTag(w http.ResponseWriter, r *http.Request) {
+	s.updateTags(w, r, func(id int64) (string, error) {
+		return "", s.queries.RemoveTag(r.Context(), id, r.PathValue("tag"))
+	})
+}
+
+// updateTags applies a tag change to the prompt request in the path and
+// responds with the refreshed tags fragment. apply returns a user-facing
+// message for invalid input, or an error for failures.
+func (s *Server) updateTags(w http.ResponseWriter, r *http.Request, apply func(id int64) (string, error)) {
+	org := r.PathValue("org")
+	repoName := r.PathValue("repo")
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	if _, err := s.queries.GetPromptRequest(r.Context(), id); err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+
+	invalid, err := apply(id)
+	if err != nil {
+		log.Printf("updating tags for prompt request %d: %v", id, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	if r.Header.Get("HX-Request") != "true" {
+		if invalid != "" {
+			http.Error(w, invalid, http.StatusBadRequest)
+			return
+		}
+		referer := r.Header.Get("Referer")
+		if referer == "" {
+			referer = fmt.Sprintf("/github.com/%s/%s/prompt-requests/%d", org, repoName, id)
+		}
+		http.Redirect(w, r, referer, http.StatusSeeOther)
+		return
+	}
+
+	data, err := s.newTagsFragmentData(r.Context(), org, repoName, id)
+	if err != nil {
+		log.Printf("listing tags for prompt request %d: %v", id, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	data.Error = invalid
+	s.renderFragment(w, "tags_fragment.html", data)
+}
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"fmt"
+	"time"
+)
+
+// timeAgo describes t relative to now ("5 minutes ago", "yesterday"),
+// falling back to the date for anything older than a month. Pages redo this
+// in the viewer's time zone (see localizeTimes in app.js); keep them in step.
+func timeAgo(t time.Time) string {
+	d := time.Since(t)
+	plural := func(n int, unit string) string {
+		if n == 1 {
+			return fmt.Sprintf("1 %s ago", unit)
+		}
+		return fmt.Sprintf("%d %ss ago", n, unit)
+	}
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return plural(int(d/time.Minute), "minute")
+	case d < 24*time.Hour:
+		return plural(int(d/time.Hour), "hour")
+	case d < 48*time.Hour:
+		return "yesterday"
+	case d < 30*24*time.Hour:
+		return plural(int(d/(24*time.Hour)), "day")
+	}
+	return t.Format("Jan 2, 2006")
+}
+
+// isoTime is t as a <time datetime> attribute value.
+func isoTime(t time.Time) string {
+	return t.Format(time.RFC3339)
+}
+
+// fullTime is the absolute time shown on hover, in UTC until the page
+// converts it to the viewer's time zone.
+func fullTime(t time.Time) string {
+	return t.UTC().Format("Jan 2, 2006 3:04 PM UTC")
+}
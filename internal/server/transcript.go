@@ -0,0 +1,65 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/esnunes/prompter/internal/models"
+)
+
+// sanitizeTranscriptText makes conversation text inert inside the issue:
+// HTML can't close the surrounding <details>, and @mentions don't notify anyone.
+func sanitizeTranscriptText(text string) string {
+	text = html.EscapeString(strings.TrimSpace(text))
+	return strings.ReplaceAll(text, "@", "&#64;")
+}
+
+// issueTranscript renders the conversation up to lastMessageID as a collapsed
+// section for the issue body, including the questions the AI asked.
+func (s *Server) issueTranscript(msgs []models.Message, lastMessageID int64) string {
+	var b strings.Builder
+	b.WriteString("\n\n<details>\n<summary>Conversation transcript</summary>\n\n")
+	for _, m := range msgs {
+		if m.ID > lastMessageID {
+			break
+		}
+		role := "User"
+		if m.Role == "assistant" {
+			role = "Assistant"
+		}
+		fmt.Fprintf(&b, "**%s:** %s\n\n", role, sanitizeTranscriptText(m.Content))
+		if m.Role == "assistant" {
+			questions, _ := s.pendingQuestions(context.Background(), &m)
+			for _, q := range questions {
+				fmt.Fprintf(&b, "> %s\n\n", strings.ReplaceAll(sanitizeTranscriptText(q.Text), "\n", "\n> "))
+			}
+		}
+	}
+	b.WriteString("</details>")
+	return b.String()
+}
+
+// handleIncludeTranscript toggles the transcript section and re-renders the
+// publish preview with it.
+func (s *Server) handleIncludeTranscript(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	if _, err := s.queries.GetPromptRequest(r.Context(), id); err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	if err := s.queries.SetPromptRequestIncludeTranscript(r.Context(), id, r.FormValue("include_transcript") == "1"); err != nil {
+		log.Printf("updating transcript option for prompt request %d: %v", id, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	s.handlePublishPreview(w, r)
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/esnunes/prompter/internal/models"
+)
+
+type trashData struct {
+	basePageData
+	PromptRequests []models.PromptRequest
+}
+
+// handleTrashPage lists soft-deleted prompt requests.
+func (s *Server) handleTrashPage(w http.ResponseWriter, r *http.Request) {
+	prs, err := s.queries.ListDeletedPromptRequests(r.Context())
+	if err != nil {
+		log.Printf("listing deleted prompt requests: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	s.renderPage(w, "trash.html", trashData{
+		basePageData:   basePageData{Sidebar: s.buildAllSidebar(r.Context(), sidebarPageSize)},
+		PromptRequests: prs,
+	})
+}
+
+// handleTrashRestore moves a prompt request out of the trash.
+func (s *Server) handleTrashRestore(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	if err := s.queries.RestorePromptRequest(r.Context(), id); err != nil {
+		log.Printf("restoring prompt request %d: %v", id, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	s.audit(id, "restored", "from the trash", 0)
+	http.Redirect(w, r, "/trash", http.StatusSeeOther)
+}
+
+// handleTrashPurge permanently deletes a prompt request in the trash.
+func (s *Server) handleTrashPurge(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	// A running clone or Claude call would write to rows we're about to remove.
+	switch s.getRepoStatus(r.Context(), id).Status {
+	case stateCloning, statePulling, stateProcessing:
+		http.Error(w, "This prompt request is still being processed. Try again once it finishes.", http.StatusConflict)
+		return
+	}
+	pr, err := s.queries.GetPromptRequest(r.Context(), id)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	if err := s.queries.PurgePromptRequest(r.Context(), id); err != nil {
+		log.Printf("purging prompt request %d: %v", id, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	s.auditPR(pr, "purged", "", 0)
+	removeAttachments(id)
+	http.Redirect(w, r, "/trash", http.StatusSeeOther)
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/esnunes/prompter/internal/github"
+)
+
+// handleUnpublish retracts a published prompt request: it closes its GitHub
+// issue, with an optional comment, and turns it back into a draft. Revisions
+// are kept, and publishing again opens a new issue.
+func (s *Server) handleUnpublish(w http.ResponseWriter, r *http.Request) {
+	org := r.PathValue("org")
+	repoName := r.PathValue("repo")
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	pr, err := s.queries.GetPromptRequest(r.Context(), id)
+	if err != nil {
+		http.Error(w, "Not Found", http.StatusNotFound)
+		return
+	}
+	if pr.IssueNumber == nil {
+		http.Error(w, "This prompt request isn't published.", http.StatusConflict)
+		return
+	}
+	// An imported issue belongs to someone else; it is only unlinked.
+	imported := pr.SourceIssueNumber != nil && *pr.SourceIssueNumber == *pr.IssueNumber
+
+	comment := strings.TrimSpace(r.FormValue("comment"))
+	if imported {
+		if comment != "" {
+			if err := github.CommentOnIssue(r.Context(), pr.RepoURL, *pr.IssueNumber, comment); err != nil {
+				log.Printf("commenting on issue #%d: %v", *pr.IssueNumber, err)
+				http.Error(w, "Couldn't comment on the GitHub issue. Check your connection and try again.", http.StatusBadRequest)
+				return
+			}
+		}
+	} else if err := github.CloseIssue(r.Context(), pr.RepoURL, *pr.IssueNumber, comment); err != nil {
+		log.Printf("closing issue #%d: %v", *pr.IssueNumber, err)
+		http.Error(w, "Couldn't close the GitHub issue. Check your connection and try again.", http.StatusBadRequest)
+		return
+	}
+
+	if err := s.queries.UnpublishPromptRequest(r.Context(), id); err != nil {
+		log.Printf("unpublishing prompt request %d: %v", id, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if imported {
+		s.auditPR(pr, "unpublished", fmt.Sprintf("unlinked issue #%d", *pr.IssueNumber), 0)
+	} else {
+		s.auditPR(pr, "unpublished", fmt.Sprintf("closed issue #%d", *pr.IssueNumber), 0)
+	}
+
+	redirectURL := fmt.Sprintf("/github.com/%s/%s/prompt-requests/%d", org, repoName, id)
+	if r.Header.Get("HX-Request") == "true" {
+		w.Header().Set("HX-Redirect", redirectURL)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+}
@@ -0,0 +1,48 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/esnunes/prompter/internal/models"
+)
+
+// turnUsage captions an assistant reply with how long Claude took and what
+// it cost, e.g. "took 1m 42s · $0.12"; "" when neither was recorded.
+func turnUsage(m *models.Message) string {
+	if m == nil || m.Role != "assistant" {
+		return ""
+	}
+	return usageCaption("took ", m.Duration, m.CostUSD)
+}
+
+// usageCaption joins a duration and a cost, leaving out whichever is zero.
+func usageCaption(prefix string, d time.Duration, costUSD float64) string {
+	var parts []string
+	if d > 0 {
+		parts = append(parts, prefix+formatDuration(d))
+	}
+	if costUSD > 0 {
+		parts = append(parts, formatCost(costUSD))
+	}
+	return strings.Join(parts, " · ")
+}
+
+func formatCost(usd float64) string {
+	if usd < 0.01 {
+		return "<$0.01"
+	}
+	return fmt.Sprintf("$%.2f", usd)
+}
+
+// conversationUsage is the header's running total of Claude's time and cost
+// for a prompt request.
+func (s *Server) conversationUsage(ctx context.Context, prID int64) string {
+	d, cost, err := s.queries.PromptRequestUsage(ctx, prID)
+	if err != nil {
+		return ""
+	}
+	return usageCaption("AI time ", d, cost)
+}